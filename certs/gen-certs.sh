@@ -0,0 +1,29 @@
+#!/bin/bash
+# Genera la CA e i certificati delle repliche per TLS. Il CommonName di ogni certificato è l'ID della replica,
+# che i server confrontano con la tabella dei peer per autenticare le altre repliche.
+#
+# Uso: ./certs/gen-certs.sh [server_id...]   (default: server0 server1 server2)
+
+set -e
+
+DIR=$(dirname "$0")
+SERVER_IDS=("$@")
+if [ ${#SERVER_IDS[@]} -eq 0 ]; then
+  SERVER_IDS=(server0 server1 server2)
+fi
+
+if [ ! -f "$DIR/ca.pem" ]; then
+  openssl req -x509 -newkey rsa:2048 -nodes -days 365 -subj "/CN=SDCC CA" \
+    -keyout "$DIR/ca-key.pem" -out "$DIR/ca.pem"
+fi
+
+for id in "${SERVER_IDS[@]}"; do
+  openssl req -newkey rsa:2048 -nodes -subj "/CN=$id" \
+    -keyout "$DIR/$id-key.pem" -out "$DIR/$id.csr"
+  # I certificati valgono sia come server che come client (mTLS tra repliche)
+  openssl x509 -req -in "$DIR/$id.csr" -CA "$DIR/ca.pem" -CAkey "$DIR/ca-key.pem" -CAcreateserial -days 365 \
+    -out "$DIR/$id.pem" \
+    -extfile <(printf "subjectAltName=DNS:%s,DNS:localhost,IP:127.0.0.1\nextendedKeyUsage=serverAuth,clientAuth" "$id")
+  rm "$DIR/$id.csr"
+done
+rm -f "$DIR/ca.srl"
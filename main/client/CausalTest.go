@@ -3,13 +3,13 @@ package main
 import (
 	"SDCC/main/utils"
 	"fmt"
-	"os"
 	"sync"
 	"time"
 )
 
 func basicCasualTest() {
-	fmt.Println("In questo test causale, le seguenti operazioni vengono inviate in parallelo ai server:\n")
+	fmt.Println("In questo test causale, le seguenti operazioni vengono inviate in parallelo ai server:")
+	fmt.Println()
 
 	fmt.Println(" +-----------+-----------+-----------+")
 	fmt.Println(" | Operazione|     1     |     2     |")
@@ -53,14 +53,15 @@ func basicCasualTest() {
 
 	fmt.Println("All operations have completed.")
 
-	if os.Getenv("DOCKER") == "1" {
-		time.Sleep(1 * time.Hour) //Rimane attivo per permettere di accedere al log
+	if utils.Conf.Client.KeepAlive > 0 {
+		time.Sleep(utils.Conf.Client.KeepAlive) //Rimane attivo per permettere di accedere al log
 
 	}
 }
 
 func advancedCasualTest() {
-	fmt.Println("In questo test causale, le seguenti operazioni vengono inviate in parallelo ai server:\n")
+	fmt.Println("In questo test causale, le seguenti operazioni vengono inviate in parallelo ai server:")
+	fmt.Println()
 
 	fmt.Println(" +-----------+-----------+-----------+-----------+-----------+")
 	fmt.Println(" | Operazione|     1     |     2     |     3     |     4     |")
@@ -110,8 +111,8 @@ func advancedCasualTest() {
 
 	fmt.Println("All operations have completed.")
 
-	if os.Getenv("DOCKER") == "1" {
-		time.Sleep(1 * time.Hour) //Rimane attivo per permettere di accedere al log
+	if utils.Conf.Client.KeepAlive > 0 {
+		time.Sleep(utils.Conf.Client.KeepAlive) //Rimane attivo per permettere di accedere al log
 
 	}
 
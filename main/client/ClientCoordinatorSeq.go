@@ -3,9 +3,11 @@ package main
 import (
 	"SDCC/main/utils"
 	"bufio"
+	"flag"
 	"fmt"
-	"net/rpc"
+	"math/rand"
 	"os"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -18,9 +20,28 @@ type Operation struct {
 	Value         string
 }
 
-var consistType = strings.ToLower(os.Getenv("CONSIST_TYPE"))
+var consistType string
 
 func main() {
+	// Lettura della configurazione: file YAML + flag da riga di comando
+	err := utils.ParseConfig(flag.CommandLine, os.Args[1:])
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	if err := utils.LoadTLS(""); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	consistType = strings.ToLower(utils.Conf.Consistency)
+
+	if utils.Conf.Client.RandomReplica {
+		fmt.Print("\033[36mUsing a random server for every client\n\033[0m")
+	}
+	if utils.Conf.Client.NamespaceConsistency != "" {
+		createNamespace()
+	}
+
 	reader := bufio.NewReader(os.Stdin) // Crea un lettore per leggere l'input dell'utente
 	for {
 		// Mostra il prompt all'utente
@@ -32,20 +53,18 @@ func main() {
 			fmt.Println("[3] Test causale base")
 			fmt.Println("[4] Test causale avanzato")
 
-		} else {
-			fmt.Println("You have an error in you environment configuration: value of 'CONSIST_TYPE' env variable not set or invalid")
-			os.Exit(1)
 		}
 		fmt.Println("[5] Esci")
 		printMenuExplanation()
 
-		// Legge l'input dell'utente
-		fmt.Print("Enter your choice: ")
-		input, err := reader.ReadString('\n')
-		if err != nil {
-			if os.Getenv("DOCKER") == "1" {
-				input = os.Getenv("DOCKER_OP")
-			} else {
+		// Legge l'input dell'utente, a meno che il test da eseguire non sia già indicato nella configurazione
+		var input string
+		if utils.Conf.Client.Operation != 0 {
+			input = strconv.Itoa(utils.Conf.Client.Operation)
+		} else {
+			fmt.Print("Enter your choice: ")
+			input, err = reader.ReadString('\n')
+			if err != nil {
 				fmt.Println("Error reading input, please try again.")
 				continue
 			}
@@ -84,14 +103,14 @@ func main() {
 }
 
 func checkInput(input string) {
-	consist := os.Getenv("CONSIST_TYPE")
+	consist := utils.Conf.Consistency
 
-	if consist == "Sequential" {
+	if consist == utils.Sequential {
 		if input != "1" && input != "2" {
 			fmt.Println("ERRORE: Tipo di test incompatibile con il tipo di consistenza scelto")
 			os.Exit(1)
 		}
-	} else if consist == "Causal" {
+	} else if consist == utils.Causal {
 		if input != "3" && input != "4" {
 			fmt.Println("ERRORE: Tipo di test incompatibile con il tipo di consistenza scelto")
 			os.Exit(1)
@@ -105,16 +124,16 @@ func printMenuExplanation() {
 	yellow := "\033[33m"
 	reset := "\033[0m"
 
-	// Ottieni il tipo di consistenza dall'ambiente
-	consistType := os.Getenv("CONSIST_TYPE")
+	// Ottieni il tipo di consistenza dalla configurazione
+	consistType := utils.Conf.Consistency
 
-	if consistType == "Sequential" {
+	if consistType == utils.Sequential {
 		fmt.Printf(yellow + "\n╔════════════════════════════════════════════════════════════════════════════════════════════╗\n")
 		fmt.Printf("║ N.B.: Avendo selezionato la consistenza 'Sequential', sono visibili solo i test [1] e [2]. ║\n")
 		fmt.Printf("║ Per vedere gli altri, assicurati di cambiare il tipo di consistenza scelta!                ║\n")
 		fmt.Printf("╚════════════════════════════════════════════════════════════════════════════════════════════╝\n" + reset)
 
-	} else if consistType == "Causal" {
+	} else if consistType == utils.Causal {
 		fmt.Printf(yellow + "\n╔════════════════════════════════════════════════════════════════════════════════════════════╗\n")
 		fmt.Printf("║ N.B.: Avendo selezionato la consistenza 'Causal', sono visibili solo i test [3] e [4].     ║\n")
 		fmt.Printf("║ Per vedere gli altri, assicurati di cambiare il tipo di consistenza scelta!                ║\n")
@@ -126,90 +145,214 @@ func printMenuExplanation() {
 func addEndOps(replicas int) []Operation {
 	ops := make([]Operation, replicas)
 	for i := 0; i < replicas; i++ {
-		ops[i] = Operation{ClientIndex: i, OperationType: utils.Put, Key: utils.EndKey, Value: utils.EndValue}
+		ops[i] = Operation{ClientIndex: i, OperationType: utils.End}
 	}
 
 	return ops
 }
 
+// replicaConns tiene le connessioni di un client verso le repliche e, per ognuna, il numero dell'ultima richiesta
+// inviata: ogni replica controlla l'ordine FIFO delle richieste del client che riceve lei, quindi quando il client
+// cambia replica la numerazione prosegue da quella della nuova replica (da 1 se non l'ha mai contattata)
+type replicaConns struct {
+	index    int
+	conns    map[int]utils.Conn
+	requests map[int]int
+}
+
+func newReplicaConns(index int) *replicaConns {
+	return &replicaConns{index: index, conns: make(map[int]utils.Conn), requests: make(map[int]int)}
+}
+
+// next ritorna la connessione verso la replica replica, aprendola se necessario, e il numero della prossima
+// richiesta da inviarle
+func (c *replicaConns) next(replica int) (utils.Conn, int) {
+	conn, ok := c.conns[replica]
+	if !ok {
+		addr := utils.DialAddress(replica)
+		fmt.Printf("[CLIENT %d] Connecting to server %s (%s)\n", c.index, utils.Peers.ID(replica), addr)
+
+		var err error
+		conn, err = utils.DialReplica(replica)
+		if err != nil {
+			fmt.Printf("[CLIENT %d] Failed to connect to server %s: %v\n", c.index, utils.Peers.ID(replica), err)
+			os.Exit(1)
+		}
+		c.conns[replica] = conn
+	}
+	c.requests[replica]++
+	return conn, c.requests[replica]
+}
+
+func (c *replicaConns) close() {
+	for replica, conn := range c.conns {
+		if err := conn.Close(); err != nil {
+			fmt.Printf("[CLIENT %d] Failed to close connection to server %s: %v\n", c.index, utils.Peers.ID(replica), err)
+		}
+	}
+}
+
+// chooseReplica ritorna la replica a cui il client index invia la prossima richiesta
+func chooseReplica(index int) int {
+	if utils.Conf.Client.RandomReplica {
+		return rand.Intn(utils.NumberOfReplicas)
+	}
+	return index
+}
+
 func executeOperations(index int, operations []Operation) {
-	var serverName string
-	var serverPort string
-	var chosenServer int
+	conns := newReplicaConns(index)
+	defer conns.close()
 
-	if os.Getenv("RANDOM_REPLICA") == "1" {
-		r := utils.GetRandomReplica()
-		serverName = utils.GetServerName(r)
-		serverPort = utils.GetServerPort(r)
-		chosenServer = r
+	if consistType == "causal" {
+		executeSession(index, operations, conns)
 	} else {
-		chosenServer = index
-		serverName = utils.GetServerName(chosenServer)
-		serverPort = utils.GetServerPort(chosenServer)
+		executePipelined(index, operations, conns)
 	}
+	fmt.Printf("[CLIENT %d] Done\n", index)
+}
 
-	addr := serverName + serverPort
-	fmt.Printf("[CLIENT %d] Connecting to server %s\n", index, addr)
+// executePipelined invia le operazioni del client index tutte insieme alla stessa replica, che le esegue nell'ordine
+// delle richieste. Con la consistenza sequenziale un messaggio viene consegnato solo dopo che ogni replica ne ha
+// inviato uno con clock maggiore, quindi il client non può attendere la risposta prima di inviare la richiesta
+// successiva: le ultime vengono sbloccate dalle End.
+func executePipelined(index int, operations []Operation, conns *replicaConns) {
+	var chosenServer int
 
-	conn, err := rpc.Dial("tcp", addr)
-	if err != nil {
-		fmt.Printf("[CLIENT %d] Failed to connect to server %d: %v\n", index, chosenServer, err)
-		os.Exit(1)
+	if utils.Conf.Client.RandomReplica {
+		chosenServer = utils.GetRandomReplica()
+	} else {
+		chosenServer = index
 	}
-	defer func(conn *rpc.Client) {
-		err := conn.Close()
-		if err != nil {
-			fmt.Printf("[CLIENT %d] Failed to close connection to server %d: %v\n", index, chosenServer, err)
-		}
-	}(conn)
 
 	// Crea un WaitGroup per sincronizzare tutte le goroutine
 	var wg sync.WaitGroup
-	wg.Add(len(operations) / utils.NumberOfReplicas) // Incrementa il contatore per ogni chiamata
-
-	// Contatore per la numerazione delle richieste
-	var requestNumber int
 
 	for _, op := range operations {
 		if op.ClientIndex != index {
 			continue
 		}
 
-		requestNumber++
-		args := utils.NewArg(op.Key, op.Value, requestNumber, index)
+		conn, requestNumber := conns.next(chosenServer)
+		args := newArgs(op, requestNumber, index)
 		resp := utils.NewResponse()
 
 		// Esegui la chiamata in una goroutine
+		wg.Add(1)
 		go func(opType string, args *utils.Args, resp *utils.Response) {
 			defer wg.Done() // Decrementa il contatore al termine della chiamata
-			var err error
-
-			switch opType {
-			case utils.Put:
-				if op.Key == utils.EndKey && op.Value == utils.EndValue {
-					time.Sleep(5 * time.Second) //Sono op. speciali che servono solo a sbloccare l'ultima exec. Devono necessariamente essere le ultime
-				}
-				err = conn.Call(consistType+".Put", args, resp)
-			case utils.Get:
-				err = conn.Call(consistType+".Get", args, resp)
-			case utils.Delete:
-				err = conn.Call(consistType+".Delete", args, resp)
+			if opType == utils.End {
+				time.Sleep(5 * time.Second) //Sono op. speciali che servono solo a sbloccare l'ultima exec. Devono necessariamente essere le ultime
 			}
+			err := call(conn, opType, args, resp)
+			printResponse(index, opType, args, resp, err)
+		}(op.OperationType, args, resp)
 
-			if err != nil {
-				fmt.Printf("[CLIENT %d] Error in call to %s: %v\n", index, opType, err)
-				return
-			}
+	}
+
+	wg.Wait() // Aspetta che tutte le goroutine abbiano finito
+}
+
+// executeSession esegue le operazioni del client index una alla volta, nell'ordine delle richieste: ogni richiesta
+// porta il clock di sessione aggiornato con le risposte delle precedenti, quindi la replica che la riceve, anche
+// diversa dalla precedente, ha già consegnato tutte le scritture che il client ha eseguito o letto. Se la replica
+// non raggiunge il clock di sessione la richiesta viene ripetuta verso un'altra.
+func executeSession(index int, operations []Operation, conns *replicaConns) {
+	// Clock di sessione del client: con la consistenza causale la replica serve ogni richiesta solo dopo aver visto
+	// le scritture già eseguite o lette dal client, anche se servite da un'altra replica
+	session := &utils.Session{}
+
+	for _, op := range operations {
+		if op.ClientIndex != index {
+			continue
+		}
 
-			if resp.IsPrintable {
-				fmt.Printf("[CLIENT %d] Answer from server: GET of Key '%s' Value = %s\n", index, resp.Key, resp.Value)
+		replica := chooseReplica(index)
+		for attempt := 1; ; attempt++ {
+			conn, requestNumber := conns.next(replica)
+			args := newArgs(op, requestNumber, index)
+			session.Apply(args)
+			resp := utils.NewResponse()
+
+			err := call(conn, op.OperationType, args, resp)
+			if utils.IsSessionBehind(err) && attempt < utils.NumberOfReplicas {
+				fmt.Printf("[CLIENT %d] Server %s has not yet seen the session, retrying on another server\n", index, utils.Peers.ID(replica))
+				replica = (replica + 1) % utils.NumberOfReplicas
+				continue
+			}
+			if err == nil {
+				session.Update(resp)
 			}
+			printResponse(index, op.OperationType, args, resp, err)
+			break
+		}
+	}
+}
 
-		}(op.OperationType, args, resp)
+// createNamespace crea il namespace dei test con la consistenza indicata in client.namespace_consistency, così i
+// test possono essere eseguiti anche su namespace con una consistenza diversa da quella del cluster. Un namespace
+// già esistente viene usato così com'è.
+func createNamespace() {
+	spec := utils.Namespace{Name: utils.Conf.Client.Namespace, Consistency: utils.Conf.Client.NamespaceConsistency}
+	conn, err := utils.DialReplica(0)
+	if err != nil {
+		fmt.Printf("Failed to connect to server %s: %v\n", utils.Peers.ID(0), err)
+		os.Exit(1)
+	}
+	defer conn.Close()
+
+	//La richiesta usa un'identità diversa da quelle dei client dei test, che numerano le proprie richieste da 1
+	args := utils.NewArg("", nil, 1, utils.NumberOfReplicas)
+	args.Token, args.Namespace, args.NamespaceSpec = utils.Conf.Client.Token, spec.Name, spec
+	err = conn.Call(consistType+".CreateNamespace", args, utils.NewResponse())
+	switch {
+	case err == nil:
+		fmt.Printf("\033[36mCreated namespace %s with consistency %s\n\033[0m", spec.Name, spec.Consistency)
+	case strings.Contains(err.Error(), utils.ErrNamespaceExists.Error()):
+		fmt.Printf("\033[36mNamespace %s already exists, using it\n\033[0m", spec.Name)
+	default:
+		fmt.Printf("Failed to create namespace %s: %v\n", spec.Name, err)
+		os.Exit(1)
+	}
+}
 
+// newArgs ritorna gli Args della richiesta requestNumber del client index per l'operazione op
+func newArgs(op Operation, requestNumber int, index int) *utils.Args {
+	args := utils.NewArg(op.Key, []byte(op.Value), requestNumber, index)
+	args.Token = utils.Conf.Client.Token
+	args.Namespace = utils.Conf.Client.Namespace
+	return args
+}
+
+// call invia la richiesta dell'operazione opType sulla connessione conn
+func call(conn utils.Conn, opType string, args *utils.Args, resp *utils.Response) error {
+	switch opType {
+	case utils.Put:
+		return conn.Call(consistType+".Put", args, resp)
+	case utils.Get:
+		return conn.Call(consistType+".Get", args, resp)
+	case utils.Delete:
+		return conn.Call(consistType+".Delete", args, resp)
+	case utils.End:
+		return conn.Call(consistType+".End", args, resp)
 	}
+	return nil
+}
 
-	wg.Wait() // Aspetta che tutte le goroutine abbiano finito
-	fmt.Printf("[CLIENT %d] Done\n", index)
+// printResponse stampa l'esito della richiesta del client index
+func printResponse(index int, opType string, args *utils.Args, resp *utils.Response, err error) {
+	if utils.IsAccessDenied(err) {
+		fmt.Printf("[CLIENT %d] %s of key '%s' denied: %v\n", index, opType, args.Key, err)
+		return
+	}
+	if err != nil {
+		fmt.Printf("[CLIENT %d] Error in call to %s: %v\n", index, opType, err)
+		return
+	}
 
+	if resp.IsPrintable && !resp.Found {
+		fmt.Printf("[CLIENT %d] Answer from server: GET of Key '%s' not found\n", index, resp.Key)
+	} else if resp.IsPrintable {
+		fmt.Printf("[CLIENT %d] Answer from server: GET of Key '%s' Value = %s\n", index, resp.Key, resp.Value)
+	}
 }
@@ -3,19 +3,13 @@ package main
 import (
 	"SDCC/main/utils"
 	"fmt"
-	"os"
 	"sync"
 	"time"
 )
 
-func init() {
-	if os.Getenv("RANDOM_REPLICA") == "1" {
-		fmt.Print("\033[36mUsing a random server for every client\n\033[0m")
-	}
-}
-
 func basicTestSeq() {
-	fmt.Println("In questo test sequenziale, le seguenti operazioni vengono inviate in parallelo ai server:\n")
+	fmt.Println("In questo test sequenziale, le seguenti operazioni vengono inviate in parallelo ai server:")
+	fmt.Println()
 
 	fmt.Println(" +-----------+-----------+-----------+-----------+-----------+")
 	fmt.Println(" | Operazione|     1     |     2     |     3     |     4     |")
@@ -66,14 +60,15 @@ func basicTestSeq() {
 
 	fmt.Println("All operations have completed.")
 
-	if os.Getenv("DOCKER") == "1" {
-		time.Sleep(1 * time.Hour) //Rimane attivo per permettere di accedere al log
+	if utils.Conf.Client.KeepAlive > 0 {
+		time.Sleep(utils.Conf.Client.KeepAlive) //Rimane attivo per permettere di accedere al log
 
 	}
 }
 
 func advancedTestSeq() {
-	fmt.Println("In questo test sequenziale, le seguenti operazioni vengono inviate in parallelo ai server:\n")
+	fmt.Println("In questo test sequenziale, le seguenti operazioni vengono inviate in parallelo ai server:")
+	fmt.Println()
 
 	fmt.Println(" +-----------+-----------+-----------+-----------+-----------+")
 	fmt.Println(" | Operazione|     1     |     2     |     3     |     4     |")
@@ -125,8 +120,8 @@ func advancedTestSeq() {
 
 	fmt.Println("All operations have completed.")
 
-	if os.Getenv("DOCKER") == "1" {
-		time.Sleep(1 * time.Hour) //Rimane attivo per permettere di accedere al log
+	if utils.Conf.Client.KeepAlive > 0 {
+		time.Sleep(utils.Conf.Client.KeepAlive) //Rimane attivo per permettere di accedere al log
 
 	}
 }
@@ -0,0 +1,2526 @@
+// Schema versionato dell'API dello storage chiave-valore.
+//
+// Il servizio KeyValue è l'API per i client, equivalente alle RPC Get/Put/Delete esposte tramite net/rpc.
+// Il servizio Replica trasporta i messaggi scambiati tra le repliche dai protocolli di multicast.
+//
+// Regole di evoluzione: i numeri dei campi non vanno mai riutilizzati, i campi rimossi vanno marcati come
+// reserved e le modifiche incompatibili richiedono un nuovo package (kvs.v2).
+
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.34.2
+// 	protoc        (unknown)
+// source: kvs.proto
+
+package kvspb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// Args corrisponde a utils.Args
+type Args struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Key           string `protobuf:"bytes,1,opt,name=key,proto3" json:"key,omitempty"`
+	Value         []byte `protobuf:"bytes,2,opt,name=value,proto3" json:"value,omitempty"`
+	RequestNumber int64  `protobuf:"varint,3,opt,name=request_number,json=requestNumber,proto3" json:"request_number,omitempty"`
+	ClientIndex   int64  `protobuf:"varint,4,opt,name=client_index,json=clientIndex,proto3" json:"client_index,omitempty"`
+	// Condizione di CompareAndSwap: expected_version (0 = la chiave non deve esistere) oppure, se compare_value
+	// è true, expected_value
+	ExpectedVersion int64  `protobuf:"varint,5,opt,name=expected_version,json=expectedVersion,proto3" json:"expected_version,omitempty"`
+	ExpectedValue   []byte `protobuf:"bytes,6,opt,name=expected_value,json=expectedValue,proto3" json:"expected_value,omitempty"`
+	CompareValue    bool   `protobuf:"varint,7,opt,name=compare_value,json=compareValue,proto3" json:"compare_value,omitempty"`
+	// Operazioni di una transazione (Txn)
+	Txn []*TxnOp `protobuf:"bytes,8,rep,name=txn,proto3" json:"txn,omitempty"`
+	// Elementi di un Batch
+	Batch []*BatchItem `protobuf:"bytes,9,rep,name=batch,proto3" json:"batch,omitempty"`
+	// TTL di una Put in millisecondi (0 = nessuna scadenza)
+	TtlMs int64 `protobuf:"varint,10,opt,name=ttl_ms,json=ttlMs,proto3" json:"ttl_ms,omitempty"`
+	// Intervallo di una Scan: da key (inclusa) a range_end (esclusa, vuota = fino all'ultima chiave), al più limit
+	// chiavi; cursor è il cursor della Response della pagina precedente
+	RangeEnd string `protobuf:"bytes,11,opt,name=range_end,json=rangeEnd,proto3" json:"range_end,omitempty"`
+	Limit    int64  `protobuf:"varint,12,opt,name=limit,proto3" json:"limit,omitempty"`
+	Cursor   string `protobuf:"bytes,13,opt,name=cursor,proto3" json:"cursor,omitempty"`
+	// Namespace della richiesta (vuoto = "default") e, per CreateNamespace e DropNamespace, il namespace da creare
+	// o eliminare
+	Namespace     string     `protobuf:"bytes,14,opt,name=namespace,proto3" json:"namespace,omitempty"`
+	NamespaceSpec *Namespace `protobuf:"bytes,15,opt,name=namespace_spec,json=namespaceSpec,proto3" json:"namespace_spec,omitempty"`
+	// Quantità sommata al contatore da una Increment (anche negativa)
+	Delta int64 `protobuf:"varint,16,opt,name=delta,proto3" json:"delta,omitempty"`
+	// Aggiornamento di una chiave CRDT (UpdateCRDT): tipo del valore e, per un ORSet, rimozione di value
+	Crdt       string `protobuf:"bytes,17,opt,name=crdt,proto3" json:"crdt,omitempty"`
+	CrdtRemove bool   `protobuf:"varint,18,opt,name=crdt_remove,json=crdtRemove,proto3" json:"crdt_remove,omitempty"`
+	// Contesto di una Put o di una Delete causale: il context della Response della Get che ha letto i sibling
+	Context []int64 `protobuf:"varint,19,rep,packed,name=context,proto3" json:"context,omitempty"`
+	// Clock di sessione del client: la replica serve la richiesta solo dopo averlo raggiunto (consistenza causale)
+	SessionClock []int64 `protobuf:"varint,20,rep,packed,name=session_clock,json=sessionClock,proto3" json:"session_clock,omitempty"`
+	// Dipendenze del client nei namespace causali+: la replica serve la richiesta solo quando sono visibili
+	Dependencies []*Dependency `protobuf:"bytes,21,rep,name=dependencies,proto3" json:"dependencies,omitempty"`
+	// Token del client, inoltrato tra le repliche: la replica che esegue una Scan ne esclude le chiavi che il client
+	// non può leggere. Le richieste dei client lo indicano invece nei metadata.
+	Token string `protobuf:"bytes,22,opt,name=token,proto3" json:"token,omitempty"`
+	// Revisione del catalogo che ha creato il namespace della richiesta, indicata dalla replica che la riceve
+	NamespaceRevision int64 `protobuf:"varint,23,opt,name=namespace_revision,json=namespaceRevision,proto3" json:"namespace_revision,omitempty"`
+}
+
+func (x *Args) Reset() {
+	*x = Args{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_kvs_proto_msgTypes[0]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Args) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Args) ProtoMessage() {}
+
+func (x *Args) ProtoReflect() protoreflect.Message {
+	mi := &file_kvs_proto_msgTypes[0]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Args.ProtoReflect.Descriptor instead.
+func (*Args) Descriptor() ([]byte, []int) {
+	return file_kvs_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *Args) GetKey() string {
+	if x != nil {
+		return x.Key
+	}
+	return ""
+}
+
+func (x *Args) GetValue() []byte {
+	if x != nil {
+		return x.Value
+	}
+	return nil
+}
+
+func (x *Args) GetRequestNumber() int64 {
+	if x != nil {
+		return x.RequestNumber
+	}
+	return 0
+}
+
+func (x *Args) GetClientIndex() int64 {
+	if x != nil {
+		return x.ClientIndex
+	}
+	return 0
+}
+
+func (x *Args) GetExpectedVersion() int64 {
+	if x != nil {
+		return x.ExpectedVersion
+	}
+	return 0
+}
+
+func (x *Args) GetExpectedValue() []byte {
+	if x != nil {
+		return x.ExpectedValue
+	}
+	return nil
+}
+
+func (x *Args) GetCompareValue() bool {
+	if x != nil {
+		return x.CompareValue
+	}
+	return false
+}
+
+func (x *Args) GetTxn() []*TxnOp {
+	if x != nil {
+		return x.Txn
+	}
+	return nil
+}
+
+func (x *Args) GetBatch() []*BatchItem {
+	if x != nil {
+		return x.Batch
+	}
+	return nil
+}
+
+func (x *Args) GetTtlMs() int64 {
+	if x != nil {
+		return x.TtlMs
+	}
+	return 0
+}
+
+func (x *Args) GetRangeEnd() string {
+	if x != nil {
+		return x.RangeEnd
+	}
+	return ""
+}
+
+func (x *Args) GetLimit() int64 {
+	if x != nil {
+		return x.Limit
+	}
+	return 0
+}
+
+func (x *Args) GetCursor() string {
+	if x != nil {
+		return x.Cursor
+	}
+	return ""
+}
+
+func (x *Args) GetNamespace() string {
+	if x != nil {
+		return x.Namespace
+	}
+	return ""
+}
+
+func (x *Args) GetNamespaceSpec() *Namespace {
+	if x != nil {
+		return x.NamespaceSpec
+	}
+	return nil
+}
+
+func (x *Args) GetDelta() int64 {
+	if x != nil {
+		return x.Delta
+	}
+	return 0
+}
+
+func (x *Args) GetCrdt() string {
+	if x != nil {
+		return x.Crdt
+	}
+	return ""
+}
+
+func (x *Args) GetCrdtRemove() bool {
+	if x != nil {
+		return x.CrdtRemove
+	}
+	return false
+}
+
+func (x *Args) GetContext() []int64 {
+	if x != nil {
+		return x.Context
+	}
+	return nil
+}
+
+func (x *Args) GetSessionClock() []int64 {
+	if x != nil {
+		return x.SessionClock
+	}
+	return nil
+}
+
+func (x *Args) GetDependencies() []*Dependency {
+	if x != nil {
+		return x.Dependencies
+	}
+	return nil
+}
+
+func (x *Args) GetToken() string {
+	if x != nil {
+		return x.Token
+	}
+	return ""
+}
+
+func (x *Args) GetNamespaceRevision() int64 {
+	if x != nil {
+		return x.NamespaceRevision
+	}
+	return 0
+}
+
+// Stamp corrisponde a utils.Stamp: clock di Lamport e replica d'origine di una scrittura
+type Stamp struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Clock  int64 `protobuf:"varint,1,opt,name=clock,proto3" json:"clock,omitempty"`
+	Origin int64 `protobuf:"varint,2,opt,name=origin,proto3" json:"origin,omitempty"`
+}
+
+func (x *Stamp) Reset() {
+	*x = Stamp{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_kvs_proto_msgTypes[1]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Stamp) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Stamp) ProtoMessage() {}
+
+func (x *Stamp) ProtoReflect() protoreflect.Message {
+	mi := &file_kvs_proto_msgTypes[1]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Stamp.ProtoReflect.Descriptor instead.
+func (*Stamp) Descriptor() ([]byte, []int) {
+	return file_kvs_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *Stamp) GetClock() int64 {
+	if x != nil {
+		return x.Clock
+	}
+	return 0
+}
+
+func (x *Stamp) GetOrigin() int64 {
+	if x != nil {
+		return x.Origin
+	}
+	return 0
+}
+
+// Dependency corrisponde a utils.Dependency
+type Dependency struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Namespace string `protobuf:"bytes,1,opt,name=namespace,proto3" json:"namespace,omitempty"`
+	Key       string `protobuf:"bytes,2,opt,name=key,proto3" json:"key,omitempty"`
+	Stamp     *Stamp `protobuf:"bytes,3,opt,name=stamp,proto3" json:"stamp,omitempty"`
+}
+
+func (x *Dependency) Reset() {
+	*x = Dependency{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_kvs_proto_msgTypes[2]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Dependency) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Dependency) ProtoMessage() {}
+
+func (x *Dependency) ProtoReflect() protoreflect.Message {
+	mi := &file_kvs_proto_msgTypes[2]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Dependency.ProtoReflect.Descriptor instead.
+func (*Dependency) Descriptor() ([]byte, []int) {
+	return file_kvs_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *Dependency) GetNamespace() string {
+	if x != nil {
+		return x.Namespace
+	}
+	return ""
+}
+
+func (x *Dependency) GetKey() string {
+	if x != nil {
+		return x.Key
+	}
+	return ""
+}
+
+func (x *Dependency) GetStamp() *Stamp {
+	if x != nil {
+		return x.Stamp
+	}
+	return nil
+}
+
+// Namespace corrisponde a utils.Namespace: max_keys e max_bytes sono le quote (0 = nessun limite), keys e bytes
+// l'utilizzo corrente riportato da ListNamespaces, revision la revisione del catalogo che lo ha creato
+type Namespace struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Name        string `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Consistency string `protobuf:"bytes,2,opt,name=consistency,proto3" json:"consistency,omitempty"`
+	MaxKeys     int64  `protobuf:"varint,3,opt,name=max_keys,json=maxKeys,proto3" json:"max_keys,omitempty"`
+	MaxBytes    int64  `protobuf:"varint,4,opt,name=max_bytes,json=maxBytes,proto3" json:"max_bytes,omitempty"`
+	Keys        int64  `protobuf:"varint,5,opt,name=keys,proto3" json:"keys,omitempty"`
+	Bytes       int64  `protobuf:"varint,6,opt,name=bytes,proto3" json:"bytes,omitempty"`
+	Revision    int64  `protobuf:"varint,7,opt,name=revision,proto3" json:"revision,omitempty"`
+}
+
+func (x *Namespace) Reset() {
+	*x = Namespace{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_kvs_proto_msgTypes[3]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Namespace) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Namespace) ProtoMessage() {}
+
+func (x *Namespace) ProtoReflect() protoreflect.Message {
+	mi := &file_kvs_proto_msgTypes[3]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Namespace.ProtoReflect.Descriptor instead.
+func (*Namespace) Descriptor() ([]byte, []int) {
+	return file_kvs_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *Namespace) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *Namespace) GetConsistency() string {
+	if x != nil {
+		return x.Consistency
+	}
+	return ""
+}
+
+func (x *Namespace) GetMaxKeys() int64 {
+	if x != nil {
+		return x.MaxKeys
+	}
+	return 0
+}
+
+func (x *Namespace) GetMaxBytes() int64 {
+	if x != nil {
+		return x.MaxBytes
+	}
+	return 0
+}
+
+func (x *Namespace) GetKeys() int64 {
+	if x != nil {
+		return x.Keys
+	}
+	return 0
+}
+
+func (x *Namespace) GetBytes() int64 {
+	if x != nil {
+		return x.Bytes
+	}
+	return 0
+}
+
+func (x *Namespace) GetRevision() int64 {
+	if x != nil {
+		return x.Revision
+	}
+	return 0
+}
+
+// BatchItem corrisponde a utils.BatchItem: op_type è Get, Put, Delete, Increment o Append
+type BatchItem struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	OpType string `protobuf:"bytes,1,opt,name=op_type,json=opType,proto3" json:"op_type,omitempty"`
+	Args   *Args  `protobuf:"bytes,2,opt,name=args,proto3" json:"args,omitempty"`
+}
+
+func (x *BatchItem) Reset() {
+	*x = BatchItem{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_kvs_proto_msgTypes[4]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *BatchItem) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BatchItem) ProtoMessage() {}
+
+func (x *BatchItem) ProtoReflect() protoreflect.Message {
+	mi := &file_kvs_proto_msgTypes[4]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BatchItem.ProtoReflect.Descriptor instead.
+func (*BatchItem) Descriptor() ([]byte, []int) {
+	return file_kvs_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *BatchItem) GetOpType() string {
+	if x != nil {
+		return x.OpType
+	}
+	return ""
+}
+
+func (x *BatchItem) GetArgs() *Args {
+	if x != nil {
+		return x.Args
+	}
+	return nil
+}
+
+// BatchResult corrisponde a utils.BatchResult
+type BatchResult struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Key     string `protobuf:"bytes,1,opt,name=key,proto3" json:"key,omitempty"`
+	Value   []byte `protobuf:"bytes,2,opt,name=value,proto3" json:"value,omitempty"`
+	Version int64  `protobuf:"varint,3,opt,name=version,proto3" json:"version,omitempty"`
+	Error   string `protobuf:"bytes,4,opt,name=error,proto3" json:"error,omitempty"`
+	Found   bool   `protobuf:"varint,5,opt,name=found,proto3" json:"found,omitempty"`
+}
+
+func (x *BatchResult) Reset() {
+	*x = BatchResult{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_kvs_proto_msgTypes[5]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *BatchResult) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BatchResult) ProtoMessage() {}
+
+func (x *BatchResult) ProtoReflect() protoreflect.Message {
+	mi := &file_kvs_proto_msgTypes[5]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BatchResult.ProtoReflect.Descriptor instead.
+func (*BatchResult) Descriptor() ([]byte, []int) {
+	return file_kvs_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *BatchResult) GetKey() string {
+	if x != nil {
+		return x.Key
+	}
+	return ""
+}
+
+func (x *BatchResult) GetValue() []byte {
+	if x != nil {
+		return x.Value
+	}
+	return nil
+}
+
+func (x *BatchResult) GetVersion() int64 {
+	if x != nil {
+		return x.Version
+	}
+	return 0
+}
+
+func (x *BatchResult) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+func (x *BatchResult) GetFound() bool {
+	if x != nil {
+		return x.Found
+	}
+	return false
+}
+
+// TxnOp corrisponde a utils.TxnOp: op_type è Get, Put, Delete oppure Guard
+type TxnOp struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	OpType          string `protobuf:"bytes,1,opt,name=op_type,json=opType,proto3" json:"op_type,omitempty"`
+	Key             string `protobuf:"bytes,2,opt,name=key,proto3" json:"key,omitempty"`
+	Value           []byte `protobuf:"bytes,3,opt,name=value,proto3" json:"value,omitempty"`
+	ExpectedVersion int64  `protobuf:"varint,4,opt,name=expected_version,json=expectedVersion,proto3" json:"expected_version,omitempty"`
+	ExpectedValue   []byte `protobuf:"bytes,5,opt,name=expected_value,json=expectedValue,proto3" json:"expected_value,omitempty"`
+	CompareValue    bool   `protobuf:"varint,6,opt,name=compare_value,json=compareValue,proto3" json:"compare_value,omitempty"`
+}
+
+func (x *TxnOp) Reset() {
+	*x = TxnOp{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_kvs_proto_msgTypes[6]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *TxnOp) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TxnOp) ProtoMessage() {}
+
+func (x *TxnOp) ProtoReflect() protoreflect.Message {
+	mi := &file_kvs_proto_msgTypes[6]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TxnOp.ProtoReflect.Descriptor instead.
+func (*TxnOp) Descriptor() ([]byte, []int) {
+	return file_kvs_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *TxnOp) GetOpType() string {
+	if x != nil {
+		return x.OpType
+	}
+	return ""
+}
+
+func (x *TxnOp) GetKey() string {
+	if x != nil {
+		return x.Key
+	}
+	return ""
+}
+
+func (x *TxnOp) GetValue() []byte {
+	if x != nil {
+		return x.Value
+	}
+	return nil
+}
+
+func (x *TxnOp) GetExpectedVersion() int64 {
+	if x != nil {
+		return x.ExpectedVersion
+	}
+	return 0
+}
+
+func (x *TxnOp) GetExpectedValue() []byte {
+	if x != nil {
+		return x.ExpectedValue
+	}
+	return nil
+}
+
+func (x *TxnOp) GetCompareValue() bool {
+	if x != nil {
+		return x.CompareValue
+	}
+	return false
+}
+
+// TxnResult corrisponde a utils.TxnResult
+type TxnResult struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Key     string `protobuf:"bytes,1,opt,name=key,proto3" json:"key,omitempty"`
+	Value   []byte `protobuf:"bytes,2,opt,name=value,proto3" json:"value,omitempty"`
+	Version int64  `protobuf:"varint,3,opt,name=version,proto3" json:"version,omitempty"`
+	Found   bool   `protobuf:"varint,4,opt,name=found,proto3" json:"found,omitempty"`
+}
+
+func (x *TxnResult) Reset() {
+	*x = TxnResult{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_kvs_proto_msgTypes[7]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *TxnResult) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TxnResult) ProtoMessage() {}
+
+func (x *TxnResult) ProtoReflect() protoreflect.Message {
+	mi := &file_kvs_proto_msgTypes[7]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TxnResult.ProtoReflect.Descriptor instead.
+func (*TxnResult) Descriptor() ([]byte, []int) {
+	return file_kvs_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *TxnResult) GetKey() string {
+	if x != nil {
+		return x.Key
+	}
+	return ""
+}
+
+func (x *TxnResult) GetValue() []byte {
+	if x != nil {
+		return x.Value
+	}
+	return nil
+}
+
+func (x *TxnResult) GetVersion() int64 {
+	if x != nil {
+		return x.Version
+	}
+	return 0
+}
+
+func (x *TxnResult) GetFound() bool {
+	if x != nil {
+		return x.Found
+	}
+	return false
+}
+
+// Response corrisponde a utils.Response
+type Response struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Key          string         `protobuf:"bytes,1,opt,name=key,proto3" json:"key,omitempty"`
+	Value        []byte         `protobuf:"bytes,2,opt,name=value,proto3" json:"value,omitempty"`
+	IsPrintable  bool           `protobuf:"varint,3,opt,name=is_printable,json=isPrintable,proto3" json:"is_printable,omitempty"`
+	ClockValue   int64          `protobuf:"varint,4,opt,name=clock_value,json=clockValue,proto3" json:"clock_value,omitempty"`
+	ClockVector  []int64        `protobuf:"varint,5,rep,packed,name=clock_vector,json=clockVector,proto3" json:"clock_vector,omitempty"`
+	Version      int64          `protobuf:"varint,6,opt,name=version,proto3" json:"version,omitempty"`
+	Succeeded    bool           `protobuf:"varint,7,opt,name=succeeded,proto3" json:"succeeded,omitempty"`
+	TxnResults   []*TxnResult   `protobuf:"bytes,8,rep,name=txn_results,json=txnResults,proto3" json:"txn_results,omitempty"`
+	BatchResults []*BatchResult `protobuf:"bytes,9,rep,name=batch_results,json=batchResults,proto3" json:"batch_results,omitempty"`
+	ScanResults  []*ScanResult  `protobuf:"bytes,10,rep,name=scan_results,json=scanResults,proto3" json:"scan_results,omitempty"`
+	Cursor       string         `protobuf:"bytes,11,opt,name=cursor,proto3" json:"cursor,omitempty"`
+	Found        bool           `protobuf:"varint,12,opt,name=found,proto3" json:"found,omitempty"` // false se la chiave letta non esiste
+	Namespaces   []*Namespace   `protobuf:"bytes,13,rep,name=namespaces,proto3" json:"namespaces,omitempty"`
+	Crdt         *CRDTValue     `protobuf:"bytes,14,opt,name=crdt,proto3" json:"crdt,omitempty"`                                             // valore della chiave, se è un CRDT
+	Siblings     []*Sibling     `protobuf:"bytes,15,rep,name=siblings,proto3" json:"siblings,omitempty"`                                     // versioni concorrenti della chiave letta (consistenza causale)
+	Context      []int64        `protobuf:"varint,16,rep,packed,name=context,proto3" json:"context,omitempty"`                               // contesto con cui una Put risolve i siblings
+	SessionClock []int64        `protobuf:"varint,17,rep,packed,name=session_clock,json=sessionClock,proto3" json:"session_clock,omitempty"` // clock di sessione da indicare nella richiesta successiva del client
+	Stamp        *Stamp         `protobuf:"bytes,18,opt,name=stamp,proto3" json:"stamp,omitempty"`                                           // versione della chiave letta o scritta (consistenza causale+)
+	Dependencies []*Dependency  `protobuf:"bytes,19,rep,name=dependencies,proto3" json:"dependencies,omitempty"`                             // dipendenze da indicare nella richiesta successiva (consistenza causale+)
+	View         int64          `protobuf:"varint,20,opt,name=view,proto3" json:"view,omitempty"`                                            // configurazione della replica che ha risposto (primary-backup, catena)
+}
+
+func (x *Response) Reset() {
+	*x = Response{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_kvs_proto_msgTypes[8]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Response) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Response) ProtoMessage() {}
+
+func (x *Response) ProtoReflect() protoreflect.Message {
+	mi := &file_kvs_proto_msgTypes[8]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Response.ProtoReflect.Descriptor instead.
+func (*Response) Descriptor() ([]byte, []int) {
+	return file_kvs_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *Response) GetKey() string {
+	if x != nil {
+		return x.Key
+	}
+	return ""
+}
+
+func (x *Response) GetValue() []byte {
+	if x != nil {
+		return x.Value
+	}
+	return nil
+}
+
+func (x *Response) GetIsPrintable() bool {
+	if x != nil {
+		return x.IsPrintable
+	}
+	return false
+}
+
+func (x *Response) GetClockValue() int64 {
+	if x != nil {
+		return x.ClockValue
+	}
+	return 0
+}
+
+func (x *Response) GetClockVector() []int64 {
+	if x != nil {
+		return x.ClockVector
+	}
+	return nil
+}
+
+func (x *Response) GetVersion() int64 {
+	if x != nil {
+		return x.Version
+	}
+	return 0
+}
+
+func (x *Response) GetSucceeded() bool {
+	if x != nil {
+		return x.Succeeded
+	}
+	return false
+}
+
+func (x *Response) GetTxnResults() []*TxnResult {
+	if x != nil {
+		return x.TxnResults
+	}
+	return nil
+}
+
+func (x *Response) GetBatchResults() []*BatchResult {
+	if x != nil {
+		return x.BatchResults
+	}
+	return nil
+}
+
+func (x *Response) GetScanResults() []*ScanResult {
+	if x != nil {
+		return x.ScanResults
+	}
+	return nil
+}
+
+func (x *Response) GetCursor() string {
+	if x != nil {
+		return x.Cursor
+	}
+	return ""
+}
+
+func (x *Response) GetFound() bool {
+	if x != nil {
+		return x.Found
+	}
+	return false
+}
+
+func (x *Response) GetNamespaces() []*Namespace {
+	if x != nil {
+		return x.Namespaces
+	}
+	return nil
+}
+
+func (x *Response) GetCrdt() *CRDTValue {
+	if x != nil {
+		return x.Crdt
+	}
+	return nil
+}
+
+func (x *Response) GetSiblings() []*Sibling {
+	if x != nil {
+		return x.Siblings
+	}
+	return nil
+}
+
+func (x *Response) GetContext() []int64 {
+	if x != nil {
+		return x.Context
+	}
+	return nil
+}
+
+func (x *Response) GetSessionClock() []int64 {
+	if x != nil {
+		return x.SessionClock
+	}
+	return nil
+}
+
+func (x *Response) GetStamp() *Stamp {
+	if x != nil {
+		return x.Stamp
+	}
+	return nil
+}
+
+func (x *Response) GetDependencies() []*Dependency {
+	if x != nil {
+		return x.Dependencies
+	}
+	return nil
+}
+
+func (x *Response) GetView() int64 {
+	if x != nil {
+		return x.View
+	}
+	return 0
+}
+
+// Sibling corrisponde a utils.Sibling
+type Sibling struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Value       []byte  `protobuf:"bytes,1,opt,name=value,proto3" json:"value,omitempty"`
+	ClockVector []int64 `protobuf:"varint,2,rep,packed,name=clock_vector,json=clockVector,proto3" json:"clock_vector,omitempty"`
+}
+
+func (x *Sibling) Reset() {
+	*x = Sibling{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_kvs_proto_msgTypes[9]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Sibling) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Sibling) ProtoMessage() {}
+
+func (x *Sibling) ProtoReflect() protoreflect.Message {
+	mi := &file_kvs_proto_msgTypes[9]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Sibling.ProtoReflect.Descriptor instead.
+func (*Sibling) Descriptor() ([]byte, []int) {
+	return file_kvs_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *Sibling) GetValue() []byte {
+	if x != nil {
+		return x.Value
+	}
+	return nil
+}
+
+func (x *Sibling) GetClockVector() []int64 {
+	if x != nil {
+		return x.ClockVector
+	}
+	return nil
+}
+
+// CRDTValue corrisponde a utils.CRDTValue
+type CRDTValue struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Type    string   `protobuf:"bytes,1,opt,name=type,proto3" json:"type,omitempty"`
+	Counter int64    `protobuf:"varint,2,opt,name=counter,proto3" json:"counter,omitempty"`
+	Values  [][]byte `protobuf:"bytes,3,rep,name=values,proto3" json:"values,omitempty"`
+}
+
+func (x *CRDTValue) Reset() {
+	*x = CRDTValue{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_kvs_proto_msgTypes[10]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *CRDTValue) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CRDTValue) ProtoMessage() {}
+
+func (x *CRDTValue) ProtoReflect() protoreflect.Message {
+	mi := &file_kvs_proto_msgTypes[10]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CRDTValue.ProtoReflect.Descriptor instead.
+func (*CRDTValue) Descriptor() ([]byte, []int) {
+	return file_kvs_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *CRDTValue) GetType() string {
+	if x != nil {
+		return x.Type
+	}
+	return ""
+}
+
+func (x *CRDTValue) GetCounter() int64 {
+	if x != nil {
+		return x.Counter
+	}
+	return 0
+}
+
+func (x *CRDTValue) GetValues() [][]byte {
+	if x != nil {
+		return x.Values
+	}
+	return nil
+}
+
+type ScanResult struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Key     string `protobuf:"bytes,1,opt,name=key,proto3" json:"key,omitempty"`
+	Value   []byte `protobuf:"bytes,2,opt,name=value,proto3" json:"value,omitempty"`
+	Version int64  `protobuf:"varint,3,opt,name=version,proto3" json:"version,omitempty"`
+}
+
+func (x *ScanResult) Reset() {
+	*x = ScanResult{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_kvs_proto_msgTypes[11]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ScanResult) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ScanResult) ProtoMessage() {}
+
+func (x *ScanResult) ProtoReflect() protoreflect.Message {
+	mi := &file_kvs_proto_msgTypes[11]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ScanResult.ProtoReflect.Descriptor instead.
+func (*ScanResult) Descriptor() ([]byte, []int) {
+	return file_kvs_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *ScanResult) GetKey() string {
+	if x != nil {
+		return x.Key
+	}
+	return ""
+}
+
+func (x *ScanResult) GetValue() []byte {
+	if x != nil {
+		return x.Value
+	}
+	return nil
+}
+
+func (x *ScanResult) GetVersion() int64 {
+	if x != nil {
+		return x.Version
+	}
+	return 0
+}
+
+// WatchRequest apre un Watch sulla chiave key o, con prefix, sulle chiavi che iniziano con key, nel namespace
+// indicato. Va indicato al più un punto di ripresa: after_revision, after_clock (sequenziale, con after_message e
+// after_position dell'ultimo evento ricevuto) o after_vector (causale).
+type WatchRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Key           string  `protobuf:"bytes,1,opt,name=key,proto3" json:"key,omitempty"`
+	Prefix        bool    `protobuf:"varint,2,opt,name=prefix,proto3" json:"prefix,omitempty"`
+	AfterRevision int64   `protobuf:"varint,3,opt,name=after_revision,json=afterRevision,proto3" json:"after_revision,omitempty"`
+	AfterClock    int64   `protobuf:"varint,4,opt,name=after_clock,json=afterClock,proto3" json:"after_clock,omitempty"`
+	AfterVector   []int64 `protobuf:"varint,5,rep,packed,name=after_vector,json=afterVector,proto3" json:"after_vector,omitempty"`
+	Namespace     string  `protobuf:"bytes,6,opt,name=namespace,proto3" json:"namespace,omitempty"`
+	AfterMessage  string  `protobuf:"bytes,7,opt,name=after_message,json=afterMessage,proto3" json:"after_message,omitempty"`
+	AfterPosition int64   `protobuf:"varint,8,opt,name=after_position,json=afterPosition,proto3" json:"after_position,omitempty"`
+}
+
+func (x *WatchRequest) Reset() {
+	*x = WatchRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_kvs_proto_msgTypes[12]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *WatchRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WatchRequest) ProtoMessage() {}
+
+func (x *WatchRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_kvs_proto_msgTypes[12]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WatchRequest.ProtoReflect.Descriptor instead.
+func (*WatchRequest) Descriptor() ([]byte, []int) {
+	return file_kvs_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *WatchRequest) GetKey() string {
+	if x != nil {
+		return x.Key
+	}
+	return ""
+}
+
+func (x *WatchRequest) GetPrefix() bool {
+	if x != nil {
+		return x.Prefix
+	}
+	return false
+}
+
+func (x *WatchRequest) GetAfterRevision() int64 {
+	if x != nil {
+		return x.AfterRevision
+	}
+	return 0
+}
+
+func (x *WatchRequest) GetAfterClock() int64 {
+	if x != nil {
+		return x.AfterClock
+	}
+	return 0
+}
+
+func (x *WatchRequest) GetAfterVector() []int64 {
+	if x != nil {
+		return x.AfterVector
+	}
+	return nil
+}
+
+func (x *WatchRequest) GetNamespace() string {
+	if x != nil {
+		return x.Namespace
+	}
+	return ""
+}
+
+func (x *WatchRequest) GetAfterMessage() string {
+	if x != nil {
+		return x.AfterMessage
+	}
+	return ""
+}
+
+func (x *WatchRequest) GetAfterPosition() int64 {
+	if x != nil {
+		return x.AfterPosition
+	}
+	return 0
+}
+
+type WatchEvent struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Revision    int64   `protobuf:"varint,1,opt,name=revision,proto3" json:"revision,omitempty"`
+	OpType      string  `protobuf:"bytes,2,opt,name=op_type,json=opType,proto3" json:"op_type,omitempty"`
+	Key         string  `protobuf:"bytes,3,opt,name=key,proto3" json:"key,omitempty"`
+	Value       []byte  `protobuf:"bytes,4,opt,name=value,proto3" json:"value,omitempty"`
+	Version     int64   `protobuf:"varint,5,opt,name=version,proto3" json:"version,omitempty"`
+	ClockValue  int64   `protobuf:"varint,6,opt,name=clock_value,json=clockValue,proto3" json:"clock_value,omitempty"`
+	ClockVector []int64 `protobuf:"varint,7,rep,packed,name=clock_vector,json=clockVector,proto3" json:"clock_vector,omitempty"`
+	Namespace   string  `protobuf:"bytes,8,opt,name=namespace,proto3" json:"namespace,omitempty"`
+	Message     string  `protobuf:"bytes,9,opt,name=message,proto3" json:"message,omitempty"`
+	Position    int64   `protobuf:"varint,10,opt,name=position,proto3" json:"position,omitempty"`
+}
+
+func (x *WatchEvent) Reset() {
+	*x = WatchEvent{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_kvs_proto_msgTypes[13]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *WatchEvent) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WatchEvent) ProtoMessage() {}
+
+func (x *WatchEvent) ProtoReflect() protoreflect.Message {
+	mi := &file_kvs_proto_msgTypes[13]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WatchEvent.ProtoReflect.Descriptor instead.
+func (*WatchEvent) Descriptor() ([]byte, []int) {
+	return file_kvs_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *WatchEvent) GetRevision() int64 {
+	if x != nil {
+		return x.Revision
+	}
+	return 0
+}
+
+func (x *WatchEvent) GetOpType() string {
+	if x != nil {
+		return x.OpType
+	}
+	return ""
+}
+
+func (x *WatchEvent) GetKey() string {
+	if x != nil {
+		return x.Key
+	}
+	return ""
+}
+
+func (x *WatchEvent) GetValue() []byte {
+	if x != nil {
+		return x.Value
+	}
+	return nil
+}
+
+func (x *WatchEvent) GetVersion() int64 {
+	if x != nil {
+		return x.Version
+	}
+	return 0
+}
+
+func (x *WatchEvent) GetClockValue() int64 {
+	if x != nil {
+		return x.ClockValue
+	}
+	return 0
+}
+
+func (x *WatchEvent) GetClockVector() []int64 {
+	if x != nil {
+		return x.ClockVector
+	}
+	return nil
+}
+
+func (x *WatchEvent) GetNamespace() string {
+	if x != nil {
+		return x.Namespace
+	}
+	return ""
+}
+
+func (x *WatchEvent) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+func (x *WatchEvent) GetPosition() int64 {
+	if x != nil {
+		return x.Position
+	}
+	return 0
+}
+
+// ReplicaMessage corrisponde sia a utils.MessageNA (consistenza sequenziale) che a utils.VMessageNA
+// (consistenza causale): ogni protocollo valorizza solo i campi del proprio clock, e la replica che lo riceve lo
+// consegna allo storage della consistenza corrispondente. Il namespace dell'operazione è in args.
+type ReplicaMessage struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Args        *Args  `protobuf:"bytes,1,opt,name=args,proto3" json:"args,omitempty"`
+	Uuid        string `protobuf:"bytes,2,opt,name=uuid,proto3" json:"uuid,omitempty"`
+	ServerIndex int64  `protobuf:"varint,3,opt,name=server_index,json=serverIndex,proto3" json:"server_index,omitempty"`
+	OpType      string `protobuf:"bytes,4,opt,name=op_type,json=opType,proto3" json:"op_type,omitempty"`
+	// Consistenza sequenziale
+	ClockValue       int64 `protobuf:"varint,5,opt,name=clock_value,json=clockValue,proto3" json:"clock_value,omitempty"`
+	ServerMsgCounter int64 `protobuf:"varint,6,opt,name=server_msg_counter,json=serverMsgCounter,proto3" json:"server_msg_counter,omitempty"`
+	// Consistenza causale
+	ClockVector []int64 `protobuf:"varint,7,rep,packed,name=clock_vector,json=clockVector,proto3" json:"clock_vector,omitempty"`
+	FifoIndex   int64   `protobuf:"varint,8,opt,name=fifo_index,json=fifoIndex,proto3" json:"fifo_index,omitempty"`
+}
+
+func (x *ReplicaMessage) Reset() {
+	*x = ReplicaMessage{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_kvs_proto_msgTypes[14]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ReplicaMessage) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ReplicaMessage) ProtoMessage() {}
+
+func (x *ReplicaMessage) ProtoReflect() protoreflect.Message {
+	mi := &file_kvs_proto_msgTypes[14]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ReplicaMessage.ProtoReflect.Descriptor instead.
+func (*ReplicaMessage) Descriptor() ([]byte, []int) {
+	return file_kvs_proto_rawDescGZIP(), []int{14}
+}
+
+func (x *ReplicaMessage) GetArgs() *Args {
+	if x != nil {
+		return x.Args
+	}
+	return nil
+}
+
+func (x *ReplicaMessage) GetUuid() string {
+	if x != nil {
+		return x.Uuid
+	}
+	return ""
+}
+
+func (x *ReplicaMessage) GetServerIndex() int64 {
+	if x != nil {
+		return x.ServerIndex
+	}
+	return 0
+}
+
+func (x *ReplicaMessage) GetOpType() string {
+	if x != nil {
+		return x.OpType
+	}
+	return ""
+}
+
+func (x *ReplicaMessage) GetClockValue() int64 {
+	if x != nil {
+		return x.ClockValue
+	}
+	return 0
+}
+
+func (x *ReplicaMessage) GetServerMsgCounter() int64 {
+	if x != nil {
+		return x.ServerMsgCounter
+	}
+	return 0
+}
+
+func (x *ReplicaMessage) GetClockVector() []int64 {
+	if x != nil {
+		return x.ClockVector
+	}
+	return nil
+}
+
+func (x *ReplicaMessage) GetFifoIndex() int64 {
+	if x != nil {
+		return x.FifoIndex
+	}
+	return 0
+}
+
+// UpdateReply è la risposta alla consegna di un ReplicaMessage, correlata tramite uuid
+type UpdateReply struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Uuid     string    `protobuf:"bytes,1,opt,name=uuid,proto3" json:"uuid,omitempty"`
+	Response *Response `protobuf:"bytes,2,opt,name=response,proto3" json:"response,omitempty"`
+	Error    string    `protobuf:"bytes,3,opt,name=error,proto3" json:"error,omitempty"`
+}
+
+func (x *UpdateReply) Reset() {
+	*x = UpdateReply{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_kvs_proto_msgTypes[15]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *UpdateReply) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UpdateReply) ProtoMessage() {}
+
+func (x *UpdateReply) ProtoReflect() protoreflect.Message {
+	mi := &file_kvs_proto_msgTypes[15]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UpdateReply.ProtoReflect.Descriptor instead.
+func (*UpdateReply) Descriptor() ([]byte, []int) {
+	return file_kvs_proto_rawDescGZIP(), []int{15}
+}
+
+func (x *UpdateReply) GetUuid() string {
+	if x != nil {
+		return x.Uuid
+	}
+	return ""
+}
+
+func (x *UpdateReply) GetResponse() *Response {
+	if x != nil {
+		return x.Response
+	}
+	return nil
+}
+
+func (x *UpdateReply) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+// ReplicationMessage corrisponde a utils.ReplicationMessage: il messaggio dei protocolli di replicazione dei
+// namespace che non usano il multicast, consegnato allo storage di protocol
+type ReplicationMessage struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Protocol     string            `protobuf:"bytes,1,opt,name=protocol,proto3" json:"protocol,omitempty"`
+	Args         *Args             `protobuf:"bytes,2,opt,name=args,proto3" json:"args,omitempty"`
+	Uuid         string            `protobuf:"bytes,3,opt,name=uuid,proto3" json:"uuid,omitempty"`
+	ServerIndex  int64             `protobuf:"varint,4,opt,name=server_index,json=serverIndex,proto3" json:"server_index,omitempty"`
+	OpType       string            `protobuf:"bytes,5,opt,name=op_type,json=opType,proto3" json:"op_type,omitempty"`
+	Stamp        *Stamp            `protobuf:"bytes,6,opt,name=stamp,proto3" json:"stamp,omitempty"`
+	Dependencies []*Dependency     `protobuf:"bytes,7,rep,name=dependencies,proto3" json:"dependencies,omitempty"`
+	View         int64             `protobuf:"varint,8,opt,name=view,proto3" json:"view,omitempty"`
+	Sequence     int64             `protobuf:"varint,9,opt,name=sequence,proto3" json:"sequence,omitempty"`
+	State        []*NamespaceState `protobuf:"bytes,10,rep,name=state,proto3" json:"state,omitempty"`
+	Chain        []int64           `protobuf:"varint,11,rep,packed,name=chain,proto3" json:"chain,omitempty"`
+}
+
+func (x *ReplicationMessage) Reset() {
+	*x = ReplicationMessage{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_kvs_proto_msgTypes[16]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ReplicationMessage) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ReplicationMessage) ProtoMessage() {}
+
+func (x *ReplicationMessage) ProtoReflect() protoreflect.Message {
+	mi := &file_kvs_proto_msgTypes[16]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ReplicationMessage.ProtoReflect.Descriptor instead.
+func (*ReplicationMessage) Descriptor() ([]byte, []int) {
+	return file_kvs_proto_rawDescGZIP(), []int{16}
+}
+
+func (x *ReplicationMessage) GetProtocol() string {
+	if x != nil {
+		return x.Protocol
+	}
+	return ""
+}
+
+func (x *ReplicationMessage) GetArgs() *Args {
+	if x != nil {
+		return x.Args
+	}
+	return nil
+}
+
+func (x *ReplicationMessage) GetUuid() string {
+	if x != nil {
+		return x.Uuid
+	}
+	return ""
+}
+
+func (x *ReplicationMessage) GetServerIndex() int64 {
+	if x != nil {
+		return x.ServerIndex
+	}
+	return 0
+}
+
+func (x *ReplicationMessage) GetOpType() string {
+	if x != nil {
+		return x.OpType
+	}
+	return ""
+}
+
+func (x *ReplicationMessage) GetStamp() *Stamp {
+	if x != nil {
+		return x.Stamp
+	}
+	return nil
+}
+
+func (x *ReplicationMessage) GetDependencies() []*Dependency {
+	if x != nil {
+		return x.Dependencies
+	}
+	return nil
+}
+
+func (x *ReplicationMessage) GetView() int64 {
+	if x != nil {
+		return x.View
+	}
+	return 0
+}
+
+func (x *ReplicationMessage) GetSequence() int64 {
+	if x != nil {
+		return x.Sequence
+	}
+	return 0
+}
+
+func (x *ReplicationMessage) GetState() []*NamespaceState {
+	if x != nil {
+		return x.State
+	}
+	return nil
+}
+
+func (x *ReplicationMessage) GetChain() []int64 {
+	if x != nil {
+		return x.Chain
+	}
+	return nil
+}
+
+// NamespaceState corrisponde a utils.NamespaceState: il contenuto di un namespace trasferito a un'altra replica
+type NamespaceState struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Namespace *Namespace    `protobuf:"bytes,1,opt,name=namespace,proto3" json:"namespace,omitempty"`
+	Keys      []*ScanResult `protobuf:"bytes,2,rep,name=keys,proto3" json:"keys,omitempty"`
+}
+
+func (x *NamespaceState) Reset() {
+	*x = NamespaceState{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_kvs_proto_msgTypes[17]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *NamespaceState) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*NamespaceState) ProtoMessage() {}
+
+func (x *NamespaceState) ProtoReflect() protoreflect.Message {
+	mi := &file_kvs_proto_msgTypes[17]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use NamespaceState.ProtoReflect.Descriptor instead.
+func (*NamespaceState) Descriptor() ([]byte, []int) {
+	return file_kvs_proto_rawDescGZIP(), []int{17}
+}
+
+func (x *NamespaceState) GetNamespace() *Namespace {
+	if x != nil {
+		return x.Namespace
+	}
+	return nil
+}
+
+func (x *NamespaceState) GetKeys() []*ScanResult {
+	if x != nil {
+		return x.Keys
+	}
+	return nil
+}
+
+type LeaveRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	ServerId string `protobuf:"bytes,1,opt,name=server_id,json=serverId,proto3" json:"server_id,omitempty"`
+}
+
+func (x *LeaveRequest) Reset() {
+	*x = LeaveRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_kvs_proto_msgTypes[18]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *LeaveRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*LeaveRequest) ProtoMessage() {}
+
+func (x *LeaveRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_kvs_proto_msgTypes[18]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use LeaveRequest.ProtoReflect.Descriptor instead.
+func (*LeaveRequest) Descriptor() ([]byte, []int) {
+	return file_kvs_proto_rawDescGZIP(), []int{18}
+}
+
+func (x *LeaveRequest) GetServerId() string {
+	if x != nil {
+		return x.ServerId
+	}
+	return ""
+}
+
+type Empty struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+}
+
+func (x *Empty) Reset() {
+	*x = Empty{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_kvs_proto_msgTypes[19]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Empty) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Empty) ProtoMessage() {}
+
+func (x *Empty) ProtoReflect() protoreflect.Message {
+	mi := &file_kvs_proto_msgTypes[19]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Empty.ProtoReflect.Descriptor instead.
+func (*Empty) Descriptor() ([]byte, []int) {
+	return file_kvs_proto_rawDescGZIP(), []int{19}
+}
+
+var File_kvs_proto protoreflect.FileDescriptor
+
+var file_kvs_proto_rawDesc = []byte{
+	0x0a, 0x09, 0x6b, 0x76, 0x73, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x12, 0x06, 0x6b, 0x76, 0x73,
+	0x2e, 0x76, 0x31, 0x22, 0xfa, 0x05, 0x0a, 0x04, 0x41, 0x72, 0x67, 0x73, 0x12, 0x10, 0x0a, 0x03,
+	0x6b, 0x65, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x6b, 0x65, 0x79, 0x12, 0x14,
+	0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x05, 0x76,
+	0x61, 0x6c, 0x75, 0x65, 0x12, 0x25, 0x0a, 0x0e, 0x72, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x5f,
+	0x6e, 0x75, 0x6d, 0x62, 0x65, 0x72, 0x18, 0x03, 0x20, 0x01, 0x28, 0x03, 0x52, 0x0d, 0x72, 0x65,
+	0x71, 0x75, 0x65, 0x73, 0x74, 0x4e, 0x75, 0x6d, 0x62, 0x65, 0x72, 0x12, 0x21, 0x0a, 0x0c, 0x63,
+	0x6c, 0x69, 0x65, 0x6e, 0x74, 0x5f, 0x69, 0x6e, 0x64, 0x65, 0x78, 0x18, 0x04, 0x20, 0x01, 0x28,
+	0x03, 0x52, 0x0b, 0x63, 0x6c, 0x69, 0x65, 0x6e, 0x74, 0x49, 0x6e, 0x64, 0x65, 0x78, 0x12, 0x29,
+	0x0a, 0x10, 0x65, 0x78, 0x70, 0x65, 0x63, 0x74, 0x65, 0x64, 0x5f, 0x76, 0x65, 0x72, 0x73, 0x69,
+	0x6f, 0x6e, 0x18, 0x05, 0x20, 0x01, 0x28, 0x03, 0x52, 0x0f, 0x65, 0x78, 0x70, 0x65, 0x63, 0x74,
+	0x65, 0x64, 0x56, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x12, 0x25, 0x0a, 0x0e, 0x65, 0x78, 0x70,
+	0x65, 0x63, 0x74, 0x65, 0x64, 0x5f, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x06, 0x20, 0x01, 0x28,
+	0x0c, 0x52, 0x0d, 0x65, 0x78, 0x70, 0x65, 0x63, 0x74, 0x65, 0x64, 0x56, 0x61, 0x6c, 0x75, 0x65,
+	0x12, 0x23, 0x0a, 0x0d, 0x63, 0x6f, 0x6d, 0x70, 0x61, 0x72, 0x65, 0x5f, 0x76, 0x61, 0x6c, 0x75,
+	0x65, 0x18, 0x07, 0x20, 0x01, 0x28, 0x08, 0x52, 0x0c, 0x63, 0x6f, 0x6d, 0x70, 0x61, 0x72, 0x65,
+	0x56, 0x61, 0x6c, 0x75, 0x65, 0x12, 0x1f, 0x0a, 0x03, 0x74, 0x78, 0x6e, 0x18, 0x08, 0x20, 0x03,
+	0x28, 0x0b, 0x32, 0x0d, 0x2e, 0x6b, 0x76, 0x73, 0x2e, 0x76, 0x31, 0x2e, 0x54, 0x78, 0x6e, 0x4f,
+	0x70, 0x52, 0x03, 0x74, 0x78, 0x6e, 0x12, 0x27, 0x0a, 0x05, 0x62, 0x61, 0x74, 0x63, 0x68, 0x18,
+	0x09, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x11, 0x2e, 0x6b, 0x76, 0x73, 0x2e, 0x76, 0x31, 0x2e, 0x42,
+	0x61, 0x74, 0x63, 0x68, 0x49, 0x74, 0x65, 0x6d, 0x52, 0x05, 0x62, 0x61, 0x74, 0x63, 0x68, 0x12,
+	0x15, 0x0a, 0x06, 0x74, 0x74, 0x6c, 0x5f, 0x6d, 0x73, 0x18, 0x0a, 0x20, 0x01, 0x28, 0x03, 0x52,
+	0x05, 0x74, 0x74, 0x6c, 0x4d, 0x73, 0x12, 0x1b, 0x0a, 0x09, 0x72, 0x61, 0x6e, 0x67, 0x65, 0x5f,
+	0x65, 0x6e, 0x64, 0x18, 0x0b, 0x20, 0x01, 0x28, 0x09, 0x52, 0x08, 0x72, 0x61, 0x6e, 0x67, 0x65,
+	0x45, 0x6e, 0x64, 0x12, 0x14, 0x0a, 0x05, 0x6c, 0x69, 0x6d, 0x69, 0x74, 0x18, 0x0c, 0x20, 0x01,
+	0x28, 0x03, 0x52, 0x05, 0x6c, 0x69, 0x6d, 0x69, 0x74, 0x12, 0x16, 0x0a, 0x06, 0x63, 0x75, 0x72,
+	0x73, 0x6f, 0x72, 0x18, 0x0d, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x63, 0x75, 0x72, 0x73, 0x6f,
+	0x72, 0x12, 0x1c, 0x0a, 0x09, 0x6e, 0x61, 0x6d, 0x65, 0x73, 0x70, 0x61, 0x63, 0x65, 0x18, 0x0e,
+	0x20, 0x01, 0x28, 0x09, 0x52, 0x09, 0x6e, 0x61, 0x6d, 0x65, 0x73, 0x70, 0x61, 0x63, 0x65, 0x12,
+	0x38, 0x0a, 0x0e, 0x6e, 0x61, 0x6d, 0x65, 0x73, 0x70, 0x61, 0x63, 0x65, 0x5f, 0x73, 0x70, 0x65,
+	0x63, 0x18, 0x0f, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x11, 0x2e, 0x6b, 0x76, 0x73, 0x2e, 0x76, 0x31,
+	0x2e, 0x4e, 0x61, 0x6d, 0x65, 0x73, 0x70, 0x61, 0x63, 0x65, 0x52, 0x0d, 0x6e, 0x61, 0x6d, 0x65,
+	0x73, 0x70, 0x61, 0x63, 0x65, 0x53, 0x70, 0x65, 0x63, 0x12, 0x14, 0x0a, 0x05, 0x64, 0x65, 0x6c,
+	0x74, 0x61, 0x18, 0x10, 0x20, 0x01, 0x28, 0x03, 0x52, 0x05, 0x64, 0x65, 0x6c, 0x74, 0x61, 0x12,
+	0x12, 0x0a, 0x04, 0x63, 0x72, 0x64, 0x74, 0x18, 0x11, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x63,
+	0x72, 0x64, 0x74, 0x12, 0x1f, 0x0a, 0x0b, 0x63, 0x72, 0x64, 0x74, 0x5f, 0x72, 0x65, 0x6d, 0x6f,
+	0x76, 0x65, 0x18, 0x12, 0x20, 0x01, 0x28, 0x08, 0x52, 0x0a, 0x63, 0x72, 0x64, 0x74, 0x52, 0x65,
+	0x6d, 0x6f, 0x76, 0x65, 0x12, 0x18, 0x0a, 0x07, 0x63, 0x6f, 0x6e, 0x74, 0x65, 0x78, 0x74, 0x18,
+	0x13, 0x20, 0x03, 0x28, 0x03, 0x52, 0x07, 0x63, 0x6f, 0x6e, 0x74, 0x65, 0x78, 0x74, 0x12, 0x23,
+	0x0a, 0x0d, 0x73, 0x65, 0x73, 0x73, 0x69, 0x6f, 0x6e, 0x5f, 0x63, 0x6c, 0x6f, 0x63, 0x6b, 0x18,
+	0x14, 0x20, 0x03, 0x28, 0x03, 0x52, 0x0c, 0x73, 0x65, 0x73, 0x73, 0x69, 0x6f, 0x6e, 0x43, 0x6c,
+	0x6f, 0x63, 0x6b, 0x12, 0x36, 0x0a, 0x0c, 0x64, 0x65, 0x70, 0x65, 0x6e, 0x64, 0x65, 0x6e, 0x63,
+	0x69, 0x65, 0x73, 0x18, 0x15, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x12, 0x2e, 0x6b, 0x76, 0x73, 0x2e,
+	0x76, 0x31, 0x2e, 0x44, 0x65, 0x70, 0x65, 0x6e, 0x64, 0x65, 0x6e, 0x63, 0x79, 0x52, 0x0c, 0x64,
+	0x65, 0x70, 0x65, 0x6e, 0x64, 0x65, 0x6e, 0x63, 0x69, 0x65, 0x73, 0x12, 0x14, 0x0a, 0x05, 0x74,
+	0x6f, 0x6b, 0x65, 0x6e, 0x18, 0x16, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x74, 0x6f, 0x6b, 0x65,
+	0x6e, 0x12, 0x2d, 0x0a, 0x12, 0x6e, 0x61, 0x6d, 0x65, 0x73, 0x70, 0x61, 0x63, 0x65, 0x5f, 0x72,
+	0x65, 0x76, 0x69, 0x73, 0x69, 0x6f, 0x6e, 0x18, 0x17, 0x20, 0x01, 0x28, 0x03, 0x52, 0x11, 0x6e,
+	0x61, 0x6d, 0x65, 0x73, 0x70, 0x61, 0x63, 0x65, 0x52, 0x65, 0x76, 0x69, 0x73, 0x69, 0x6f, 0x6e,
+	0x22, 0x35, 0x0a, 0x05, 0x53, 0x74, 0x61, 0x6d, 0x70, 0x12, 0x14, 0x0a, 0x05, 0x63, 0x6c, 0x6f,
+	0x63, 0x6b, 0x18, 0x01, 0x20, 0x01, 0x28, 0x03, 0x52, 0x05, 0x63, 0x6c, 0x6f, 0x63, 0x6b, 0x12,
+	0x16, 0x0a, 0x06, 0x6f, 0x72, 0x69, 0x67, 0x69, 0x6e, 0x18, 0x02, 0x20, 0x01, 0x28, 0x03, 0x52,
+	0x06, 0x6f, 0x72, 0x69, 0x67, 0x69, 0x6e, 0x22, 0x61, 0x0a, 0x0a, 0x44, 0x65, 0x70, 0x65, 0x6e,
+	0x64, 0x65, 0x6e, 0x63, 0x79, 0x12, 0x1c, 0x0a, 0x09, 0x6e, 0x61, 0x6d, 0x65, 0x73, 0x70, 0x61,
+	0x63, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x09, 0x6e, 0x61, 0x6d, 0x65, 0x73, 0x70,
+	0x61, 0x63, 0x65, 0x12, 0x10, 0x0a, 0x03, 0x6b, 0x65, 0x79, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09,
+	0x52, 0x03, 0x6b, 0x65, 0x79, 0x12, 0x23, 0x0a, 0x05, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x18, 0x03,
+	0x20, 0x01, 0x28, 0x0b, 0x32, 0x0d, 0x2e, 0x6b, 0x76, 0x73, 0x2e, 0x76, 0x31, 0x2e, 0x53, 0x74,
+	0x61, 0x6d, 0x70, 0x52, 0x05, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x22, 0xbf, 0x01, 0x0a, 0x09, 0x4e,
+	0x61, 0x6d, 0x65, 0x73, 0x70, 0x61, 0x63, 0x65, 0x12, 0x12, 0x0a, 0x04, 0x6e, 0x61, 0x6d, 0x65,
+	0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x6e, 0x61, 0x6d, 0x65, 0x12, 0x20, 0x0a, 0x0b,
+	0x63, 0x6f, 0x6e, 0x73, 0x69, 0x73, 0x74, 0x65, 0x6e, 0x63, 0x79, 0x18, 0x02, 0x20, 0x01, 0x28,
+	0x09, 0x52, 0x0b, 0x63, 0x6f, 0x6e, 0x73, 0x69, 0x73, 0x74, 0x65, 0x6e, 0x63, 0x79, 0x12, 0x19,
+	0x0a, 0x08, 0x6d, 0x61, 0x78, 0x5f, 0x6b, 0x65, 0x79, 0x73, 0x18, 0x03, 0x20, 0x01, 0x28, 0x03,
+	0x52, 0x07, 0x6d, 0x61, 0x78, 0x4b, 0x65, 0x79, 0x73, 0x12, 0x1b, 0x0a, 0x09, 0x6d, 0x61, 0x78,
+	0x5f, 0x62, 0x79, 0x74, 0x65, 0x73, 0x18, 0x04, 0x20, 0x01, 0x28, 0x03, 0x52, 0x08, 0x6d, 0x61,
+	0x78, 0x42, 0x79, 0x74, 0x65, 0x73, 0x12, 0x12, 0x0a, 0x04, 0x6b, 0x65, 0x79, 0x73, 0x18, 0x05,
+	0x20, 0x01, 0x28, 0x03, 0x52, 0x04, 0x6b, 0x65, 0x79, 0x73, 0x12, 0x14, 0x0a, 0x05, 0x62, 0x79,
+	0x74, 0x65, 0x73, 0x18, 0x06, 0x20, 0x01, 0x28, 0x03, 0x52, 0x05, 0x62, 0x79, 0x74, 0x65, 0x73,
+	0x12, 0x1a, 0x0a, 0x08, 0x72, 0x65, 0x76, 0x69, 0x73, 0x69, 0x6f, 0x6e, 0x18, 0x07, 0x20, 0x01,
+	0x28, 0x03, 0x52, 0x08, 0x72, 0x65, 0x76, 0x69, 0x73, 0x69, 0x6f, 0x6e, 0x22, 0x46, 0x0a, 0x09,
+	0x42, 0x61, 0x74, 0x63, 0x68, 0x49, 0x74, 0x65, 0x6d, 0x12, 0x17, 0x0a, 0x07, 0x6f, 0x70, 0x5f,
+	0x74, 0x79, 0x70, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x6f, 0x70, 0x54, 0x79,
+	0x70, 0x65, 0x12, 0x20, 0x0a, 0x04, 0x61, 0x72, 0x67, 0x73, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0b,
+	0x32, 0x0c, 0x2e, 0x6b, 0x76, 0x73, 0x2e, 0x76, 0x31, 0x2e, 0x41, 0x72, 0x67, 0x73, 0x52, 0x04,
+	0x61, 0x72, 0x67, 0x73, 0x22, 0x7b, 0x0a, 0x0b, 0x42, 0x61, 0x74, 0x63, 0x68, 0x52, 0x65, 0x73,
+	0x75, 0x6c, 0x74, 0x12, 0x10, 0x0a, 0x03, 0x6b, 0x65, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09,
+	0x52, 0x03, 0x6b, 0x65, 0x79, 0x12, 0x14, 0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x02,
+	0x20, 0x01, 0x28, 0x0c, 0x52, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x12, 0x18, 0x0a, 0x07, 0x76,
+	0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x18, 0x03, 0x20, 0x01, 0x28, 0x03, 0x52, 0x07, 0x76, 0x65,
+	0x72, 0x73, 0x69, 0x6f, 0x6e, 0x12, 0x14, 0x0a, 0x05, 0x65, 0x72, 0x72, 0x6f, 0x72, 0x18, 0x04,
+	0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x65, 0x72, 0x72, 0x6f, 0x72, 0x12, 0x14, 0x0a, 0x05, 0x66,
+	0x6f, 0x75, 0x6e, 0x64, 0x18, 0x05, 0x20, 0x01, 0x28, 0x08, 0x52, 0x05, 0x66, 0x6f, 0x75, 0x6e,
+	0x64, 0x22, 0xbf, 0x01, 0x0a, 0x05, 0x54, 0x78, 0x6e, 0x4f, 0x70, 0x12, 0x17, 0x0a, 0x07, 0x6f,
+	0x70, 0x5f, 0x74, 0x79, 0x70, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x6f, 0x70,
+	0x54, 0x79, 0x70, 0x65, 0x12, 0x10, 0x0a, 0x03, 0x6b, 0x65, 0x79, 0x18, 0x02, 0x20, 0x01, 0x28,
+	0x09, 0x52, 0x03, 0x6b, 0x65, 0x79, 0x12, 0x14, 0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18,
+	0x03, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x12, 0x29, 0x0a, 0x10,
+	0x65, 0x78, 0x70, 0x65, 0x63, 0x74, 0x65, 0x64, 0x5f, 0x76, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e,
+	0x18, 0x04, 0x20, 0x01, 0x28, 0x03, 0x52, 0x0f, 0x65, 0x78, 0x70, 0x65, 0x63, 0x74, 0x65, 0x64,
+	0x56, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x12, 0x25, 0x0a, 0x0e, 0x65, 0x78, 0x70, 0x65, 0x63,
+	0x74, 0x65, 0x64, 0x5f, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x05, 0x20, 0x01, 0x28, 0x0c, 0x52,
+	0x0d, 0x65, 0x78, 0x70, 0x65, 0x63, 0x74, 0x65, 0x64, 0x56, 0x61, 0x6c, 0x75, 0x65, 0x12, 0x23,
+	0x0a, 0x0d, 0x63, 0x6f, 0x6d, 0x70, 0x61, 0x72, 0x65, 0x5f, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18,
+	0x06, 0x20, 0x01, 0x28, 0x08, 0x52, 0x0c, 0x63, 0x6f, 0x6d, 0x70, 0x61, 0x72, 0x65, 0x56, 0x61,
+	0x6c, 0x75, 0x65, 0x22, 0x63, 0x0a, 0x09, 0x54, 0x78, 0x6e, 0x52, 0x65, 0x73, 0x75, 0x6c, 0x74,
+	0x12, 0x10, 0x0a, 0x03, 0x6b, 0x65, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x6b,
+	0x65, 0x79, 0x12, 0x14, 0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28,
+	0x0c, 0x52, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x12, 0x18, 0x0a, 0x07, 0x76, 0x65, 0x72, 0x73,
+	0x69, 0x6f, 0x6e, 0x18, 0x03, 0x20, 0x01, 0x28, 0x03, 0x52, 0x07, 0x76, 0x65, 0x72, 0x73, 0x69,
+	0x6f, 0x6e, 0x12, 0x14, 0x0a, 0x05, 0x66, 0x6f, 0x75, 0x6e, 0x64, 0x18, 0x04, 0x20, 0x01, 0x28,
+	0x08, 0x52, 0x05, 0x66, 0x6f, 0x75, 0x6e, 0x64, 0x22, 0xdb, 0x05, 0x0a, 0x08, 0x52, 0x65, 0x73,
+	0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x10, 0x0a, 0x03, 0x6b, 0x65, 0x79, 0x18, 0x01, 0x20, 0x01,
+	0x28, 0x09, 0x52, 0x03, 0x6b, 0x65, 0x79, 0x12, 0x14, 0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65,
+	0x18, 0x02, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x12, 0x21, 0x0a,
+	0x0c, 0x69, 0x73, 0x5f, 0x70, 0x72, 0x69, 0x6e, 0x74, 0x61, 0x62, 0x6c, 0x65, 0x18, 0x03, 0x20,
+	0x01, 0x28, 0x08, 0x52, 0x0b, 0x69, 0x73, 0x50, 0x72, 0x69, 0x6e, 0x74, 0x61, 0x62, 0x6c, 0x65,
+	0x12, 0x1f, 0x0a, 0x0b, 0x63, 0x6c, 0x6f, 0x63, 0x6b, 0x5f, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18,
+	0x04, 0x20, 0x01, 0x28, 0x03, 0x52, 0x0a, 0x63, 0x6c, 0x6f, 0x63, 0x6b, 0x56, 0x61, 0x6c, 0x75,
+	0x65, 0x12, 0x21, 0x0a, 0x0c, 0x63, 0x6c, 0x6f, 0x63, 0x6b, 0x5f, 0x76, 0x65, 0x63, 0x74, 0x6f,
+	0x72, 0x18, 0x05, 0x20, 0x03, 0x28, 0x03, 0x52, 0x0b, 0x63, 0x6c, 0x6f, 0x63, 0x6b, 0x56, 0x65,
+	0x63, 0x74, 0x6f, 0x72, 0x12, 0x18, 0x0a, 0x07, 0x76, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x18,
+	0x06, 0x20, 0x01, 0x28, 0x03, 0x52, 0x07, 0x76, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x12, 0x1c,
+	0x0a, 0x09, 0x73, 0x75, 0x63, 0x63, 0x65, 0x65, 0x64, 0x65, 0x64, 0x18, 0x07, 0x20, 0x01, 0x28,
+	0x08, 0x52, 0x09, 0x73, 0x75, 0x63, 0x63, 0x65, 0x65, 0x64, 0x65, 0x64, 0x12, 0x32, 0x0a, 0x0b,
+	0x74, 0x78, 0x6e, 0x5f, 0x72, 0x65, 0x73, 0x75, 0x6c, 0x74, 0x73, 0x18, 0x08, 0x20, 0x03, 0x28,
+	0x0b, 0x32, 0x11, 0x2e, 0x6b, 0x76, 0x73, 0x2e, 0x76, 0x31, 0x2e, 0x54, 0x78, 0x6e, 0x52, 0x65,
+	0x73, 0x75, 0x6c, 0x74, 0x52, 0x0a, 0x74, 0x78, 0x6e, 0x52, 0x65, 0x73, 0x75, 0x6c, 0x74, 0x73,
+	0x12, 0x38, 0x0a, 0x0d, 0x62, 0x61, 0x74, 0x63, 0x68, 0x5f, 0x72, 0x65, 0x73, 0x75, 0x6c, 0x74,
+	0x73, 0x18, 0x09, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x13, 0x2e, 0x6b, 0x76, 0x73, 0x2e, 0x76, 0x31,
+	0x2e, 0x42, 0x61, 0x74, 0x63, 0x68, 0x52, 0x65, 0x73, 0x75, 0x6c, 0x74, 0x52, 0x0c, 0x62, 0x61,
+	0x74, 0x63, 0x68, 0x52, 0x65, 0x73, 0x75, 0x6c, 0x74, 0x73, 0x12, 0x35, 0x0a, 0x0c, 0x73, 0x63,
+	0x61, 0x6e, 0x5f, 0x72, 0x65, 0x73, 0x75, 0x6c, 0x74, 0x73, 0x18, 0x0a, 0x20, 0x03, 0x28, 0x0b,
+	0x32, 0x12, 0x2e, 0x6b, 0x76, 0x73, 0x2e, 0x76, 0x31, 0x2e, 0x53, 0x63, 0x61, 0x6e, 0x52, 0x65,
+	0x73, 0x75, 0x6c, 0x74, 0x52, 0x0b, 0x73, 0x63, 0x61, 0x6e, 0x52, 0x65, 0x73, 0x75, 0x6c, 0x74,
+	0x73, 0x12, 0x16, 0x0a, 0x06, 0x63, 0x75, 0x72, 0x73, 0x6f, 0x72, 0x18, 0x0b, 0x20, 0x01, 0x28,
+	0x09, 0x52, 0x06, 0x63, 0x75, 0x72, 0x73, 0x6f, 0x72, 0x12, 0x14, 0x0a, 0x05, 0x66, 0x6f, 0x75,
+	0x6e, 0x64, 0x18, 0x0c, 0x20, 0x01, 0x28, 0x08, 0x52, 0x05, 0x66, 0x6f, 0x75, 0x6e, 0x64, 0x12,
+	0x31, 0x0a, 0x0a, 0x6e, 0x61, 0x6d, 0x65, 0x73, 0x70, 0x61, 0x63, 0x65, 0x73, 0x18, 0x0d, 0x20,
+	0x03, 0x28, 0x0b, 0x32, 0x11, 0x2e, 0x6b, 0x76, 0x73, 0x2e, 0x76, 0x31, 0x2e, 0x4e, 0x61, 0x6d,
+	0x65, 0x73, 0x70, 0x61, 0x63, 0x65, 0x52, 0x0a, 0x6e, 0x61, 0x6d, 0x65, 0x73, 0x70, 0x61, 0x63,
+	0x65, 0x73, 0x12, 0x25, 0x0a, 0x04, 0x63, 0x72, 0x64, 0x74, 0x18, 0x0e, 0x20, 0x01, 0x28, 0x0b,
+	0x32, 0x11, 0x2e, 0x6b, 0x76, 0x73, 0x2e, 0x76, 0x31, 0x2e, 0x43, 0x52, 0x44, 0x54, 0x56, 0x61,
+	0x6c, 0x75, 0x65, 0x52, 0x04, 0x63, 0x72, 0x64, 0x74, 0x12, 0x2b, 0x0a, 0x08, 0x73, 0x69, 0x62,
+	0x6c, 0x69, 0x6e, 0x67, 0x73, 0x18, 0x0f, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x0f, 0x2e, 0x6b, 0x76,
+	0x73, 0x2e, 0x76, 0x31, 0x2e, 0x53, 0x69, 0x62, 0x6c, 0x69, 0x6e, 0x67, 0x52, 0x08, 0x73, 0x69,
+	0x62, 0x6c, 0x69, 0x6e, 0x67, 0x73, 0x12, 0x18, 0x0a, 0x07, 0x63, 0x6f, 0x6e, 0x74, 0x65, 0x78,
+	0x74, 0x18, 0x10, 0x20, 0x03, 0x28, 0x03, 0x52, 0x07, 0x63, 0x6f, 0x6e, 0x74, 0x65, 0x78, 0x74,
+	0x12, 0x23, 0x0a, 0x0d, 0x73, 0x65, 0x73, 0x73, 0x69, 0x6f, 0x6e, 0x5f, 0x63, 0x6c, 0x6f, 0x63,
+	0x6b, 0x18, 0x11, 0x20, 0x03, 0x28, 0x03, 0x52, 0x0c, 0x73, 0x65, 0x73, 0x73, 0x69, 0x6f, 0x6e,
+	0x43, 0x6c, 0x6f, 0x63, 0x6b, 0x12, 0x23, 0x0a, 0x05, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x18, 0x12,
+	0x20, 0x01, 0x28, 0x0b, 0x32, 0x0d, 0x2e, 0x6b, 0x76, 0x73, 0x2e, 0x76, 0x31, 0x2e, 0x53, 0x74,
+	0x61, 0x6d, 0x70, 0x52, 0x05, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x12, 0x36, 0x0a, 0x0c, 0x64, 0x65,
+	0x70, 0x65, 0x6e, 0x64, 0x65, 0x6e, 0x63, 0x69, 0x65, 0x73, 0x18, 0x13, 0x20, 0x03, 0x28, 0x0b,
+	0x32, 0x12, 0x2e, 0x6b, 0x76, 0x73, 0x2e, 0x76, 0x31, 0x2e, 0x44, 0x65, 0x70, 0x65, 0x6e, 0x64,
+	0x65, 0x6e, 0x63, 0x79, 0x52, 0x0c, 0x64, 0x65, 0x70, 0x65, 0x6e, 0x64, 0x65, 0x6e, 0x63, 0x69,
+	0x65, 0x73, 0x12, 0x12, 0x0a, 0x04, 0x76, 0x69, 0x65, 0x77, 0x18, 0x14, 0x20, 0x01, 0x28, 0x03,
+	0x52, 0x04, 0x76, 0x69, 0x65, 0x77, 0x22, 0x42, 0x0a, 0x07, 0x53, 0x69, 0x62, 0x6c, 0x69, 0x6e,
+	0x67, 0x12, 0x14, 0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0c,
+	0x52, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x12, 0x21, 0x0a, 0x0c, 0x63, 0x6c, 0x6f, 0x63, 0x6b,
+	0x5f, 0x76, 0x65, 0x63, 0x74, 0x6f, 0x72, 0x18, 0x02, 0x20, 0x03, 0x28, 0x03, 0x52, 0x0b, 0x63,
+	0x6c, 0x6f, 0x63, 0x6b, 0x56, 0x65, 0x63, 0x74, 0x6f, 0x72, 0x22, 0x51, 0x0a, 0x09, 0x43, 0x52,
+	0x44, 0x54, 0x56, 0x61, 0x6c, 0x75, 0x65, 0x12, 0x12, 0x0a, 0x04, 0x74, 0x79, 0x70, 0x65, 0x18,
+	0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x74, 0x79, 0x70, 0x65, 0x12, 0x18, 0x0a, 0x07, 0x63,
+	0x6f, 0x75, 0x6e, 0x74, 0x65, 0x72, 0x18, 0x02, 0x20, 0x01, 0x28, 0x03, 0x52, 0x07, 0x63, 0x6f,
+	0x75, 0x6e, 0x74, 0x65, 0x72, 0x12, 0x16, 0x0a, 0x06, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x73, 0x18,
+	0x03, 0x20, 0x03, 0x28, 0x0c, 0x52, 0x06, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x73, 0x22, 0x4e, 0x0a,
+	0x0a, 0x53, 0x63, 0x61, 0x6e, 0x52, 0x65, 0x73, 0x75, 0x6c, 0x74, 0x12, 0x10, 0x0a, 0x03, 0x6b,
+	0x65, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x6b, 0x65, 0x79, 0x12, 0x14, 0x0a,
+	0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x05, 0x76, 0x61,
+	0x6c, 0x75, 0x65, 0x12, 0x18, 0x0a, 0x07, 0x76, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x18, 0x03,
+	0x20, 0x01, 0x28, 0x03, 0x52, 0x07, 0x76, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x22, 0x8d, 0x02,
+	0x0a, 0x0c, 0x57, 0x61, 0x74, 0x63, 0x68, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x10,
+	0x0a, 0x03, 0x6b, 0x65, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x6b, 0x65, 0x79,
+	0x12, 0x16, 0x0a, 0x06, 0x70, 0x72, 0x65, 0x66, 0x69, 0x78, 0x18, 0x02, 0x20, 0x01, 0x28, 0x08,
+	0x52, 0x06, 0x70, 0x72, 0x65, 0x66, 0x69, 0x78, 0x12, 0x25, 0x0a, 0x0e, 0x61, 0x66, 0x74, 0x65,
+	0x72, 0x5f, 0x72, 0x65, 0x76, 0x69, 0x73, 0x69, 0x6f, 0x6e, 0x18, 0x03, 0x20, 0x01, 0x28, 0x03,
+	0x52, 0x0d, 0x61, 0x66, 0x74, 0x65, 0x72, 0x52, 0x65, 0x76, 0x69, 0x73, 0x69, 0x6f, 0x6e, 0x12,
+	0x1f, 0x0a, 0x0b, 0x61, 0x66, 0x74, 0x65, 0x72, 0x5f, 0x63, 0x6c, 0x6f, 0x63, 0x6b, 0x18, 0x04,
+	0x20, 0x01, 0x28, 0x03, 0x52, 0x0a, 0x61, 0x66, 0x74, 0x65, 0x72, 0x43, 0x6c, 0x6f, 0x63, 0x6b,
+	0x12, 0x21, 0x0a, 0x0c, 0x61, 0x66, 0x74, 0x65, 0x72, 0x5f, 0x76, 0x65, 0x63, 0x74, 0x6f, 0x72,
+	0x18, 0x05, 0x20, 0x03, 0x28, 0x03, 0x52, 0x0b, 0x61, 0x66, 0x74, 0x65, 0x72, 0x56, 0x65, 0x63,
+	0x74, 0x6f, 0x72, 0x12, 0x1c, 0x0a, 0x09, 0x6e, 0x61, 0x6d, 0x65, 0x73, 0x70, 0x61, 0x63, 0x65,
+	0x18, 0x06, 0x20, 0x01, 0x28, 0x09, 0x52, 0x09, 0x6e, 0x61, 0x6d, 0x65, 0x73, 0x70, 0x61, 0x63,
+	0x65, 0x12, 0x23, 0x0a, 0x0d, 0x61, 0x66, 0x74, 0x65, 0x72, 0x5f, 0x6d, 0x65, 0x73, 0x73, 0x61,
+	0x67, 0x65, 0x18, 0x07, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0c, 0x61, 0x66, 0x74, 0x65, 0x72, 0x4d,
+	0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x12, 0x25, 0x0a, 0x0e, 0x61, 0x66, 0x74, 0x65, 0x72, 0x5f,
+	0x70, 0x6f, 0x73, 0x69, 0x74, 0x69, 0x6f, 0x6e, 0x18, 0x08, 0x20, 0x01, 0x28, 0x03, 0x52, 0x0d,
+	0x61, 0x66, 0x74, 0x65, 0x72, 0x50, 0x6f, 0x73, 0x69, 0x74, 0x69, 0x6f, 0x6e, 0x22, 0x9b, 0x02,
+	0x0a, 0x0a, 0x57, 0x61, 0x74, 0x63, 0x68, 0x45, 0x76, 0x65, 0x6e, 0x74, 0x12, 0x1a, 0x0a, 0x08,
+	0x72, 0x65, 0x76, 0x69, 0x73, 0x69, 0x6f, 0x6e, 0x18, 0x01, 0x20, 0x01, 0x28, 0x03, 0x52, 0x08,
+	0x72, 0x65, 0x76, 0x69, 0x73, 0x69, 0x6f, 0x6e, 0x12, 0x17, 0x0a, 0x07, 0x6f, 0x70, 0x5f, 0x74,
+	0x79, 0x70, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x6f, 0x70, 0x54, 0x79, 0x70,
+	0x65, 0x12, 0x10, 0x0a, 0x03, 0x6b, 0x65, 0x79, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03,
+	0x6b, 0x65, 0x79, 0x12, 0x14, 0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x04, 0x20, 0x01,
+	0x28, 0x0c, 0x52, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x12, 0x18, 0x0a, 0x07, 0x76, 0x65, 0x72,
+	0x73, 0x69, 0x6f, 0x6e, 0x18, 0x05, 0x20, 0x01, 0x28, 0x03, 0x52, 0x07, 0x76, 0x65, 0x72, 0x73,
+	0x69, 0x6f, 0x6e, 0x12, 0x1f, 0x0a, 0x0b, 0x63, 0x6c, 0x6f, 0x63, 0x6b, 0x5f, 0x76, 0x61, 0x6c,
+	0x75, 0x65, 0x18, 0x06, 0x20, 0x01, 0x28, 0x03, 0x52, 0x0a, 0x63, 0x6c, 0x6f, 0x63, 0x6b, 0x56,
+	0x61, 0x6c, 0x75, 0x65, 0x12, 0x21, 0x0a, 0x0c, 0x63, 0x6c, 0x6f, 0x63, 0x6b, 0x5f, 0x76, 0x65,
+	0x63, 0x74, 0x6f, 0x72, 0x18, 0x07, 0x20, 0x03, 0x28, 0x03, 0x52, 0x0b, 0x63, 0x6c, 0x6f, 0x63,
+	0x6b, 0x56, 0x65, 0x63, 0x74, 0x6f, 0x72, 0x12, 0x1c, 0x0a, 0x09, 0x6e, 0x61, 0x6d, 0x65, 0x73,
+	0x70, 0x61, 0x63, 0x65, 0x18, 0x08, 0x20, 0x01, 0x28, 0x09, 0x52, 0x09, 0x6e, 0x61, 0x6d, 0x65,
+	0x73, 0x70, 0x61, 0x63, 0x65, 0x12, 0x18, 0x0a, 0x07, 0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65,
+	0x18, 0x09, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x12,
+	0x1a, 0x0a, 0x08, 0x70, 0x6f, 0x73, 0x69, 0x74, 0x69, 0x6f, 0x6e, 0x18, 0x0a, 0x20, 0x01, 0x28,
+	0x03, 0x52, 0x08, 0x70, 0x6f, 0x73, 0x69, 0x74, 0x69, 0x6f, 0x6e, 0x22, 0x93, 0x02, 0x0a, 0x0e,
+	0x52, 0x65, 0x70, 0x6c, 0x69, 0x63, 0x61, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x12, 0x20,
+	0x0a, 0x04, 0x61, 0x72, 0x67, 0x73, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x0c, 0x2e, 0x6b,
+	0x76, 0x73, 0x2e, 0x76, 0x31, 0x2e, 0x41, 0x72, 0x67, 0x73, 0x52, 0x04, 0x61, 0x72, 0x67, 0x73,
+	0x12, 0x12, 0x0a, 0x04, 0x75, 0x75, 0x69, 0x64, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04,
+	0x75, 0x75, 0x69, 0x64, 0x12, 0x21, 0x0a, 0x0c, 0x73, 0x65, 0x72, 0x76, 0x65, 0x72, 0x5f, 0x69,
+	0x6e, 0x64, 0x65, 0x78, 0x18, 0x03, 0x20, 0x01, 0x28, 0x03, 0x52, 0x0b, 0x73, 0x65, 0x72, 0x76,
+	0x65, 0x72, 0x49, 0x6e, 0x64, 0x65, 0x78, 0x12, 0x17, 0x0a, 0x07, 0x6f, 0x70, 0x5f, 0x74, 0x79,
+	0x70, 0x65, 0x18, 0x04, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x6f, 0x70, 0x54, 0x79, 0x70, 0x65,
+	0x12, 0x1f, 0x0a, 0x0b, 0x63, 0x6c, 0x6f, 0x63, 0x6b, 0x5f, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18,
+	0x05, 0x20, 0x01, 0x28, 0x03, 0x52, 0x0a, 0x63, 0x6c, 0x6f, 0x63, 0x6b, 0x56, 0x61, 0x6c, 0x75,
+	0x65, 0x12, 0x2c, 0x0a, 0x12, 0x73, 0x65, 0x72, 0x76, 0x65, 0x72, 0x5f, 0x6d, 0x73, 0x67, 0x5f,
+	0x63, 0x6f, 0x75, 0x6e, 0x74, 0x65, 0x72, 0x18, 0x06, 0x20, 0x01, 0x28, 0x03, 0x52, 0x10, 0x73,
+	0x65, 0x72, 0x76, 0x65, 0x72, 0x4d, 0x73, 0x67, 0x43, 0x6f, 0x75, 0x6e, 0x74, 0x65, 0x72, 0x12,
+	0x21, 0x0a, 0x0c, 0x63, 0x6c, 0x6f, 0x63, 0x6b, 0x5f, 0x76, 0x65, 0x63, 0x74, 0x6f, 0x72, 0x18,
+	0x07, 0x20, 0x03, 0x28, 0x03, 0x52, 0x0b, 0x63, 0x6c, 0x6f, 0x63, 0x6b, 0x56, 0x65, 0x63, 0x74,
+	0x6f, 0x72, 0x12, 0x1d, 0x0a, 0x0a, 0x66, 0x69, 0x66, 0x6f, 0x5f, 0x69, 0x6e, 0x64, 0x65, 0x78,
+	0x18, 0x08, 0x20, 0x01, 0x28, 0x03, 0x52, 0x09, 0x66, 0x69, 0x66, 0x6f, 0x49, 0x6e, 0x64, 0x65,
+	0x78, 0x22, 0x65, 0x0a, 0x0b, 0x55, 0x70, 0x64, 0x61, 0x74, 0x65, 0x52, 0x65, 0x70, 0x6c, 0x79,
+	0x12, 0x12, 0x0a, 0x04, 0x75, 0x75, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04,
+	0x75, 0x75, 0x69, 0x64, 0x12, 0x2c, 0x0a, 0x08, 0x72, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65,
+	0x18, 0x02, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x10, 0x2e, 0x6b, 0x76, 0x73, 0x2e, 0x76, 0x31, 0x2e,
+	0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x52, 0x08, 0x72, 0x65, 0x73, 0x70, 0x6f, 0x6e,
+	0x73, 0x65, 0x12, 0x14, 0x0a, 0x05, 0x65, 0x72, 0x72, 0x6f, 0x72, 0x18, 0x03, 0x20, 0x01, 0x28,
+	0x09, 0x52, 0x05, 0x65, 0x72, 0x72, 0x6f, 0x72, 0x22, 0xf3, 0x02, 0x0a, 0x12, 0x52, 0x65, 0x70,
+	0x6c, 0x69, 0x63, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x12,
+	0x1a, 0x0a, 0x08, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x63, 0x6f, 0x6c, 0x18, 0x01, 0x20, 0x01, 0x28,
+	0x09, 0x52, 0x08, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x63, 0x6f, 0x6c, 0x12, 0x20, 0x0a, 0x04, 0x61,
+	0x72, 0x67, 0x73, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x0c, 0x2e, 0x6b, 0x76, 0x73, 0x2e,
+	0x76, 0x31, 0x2e, 0x41, 0x72, 0x67, 0x73, 0x52, 0x04, 0x61, 0x72, 0x67, 0x73, 0x12, 0x12, 0x0a,
+	0x04, 0x75, 0x75, 0x69, 0x64, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x75, 0x75, 0x69,
+	0x64, 0x12, 0x21, 0x0a, 0x0c, 0x73, 0x65, 0x72, 0x76, 0x65, 0x72, 0x5f, 0x69, 0x6e, 0x64, 0x65,
+	0x78, 0x18, 0x04, 0x20, 0x01, 0x28, 0x03, 0x52, 0x0b, 0x73, 0x65, 0x72, 0x76, 0x65, 0x72, 0x49,
+	0x6e, 0x64, 0x65, 0x78, 0x12, 0x17, 0x0a, 0x07, 0x6f, 0x70, 0x5f, 0x74, 0x79, 0x70, 0x65, 0x18,
+	0x05, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x6f, 0x70, 0x54, 0x79, 0x70, 0x65, 0x12, 0x23, 0x0a,
+	0x05, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x18, 0x06, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x0d, 0x2e, 0x6b,
+	0x76, 0x73, 0x2e, 0x76, 0x31, 0x2e, 0x53, 0x74, 0x61, 0x6d, 0x70, 0x52, 0x05, 0x73, 0x74, 0x61,
+	0x6d, 0x70, 0x12, 0x36, 0x0a, 0x0c, 0x64, 0x65, 0x70, 0x65, 0x6e, 0x64, 0x65, 0x6e, 0x63, 0x69,
+	0x65, 0x73, 0x18, 0x07, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x12, 0x2e, 0x6b, 0x76, 0x73, 0x2e, 0x76,
+	0x31, 0x2e, 0x44, 0x65, 0x70, 0x65, 0x6e, 0x64, 0x65, 0x6e, 0x63, 0x79, 0x52, 0x0c, 0x64, 0x65,
+	0x70, 0x65, 0x6e, 0x64, 0x65, 0x6e, 0x63, 0x69, 0x65, 0x73, 0x12, 0x12, 0x0a, 0x04, 0x76, 0x69,
+	0x65, 0x77, 0x18, 0x08, 0x20, 0x01, 0x28, 0x03, 0x52, 0x04, 0x76, 0x69, 0x65, 0x77, 0x12, 0x1a,
+	0x0a, 0x08, 0x73, 0x65, 0x71, 0x75, 0x65, 0x6e, 0x63, 0x65, 0x18, 0x09, 0x20, 0x01, 0x28, 0x03,
+	0x52, 0x08, 0x73, 0x65, 0x71, 0x75, 0x65, 0x6e, 0x63, 0x65, 0x12, 0x2c, 0x0a, 0x05, 0x73, 0x74,
+	0x61, 0x74, 0x65, 0x18, 0x0a, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x16, 0x2e, 0x6b, 0x76, 0x73, 0x2e,
+	0x76, 0x31, 0x2e, 0x4e, 0x61, 0x6d, 0x65, 0x73, 0x70, 0x61, 0x63, 0x65, 0x53, 0x74, 0x61, 0x74,
+	0x65, 0x52, 0x05, 0x73, 0x74, 0x61, 0x74, 0x65, 0x12, 0x14, 0x0a, 0x05, 0x63, 0x68, 0x61, 0x69,
+	0x6e, 0x18, 0x0b, 0x20, 0x03, 0x28, 0x03, 0x52, 0x05, 0x63, 0x68, 0x61, 0x69, 0x6e, 0x22, 0x69,
+	0x0a, 0x0e, 0x4e, 0x61, 0x6d, 0x65, 0x73, 0x70, 0x61, 0x63, 0x65, 0x53, 0x74, 0x61, 0x74, 0x65,
+	0x12, 0x2f, 0x0a, 0x09, 0x6e, 0x61, 0x6d, 0x65, 0x73, 0x70, 0x61, 0x63, 0x65, 0x18, 0x01, 0x20,
+	0x01, 0x28, 0x0b, 0x32, 0x11, 0x2e, 0x6b, 0x76, 0x73, 0x2e, 0x76, 0x31, 0x2e, 0x4e, 0x61, 0x6d,
+	0x65, 0x73, 0x70, 0x61, 0x63, 0x65, 0x52, 0x09, 0x6e, 0x61, 0x6d, 0x65, 0x73, 0x70, 0x61, 0x63,
+	0x65, 0x12, 0x26, 0x0a, 0x04, 0x6b, 0x65, 0x79, 0x73, 0x18, 0x02, 0x20, 0x03, 0x28, 0x0b, 0x32,
+	0x12, 0x2e, 0x6b, 0x76, 0x73, 0x2e, 0x76, 0x31, 0x2e, 0x53, 0x63, 0x61, 0x6e, 0x52, 0x65, 0x73,
+	0x75, 0x6c, 0x74, 0x52, 0x04, 0x6b, 0x65, 0x79, 0x73, 0x22, 0x2b, 0x0a, 0x0c, 0x4c, 0x65, 0x61,
+	0x76, 0x65, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x1b, 0x0a, 0x09, 0x73, 0x65, 0x72,
+	0x76, 0x65, 0x72, 0x5f, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x08, 0x73, 0x65,
+	0x72, 0x76, 0x65, 0x72, 0x49, 0x64, 0x22, 0x07, 0x0a, 0x05, 0x45, 0x6d, 0x70, 0x74, 0x79, 0x32,
+	0xd1, 0x05, 0x0a, 0x08, 0x4b, 0x65, 0x79, 0x56, 0x61, 0x6c, 0x75, 0x65, 0x12, 0x25, 0x0a, 0x03,
+	0x47, 0x65, 0x74, 0x12, 0x0c, 0x2e, 0x6b, 0x76, 0x73, 0x2e, 0x76, 0x31, 0x2e, 0x41, 0x72, 0x67,
+	0x73, 0x1a, 0x10, 0x2e, 0x6b, 0x76, 0x73, 0x2e, 0x76, 0x31, 0x2e, 0x52, 0x65, 0x73, 0x70, 0x6f,
+	0x6e, 0x73, 0x65, 0x12, 0x25, 0x0a, 0x03, 0x50, 0x75, 0x74, 0x12, 0x0c, 0x2e, 0x6b, 0x76, 0x73,
+	0x2e, 0x76, 0x31, 0x2e, 0x41, 0x72, 0x67, 0x73, 0x1a, 0x10, 0x2e, 0x6b, 0x76, 0x73, 0x2e, 0x76,
+	0x31, 0x2e, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x28, 0x0a, 0x06, 0x44, 0x65,
+	0x6c, 0x65, 0x74, 0x65, 0x12, 0x0c, 0x2e, 0x6b, 0x76, 0x73, 0x2e, 0x76, 0x31, 0x2e, 0x41, 0x72,
+	0x67, 0x73, 0x1a, 0x10, 0x2e, 0x6b, 0x76, 0x73, 0x2e, 0x76, 0x31, 0x2e, 0x52, 0x65, 0x73, 0x70,
+	0x6f, 0x6e, 0x73, 0x65, 0x12, 0x2b, 0x0a, 0x09, 0x49, 0x6e, 0x63, 0x72, 0x65, 0x6d, 0x65, 0x6e,
+	0x74, 0x12, 0x0c, 0x2e, 0x6b, 0x76, 0x73, 0x2e, 0x76, 0x31, 0x2e, 0x41, 0x72, 0x67, 0x73, 0x1a,
+	0x10, 0x2e, 0x6b, 0x76, 0x73, 0x2e, 0x76, 0x31, 0x2e, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73,
+	0x65, 0x12, 0x28, 0x0a, 0x06, 0x41, 0x70, 0x70, 0x65, 0x6e, 0x64, 0x12, 0x0c, 0x2e, 0x6b, 0x76,
+	0x73, 0x2e, 0x76, 0x31, 0x2e, 0x41, 0x72, 0x67, 0x73, 0x1a, 0x10, 0x2e, 0x6b, 0x76, 0x73, 0x2e,
+	0x76, 0x31, 0x2e, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x2c, 0x0a, 0x0a, 0x55,
+	0x70, 0x64, 0x61, 0x74, 0x65, 0x43, 0x52, 0x44, 0x54, 0x12, 0x0c, 0x2e, 0x6b, 0x76, 0x73, 0x2e,
+	0x76, 0x31, 0x2e, 0x41, 0x72, 0x67, 0x73, 0x1a, 0x10, 0x2e, 0x6b, 0x76, 0x73, 0x2e, 0x76, 0x31,
+	0x2e, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x30, 0x0a, 0x0e, 0x43, 0x6f, 0x6d,
+	0x70, 0x61, 0x72, 0x65, 0x41, 0x6e, 0x64, 0x53, 0x77, 0x61, 0x70, 0x12, 0x0c, 0x2e, 0x6b, 0x76,
+	0x73, 0x2e, 0x76, 0x31, 0x2e, 0x41, 0x72, 0x67, 0x73, 0x1a, 0x10, 0x2e, 0x6b, 0x76, 0x73, 0x2e,
+	0x76, 0x31, 0x2e, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x25, 0x0a, 0x03, 0x54,
+	0x78, 0x6e, 0x12, 0x0c, 0x2e, 0x6b, 0x76, 0x73, 0x2e, 0x76, 0x31, 0x2e, 0x41, 0x72, 0x67, 0x73,
+	0x1a, 0x10, 0x2e, 0x6b, 0x76, 0x73, 0x2e, 0x76, 0x31, 0x2e, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e,
+	0x73, 0x65, 0x12, 0x27, 0x0a, 0x05, 0x42, 0x61, 0x74, 0x63, 0x68, 0x12, 0x0c, 0x2e, 0x6b, 0x76,
+	0x73, 0x2e, 0x76, 0x31, 0x2e, 0x41, 0x72, 0x67, 0x73, 0x1a, 0x10, 0x2e, 0x6b, 0x76, 0x73, 0x2e,
+	0x76, 0x31, 0x2e, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x26, 0x0a, 0x04, 0x53,
+	0x63, 0x61, 0x6e, 0x12, 0x0c, 0x2e, 0x6b, 0x76, 0x73, 0x2e, 0x76, 0x31, 0x2e, 0x41, 0x72, 0x67,
+	0x73, 0x1a, 0x10, 0x2e, 0x6b, 0x76, 0x73, 0x2e, 0x76, 0x31, 0x2e, 0x52, 0x65, 0x73, 0x70, 0x6f,
+	0x6e, 0x73, 0x65, 0x12, 0x2c, 0x0a, 0x0a, 0x53, 0x63, 0x61, 0x6e, 0x50, 0x72, 0x65, 0x66, 0x69,
+	0x78, 0x12, 0x0c, 0x2e, 0x6b, 0x76, 0x73, 0x2e, 0x76, 0x31, 0x2e, 0x41, 0x72, 0x67, 0x73, 0x1a,
+	0x10, 0x2e, 0x6b, 0x76, 0x73, 0x2e, 0x76, 0x31, 0x2e, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73,
+	0x65, 0x12, 0x25, 0x0a, 0x03, 0x45, 0x6e, 0x64, 0x12, 0x0c, 0x2e, 0x6b, 0x76, 0x73, 0x2e, 0x76,
+	0x31, 0x2e, 0x41, 0x72, 0x67, 0x73, 0x1a, 0x10, 0x2e, 0x6b, 0x76, 0x73, 0x2e, 0x76, 0x31, 0x2e,
+	0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x31, 0x0a, 0x0f, 0x43, 0x72, 0x65, 0x61,
+	0x74, 0x65, 0x4e, 0x61, 0x6d, 0x65, 0x73, 0x70, 0x61, 0x63, 0x65, 0x12, 0x0c, 0x2e, 0x6b, 0x76,
+	0x73, 0x2e, 0x76, 0x31, 0x2e, 0x41, 0x72, 0x67, 0x73, 0x1a, 0x10, 0x2e, 0x6b, 0x76, 0x73, 0x2e,
+	0x76, 0x31, 0x2e, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x2f, 0x0a, 0x0d, 0x44,
+	0x72, 0x6f, 0x70, 0x4e, 0x61, 0x6d, 0x65, 0x73, 0x70, 0x61, 0x63, 0x65, 0x12, 0x0c, 0x2e, 0x6b,
+	0x76, 0x73, 0x2e, 0x76, 0x31, 0x2e, 0x41, 0x72, 0x67, 0x73, 0x1a, 0x10, 0x2e, 0x6b, 0x76, 0x73,
+	0x2e, 0x76, 0x31, 0x2e, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x30, 0x0a, 0x0e,
+	0x4c, 0x69, 0x73, 0x74, 0x4e, 0x61, 0x6d, 0x65, 0x73, 0x70, 0x61, 0x63, 0x65, 0x73, 0x12, 0x0c,
+	0x2e, 0x6b, 0x76, 0x73, 0x2e, 0x76, 0x31, 0x2e, 0x41, 0x72, 0x67, 0x73, 0x1a, 0x10, 0x2e, 0x6b,
+	0x76, 0x73, 0x2e, 0x76, 0x31, 0x2e, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x33,
+	0x0a, 0x05, 0x57, 0x61, 0x74, 0x63, 0x68, 0x12, 0x14, 0x2e, 0x6b, 0x76, 0x73, 0x2e, 0x76, 0x31,
+	0x2e, 0x57, 0x61, 0x74, 0x63, 0x68, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x12, 0x2e,
+	0x6b, 0x76, 0x73, 0x2e, 0x76, 0x31, 0x2e, 0x57, 0x61, 0x74, 0x63, 0x68, 0x45, 0x76, 0x65, 0x6e,
+	0x74, 0x30, 0x01, 0x32, 0xeb, 0x01, 0x0a, 0x07, 0x52, 0x65, 0x70, 0x6c, 0x69, 0x63, 0x61, 0x12,
+	0x3c, 0x0a, 0x09, 0x4d, 0x75, 0x6c, 0x74, 0x69, 0x63, 0x61, 0x73, 0x74, 0x12, 0x16, 0x2e, 0x6b,
+	0x76, 0x73, 0x2e, 0x76, 0x31, 0x2e, 0x52, 0x65, 0x70, 0x6c, 0x69, 0x63, 0x61, 0x4d, 0x65, 0x73,
+	0x73, 0x61, 0x67, 0x65, 0x1a, 0x13, 0x2e, 0x6b, 0x76, 0x73, 0x2e, 0x76, 0x31, 0x2e, 0x55, 0x70,
+	0x64, 0x61, 0x74, 0x65, 0x52, 0x65, 0x70, 0x6c, 0x79, 0x28, 0x01, 0x30, 0x01, 0x12, 0x33, 0x0a,
+	0x0a, 0x52, 0x65, 0x63, 0x65, 0x69, 0x76, 0x65, 0x41, 0x63, 0x6b, 0x12, 0x16, 0x2e, 0x6b, 0x76,
+	0x73, 0x2e, 0x76, 0x31, 0x2e, 0x52, 0x65, 0x70, 0x6c, 0x69, 0x63, 0x61, 0x4d, 0x65, 0x73, 0x73,
+	0x61, 0x67, 0x65, 0x1a, 0x0d, 0x2e, 0x6b, 0x76, 0x73, 0x2e, 0x76, 0x31, 0x2e, 0x45, 0x6d, 0x70,
+	0x74, 0x79, 0x12, 0x32, 0x0a, 0x0b, 0x50, 0x65, 0x65, 0x72, 0x4c, 0x65, 0x61, 0x76, 0x69, 0x6e,
+	0x67, 0x12, 0x14, 0x2e, 0x6b, 0x76, 0x73, 0x2e, 0x76, 0x31, 0x2e, 0x4c, 0x65, 0x61, 0x76, 0x65,
+	0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x0d, 0x2e, 0x6b, 0x76, 0x73, 0x2e, 0x76, 0x31,
+	0x2e, 0x45, 0x6d, 0x70, 0x74, 0x79, 0x12, 0x39, 0x0a, 0x09, 0x52, 0x65, 0x70, 0x6c, 0x69, 0x63,
+	0x61, 0x74, 0x65, 0x12, 0x1a, 0x2e, 0x6b, 0x76, 0x73, 0x2e, 0x76, 0x31, 0x2e, 0x52, 0x65, 0x70,
+	0x6c, 0x69, 0x63, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x1a,
+	0x10, 0x2e, 0x6b, 0x76, 0x73, 0x2e, 0x76, 0x31, 0x2e, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73,
+	0x65, 0x42, 0x11, 0x5a, 0x0f, 0x53, 0x44, 0x43, 0x43, 0x2f, 0x6d, 0x61, 0x69, 0x6e, 0x2f, 0x6b,
+	0x76, 0x73, 0x70, 0x62, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
+}
+
+var (
+	file_kvs_proto_rawDescOnce sync.Once
+	file_kvs_proto_rawDescData = file_kvs_proto_rawDesc
+)
+
+func file_kvs_proto_rawDescGZIP() []byte {
+	file_kvs_proto_rawDescOnce.Do(func() {
+		file_kvs_proto_rawDescData = protoimpl.X.CompressGZIP(file_kvs_proto_rawDescData)
+	})
+	return file_kvs_proto_rawDescData
+}
+
+var file_kvs_proto_msgTypes = make([]protoimpl.MessageInfo, 20)
+var file_kvs_proto_goTypes = []any{
+	(*Args)(nil),               // 0: kvs.v1.Args
+	(*Stamp)(nil),              // 1: kvs.v1.Stamp
+	(*Dependency)(nil),         // 2: kvs.v1.Dependency
+	(*Namespace)(nil),          // 3: kvs.v1.Namespace
+	(*BatchItem)(nil),          // 4: kvs.v1.BatchItem
+	(*BatchResult)(nil),        // 5: kvs.v1.BatchResult
+	(*TxnOp)(nil),              // 6: kvs.v1.TxnOp
+	(*TxnResult)(nil),          // 7: kvs.v1.TxnResult
+	(*Response)(nil),           // 8: kvs.v1.Response
+	(*Sibling)(nil),            // 9: kvs.v1.Sibling
+	(*CRDTValue)(nil),          // 10: kvs.v1.CRDTValue
+	(*ScanResult)(nil),         // 11: kvs.v1.ScanResult
+	(*WatchRequest)(nil),       // 12: kvs.v1.WatchRequest
+	(*WatchEvent)(nil),         // 13: kvs.v1.WatchEvent
+	(*ReplicaMessage)(nil),     // 14: kvs.v1.ReplicaMessage
+	(*UpdateReply)(nil),        // 15: kvs.v1.UpdateReply
+	(*ReplicationMessage)(nil), // 16: kvs.v1.ReplicationMessage
+	(*NamespaceState)(nil),     // 17: kvs.v1.NamespaceState
+	(*LeaveRequest)(nil),       // 18: kvs.v1.LeaveRequest
+	(*Empty)(nil),              // 19: kvs.v1.Empty
+}
+var file_kvs_proto_depIdxs = []int32{
+	6,  // 0: kvs.v1.Args.txn:type_name -> kvs.v1.TxnOp
+	4,  // 1: kvs.v1.Args.batch:type_name -> kvs.v1.BatchItem
+	3,  // 2: kvs.v1.Args.namespace_spec:type_name -> kvs.v1.Namespace
+	2,  // 3: kvs.v1.Args.dependencies:type_name -> kvs.v1.Dependency
+	1,  // 4: kvs.v1.Dependency.stamp:type_name -> kvs.v1.Stamp
+	0,  // 5: kvs.v1.BatchItem.args:type_name -> kvs.v1.Args
+	7,  // 6: kvs.v1.Response.txn_results:type_name -> kvs.v1.TxnResult
+	5,  // 7: kvs.v1.Response.batch_results:type_name -> kvs.v1.BatchResult
+	11, // 8: kvs.v1.Response.scan_results:type_name -> kvs.v1.ScanResult
+	3,  // 9: kvs.v1.Response.namespaces:type_name -> kvs.v1.Namespace
+	10, // 10: kvs.v1.Response.crdt:type_name -> kvs.v1.CRDTValue
+	9,  // 11: kvs.v1.Response.siblings:type_name -> kvs.v1.Sibling
+	1,  // 12: kvs.v1.Response.stamp:type_name -> kvs.v1.Stamp
+	2,  // 13: kvs.v1.Response.dependencies:type_name -> kvs.v1.Dependency
+	0,  // 14: kvs.v1.ReplicaMessage.args:type_name -> kvs.v1.Args
+	8,  // 15: kvs.v1.UpdateReply.response:type_name -> kvs.v1.Response
+	0,  // 16: kvs.v1.ReplicationMessage.args:type_name -> kvs.v1.Args
+	1,  // 17: kvs.v1.ReplicationMessage.stamp:type_name -> kvs.v1.Stamp
+	2,  // 18: kvs.v1.ReplicationMessage.dependencies:type_name -> kvs.v1.Dependency
+	17, // 19: kvs.v1.ReplicationMessage.state:type_name -> kvs.v1.NamespaceState
+	3,  // 20: kvs.v1.NamespaceState.namespace:type_name -> kvs.v1.Namespace
+	11, // 21: kvs.v1.NamespaceState.keys:type_name -> kvs.v1.ScanResult
+	0,  // 22: kvs.v1.KeyValue.Get:input_type -> kvs.v1.Args
+	0,  // 23: kvs.v1.KeyValue.Put:input_type -> kvs.v1.Args
+	0,  // 24: kvs.v1.KeyValue.Delete:input_type -> kvs.v1.Args
+	0,  // 25: kvs.v1.KeyValue.Increment:input_type -> kvs.v1.Args
+	0,  // 26: kvs.v1.KeyValue.Append:input_type -> kvs.v1.Args
+	0,  // 27: kvs.v1.KeyValue.UpdateCRDT:input_type -> kvs.v1.Args
+	0,  // 28: kvs.v1.KeyValue.CompareAndSwap:input_type -> kvs.v1.Args
+	0,  // 29: kvs.v1.KeyValue.Txn:input_type -> kvs.v1.Args
+	0,  // 30: kvs.v1.KeyValue.Batch:input_type -> kvs.v1.Args
+	0,  // 31: kvs.v1.KeyValue.Scan:input_type -> kvs.v1.Args
+	0,  // 32: kvs.v1.KeyValue.ScanPrefix:input_type -> kvs.v1.Args
+	0,  // 33: kvs.v1.KeyValue.End:input_type -> kvs.v1.Args
+	0,  // 34: kvs.v1.KeyValue.CreateNamespace:input_type -> kvs.v1.Args
+	0,  // 35: kvs.v1.KeyValue.DropNamespace:input_type -> kvs.v1.Args
+	0,  // 36: kvs.v1.KeyValue.ListNamespaces:input_type -> kvs.v1.Args
+	12, // 37: kvs.v1.KeyValue.Watch:input_type -> kvs.v1.WatchRequest
+	14, // 38: kvs.v1.Replica.Multicast:input_type -> kvs.v1.ReplicaMessage
+	14, // 39: kvs.v1.Replica.ReceiveAck:input_type -> kvs.v1.ReplicaMessage
+	18, // 40: kvs.v1.Replica.PeerLeaving:input_type -> kvs.v1.LeaveRequest
+	16, // 41: kvs.v1.Replica.Replicate:input_type -> kvs.v1.ReplicationMessage
+	8,  // 42: kvs.v1.KeyValue.Get:output_type -> kvs.v1.Response
+	8,  // 43: kvs.v1.KeyValue.Put:output_type -> kvs.v1.Response
+	8,  // 44: kvs.v1.KeyValue.Delete:output_type -> kvs.v1.Response
+	8,  // 45: kvs.v1.KeyValue.Increment:output_type -> kvs.v1.Response
+	8,  // 46: kvs.v1.KeyValue.Append:output_type -> kvs.v1.Response
+	8,  // 47: kvs.v1.KeyValue.UpdateCRDT:output_type -> kvs.v1.Response
+	8,  // 48: kvs.v1.KeyValue.CompareAndSwap:output_type -> kvs.v1.Response
+	8,  // 49: kvs.v1.KeyValue.Txn:output_type -> kvs.v1.Response
+	8,  // 50: kvs.v1.KeyValue.Batch:output_type -> kvs.v1.Response
+	8,  // 51: kvs.v1.KeyValue.Scan:output_type -> kvs.v1.Response
+	8,  // 52: kvs.v1.KeyValue.ScanPrefix:output_type -> kvs.v1.Response
+	8,  // 53: kvs.v1.KeyValue.End:output_type -> kvs.v1.Response
+	8,  // 54: kvs.v1.KeyValue.CreateNamespace:output_type -> kvs.v1.Response
+	8,  // 55: kvs.v1.KeyValue.DropNamespace:output_type -> kvs.v1.Response
+	8,  // 56: kvs.v1.KeyValue.ListNamespaces:output_type -> kvs.v1.Response
+	13, // 57: kvs.v1.KeyValue.Watch:output_type -> kvs.v1.WatchEvent
+	15, // 58: kvs.v1.Replica.Multicast:output_type -> kvs.v1.UpdateReply
+	19, // 59: kvs.v1.Replica.ReceiveAck:output_type -> kvs.v1.Empty
+	19, // 60: kvs.v1.Replica.PeerLeaving:output_type -> kvs.v1.Empty
+	8,  // 61: kvs.v1.Replica.Replicate:output_type -> kvs.v1.Response
+	42, // [42:62] is the sub-list for method output_type
+	22, // [22:42] is the sub-list for method input_type
+	22, // [22:22] is the sub-list for extension type_name
+	22, // [22:22] is the sub-list for extension extendee
+	0,  // [0:22] is the sub-list for field type_name
+}
+
+func init() { file_kvs_proto_init() }
+func file_kvs_proto_init() {
+	if File_kvs_proto != nil {
+		return
+	}
+	if !protoimpl.UnsafeEnabled {
+		file_kvs_proto_msgTypes[0].Exporter = func(v any, i int) any {
+			switch v := v.(*Args); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_kvs_proto_msgTypes[1].Exporter = func(v any, i int) any {
+			switch v := v.(*Stamp); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_kvs_proto_msgTypes[2].Exporter = func(v any, i int) any {
+			switch v := v.(*Dependency); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_kvs_proto_msgTypes[3].Exporter = func(v any, i int) any {
+			switch v := v.(*Namespace); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_kvs_proto_msgTypes[4].Exporter = func(v any, i int) any {
+			switch v := v.(*BatchItem); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_kvs_proto_msgTypes[5].Exporter = func(v any, i int) any {
+			switch v := v.(*BatchResult); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_kvs_proto_msgTypes[6].Exporter = func(v any, i int) any {
+			switch v := v.(*TxnOp); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_kvs_proto_msgTypes[7].Exporter = func(v any, i int) any {
+			switch v := v.(*TxnResult); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_kvs_proto_msgTypes[8].Exporter = func(v any, i int) any {
+			switch v := v.(*Response); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_kvs_proto_msgTypes[9].Exporter = func(v any, i int) any {
+			switch v := v.(*Sibling); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_kvs_proto_msgTypes[10].Exporter = func(v any, i int) any {
+			switch v := v.(*CRDTValue); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_kvs_proto_msgTypes[11].Exporter = func(v any, i int) any {
+			switch v := v.(*ScanResult); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_kvs_proto_msgTypes[12].Exporter = func(v any, i int) any {
+			switch v := v.(*WatchRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_kvs_proto_msgTypes[13].Exporter = func(v any, i int) any {
+			switch v := v.(*WatchEvent); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_kvs_proto_msgTypes[14].Exporter = func(v any, i int) any {
+			switch v := v.(*ReplicaMessage); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_kvs_proto_msgTypes[15].Exporter = func(v any, i int) any {
+			switch v := v.(*UpdateReply); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_kvs_proto_msgTypes[16].Exporter = func(v any, i int) any {
+			switch v := v.(*ReplicationMessage); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_kvs_proto_msgTypes[17].Exporter = func(v any, i int) any {
+			switch v := v.(*NamespaceState); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_kvs_proto_msgTypes[18].Exporter = func(v any, i int) any {
+			switch v := v.(*LeaveRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_kvs_proto_msgTypes[19].Exporter = func(v any, i int) any {
+			switch v := v.(*Empty); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: file_kvs_proto_rawDesc,
+			NumEnums:      0,
+			NumMessages:   20,
+			NumExtensions: 0,
+			NumServices:   2,
+		},
+		GoTypes:           file_kvs_proto_goTypes,
+		DependencyIndexes: file_kvs_proto_depIdxs,
+		MessageInfos:      file_kvs_proto_msgTypes,
+	}.Build()
+	File_kvs_proto = out.File
+	file_kvs_proto_rawDesc = nil
+	file_kvs_proto_goTypes = nil
+	file_kvs_proto_depIdxs = nil
+}
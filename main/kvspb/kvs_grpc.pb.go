@@ -0,0 +1,960 @@
+// Schema versionato dell'API dello storage chiave-valore.
+//
+// Il servizio KeyValue è l'API per i client, equivalente alle RPC Get/Put/Delete esposte tramite net/rpc.
+// Il servizio Replica trasporta i messaggi scambiati tra le repliche dai protocolli di multicast.
+//
+// Regole di evoluzione: i numeri dei campi non vanno mai riutilizzati, i campi rimossi vanno marcati come
+// reserved e le modifiche incompatibili richiedono un nuovo package (kvs.v2).
+
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.4.0
+// - protoc             (unknown)
+// source: kvs.proto
+
+package kvspb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.62.0 or later.
+const _ = grpc.SupportPackageIsVersion8
+
+const (
+	KeyValue_Get_FullMethodName             = "/kvs.v1.KeyValue/Get"
+	KeyValue_Put_FullMethodName             = "/kvs.v1.KeyValue/Put"
+	KeyValue_Delete_FullMethodName          = "/kvs.v1.KeyValue/Delete"
+	KeyValue_Increment_FullMethodName       = "/kvs.v1.KeyValue/Increment"
+	KeyValue_Append_FullMethodName          = "/kvs.v1.KeyValue/Append"
+	KeyValue_UpdateCRDT_FullMethodName      = "/kvs.v1.KeyValue/UpdateCRDT"
+	KeyValue_CompareAndSwap_FullMethodName  = "/kvs.v1.KeyValue/CompareAndSwap"
+	KeyValue_Txn_FullMethodName             = "/kvs.v1.KeyValue/Txn"
+	KeyValue_Batch_FullMethodName           = "/kvs.v1.KeyValue/Batch"
+	KeyValue_Scan_FullMethodName            = "/kvs.v1.KeyValue/Scan"
+	KeyValue_ScanPrefix_FullMethodName      = "/kvs.v1.KeyValue/ScanPrefix"
+	KeyValue_End_FullMethodName             = "/kvs.v1.KeyValue/End"
+	KeyValue_CreateNamespace_FullMethodName = "/kvs.v1.KeyValue/CreateNamespace"
+	KeyValue_DropNamespace_FullMethodName   = "/kvs.v1.KeyValue/DropNamespace"
+	KeyValue_ListNamespaces_FullMethodName  = "/kvs.v1.KeyValue/ListNamespaces"
+	KeyValue_Watch_FullMethodName           = "/kvs.v1.KeyValue/Watch"
+)
+
+// KeyValueClient is the client API for KeyValue service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type KeyValueClient interface {
+	Get(ctx context.Context, in *Args, opts ...grpc.CallOption) (*Response, error)
+	Put(ctx context.Context, in *Args, opts ...grpc.CallOption) (*Response, error)
+	Delete(ctx context.Context, in *Args, opts ...grpc.CallOption) (*Response, error)
+	Increment(ctx context.Context, in *Args, opts ...grpc.CallOption) (*Response, error)
+	Append(ctx context.Context, in *Args, opts ...grpc.CallOption) (*Response, error)
+	UpdateCRDT(ctx context.Context, in *Args, opts ...grpc.CallOption) (*Response, error)
+	CompareAndSwap(ctx context.Context, in *Args, opts ...grpc.CallOption) (*Response, error)
+	Txn(ctx context.Context, in *Args, opts ...grpc.CallOption) (*Response, error)
+	Batch(ctx context.Context, in *Args, opts ...grpc.CallOption) (*Response, error)
+	Scan(ctx context.Context, in *Args, opts ...grpc.CallOption) (*Response, error)
+	ScanPrefix(ctx context.Context, in *Args, opts ...grpc.CallOption) (*Response, error)
+	End(ctx context.Context, in *Args, opts ...grpc.CallOption) (*Response, error)
+	CreateNamespace(ctx context.Context, in *Args, opts ...grpc.CallOption) (*Response, error)
+	DropNamespace(ctx context.Context, in *Args, opts ...grpc.CallOption) (*Response, error)
+	ListNamespaces(ctx context.Context, in *Args, opts ...grpc.CallOption) (*Response, error)
+	Watch(ctx context.Context, in *WatchRequest, opts ...grpc.CallOption) (KeyValue_WatchClient, error)
+}
+
+type keyValueClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewKeyValueClient(cc grpc.ClientConnInterface) KeyValueClient {
+	return &keyValueClient{cc}
+}
+
+func (c *keyValueClient) Get(ctx context.Context, in *Args, opts ...grpc.CallOption) (*Response, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Response)
+	err := c.cc.Invoke(ctx, KeyValue_Get_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *keyValueClient) Put(ctx context.Context, in *Args, opts ...grpc.CallOption) (*Response, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Response)
+	err := c.cc.Invoke(ctx, KeyValue_Put_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *keyValueClient) Delete(ctx context.Context, in *Args, opts ...grpc.CallOption) (*Response, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Response)
+	err := c.cc.Invoke(ctx, KeyValue_Delete_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *keyValueClient) Increment(ctx context.Context, in *Args, opts ...grpc.CallOption) (*Response, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Response)
+	err := c.cc.Invoke(ctx, KeyValue_Increment_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *keyValueClient) Append(ctx context.Context, in *Args, opts ...grpc.CallOption) (*Response, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Response)
+	err := c.cc.Invoke(ctx, KeyValue_Append_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *keyValueClient) UpdateCRDT(ctx context.Context, in *Args, opts ...grpc.CallOption) (*Response, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Response)
+	err := c.cc.Invoke(ctx, KeyValue_UpdateCRDT_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *keyValueClient) CompareAndSwap(ctx context.Context, in *Args, opts ...grpc.CallOption) (*Response, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Response)
+	err := c.cc.Invoke(ctx, KeyValue_CompareAndSwap_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *keyValueClient) Txn(ctx context.Context, in *Args, opts ...grpc.CallOption) (*Response, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Response)
+	err := c.cc.Invoke(ctx, KeyValue_Txn_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *keyValueClient) Batch(ctx context.Context, in *Args, opts ...grpc.CallOption) (*Response, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Response)
+	err := c.cc.Invoke(ctx, KeyValue_Batch_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *keyValueClient) Scan(ctx context.Context, in *Args, opts ...grpc.CallOption) (*Response, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Response)
+	err := c.cc.Invoke(ctx, KeyValue_Scan_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *keyValueClient) ScanPrefix(ctx context.Context, in *Args, opts ...grpc.CallOption) (*Response, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Response)
+	err := c.cc.Invoke(ctx, KeyValue_ScanPrefix_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *keyValueClient) End(ctx context.Context, in *Args, opts ...grpc.CallOption) (*Response, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Response)
+	err := c.cc.Invoke(ctx, KeyValue_End_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *keyValueClient) CreateNamespace(ctx context.Context, in *Args, opts ...grpc.CallOption) (*Response, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Response)
+	err := c.cc.Invoke(ctx, KeyValue_CreateNamespace_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *keyValueClient) DropNamespace(ctx context.Context, in *Args, opts ...grpc.CallOption) (*Response, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Response)
+	err := c.cc.Invoke(ctx, KeyValue_DropNamespace_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *keyValueClient) ListNamespaces(ctx context.Context, in *Args, opts ...grpc.CallOption) (*Response, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Response)
+	err := c.cc.Invoke(ctx, KeyValue_ListNamespaces_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *keyValueClient) Watch(ctx context.Context, in *WatchRequest, opts ...grpc.CallOption) (KeyValue_WatchClient, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &KeyValue_ServiceDesc.Streams[0], KeyValue_Watch_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &keyValueWatchClient{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type KeyValue_WatchClient interface {
+	Recv() (*WatchEvent, error)
+	grpc.ClientStream
+}
+
+type keyValueWatchClient struct {
+	grpc.ClientStream
+}
+
+func (x *keyValueWatchClient) Recv() (*WatchEvent, error) {
+	m := new(WatchEvent)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// KeyValueServer is the server API for KeyValue service.
+// All implementations must embed UnimplementedKeyValueServer
+// for forward compatibility
+type KeyValueServer interface {
+	Get(context.Context, *Args) (*Response, error)
+	Put(context.Context, *Args) (*Response, error)
+	Delete(context.Context, *Args) (*Response, error)
+	Increment(context.Context, *Args) (*Response, error)
+	Append(context.Context, *Args) (*Response, error)
+	UpdateCRDT(context.Context, *Args) (*Response, error)
+	CompareAndSwap(context.Context, *Args) (*Response, error)
+	Txn(context.Context, *Args) (*Response, error)
+	Batch(context.Context, *Args) (*Response, error)
+	Scan(context.Context, *Args) (*Response, error)
+	ScanPrefix(context.Context, *Args) (*Response, error)
+	End(context.Context, *Args) (*Response, error)
+	CreateNamespace(context.Context, *Args) (*Response, error)
+	DropNamespace(context.Context, *Args) (*Response, error)
+	ListNamespaces(context.Context, *Args) (*Response, error)
+	Watch(*WatchRequest, KeyValue_WatchServer) error
+	mustEmbedUnimplementedKeyValueServer()
+}
+
+// UnimplementedKeyValueServer must be embedded to have forward compatible implementations.
+type UnimplementedKeyValueServer struct {
+}
+
+func (UnimplementedKeyValueServer) Get(context.Context, *Args) (*Response, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Get not implemented")
+}
+func (UnimplementedKeyValueServer) Put(context.Context, *Args) (*Response, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Put not implemented")
+}
+func (UnimplementedKeyValueServer) Delete(context.Context, *Args) (*Response, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Delete not implemented")
+}
+func (UnimplementedKeyValueServer) Increment(context.Context, *Args) (*Response, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Increment not implemented")
+}
+func (UnimplementedKeyValueServer) Append(context.Context, *Args) (*Response, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Append not implemented")
+}
+func (UnimplementedKeyValueServer) UpdateCRDT(context.Context, *Args) (*Response, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UpdateCRDT not implemented")
+}
+func (UnimplementedKeyValueServer) CompareAndSwap(context.Context, *Args) (*Response, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CompareAndSwap not implemented")
+}
+func (UnimplementedKeyValueServer) Txn(context.Context, *Args) (*Response, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Txn not implemented")
+}
+func (UnimplementedKeyValueServer) Batch(context.Context, *Args) (*Response, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Batch not implemented")
+}
+func (UnimplementedKeyValueServer) Scan(context.Context, *Args) (*Response, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Scan not implemented")
+}
+func (UnimplementedKeyValueServer) ScanPrefix(context.Context, *Args) (*Response, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ScanPrefix not implemented")
+}
+func (UnimplementedKeyValueServer) End(context.Context, *Args) (*Response, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method End not implemented")
+}
+func (UnimplementedKeyValueServer) CreateNamespace(context.Context, *Args) (*Response, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateNamespace not implemented")
+}
+func (UnimplementedKeyValueServer) DropNamespace(context.Context, *Args) (*Response, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DropNamespace not implemented")
+}
+func (UnimplementedKeyValueServer) ListNamespaces(context.Context, *Args) (*Response, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListNamespaces not implemented")
+}
+func (UnimplementedKeyValueServer) Watch(*WatchRequest, KeyValue_WatchServer) error {
+	return status.Errorf(codes.Unimplemented, "method Watch not implemented")
+}
+func (UnimplementedKeyValueServer) mustEmbedUnimplementedKeyValueServer() {}
+
+// UnsafeKeyValueServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to KeyValueServer will
+// result in compilation errors.
+type UnsafeKeyValueServer interface {
+	mustEmbedUnimplementedKeyValueServer()
+}
+
+func RegisterKeyValueServer(s grpc.ServiceRegistrar, srv KeyValueServer) {
+	s.RegisterService(&KeyValue_ServiceDesc, srv)
+}
+
+func _KeyValue_Get_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Args)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(KeyValueServer).Get(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: KeyValue_Get_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(KeyValueServer).Get(ctx, req.(*Args))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _KeyValue_Put_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Args)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(KeyValueServer).Put(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: KeyValue_Put_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(KeyValueServer).Put(ctx, req.(*Args))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _KeyValue_Delete_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Args)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(KeyValueServer).Delete(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: KeyValue_Delete_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(KeyValueServer).Delete(ctx, req.(*Args))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _KeyValue_Increment_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Args)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(KeyValueServer).Increment(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: KeyValue_Increment_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(KeyValueServer).Increment(ctx, req.(*Args))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _KeyValue_Append_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Args)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(KeyValueServer).Append(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: KeyValue_Append_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(KeyValueServer).Append(ctx, req.(*Args))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _KeyValue_UpdateCRDT_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Args)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(KeyValueServer).UpdateCRDT(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: KeyValue_UpdateCRDT_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(KeyValueServer).UpdateCRDT(ctx, req.(*Args))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _KeyValue_CompareAndSwap_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Args)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(KeyValueServer).CompareAndSwap(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: KeyValue_CompareAndSwap_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(KeyValueServer).CompareAndSwap(ctx, req.(*Args))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _KeyValue_Txn_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Args)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(KeyValueServer).Txn(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: KeyValue_Txn_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(KeyValueServer).Txn(ctx, req.(*Args))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _KeyValue_Batch_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Args)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(KeyValueServer).Batch(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: KeyValue_Batch_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(KeyValueServer).Batch(ctx, req.(*Args))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _KeyValue_Scan_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Args)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(KeyValueServer).Scan(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: KeyValue_Scan_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(KeyValueServer).Scan(ctx, req.(*Args))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _KeyValue_ScanPrefix_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Args)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(KeyValueServer).ScanPrefix(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: KeyValue_ScanPrefix_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(KeyValueServer).ScanPrefix(ctx, req.(*Args))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _KeyValue_End_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Args)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(KeyValueServer).End(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: KeyValue_End_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(KeyValueServer).End(ctx, req.(*Args))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _KeyValue_CreateNamespace_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Args)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(KeyValueServer).CreateNamespace(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: KeyValue_CreateNamespace_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(KeyValueServer).CreateNamespace(ctx, req.(*Args))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _KeyValue_DropNamespace_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Args)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(KeyValueServer).DropNamespace(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: KeyValue_DropNamespace_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(KeyValueServer).DropNamespace(ctx, req.(*Args))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _KeyValue_ListNamespaces_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Args)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(KeyValueServer).ListNamespaces(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: KeyValue_ListNamespaces_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(KeyValueServer).ListNamespaces(ctx, req.(*Args))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _KeyValue_Watch_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(KeyValueServer).Watch(m, &keyValueWatchServer{ServerStream: stream})
+}
+
+type KeyValue_WatchServer interface {
+	Send(*WatchEvent) error
+	grpc.ServerStream
+}
+
+type keyValueWatchServer struct {
+	grpc.ServerStream
+}
+
+func (x *keyValueWatchServer) Send(m *WatchEvent) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+// KeyValue_ServiceDesc is the grpc.ServiceDesc for KeyValue service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var KeyValue_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "kvs.v1.KeyValue",
+	HandlerType: (*KeyValueServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Get",
+			Handler:    _KeyValue_Get_Handler,
+		},
+		{
+			MethodName: "Put",
+			Handler:    _KeyValue_Put_Handler,
+		},
+		{
+			MethodName: "Delete",
+			Handler:    _KeyValue_Delete_Handler,
+		},
+		{
+			MethodName: "Increment",
+			Handler:    _KeyValue_Increment_Handler,
+		},
+		{
+			MethodName: "Append",
+			Handler:    _KeyValue_Append_Handler,
+		},
+		{
+			MethodName: "UpdateCRDT",
+			Handler:    _KeyValue_UpdateCRDT_Handler,
+		},
+		{
+			MethodName: "CompareAndSwap",
+			Handler:    _KeyValue_CompareAndSwap_Handler,
+		},
+		{
+			MethodName: "Txn",
+			Handler:    _KeyValue_Txn_Handler,
+		},
+		{
+			MethodName: "Batch",
+			Handler:    _KeyValue_Batch_Handler,
+		},
+		{
+			MethodName: "Scan",
+			Handler:    _KeyValue_Scan_Handler,
+		},
+		{
+			MethodName: "ScanPrefix",
+			Handler:    _KeyValue_ScanPrefix_Handler,
+		},
+		{
+			MethodName: "End",
+			Handler:    _KeyValue_End_Handler,
+		},
+		{
+			MethodName: "CreateNamespace",
+			Handler:    _KeyValue_CreateNamespace_Handler,
+		},
+		{
+			MethodName: "DropNamespace",
+			Handler:    _KeyValue_DropNamespace_Handler,
+		},
+		{
+			MethodName: "ListNamespaces",
+			Handler:    _KeyValue_ListNamespaces_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Watch",
+			Handler:       _KeyValue_Watch_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "kvs.proto",
+}
+
+const (
+	Replica_Multicast_FullMethodName   = "/kvs.v1.Replica/Multicast"
+	Replica_ReceiveAck_FullMethodName  = "/kvs.v1.Replica/ReceiveAck"
+	Replica_PeerLeaving_FullMethodName = "/kvs.v1.Replica/PeerLeaving"
+	Replica_Replicate_FullMethodName   = "/kvs.v1.Replica/Replicate"
+)
+
+// ReplicaClient is the client API for Replica service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type ReplicaClient interface {
+	// Multicast è lo stream su cui una replica invia i propri messaggi a un'altra. Per ogni messaggio, una volta
+	// consegnato, il destinatario risponde sullo stesso stream con un UpdateReply con lo stesso uuid.
+	Multicast(ctx context.Context, opts ...grpc.CallOption) (Replica_MulticastClient, error)
+	ReceiveAck(ctx context.Context, in *ReplicaMessage, opts ...grpc.CallOption) (*Empty, error)
+	PeerLeaving(ctx context.Context, in *LeaveRequest, opts ...grpc.CallOption) (*Empty, error)
+	// Replicate consegna un ReplicationMessage e ne ritorna l'esito
+	Replicate(ctx context.Context, in *ReplicationMessage, opts ...grpc.CallOption) (*Response, error)
+}
+
+type replicaClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewReplicaClient(cc grpc.ClientConnInterface) ReplicaClient {
+	return &replicaClient{cc}
+}
+
+func (c *replicaClient) Multicast(ctx context.Context, opts ...grpc.CallOption) (Replica_MulticastClient, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Replica_ServiceDesc.Streams[0], Replica_Multicast_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &replicaMulticastClient{ClientStream: stream}
+	return x, nil
+}
+
+type Replica_MulticastClient interface {
+	Send(*ReplicaMessage) error
+	Recv() (*UpdateReply, error)
+	grpc.ClientStream
+}
+
+type replicaMulticastClient struct {
+	grpc.ClientStream
+}
+
+func (x *replicaMulticastClient) Send(m *ReplicaMessage) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *replicaMulticastClient) Recv() (*UpdateReply, error) {
+	m := new(UpdateReply)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *replicaClient) ReceiveAck(ctx context.Context, in *ReplicaMessage, opts ...grpc.CallOption) (*Empty, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Empty)
+	err := c.cc.Invoke(ctx, Replica_ReceiveAck_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *replicaClient) PeerLeaving(ctx context.Context, in *LeaveRequest, opts ...grpc.CallOption) (*Empty, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Empty)
+	err := c.cc.Invoke(ctx, Replica_PeerLeaving_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *replicaClient) Replicate(ctx context.Context, in *ReplicationMessage, opts ...grpc.CallOption) (*Response, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Response)
+	err := c.cc.Invoke(ctx, Replica_Replicate_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// ReplicaServer is the server API for Replica service.
+// All implementations must embed UnimplementedReplicaServer
+// for forward compatibility
+type ReplicaServer interface {
+	// Multicast è lo stream su cui una replica invia i propri messaggi a un'altra. Per ogni messaggio, una volta
+	// consegnato, il destinatario risponde sullo stesso stream con un UpdateReply con lo stesso uuid.
+	Multicast(Replica_MulticastServer) error
+	ReceiveAck(context.Context, *ReplicaMessage) (*Empty, error)
+	PeerLeaving(context.Context, *LeaveRequest) (*Empty, error)
+	// Replicate consegna un ReplicationMessage e ne ritorna l'esito
+	Replicate(context.Context, *ReplicationMessage) (*Response, error)
+	mustEmbedUnimplementedReplicaServer()
+}
+
+// UnimplementedReplicaServer must be embedded to have forward compatible implementations.
+type UnimplementedReplicaServer struct {
+}
+
+func (UnimplementedReplicaServer) Multicast(Replica_MulticastServer) error {
+	return status.Errorf(codes.Unimplemented, "method Multicast not implemented")
+}
+func (UnimplementedReplicaServer) ReceiveAck(context.Context, *ReplicaMessage) (*Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ReceiveAck not implemented")
+}
+func (UnimplementedReplicaServer) PeerLeaving(context.Context, *LeaveRequest) (*Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method PeerLeaving not implemented")
+}
+func (UnimplementedReplicaServer) Replicate(context.Context, *ReplicationMessage) (*Response, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Replicate not implemented")
+}
+func (UnimplementedReplicaServer) mustEmbedUnimplementedReplicaServer() {}
+
+// UnsafeReplicaServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to ReplicaServer will
+// result in compilation errors.
+type UnsafeReplicaServer interface {
+	mustEmbedUnimplementedReplicaServer()
+}
+
+func RegisterReplicaServer(s grpc.ServiceRegistrar, srv ReplicaServer) {
+	s.RegisterService(&Replica_ServiceDesc, srv)
+}
+
+func _Replica_Multicast_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(ReplicaServer).Multicast(&replicaMulticastServer{ServerStream: stream})
+}
+
+type Replica_MulticastServer interface {
+	Send(*UpdateReply) error
+	Recv() (*ReplicaMessage, error)
+	grpc.ServerStream
+}
+
+type replicaMulticastServer struct {
+	grpc.ServerStream
+}
+
+func (x *replicaMulticastServer) Send(m *UpdateReply) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *replicaMulticastServer) Recv() (*ReplicaMessage, error) {
+	m := new(ReplicaMessage)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func _Replica_ReceiveAck_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ReplicaMessage)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ReplicaServer).ReceiveAck(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Replica_ReceiveAck_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ReplicaServer).ReceiveAck(ctx, req.(*ReplicaMessage))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Replica_PeerLeaving_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(LeaveRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ReplicaServer).PeerLeaving(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Replica_PeerLeaving_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ReplicaServer).PeerLeaving(ctx, req.(*LeaveRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Replica_Replicate_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ReplicationMessage)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ReplicaServer).Replicate(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Replica_Replicate_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ReplicaServer).Replicate(ctx, req.(*ReplicationMessage))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// Replica_ServiceDesc is the grpc.ServiceDesc for Replica service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Replica_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "kvs.v1.Replica",
+	HandlerType: (*ReplicaServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "ReceiveAck",
+			Handler:    _Replica_ReceiveAck_Handler,
+		},
+		{
+			MethodName: "PeerLeaving",
+			Handler:    _Replica_PeerLeaving_Handler,
+		},
+		{
+			MethodName: "Replicate",
+			Handler:    _Replica_Replicate_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Multicast",
+			Handler:       _Replica_Multicast_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+	},
+	Metadata: "kvs.proto",
+}
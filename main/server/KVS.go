@@ -1,11 +1,109 @@
 package main
 
-import "SDCC/main/utils"
+import (
+	"SDCC/main/utils"
+	"fmt"
+	"time"
+)
 
 type (
 	KVS interface {
 		Get(args utils.Args, reply *utils.Response) error
 		Put(args utils.Args, reply *utils.Response) error
 		Delete(args utils.Args, reply *utils.Response) error
+		Increment(args utils.Args, reply *utils.Response) error
+		Append(args utils.Args, reply *utils.Response) error
+		UpdateCRDT(args utils.Args, reply *utils.Response) error
+		CompareAndSwap(args utils.Args, reply *utils.Response) error
+		Txn(args utils.Args, reply *utils.Response) error
+		Batch(args utils.Args, reply *utils.Response) error
+		Scan(args utils.Args, reply *utils.Response) error
+		ScanPrefix(args utils.Args, reply *utils.Response) error
+		End(args utils.Args, reply *utils.Response) error
+		CreateNamespace(args utils.Args, reply *utils.Response) error
+		DropNamespace(args utils.Args, reply *utils.Response) error
+		ListNamespaces(args utils.Args, reply *utils.Response) error
+	}
+
+	// Stoppable è implementato dagli storage che supportano lo spegnimento controllato
+	Stoppable interface {
+		KVS
+		StopAcceptingRequests()
+		Drain(timeout time.Duration) int
+		Snapshot() map[string]map[string][]byte //contenuto di ogni namespace
 	}
 )
+
+// validateRequest controlla le dimensioni di chiavi e valori, le richieste con più operazioni, il TTL delle Put e
+// gli aggiornamenti CRDT prima che vengano inviate alle altre repliche
+func validateRequest(args utils.Args, op string) error {
+	if err := utils.CheckLimits(args); err != nil {
+		return err
+	}
+	if err := utils.ValidateSessionClock(args.SessionClock); err != nil {
+		return err
+	}
+	if err := utils.ValidateDependencies(args.Dependencies); err != nil {
+		return err
+	}
+	switch op {
+	case utils.Put:
+		if args.TTL < 0 {
+			return fmt.Errorf("%w: ttl must not be negative", utils.ErrInvalidRequest)
+		}
+		return utils.ValidateContext(args.Context)
+	case utils.Delete:
+		return utils.ValidateContext(args.Context)
+	case utils.Txn:
+		return utils.ValidateTxn(args.Txn)
+	case utils.Batch:
+		for _, item := range args.Batch {
+			if err := utils.ValidateContext(item.Args.Context); err != nil {
+				return err
+			}
+		}
+		return utils.ValidateBatch(args.Batch)
+	case utils.Scan:
+		return utils.ValidateScan(args)
+	case utils.UpdateCRDT:
+		return utils.ValidateCRDT(args)
+	}
+	return nil
+}
+
+// scanRange legge dallo storage una pagina dell'intervallo di una Scan, ripartendo da args.Cursor se presente.
+// Le chiavi che il client non può leggere vengono saltate, quindi non contano nel limite della pagina e non
+// diventano mai il cursore. Ritorna le chiavi lette e la chiave da cui inizia la pagina successiva ("" se
+// l'intervallo è terminato).
+func scanRange(store *skipList, args utils.Args) ([]utils.ScanResult, string) {
+	limit := args.Limit
+	if limit == 0 {
+		limit = utils.DefaultScanLimit
+	}
+	start := args.Key
+	if args.Cursor != "" {
+		start = args.Cursor
+	}
+
+	readable := utils.ReadableKeys(args.Token, args.Namespace)
+	results := make([]utils.ScanResult, 0)
+	cursor := ""
+	store.Ascend(start, args.RangeEnd, func(key string, value []byte) bool {
+		if !readable(key) {
+			return true
+		}
+		if len(results) == limit {
+			cursor = key
+			return false
+		}
+		results = append(results, utils.ScanResult{Key: key, Value: value})
+		return true
+	})
+	return results, cursor
+}
+
+// prefixRange trasforma una ScanPrefix sulle chiavi che iniziano con args.Key nella Scan equivalente
+func prefixRange(args utils.Args) utils.Args {
+	args.RangeEnd = utils.PrefixEnd(args.Key)
+	return args
+}
@@ -4,9 +4,7 @@ import (
 	"SDCC/main/utils"
 	"errors"
 	"fmt"
-	"os"
-	"strconv"
-	"strings"
+	"slices"
 	"sync"
 	"time"
 )
@@ -20,35 +18,48 @@ var isFirstReq = true
 
 // KVSCausal is a concrete implementation of the KVS interface
 type KVSCausal struct {
-	index                 int               //indice della replica corrente
-	store                 map[string]string //KVS effettivo
-	mapMutex              sync.Mutex        //mutex per accedere alla Map
-	clientList            ClientList        //Lista dei client per il singolo server
-	logicalClock          *VectLogicalClock // clock logico del server
-	sendFifoOrderIndex    int               //serve a mantenere il fifo ordering quando il server si invia da solo un'operazione
-	sendFifoOrderMutex    sync.Mutex        //mutex per fifo ordering
-	receiveFifoOrderIndex int               //serve a mantenere il fifo ordering quando il server riceve un suo messaggio
-	receiveFifoOrderMutex sync.Mutex        //mutex per fifo ordering
+	index                 int                  //indice della replica corrente
+	keyspaces             map[string]*keyspace //KVS effettivo: le chiavi di ogni namespace con consistenza causale
+	mapMutex              sync.Mutex           //mutex per accedere alla Map
+	clientList            ClientList           //Lista dei client per il singolo server
+	drainer                                    //richieste in corso, per lo spegnimento controllato
+	*namespaceCatalog                          //smistamento delle richieste tra i namespace e Watch
+	logicalClock          *VectLogicalClock    // clock logico del server
+	sendFifoOrderIndex    int                  //serve a mantenere il fifo ordering quando il server si invia da solo un'operazione
+	sendFifoOrderMutex    sync.Mutex           //mutex per fifo ordering
+	receiveFifoOrderIndex int                  //serve a mantenere il fifo ordering quando il server riceve un suo messaggio
+	receiveFifoOrderMutex sync.Mutex           //mutex per fifo ordering
+	delivered             [][]int              //clock dell'ultimo messaggio consegnato da ogni replica, per la stabilità causale
 }
 
 // NewKVSCasual  creates a new instance of KVSCasual
-func NewKVSCasual(index int) *KVSCausal {
-	numOfReplicas, _ := strconv.Atoi(os.Getenv("REPLICAS")) //numero di server = numero di client
+func NewKVSCasual(index int, catalog *namespaceCatalog) *KVSCausal {
+	numOfReplicas := utils.NumberOfReplicas //numero di server = numero di client
 	kvs := &KVSCausal{
-		index: index,
-		store: make(map[string]string),
+		index:            index,
+		keyspaces:        make(map[string]*keyspace),
+		namespaceCatalog: catalog,
 		clientList: ClientList{
-			list: make([]int, numOfReplicas),
+			list: make(map[int]int),
 		},
 		logicalClock: &VectLogicalClock{
 			clockVector: make([]int, numOfReplicas),
 		},
+		delivered: make([][]int, numOfReplicas),
+	}
+	for i := range kvs.delivered {
+		kvs.delivered[i] = make([]int, numOfReplicas)
+	}
+	if utils.Conf.Consistency == utils.Causal {
+		kvs.keyspaces[utils.DefaultNamespace] = newKeyspace(utils.Namespace{Name: utils.DefaultNamespace, Consistency: utils.Causal})
 	}
 	return kvs
 }
 
 // Update è la funzione dedicata alla ricezione di messaggi che si scambiano i server
 func (kvs *KVSCausal) Update(m utils.VMessageNA, resp *utils.Response) error {
+	kvs.beginUpdate()
+	defer kvs.end()
 
 	msg := &m
 
@@ -102,7 +113,11 @@ func (kvs *KVSCausal) WaitUntilExecutable(msg *utils.VMessageNA) {
 			 2. tsm[k] ≤ Vj[k] per ogni k =/= i:
 				per ogni altro processo pk, pj ha visto almeno gli stessi messaggi visti da pi
 
-			3. Se è una read (o eventualmente una delete) devo aspettare l'operazione di write da cui ha una dipendenza causale
+			 Le dipendenze causali di un messaggio sono tutte nel suo clock vettoriale: le scritture consegnate dalla
+			 replica d'origine prima dell'invio, tra cui quelle già viste dal client (la replica attende di raggiungere
+			 il clock di sessione del client prima di inviare il messaggio, vedere awaitSession). Una read attende
+			 quindi solo i propri predecessori causali, e se tra questi non c'è una scrittura della chiave letta
+			 risponde che la chiave non esiste.
 
 			 Se tutte le condizioni sono rispettate, allora la funzione ritornerà il controllo al chiamante, e procederà
 			 all'esecuzione della RPC di livello applicativo.
@@ -152,26 +167,6 @@ func (kvs *KVSCausal) WaitUntilExecutable(msg *utils.VMessageNA) {
 		fmt.Printf("\033[32mControllo haveSeenEnoughMessages superato per il messaggio %s\033[0m\n", msg.UUID)
 	}
 
-	//Che sia un evento che arriva dal server stesso o da un altro, se è una GET bisogna rispettare la (potenziale)
-	//relazione cause-effetto -> deve superare quest'ultimo controllo. Analogamente per la delete (se richiesto dalla configurazione)
-
-	isDeleteCausal := msg.OpType == utils.Delete && os.Getenv("DELETE_CAUSAL") == "1"
-
-	if msg.OpType == utils.Get || isDeleteCausal {
-		cond3 := make(chan bool)
-		go func() {
-			for {
-				if kvs.hasWriteHappened(msg) {
-					cond3 <- true
-					return
-				}
-				time.Sleep(SLEEP_TIME)
-			}
-		}()
-		<-cond3
-		fmt.Printf("\033[32mControllo presenza chiave superato per il messaggio %s\033[0m\n", msg.UUID)
-	}
-
 	//Una volta verificatesi tutte le condizioni, il controllo può tornare alla funzione chiamante e il messaggio
 	//può essere passato, di fatto, al livello applicativo.
 
@@ -179,32 +174,97 @@ func (kvs *KVSCausal) WaitUntilExecutable(msg *utils.VMessageNA) {
 
 func (kvs *KVSCausal) CallRealOperation(msg *utils.VMessageNA, resp *utils.Response) error {
 
+	kvs.delivered[msg.ServerIndex] = msg.ClockVector
+	ks, ok := liveKeyspace(kvs.keyspaces, msg.Args)
+	if !ok {
+		//Il namespace è stato eliminato, ed eventualmente ricreato con lo stesso nome: l'operazione non ha effetto
+		resp.Key = msg.Args.Key
+		fmt.Printf("%s operation skipped, namespace %s not found\n", msg.OpType, msg.Args.Namespace)
+		return nil
+	}
+
 	switch msg.OpType {
 	case utils.Get:
 		if msg.ServerIndex != kvs.index {
 			return nil
 		}
 		// Implementazione dell'operazione Get
-		value := kvs.store[msg.Args.Key]
+		value, found := ks.store.Get(msg.Args.Key)
 		resp.Key = msg.Args.Key
 		resp.Value = value
+		resp.Found = found
+		if state, ok := ks.crdts[msg.Args.Key]; ok {
+			resp.CRDT = state.value()
+		}
+		if set, ok := ks.siblings[msg.Args.Key]; ok {
+			resp.Siblings, resp.Context = set.siblings(), set.context()
+		}
 		resp.IsPrintable = true
 		fmt.Printf("Get operation completed. Key: %s, Value: %s\n", msg.Args.Key, value)
 
 	case utils.Put:
 		// Implementazione dell'operazione Put
-		kvs.store[msg.Args.Key] = msg.Args.Value
+		kvs.setKey(ks, msg, msg.Args.Key, msg.Args.Value, msg.Args.Context)
+		if msg.Args.TTL > 0 {
+			kvs.scheduleExpiry(ks.info, msg.Args.Key, msg.ClockVector, msg.Args.TTL, msg.ServerIndex)
+		}
 		fmt.Printf("Put operation completed. Key: %s, Value: %s\n", msg.Args.Key, msg.Args.Value)
 
 	case utils.Delete:
 		// Implementazione dell'operazione Delete
-		_, ok := kvs.store[msg.Args.Key]
+		_, ok := ks.store.Get(msg.Args.Key)
 		if !ok {
 			return errors.New("delete operation failed. Key not found")
 		}
-		delete(kvs.store, msg.Args.Key) //Se la chiave non c'è ho una no-op ed è il comportamento desiderato
+		kvs.deleteKey(ks, msg, msg.Args.Key, msg.Args.Context) //Se la chiave non c'è ho una no-op ed è il comportamento desiderato
 		fmt.Printf("Delete operation completed. Key: %s\n", msg.Args.Key)
 
+	case utils.Increment, utils.Append:
+		//Ogni replica aggiunge l'operazione a quelle della chiave invece di scrivere il valore calcolato
+		//dall'origine: le Increment e le Append concorrenti vengono applicate nello stesso ordine su tutte le
+		//repliche, senza perdere aggiornamenti, e solo alla versione che la replica d'origine aveva consegnato
+		//(vedere siblings.go)
+		resp.Key = msg.Args.Key
+		if value, err := kvs.modifyKey(ks, msg, msg.OpType, msg.Args, 0); err != nil {
+			kvs.currentValue(ks, msg.Args.Key, resp)
+			fmt.Printf("%s operation skipped. Key: %s, Error: %v\n", msg.OpType, msg.Args.Key, err)
+		} else {
+			resp.Value, resp.Found, resp.Succeeded = value, true, true
+			fmt.Printf("%s operation completed. Key: %s, Value: %s\n", msg.OpType, msg.Args.Key, value)
+		}
+
+	case utils.UpdateCRDT:
+		resp.Key = msg.Args.Key
+		err := kvs.updateCRDT(ks, msg)
+		resp.Succeeded = err == nil
+		kvs.currentValue(ks, msg.Args.Key, resp)
+		if resp.Succeeded {
+			fmt.Printf("UpdateCRDT operation completed. Key: %s, Type: %s, Value: %s\n", msg.Args.Key, msg.Args.CRDT, resp.Value)
+		} else {
+			fmt.Printf("UpdateCRDT operation skipped. Key: %s, Error: %v\n", msg.Args.Key, err)
+		}
+
+	case utils.Batch:
+		kvs.applyBatch(ks, msg, resp)
+		fmt.Printf("Batch operation completed. Operations: %d\n", len(msg.Args.Batch))
+
+	case utils.Scan:
+		if msg.ServerIndex != kvs.index {
+			return nil
+		}
+		resp.ScanResults, resp.Cursor = scanRange(ks.store, msg.Args)
+		fmt.Printf("Scan operation completed. Range: [%s, %s), Keys: %d\n", msg.Args.Key, msg.Args.RangeEnd, len(resp.ScanResults))
+
+	case utils.Expire:
+		//L'Expire segue causalmente la Put con TTL, il cui clock è in Args.Context: elimina solo le versioni viste
+		//da quel clock, quindi le scritture concorrenti o successive alla Put restano su tutte le repliche,
+		//qualunque sia l'ordine in cui sono state consegnate rispetto alla Put e all'Expire
+		if kvs.expireKey(ks, msg, msg.Args.Key, msg.Args.Context) {
+			fmt.Printf("Expire operation completed. Key: %s\n", msg.Args.Key)
+		} else {
+			fmt.Printf("Expire operation skipped, key %s was overwritten\n", msg.Args.Key)
+		}
+
 	default:
 		return fmt.Errorf("unknown operation type: %s", msg.OpType)
 	}
@@ -212,6 +272,160 @@ func (kvs *KVSCausal) CallRealOperation(msg *utils.VMessageNA, resp *utils.Respo
 	return nil
 }
 
+// setKey scrive key nel namespace ks con la scrittura contenuta in msg e il contesto context, conservando le
+// versioni concorrenti (sibling), e notifica i Watch. Va chiamata con mapMutex già acquisito.
+func (kvs *KVSCausal) setKey(ks *keyspace, msg *utils.VMessageNA, key string, value []byte, context []int) {
+	kvs.writeVersion(ks, msg, key, version{value: value, clock: msg.ClockVector, origin: msg.ServerIndex}, context)
+}
+
+// deleteKey scrive una versione cancellata di key nel namespace ks, che risolve i sibling come una Put, e notifica
+// i Watch se la chiave esisteva. Va chiamata con mapMutex già acquisito.
+func (kvs *KVSCausal) deleteKey(ks *keyspace, msg *utils.VMessageNA, key string, context []int) {
+	kvs.writeVersion(ks, msg, key, version{deleted: true, clock: msg.ClockVector, origin: msg.ServerIndex}, context)
+}
+
+// writeVersion applica la versione v ai sibling di key e scrive nello storage la versione che prevale. Una
+// versione già vista da un'altra scrittura non ha effetto. Va chiamata con mapMutex già acquisito.
+func (kvs *KVSCausal) writeVersion(ks *keyspace, msg *utils.VMessageNA, key string, v version, context []int) {
+	if !ks.siblingsOf(key).write(v, context) {
+		fmt.Printf("%s of key %s is obsolete, a concurrent write already saw it\n", msg.OpType, key)
+		return
+	}
+	kvs.syncKey(ks, msg, key)
+}
+
+// expireKey elimina le versioni di key viste da putClock, il clock della Put con TTL a cui si riferisce l'Expire
+// msg, scrivendo una versione cancellata con il clock dell'Expire. Ritorna false se non c'era nessuna versione da
+// eliminare. Va chiamata con mapMutex già acquisito.
+func (kvs *KVSCausal) expireKey(ks *keyspace, msg *utils.VMessageNA, key string, putClock []int) bool {
+	set, ok := ks.siblings[key]
+	if !ok || !set.expire(putClock, msg) {
+		return false
+	}
+	kvs.syncKey(ks, msg, key)
+	return true
+}
+
+// syncKey scrive nello storage la versione di key che prevale tra i sibling, con il suo stato CRDT, o elimina la
+// chiave se sono tutti cancellati, e notifica i Watch. Va chiamata con mapMutex già acquisito.
+func (kvs *KVSCausal) syncKey(ks *keyspace, msg *utils.VMessageNA, key string) {
+	ks.siblings[key].compact(kvs.stable())
+	current, ok := ks.siblings[key].current()
+	if ok && current.crdt != nil {
+		ks.crdts[key] = current.crdt
+	} else {
+		delete(ks.crdts, key)
+	}
+	if ok {
+		ks.set(key, current.value)
+		ks.publish(utils.WatchEvent{Namespace: ks.info.Name, OpType: utils.Put, Key: key, Value: current.value, ClockVector: msg.ClockVector, Message: msg.UUID.String()})
+	} else if ks.remove(key) {
+		ks.publish(utils.WatchEvent{Namespace: ks.info.Name, OpType: utils.Delete, Key: key, ClockVector: msg.ClockVector, Message: msg.UUID.String()})
+	}
+}
+
+// modifyKey applica una Increment o una Append alla chiave args.Key del namespace ks e ritorna il valore della
+// versione a cui si è applicata. seq è la posizione dell'operazione nel Batch. Se il valore non lo permette
+// l'operazione non ha effetto. Va chiamata con mapMutex già acquisito.
+func (kvs *KVSCausal) modifyKey(ks *keyspace, msg *utils.VMessageNA, op string, args utils.Args, seq int) ([]byte, error) {
+	u := update{op: op, args: args, clock: msg.ClockVector, origin: msg.ServerIndex, seq: seq}
+	v, err := ks.siblingsOf(args.Key).update(u)
+	kvs.syncKey(ks, msg, args.Key)
+	if err != nil {
+		return nil, err
+	}
+	return v.value, nil
+}
+
+// updateCRDT aggiunge l'aggiornamento CRDT del messaggio a quelli della chiave e scrive nello storage la versione
+// che prevale. Ritorna un errore se, tra gli aggiornamenti consegnati finora, non ha effetto perché la versione a cui
+// si applica contiene un valore ordinario o un CRDT di un altro tipo. Va chiamata con mapMutex già acquisito.
+func (kvs *KVSCausal) updateCRDT(ks *keyspace, msg *utils.VMessageNA) error {
+	u := update{op: utils.UpdateCRDT, args: msg.Args, clock: msg.ClockVector, origin: msg.ServerIndex}
+	_, err := ks.siblingsOf(msg.Args.Key).update(u)
+	kvs.syncKey(ks, msg, msg.Args.Key)
+	return err
+}
+
+// stable ritorna il clock dei messaggi causalmente stabili: il minimo, componente per componente, dei clock
+// dell'ultimo messaggio consegnato da ogni replica attiva. I messaggi che una replica invierà in seguito hanno un
+// clock maggiore o uguale, quindi hanno visto tutti quelli con clock minore o uguale al risultato. Va chiamata con
+// mapMutex già acquisito.
+func (kvs *KVSCausal) stable() []int {
+	var stable []int
+	for i, clock := range kvs.delivered {
+		if utils.HasPeerLeft(i) {
+			continue
+		}
+		if stable == nil {
+			stable = slices.Clone(clock)
+		}
+		for j := range stable {
+			stable[j] = min(stable[j], clock[j])
+		}
+	}
+	return stable
+}
+
+// currentValue riporta nella risposta il valore corrente della chiave key e, se è un CRDT, il suo stato. Va
+// chiamata con mapMutex già acquisito.
+func (kvs *KVSCausal) currentValue(ks *keyspace, key string, resp *utils.Response) {
+	resp.Value, resp.Found = ks.store.Get(key)
+	if state, ok := ks.crdts[key]; ok {
+		resp.CRDT = state.value()
+	}
+}
+
+// scheduleExpiry prepara l'invio dell'Expire per la chiave key del namespace namespace, scritta con TTL ttl dal
+// messaggio con clock putClock ricevuto dalla replica origin. L'Expire porta in Context il clock della Put a cui
+// si riferisce, e viene inviato solo se la versione scritta dalla Put è ancora tra i sibling della chiave. Va
+// chiamata con mapMutex già acquisito.
+func (kvs *KVSCausal) scheduleExpiry(namespace utils.Namespace, key string, putClock []int, ttl time.Duration, origin int) {
+	args := utils.Args{Namespace: namespace.Name, NamespaceRevision: namespace.Revision, Key: key, Context: putClock}
+	pending := func() bool {
+		kvs.mapMutex.Lock()
+		defer kvs.mapMutex.Unlock()
+		ks, ok := liveKeyspace(kvs.keyspaces, args)
+		return ok && ks.siblings[key] != nil && ks.siblings[key].has(putClock)
+	}
+	send := func() error {
+		if err := kvs.beginRequest(); err != nil {
+			return err
+		}
+		defer kvs.end()
+		return kvs.multicast(args, utils.NewResponse(), utils.Expire)
+	}
+	scheduleExpiry(key, ttl, kvs.index, origin, pending, send)
+}
+
+// applyBatch esegue gli elementi di un Batch nell'ordine indicato. I valori letti interessano solo alla replica
+// che ha ricevuto la richiesta, le altre applicano soltanto le scritture.
+func (kvs *KVSCausal) applyBatch(ks *keyspace, msg *utils.VMessageNA, resp *utils.Response) {
+	resp.BatchResults = make([]utils.BatchResult, len(msg.Args.Batch))
+	for i, item := range msg.Args.Batch {
+		key := item.Args.Key
+		result := utils.BatchResult{Key: key}
+		switch item.OpType {
+		case utils.Get:
+			result.Value, result.Found = ks.store.Get(key)
+		case utils.Put:
+			kvs.setKey(ks, msg, key, item.Args.Value, item.Args.Context)
+		case utils.Delete:
+			if _, ok := ks.store.Get(key); !ok {
+				result.Error = "delete operation failed. Key not found"
+			}
+			kvs.deleteKey(ks, msg, key, item.Args.Context)
+		case utils.Increment, utils.Append:
+			if value, err := kvs.modifyKey(ks, msg, item.OpType, item.Args, i); err != nil {
+				result.Error = err.Error()
+			} else {
+				result.Value, result.Found = value, true
+			}
+		}
+		resp.BatchResults[i] = result
+	}
+}
+
 func (kvs *KVSCausal) ExecuteClientRequest(arg utils.Args, resp *utils.Response, op string) error {
 
 	/*
@@ -220,6 +434,11 @@ func (kvs *KVSCausal) ExecuteClientRequest(arg utils.Args, resp *utils.Response,
 	 gli ack dei messaggi interni
 	*/
 
+	if err := kvs.beginRequest(); err != nil {
+		return err //il server è in fase di spegnimento
+	}
+	defer kvs.end()
+
 	//Condizione 0: FIFO ordering per le richieste dai client
 
 	go kvs.printMapAfterExecution()
@@ -239,6 +458,22 @@ func (kvs *KVSCausal) ExecuteClientRequest(arg utils.Args, resp *utils.Response,
 	<-cond0 //aspetto che cond0 sia verificata
 	//A questo punto sono sicuro di star processando la richiesta che mi aspettavo dal client.
 
+	if op == utils.End {
+		//Con la consistenza causale non ci sono code da svuotare: l'End consuma soltanto il turno FIFO del client
+		return nil
+	}
+
+	//Controllo della richiesta, dei permessi e delle quote e invio tramite lo storage che ospita il namespace
+	return kvs.dispatch(arg, resp, op)
+}
+
+// multicast crea il messaggio dell'operazione op e lo invia a tutte le repliche tramite il multicast causalmente
+// ordinato. Per le richieste dei client va chiamata solo dopo aver verificato l'ordine FIFO e i permessi.
+func (kvs *KVSCausal) multicast(arg utils.Args, resp *utils.Response, op string) error {
+	if err := kvs.awaitSession(arg.SessionClock); err != nil {
+		return err
+	}
+
 	kvs.sendFifoOrderMutex.Lock()
 	kvs.logicalClock.clockVectorMutex.Lock()
 	kvs.logicalClock.clockVector[kvs.index]++ //incremento la componente del clock vettoriale relativa al processo corrente
@@ -256,14 +491,48 @@ func (kvs *KVSCausal) ExecuteClientRequest(arg utils.Args, resp *utils.Response,
 	kvs.logicalClock.clockVectorMutex.Unlock()
 	kvs.sendFifoOrderMutex.Unlock()
 
+	resp.ClockVector = clockVectorCopy
+
 	var err error
 	if msg.OpType == utils.Get {
-		respChannel := make(chan string, 1)
+		respChannel := make(chan *utils.Response, 1)
 		err = utils.SendGETToAllServerCausal(*msg, respChannel, kvs.index)
-		resp.Value = <-respChannel
+		delivered := <-respChannel
+		resp.Value = delivered.Value
+		resp.Found = delivered.Found
+		resp.CRDT = delivered.CRDT
+		resp.Siblings = delivered.Siblings
+		resp.Context = delivered.Context
 		resp.Key = msg.Args.Key
 		resp.IsPrintable = true
 
+	} else if msg.OpType == utils.Scan {
+		//La Scan legge lo stato della replica corrente quando il messaggio viene consegnato, quindi dopo tutte le
+		//scritture da cui dipende causalmente
+		respChannel := make(chan *utils.Response, 1)
+		err = utils.SendGETToAllServerCausal(*msg, respChannel, kvs.index)
+		delivered := <-respChannel
+		resp.ScanResults = delivered.ScanResults
+		resp.Cursor = delivered.Cursor
+
+	} else if msg.OpType == utils.Batch {
+		//I risultati del Batch sono quelli calcolati alla consegna dalla replica corrente
+		respChannel := make(chan *utils.Response, 1)
+		err = utils.SendGETToAllServerCausal(*msg, respChannel, kvs.index)
+		resp.BatchResults = (<-respChannel).BatchResults
+
+	} else if msg.OpType == utils.Increment || msg.OpType == utils.Append || msg.OpType == utils.UpdateCRDT {
+		//Il valore risultante è quello calcolato alla consegna dalla replica corrente, che comprende gli
+		//aggiornamenti concorrenti già consegnati
+		respChannel := make(chan *utils.Response, 1)
+		err = utils.SendGETToAllServerCausal(*msg, respChannel, kvs.index)
+		delivered := <-respChannel
+		resp.Key = msg.Args.Key
+		resp.Value = delivered.Value
+		resp.Found = delivered.Found
+		resp.CRDT = delivered.CRDT
+		resp.Succeeded = delivered.Succeeded
+
 	} else {
 		err = utils.SendToAllServerCausal(*msg)
 
@@ -274,9 +543,41 @@ func (kvs *KVSCausal) ExecuteClientRequest(arg utils.Args, resp *utils.Response,
 		return err
 	}
 
+	//Il clock della replica dopo l'operazione comprende il messaggio appena inviato e tutte le scritture che
+	//l'operazione può aver letto
+	resp.SessionClock = mergeClocks(kvs.currentClock(), arg.SessionClock)
 	return nil
 }
 
+// awaitSession attende che il clock vettoriale della replica raggiunga il clock di sessione del client, cioè che
+// la replica abbia consegnato (o inviato, per le proprie) tutte le scritture che il client ha già eseguito o letto.
+// Un messaggio inviato dopo segue quindi causalmente quelle scritture. Se la replica non le raggiunge entro
+// timeouts.session la richiesta viene rifiutata.
+func (kvs *KVSCausal) awaitSession(session []int) error {
+	if session == nil {
+		return nil
+	}
+	deadline := time.Now().Add(utils.Conf.Timeouts.Session)
+	for {
+		clock := kvs.currentClock()
+		if happenedBefore(session, clock) {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			fmt.Printf("\033[31mSession clock %v not reached, replica clock is %v\033[0m\n", session, clock)
+			return fmt.Errorf("%w: session clock %v, replica clock %v", utils.ErrSessionBehind, session, clock)
+		}
+		time.Sleep(SLEEP_TIME)
+	}
+}
+
+// currentClock ritorna una copia del clock vettoriale della replica
+func (kvs *KVSCausal) currentClock() []int {
+	kvs.logicalClock.clockVectorMutex.Lock()
+	defer kvs.logicalClock.clockVectorMutex.Unlock()
+	return slices.Clone(kvs.logicalClock.clockVector)
+}
+
 func (kvs *KVSCausal) isNextExpected(msg *utils.VMessageNA) bool {
 	/*
 		1. tsm[i] = Vj[i] + 1:
@@ -308,15 +609,6 @@ func (kvs *KVSCausal) haveSeenEnoughMessages(msg *utils.VMessageNA) bool {
 
 }
 
-func (kvs *KVSCausal) hasWriteHappened(msg *utils.VMessageNA) bool {
-	kvs.mapMutex.Lock()
-	defer kvs.mapMutex.Unlock()
-
-	_, isPresent := kvs.store[msg.Args.Key]
-
-	return isPresent
-}
-
 func (kvs *KVSCausal) Get(args utils.Args, reply *utils.Response) error {
 	// Chiamata al metodo ExecuteClientRequest con l'operazione "Get"
 	err := kvs.ExecuteClientRequest(args, reply, utils.Get)
@@ -335,6 +627,72 @@ func (kvs *KVSCausal) Put(args utils.Args, reply *utils.Response) error {
 	return nil
 }
 
+// Increment somma args.Delta al contatore args.Key (0 se la chiave non esiste) e riporta in reply.Value il valore
+// risultante sulla replica che ha ricevuto la richiesta. Le Increment concorrenti si sommano su ogni replica.
+func (kvs *KVSCausal) Increment(args utils.Args, reply *utils.Response) error {
+	return kvs.ExecuteClientRequest(args, reply, utils.Increment)
+}
+
+// Append accoda args.Value al valore di args.Key e riporta in reply.Value il valore risultante
+func (kvs *KVSCausal) Append(args utils.Args, reply *utils.Response) error {
+	return kvs.ExecuteClientRequest(args, reply, utils.Append)
+}
+
+// UpdateCRDT applica alla chiave args.Key l'aggiornamento del valore CRDT di tipo args.CRDT e riporta in
+// reply.CRDT il valore risultante sulla replica che ha ricevuto la richiesta. Gli aggiornamenti concorrenti
+// convergono allo stesso valore su ogni replica.
+func (kvs *KVSCausal) UpdateCRDT(args utils.Args, reply *utils.Response) error {
+	return kvs.ExecuteClientRequest(args, reply, utils.UpdateCRDT)
+}
+
+// CompareAndSwap è supportata solo nei namespace con consistenza sequenziale: nei namespace causali la richiesta
+// viene rifiutata da dispatch
+func (kvs *KVSCausal) CompareAndSwap(args utils.Args, reply *utils.Response) error {
+	return kvs.ExecuteClientRequest(args, reply, utils.CompareAndSwap)
+}
+
+// Txn è supportata solo nei namespace con consistenza sequenziale, come CompareAndSwap
+func (kvs *KVSCausal) Txn(args utils.Args, reply *utils.Response) error {
+	return kvs.ExecuteClientRequest(args, reply, utils.Txn)
+}
+
+// Batch esegue gli elementi di args.Batch come un unico messaggio del multicast causalmente ordinato: il Batch
+// occupa un solo turno FIFO del client e ogni replica ne applica gli elementi in sequenza
+func (kvs *KVSCausal) Batch(args utils.Args, reply *utils.Response) error {
+	return kvs.ExecuteClientRequest(args, reply, utils.Batch)
+}
+
+// Scan legge in ordine le chiavi nell'intervallo [args.Key, args.RangeEnd), al più args.Limit per pagina, dallo
+// stato della replica che riceve la richiesta
+func (kvs *KVSCausal) Scan(args utils.Args, reply *utils.Response) error {
+	return kvs.ExecuteClientRequest(args, reply, utils.Scan)
+}
+
+// ScanPrefix legge in ordine le chiavi che iniziano con args.Key, con le stesse regole di paginazione di Scan
+func (kvs *KVSCausal) ScanPrefix(args utils.Args, reply *utils.Response) error {
+	return kvs.ExecuteClientRequest(prefixRange(args), reply, utils.Scan)
+}
+
+// End segnala che il client ha terminato le proprie operazioni
+func (kvs *KVSCausal) End(args utils.Args, reply *utils.Response) error {
+	return kvs.ExecuteClientRequest(args, reply, utils.End)
+}
+
+// CreateNamespace crea il namespace args.NamespaceSpec tramite il sequencer del catalogo
+func (kvs *KVSCausal) CreateNamespace(args utils.Args, reply *utils.Response) error {
+	return kvs.ExecuteClientRequest(args, reply, utils.CreateNamespace)
+}
+
+// DropNamespace elimina il namespace args.NamespaceSpec.Name con tutte le sue chiavi
+func (kvs *KVSCausal) DropNamespace(args utils.Args, reply *utils.Response) error {
+	return kvs.ExecuteClientRequest(args, reply, utils.DropNamespace)
+}
+
+// ListNamespaces riporta in reply.Namespaces i namespace esistenti sulla replica, con il loro utilizzo
+func (kvs *KVSCausal) ListNamespaces(args utils.Args, reply *utils.Response) error {
+	return kvs.ExecuteClientRequest(args, reply, utils.ListNamespaces)
+}
+
 func (kvs *KVSCausal) Delete(args utils.Args, reply *utils.Response) error {
 	// Chiamata al metodo ExecuteClientRequest con l'operazione "Delete"
 	err := kvs.ExecuteClientRequest(args, reply, utils.Delete)
@@ -362,33 +720,34 @@ func (kvs *KVSCausal) printMapAfterExecution() {
 
 	time.Sleep(15 * time.Second)
 
-	// Stampa in maniera formattata il contenuto della map
+	// Stampa in maniera formattata il contenuto di ogni namespace
 	kvs.mapMutex.Lock()
 	defer kvs.mapMutex.Unlock()
+	printKeyspaces(kvs.keyspaces)
+}
 
-	// Trova la lunghezza massima delle chiavi e dei valori per la formattazione
-	maxKeyLen := 3 // La lunghezza minima per "Key"
-	maxValLen := 5 // La lunghezza minima per "Value"
-	for key, value := range kvs.store {
-		if len(key) > maxKeyLen {
-			maxKeyLen = len(key)
-		}
-		if len(value) > maxValLen {
-			maxValLen = len(value)
-		}
-	}
-
-	// Stampa l'intestazione della tabella
-	fmt.Println(strings.Repeat("-", maxKeyLen+maxValLen+7))
-	fmt.Printf("| %-*s | %-*s |\n", maxKeyLen, "Key", maxValLen, "Value")
-	fmt.Println(strings.Repeat("-", maxKeyLen+maxValLen+7))
-
-	// Stampa ogni riga della tabella con key e value
-	for key, value := range kvs.store {
-		fmt.Printf("| %-*s | %-*s |\n", maxKeyLen, key, maxValLen, value)
+// PeerLeaving riceve la notifica di uscita dal cluster di un'altra replica, che da questo momento non verrà
+// più considerata nell'invio dei messaggi e nelle condizioni di consegna
+func (kvs *KVSCausal) PeerLeaving(args utils.LeaveArgs, resp *utils.Response) error {
+	index, ok := utils.Peers.IndexOf(args.ServerID)
+	if !ok {
+		return fmt.Errorf("unknown server id %q", args.ServerID)
 	}
+	utils.MarkPeerLeft(index)
+	fmt.Printf("\033[35mServer %s left the cluster\033[0m\n", args.ServerID)
+	return nil
+}
 
-	// Stampa la linea di chiusura della tabella
-	fmt.Println(strings.Repeat("-", maxKeyLen+maxValLen+7))
+// Snapshot ritorna una copia del contenuto dello storage, per namespace
+func (kvs *KVSCausal) Snapshot() map[string]map[string][]byte {
+	kvs.mapMutex.Lock()
+	defer kvs.mapMutex.Unlock()
+	return snapshotKeyspaces(kvs.keyspaces)
+}
 
+// withKeyspaces esegue fn sui namespace dello storage con mapMutex acquisito
+func (kvs *KVSCausal) withKeyspaces(fn func(keyspaces map[string]*keyspace)) {
+	kvs.mapMutex.Lock()
+	defer kvs.mapMutex.Unlock()
+	fn(kvs.keyspaces)
 }
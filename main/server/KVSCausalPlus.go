@@ -0,0 +1,227 @@
+package main
+
+import (
+	"SDCC/main/utils"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+)
+
+/*
+ Consistenza causale+
+
+ I namespace con consistenza CausalPlus sono replicati alla maniera di COPS invece che con il multicast causalmente
+ ordinato. La replica che riceve una scrittura la applica subito, le assegna una versione (Stamp: clock di Lamport
+ e replica d'origine) e la invia in background alle altre repliche, ripetendo l'invio verso quelle che non la
+ ricevono finché non riesce o la replica lascia il cluster, insieme alle sole dipendenze più vicine: le
+ Dependencies indicate dal client, cioè le versioni che ha letto dopo la sua ultima scrittura e la scrittura stessa.
+ Le dipendenze più lontane sono implicate da quelle più vicine, quindi non serve un clock con una componente per
+ ogni replica.
+
+ Una replica rende visibile una scrittura ricevuta solo quando tutte le sue dipendenze sono visibili localmente:
+ per ogni chiave indicata, l'ultima versione applicata è almeno quella della dipendenza. Se non lo diventano entro
+ timeouts.session la scrittura viene rifiutata e la replica d'origine la invia di nuovo. Le scritture concorrenti
+ sulla stessa chiave convergono con last-writer-wins sulla versione (il "+" di causale+). Il clock di Lamport
+ della replica supera le versioni delle dipendenze prima di ogni scrittura, quindi una scrittura prevale sempre su
+ quelle da cui dipende.
+
+ Le letture e le scritture non attendono le altre repliche: la replica che riceve la richiesta attende soltanto di
+ vedere le dipendenze del client, che può aver letto o scritto su un'altra replica, per al massimo
+ timeouts.session. Creazione ed eliminazione di un namespace vengono invece inviate a tutte le repliche prima di
+ rispondere.
+*/
+
+// KVSCausalPlus ospita i namespace con consistenza causale+
+type KVSCausalPlus struct {
+	index     int                  //indice della replica corrente
+	keyspaces map[string]*keyspace //le chiavi di ogni namespace con consistenza causale+
+	mapMutex  sync.Mutex           //mutex per accedere alla Map
+	clock     lamportClock         //clock della replica, da cui derivano le versioni delle scritture
+	drainer                        //scritture ancora da inviare e messaggi in consegna, per lo spegnimento controllato
+}
+
+// NewKVSCausalPlus crea lo storage causale+ della replica index. Il namespace "default" non è mai causale+.
+func NewKVSCausalPlus(index int) *KVSCausalPlus {
+	return &KVSCausalPlus{
+		index:     index,
+		keyspaces: make(map[string]*keyspace),
+	}
+}
+
+// multicast esegue l'operazione op di un client sulla replica corrente e, se è una scrittura, la invia alle altre
+// repliche. Va chiamata solo dopo aver verificato l'ordine FIFO e i permessi.
+func (kvs *KVSCausalPlus) multicast(arg utils.Args, resp *utils.Response, op string) error {
+	if err := kvs.awaitDependencies(arg.Dependencies); err != nil {
+		return err
+	}
+
+	kvs.mapMutex.Lock()
+	ks, ok := liveKeyspace(kvs.keyspaces, arg)
+	if !ok {
+		kvs.mapMutex.Unlock()
+		return fmt.Errorf("%w: %q", utils.ErrNamespaceNotFound, arg.Namespace) //eliminato dopo il controllo
+	}
+
+	switch op {
+	case utils.Get:
+		defer kvs.mapMutex.Unlock()
+		value, found := ks.store.Get(arg.Key)
+		resp.Key, resp.Value, resp.Found, resp.IsPrintable = arg.Key, value, found, true
+		resp.Stamp = ks.stamps[arg.Key]
+		resp.Dependencies = kvs.readDependencies(arg.Dependencies, arg.Namespace, arg.Key, resp.Stamp)
+		fmt.Printf("Get operation completed. Key: %s, Value: %s, Version: %s\n", arg.Key, value, resp.Stamp)
+		return nil
+
+	case utils.Scan:
+		defer kvs.mapMutex.Unlock()
+		resp.ScanResults, resp.Cursor = scanRange(ks.store, arg)
+		resp.Dependencies = kvs.readDependencies(arg.Dependencies, arg.Namespace, "", utils.Stamp{})
+		for _, result := range resp.ScanResults {
+			resp.Dependencies = kvs.readDependencies(resp.Dependencies, arg.Namespace, result.Key, ks.stamps[result.Key])
+		}
+		fmt.Printf("Scan operation completed. Range: [%s, %s), Keys: %d\n", arg.Key, arg.RangeEnd, len(resp.ScanResults))
+		return nil
+
+	case utils.Put, utils.Delete:
+		if _, exists := ks.store.Get(arg.Key); op == utils.Delete && !exists {
+			kvs.mapMutex.Unlock()
+			return errors.New("delete operation failed. Key not found")
+		}
+		msg := utils.NewReplicationMessage(utils.CausalPlus, arg, kvs.index, op)
+		msg.Stamp = kvs.tick(arg.Dependencies)
+		msg.Dependencies = arg.Dependencies
+		applyStamped(ks, msg)
+		kvs.mapMutex.Unlock()
+
+		resp.Key, resp.Stamp = arg.Key, msg.Stamp
+		resp.Dependencies = []utils.Dependency{{Namespace: arg.Namespace, Key: arg.Key, Stamp: msg.Stamp}}
+
+		//La scrittura è già visibile sulla replica corrente: le altre la riceveranno in background
+		kvs.beginUpdate()
+		go kvs.replicate(*msg)
+		return nil
+	}
+
+	kvs.mapMutex.Unlock()
+	return fmt.Errorf("%w: %s on a %s namespace", utils.ErrNotSupported, op, utils.CausalPlus)
+}
+
+// readDependencies ritorna le dipendenze del client dopo aver letto la versione stamp di key: quelle indicate più
+// la versione letta, se la chiave è stata scritta almeno una volta
+func (kvs *KVSCausalPlus) readDependencies(deps []utils.Dependency, namespace string, key string, stamp utils.Stamp) []utils.Dependency {
+	deps = slices.Clone(deps)
+	if deps == nil {
+		deps = make([]utils.Dependency, 0)
+	}
+	if stamp == (utils.Stamp{}) {
+		return deps
+	}
+	return utils.MergeDependencies(deps, utils.Dependency{Namespace: namespace, Key: key, Stamp: stamp})
+}
+
+// replicate invia in background una scrittura locale alle altre repliche. L'invio verso una replica che non
+// risponde, o che non vede ancora le dipendenze della scrittura, viene ripetuto finché non riesce o la replica
+// lascia il cluster: il client ha già ricevuto la risposta, quindi la scrittura non può andare persa.
+func (kvs *KVSCausalPlus) replicate(msg utils.ReplicationMessage) {
+	defer kvs.end()
+
+	var pending []int
+	for i := 0; i < utils.NumberOfReplicas; i++ {
+		if i != kvs.index && !utils.HasPeerLeft(i) {
+			pending = append(pending, i)
+		}
+	}
+	for len(pending) > 0 {
+		replies := utils.ReplicateTo(msg, pending, nil)
+		var failed []int
+		for range pending {
+			if reply := <-replies; reply.Err != nil && !utils.HasPeerLeft(reply.Index) {
+				failed = append(failed, reply.Index)
+			}
+		}
+		if pending = failed; len(pending) > 0 {
+			time.Sleep(SLEEP_TIME)
+		}
+	}
+}
+
+// Replicate consegna una scrittura inviata da un'altra replica: attende che tutte le sue dipendenze siano visibili
+// e la applica se è più recente della versione corrente della chiave. Se le dipendenze non diventano visibili
+// entro timeouts.session la scrittura viene rifiutata e la replica d'origine ripete l'invio più tardi, così un
+// messaggio in attesa non blocca lo spegnimento controllato.
+func (kvs *KVSCausalPlus) Replicate(msg utils.ReplicationMessage, resp *utils.Response) error {
+	kvs.beginUpdate()
+	defer kvs.end()
+	kvs.clock.observe(msg.Stamp)
+
+	fmt.Printf("MSG %s ready to wait for dependencies\n"+
+		"OP: %s, (%s, %s), version = %s, dependencies = %v\n", msg.UUID, msg.OpType, msg.Args.Key, msg.Args.Value, msg.Stamp, msg.Dependencies)
+	if err := kvs.awaitDependencies(msg.Dependencies); err != nil {
+		return err
+	}
+	fmt.Printf("\033[33mMSG %s ready to exec operation\033[0m\n", msg.UUID)
+
+	kvs.mapMutex.Lock()
+	defer kvs.mapMutex.Unlock()
+	ks, ok := liveKeyspace(kvs.keyspaces, msg.Args)
+	if !ok {
+		fmt.Printf("%s operation skipped, namespace %s not found\n", msg.OpType, msg.Args.Namespace)
+		return nil
+	}
+	resp.Key = msg.Args.Key
+	resp.Succeeded = applyStamped(ks, &msg)
+	return nil
+}
+
+// awaitDependencies attende che le dipendenze del client siano visibili sulla replica corrente. Se non lo
+// diventano entro timeouts.session la richiesta viene rifiutata, come per il clock di sessione dei namespace causali.
+func (kvs *KVSCausalPlus) awaitDependencies(deps []utils.Dependency) error {
+	deadline := time.Now().Add(utils.Conf.Timeouts.Session)
+	for !kvs.visible(deps) {
+		if time.Now().After(deadline) {
+			fmt.Printf("\033[31mDependencies %v not visible\033[0m\n", deps)
+			return fmt.Errorf("%w: dependencies %v are not yet visible", utils.ErrSessionBehind, deps)
+		}
+		time.Sleep(SLEEP_TIME)
+	}
+	return nil
+}
+
+// visible indica se tutte le dipendenze sono visibili: la versione applicata di ogni chiave è almeno quella
+// indicata. Le dipendenze su namespace che la replica non ospita (eliminati, o non causali+) sono soddisfatte.
+func (kvs *KVSCausalPlus) visible(deps []utils.Dependency) bool {
+	kvs.mapMutex.Lock()
+	defer kvs.mapMutex.Unlock()
+	for _, dep := range deps {
+		ks, ok := kvs.keyspaces[dep.Namespace]
+		if ok && ks.stamps[dep.Key].Less(dep.Stamp) {
+			return false
+		}
+	}
+	return true
+}
+
+// tick ritorna la versione di una nuova scrittura, successiva a quelle delle sue dipendenze
+func (kvs *KVSCausalPlus) tick(deps []utils.Dependency) utils.Stamp {
+	floor := 0
+	for _, dep := range deps {
+		floor = max(floor, dep.Stamp.Clock)
+	}
+	return kvs.clock.tick(kvs.index, floor)
+}
+
+// Snapshot ritorna una copia del contenuto dello storage, per namespace
+func (kvs *KVSCausalPlus) Snapshot() map[string]map[string][]byte {
+	kvs.mapMutex.Lock()
+	defer kvs.mapMutex.Unlock()
+	return snapshotKeyspaces(kvs.keyspaces)
+}
+
+// withKeyspaces esegue fn sui namespace dello storage con mapMutex acquisito
+func (kvs *KVSCausalPlus) withKeyspaces(fn func(keyspaces map[string]*keyspace)) {
+	kvs.mapMutex.Lock()
+	defer kvs.mapMutex.Unlock()
+	fn(kvs.keyspaces)
+}
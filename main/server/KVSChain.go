@@ -0,0 +1,593 @@
+package main
+
+import (
+	"SDCC/main/utils"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"slices"
+	"sync"
+	"time"
+)
+
+/*
+ Replicazione a catena
+
+ I namespace con consistenza Chain sono replicati a catena: le repliche sono ordinate in una catena, all'inizio
+ dalla replica di indice più basso (la testa) a quella di indice più alto (la coda). Ogni scrittura viene inoltrata
+ alla testa, che le assegna un numero di sequenza, la applica e la invia alla replica successiva; ogni replica la
+ applica dopo tutte le precedenti e la invia a sua volta alla successiva. Quando la coda l'ha applicata la
+ scrittura è confermata, e la risposta risale la catena fino alla testa. Le letture vengono servite dalla coda,
+ quindi vedono solo scritture confermate e mai un valore più vecchio di quelli già letti.
+
+ La testa controlla le altre repliche ogni chain.heartbeat e le altre controllano la testa. Se una replica non
+ risponde per chain.failure_timeout, chi se ne accorge propone la configurazione (view) successiva a tutte le
+ repliche (Promise). Chi la accetta promette di non applicare più scritture e trasferimenti delle view precedenti
+ e riporta la propria view e l'ultima scrittura applicata. Raccolte le promesse di una maggioranza, la nuova catena
+ è formata dalle repliche che hanno promesso, con in testa quella con l'ultima scrittura più recente, a parità
+ quella di indice più basso; la testa scelta viene promossa (StartView) e trasferisce il proprio stato a tutte le
+ altre insieme alla nuova catena (Sync). Ogni catena contiene una maggioranza, e ogni replica della catena ha tutte
+ le scritture confermate, quindi la maggioranza delle promesse ne contiene almeno una e la nuova testa le ha tutte;
+ due teste non possono essere promosse nella stessa view. Lo stesso trasferimento riallinea una replica che ha perso
+ una scrittura, e una replica esclusa ma ancora attiva che torna a contattare la testa rientra nella catena con la
+ view successiva. Una replica che ha promesso una view senza riceverne lo stato entro failure_timeout, più un
+ ritardo casuale, propone a sua volta la successiva.
+
+ Il controllo delle repliche parte con il primo namespace a catena, quando tutte le repliche sono attive.
+*/
+
+// KVSChain ospita i namespace con consistenza Chain
+type KVSChain struct {
+	index int //indice della replica corrente
+
+	sequencedKeyspaces //le chiavi di ogni namespace a catena, con il mutex per accedervi
+
+	viewMutex   sync.Mutex        //mutex per la configurazione, da acquisire prima di mapMutex
+	view        int               //configurazione corrente
+	promised    int               //view più alta promessa: i messaggi delle view precedenti vengono rifiutati
+	retryAt     time.Time         //istante dopo cui una view promessa e rimasta senza testa viene riproposta
+	chain       []int             //repliche della catena, dalla testa alla coda
+	applied     int               //numero di sequenza dell'ultima scrittura applicata
+	lastContact map[int]time.Time //ultima risposta di ogni replica ai controlli periodici
+	monitor     sync.Once         //avvio del controllo periodico
+
+	drainer //scritture e messaggi in corso, per lo spegnimento controllato
+}
+
+// NewKVSChain crea lo storage a catena della replica index. Il namespace "default" non è mai a catena.
+func NewKVSChain(index int) *KVSChain {
+	chain := make([]int, utils.NumberOfReplicas)
+	for i := range chain {
+		chain[i] = i
+	}
+	return &KVSChain{
+		index:              index,
+		sequencedKeyspaces: newSequencedKeyspaces(),
+		chain:              chain,
+		lastContact:        make(map[int]time.Time),
+	}
+}
+
+// multicast esegue l'operazione op di un client: le letture sono servite dalla coda, tutto il resto entra dalla
+// testa. Va chiamata solo dopo aver verificato l'ordine FIFO e i permessi.
+func (kvs *KVSChain) multicast(arg utils.Args, resp *utils.Response, op string) error {
+	msg := utils.NewReplicationMessage(utils.Chain, arg, kvs.index, op)
+	head, tail := kvs.ends()
+	target := head
+	if op == utils.Get || op == utils.Scan {
+		target = tail
+	}
+
+	var err error
+	if target == kvs.index {
+		err = kvs.serve(msg, resp)
+	} else {
+		utils.NetworkDelay()
+		if err = utils.Replicate(target, *msg, resp); err != nil {
+			err = fmt.Errorf("%w: forwarding %s to %s: %v", utils.ErrChainUnavailable, op, utils.Peers.ID(target), err)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	if op == utils.Delete && !resp.Found {
+		return errors.New("delete operation failed. Key not found")
+	}
+	return nil
+}
+
+// ends ritorna la testa e la coda della catena
+func (kvs *KVSChain) ends() (int, int) {
+	kvs.viewMutex.Lock()
+	defer kvs.viewMutex.Unlock()
+	return kvs.chain[0], kvs.chain[len(kvs.chain)-1]
+}
+
+// successor ritorna la replica che segue la corrente nella catena, -1 se è la coda o non fa parte della catena.
+// Va chiamata con viewMutex già acquisito.
+func (kvs *KVSChain) successor() int {
+	i := slices.Index(kvs.chain, kvs.index)
+	if i < 0 || i == len(kvs.chain)-1 {
+		return -1
+	}
+	return kvs.chain[i+1]
+}
+
+// members ritorna le repliche della catena tranne la corrente. Va chiamata con viewMutex già acquisito.
+func (kvs *KVSChain) members() []int {
+	return slices.DeleteFunc(slices.Clone(kvs.chain), func(i int) bool { return i == kvs.index })
+}
+
+// serve esegue una richiesta di un client sulla coda (letture) o sulla testa (tutto il resto), ricevuta
+// direttamente o inoltrata da un'altra replica. L'esito di una Delete è riportato nella risposta (Found).
+func (kvs *KVSChain) serve(msg *utils.ReplicationMessage, resp *utils.Response) error {
+	if msg.OpType == utils.Get || msg.OpType == utils.Scan {
+		if _, tail := kvs.ends(); tail != kvs.index {
+			return fmt.Errorf("%w: %s is not the tail", utils.ErrChainUnavailable, utils.Peers.ID(kvs.index))
+		}
+		kvs.read(msg, resp)
+		return nil
+	}
+	return kvs.sequence(msg, resp)
+}
+
+// sequence assegna alla scrittura il numero di sequenza successivo, la applica e la invia lungo la catena,
+// attendendo che la coda l'abbia applicata. Se una replica l'ha rifiutata, la scrittura è confermata solo se tutta
+// la catena accetta il trasferimento dello stato della testa.
+func (kvs *KVSChain) sequence(msg *utils.ReplicationMessage, resp *utils.Response) error {
+	kvs.viewMutex.Lock()
+	if kvs.chain[0] != kvs.index || kvs.promised > kvs.view {
+		kvs.viewMutex.Unlock()
+		return fmt.Errorf("%w: %s is not the head", utils.ErrChainUnavailable, utils.Peers.ID(kvs.index))
+	}
+	if msg.OpType == utils.Delete && !kvs.exists(msg.Args) {
+		kvs.viewMutex.Unlock()
+		resp.Key, resp.Found = msg.Args.Key, false //una Delete che non ha effetto non va ordinata
+		return nil
+	}
+	msg.ServerIndex = kvs.index
+	msg.View = kvs.view
+	msg.Sequence = kvs.applied + 1
+	msg.Stamp = utils.Stamp{Clock: msg.Sequence, Origin: kvs.index}
+	kvs.apply(msg, resp)
+	view, next := kvs.view, kvs.successor()
+	kvs.viewMutex.Unlock()
+
+	if next >= 0 {
+		downstream := utils.NewResponse()
+		utils.NetworkDelay()
+		if err := utils.Replicate(next, *msg, downstream); err != nil {
+			//La scrittura resta applicata dalla testa: la riconfigurazione la trasferirà alle altre repliche
+			return fmt.Errorf("%w: %s of key %q not acknowledged by the tail: %v", utils.ErrChainUnavailable, msg.OpType, msg.Args.Key, err)
+		}
+		switch {
+		case downstream.Succeeded:
+		case downstream.View > view:
+			kvs.stepDown(downstream)
+			return fmt.Errorf("%w: a new head was elected while applying the %s", utils.ErrChainUnavailable, msg.OpType)
+		default:
+			//Una replica ha perso scritture precedenti: il trasferimento dello stato della testa conferma anche questa
+			if err := kvs.resyncChain(); err != nil {
+				return err
+			}
+		}
+	}
+	fmt.Printf("%s operation committed. Key: %s, Sequence: %d, View: %d\n", msg.OpType, msg.Args.Key, msg.Sequence, view)
+	return nil
+}
+
+// apply applica una scrittura ordinata dalla testa e ne avanza il numero di sequenza. Va chiamata con viewMutex
+// già acquisito.
+func (kvs *KVSChain) apply(msg *utils.ReplicationMessage, resp *utils.Response) {
+	kvs.applied = msg.Sequence
+	kvs.applySequenced(msg, resp)
+}
+
+// Replicate riceve i messaggi delle altre repliche: scritture che percorrono la catena, trasferimenti di stato,
+// controlli periodici, cambi di view e richieste dei client inoltrate alla testa o alla coda
+func (kvs *KVSChain) Replicate(msg utils.ReplicationMessage, resp *utils.Response) error {
+	kvs.beginUpdate()
+	defer kvs.end()
+	switch {
+	case msg.OpType == utils.Heartbeat:
+		kvs.heartbeatReceived(msg, resp)
+		return nil
+	case msg.OpType == utils.Sync:
+		kvs.syncReceived(msg, resp)
+		return nil
+	case msg.OpType == utils.Promise:
+		kvs.promiseReceived(msg, resp)
+		return nil
+	case msg.OpType == utils.StartView:
+		kvs.startViewReceived(msg, resp)
+		return nil
+	case msg.Sequence > 0:
+		return kvs.deliver(msg, resp)
+	}
+	return kvs.serve(&msg, resp)
+}
+
+// deliver applica una scrittura ricevuta dalla replica precedente, dopo tutte quelle che la precedono, e la invia
+// alla successiva: la risposta è quella della coda. Se le precedenti non arrivano entro failure_timeout, o il
+// messaggio appartiene a un'altra view o a una view precedente a quella promessa, la scrittura non viene applicata
+// e la risposta riporta la configurazione della replica: la testa la riallineerà con un trasferimento di stato, o
+// scoprirà di essere stata sostituita.
+func (kvs *KVSChain) deliver(msg utils.ReplicationMessage, resp *utils.Response) error {
+	deadline := time.Now().Add(utils.Conf.Chain.FailureTimeout)
+	for {
+		kvs.viewMutex.Lock()
+		resp.View, resp.Stamp = kvs.view, utils.Stamp{Clock: kvs.applied, Origin: kvs.chain[0]}
+		if msg.View == kvs.view && msg.ServerIndex == kvs.chain[0] && kvs.promised <= kvs.view {
+			if msg.Sequence <= kvs.applied {
+				resp.Succeeded = true //già applicata, per esempio con un trasferimento di stato
+				kvs.viewMutex.Unlock()
+				return nil
+			}
+			if msg.Sequence == kvs.applied+1 {
+				kvs.apply(&msg, utils.NewResponse())
+				next := kvs.successor()
+				kvs.viewMutex.Unlock()
+				if next < 0 {
+					resp.Succeeded = true //la coda conferma la scrittura
+					return nil
+				}
+				utils.NetworkDelay()
+				return utils.Replicate(next, msg, resp)
+			}
+		}
+		stale := msg.View < max(kvs.view, kvs.promised)
+		kvs.viewMutex.Unlock()
+
+		if stale || time.Now().After(deadline) {
+			fmt.Printf("\033[31mMSG %s (view %d, sequence %d) not applied, replica is at view %d\033[0m\n", msg.UUID, msg.View, msg.Sequence, resp.View)
+			return nil
+		}
+		time.Sleep(SLEEP_TIME)
+	}
+}
+
+// resyncChain trasferisce lo stato e la catena della testa a tutte le altre repliche della catena. Ritorna un
+// errore se una di esse non l'ha accettato.
+func (kvs *KVSChain) resyncChain() error {
+	kvs.viewMutex.Lock()
+	if kvs.chain[0] != kvs.index {
+		kvs.viewMutex.Unlock()
+		return fmt.Errorf("%w: %s is not the head", utils.ErrChainUnavailable, utils.Peers.ID(kvs.index))
+	}
+	msg := kvs.syncMessage()
+	members := kvs.members()
+	kvs.viewMutex.Unlock()
+
+	fmt.Printf("\033[35mSending state of view %d (sequence %d) to chain %v\033[0m\n", msg.View, msg.Sequence, msg.Chain)
+	var err error
+	replies := utils.ReplicateTo(*msg, members, nil)
+	for range members {
+		reply := <-replies
+		switch {
+		case reply.Err != nil:
+			err = fmt.Errorf("%w: state transfer to %s: %v", utils.ErrChainUnavailable, utils.Peers.ID(reply.Index), reply.Err)
+		case reply.Response.View > msg.View:
+			kvs.stepDown(reply.Response)
+			err = fmt.Errorf("%w: a new head was elected", utils.ErrChainUnavailable)
+		case !reply.Response.Succeeded:
+			err = fmt.Errorf("%w: state of view %d rejected by %s", utils.ErrChainUnavailable, msg.View, utils.Peers.ID(reply.Index))
+		}
+	}
+	return err
+}
+
+// resync trasferisce lo stato e la catena della testa alla replica member
+func (kvs *KVSChain) resync(member int) {
+	kvs.viewMutex.Lock()
+	if kvs.chain[0] != kvs.index {
+		kvs.viewMutex.Unlock()
+		return
+	}
+	msg := kvs.syncMessage()
+	kvs.viewMutex.Unlock()
+
+	fmt.Printf("\033[35mSending state of view %d (sequence %d) to server %s\033[0m\n", msg.View, msg.Sequence, utils.Peers.ID(member))
+	resp := utils.NewResponse()
+	if err := utils.Replicate(member, *msg, resp); err == nil && resp.View > msg.View {
+		kvs.stepDown(resp)
+	}
+}
+
+// syncMessage crea il trasferimento dello stato e della catena correnti. Va chiamata con viewMutex già acquisito.
+func (kvs *KVSChain) syncMessage() *utils.ReplicationMessage {
+	msg := utils.NewReplicationMessage(utils.Chain, utils.Args{}, kvs.index, utils.Sync)
+	msg.View, msg.Sequence, msg.Chain = kvs.view, kvs.applied, slices.Clone(kvs.chain)
+	msg.State = kvs.states()
+	return msg
+}
+
+// syncReceived adotta la catena e lo stato trasferiti dalla testa di una view uguale o successiva alla corrente e a
+// quella promessa. Le modifiche non vengono notificate ai Watch.
+func (kvs *KVSChain) syncReceived(msg utils.ReplicationMessage, resp *utils.Response) {
+	kvs.viewMutex.Lock()
+	defer kvs.viewMutex.Unlock()
+	resp.View, resp.Stamp = kvs.view, utils.Stamp{Clock: kvs.applied, Origin: kvs.chain[0]}
+	if msg.View < max(kvs.view, kvs.promised) || (msg.View == kvs.view && msg.ServerIndex != kvs.chain[0]) {
+		fmt.Printf("\033[31mState of view %d from server %s rejected, replica is at view %d\033[0m\n", msg.View, utils.Peers.ID(msg.ServerIndex), kvs.view)
+		return
+	}
+	if msg.View == kvs.view && msg.Sequence <= kvs.applied {
+		resp.Succeeded = true //le scritture trasferite sono già state applicate
+		return
+	}
+
+	kvs.view, kvs.chain, kvs.applied = msg.View, msg.Chain, msg.Sequence
+	kvs.promised = max(kvs.promised, msg.View)
+	kvs.lastContact[msg.ServerIndex] = time.Now()
+
+	kvs.restore(msg.State, msg.ServerIndex)
+
+	if len(msg.State) > 0 {
+		kvs.startMonitor()
+	}
+	resp.View, resp.Succeeded = kvs.view, true
+	fmt.Printf("\033[35mChain %v of view %d (sequence %d) received from head %s\033[0m\n", msg.Chain, msg.View, msg.Sequence, utils.Peers.ID(msg.ServerIndex))
+}
+
+// heartbeatReceived risponde al controllo periodico di un'altra replica con la propria configurazione, l'ultima
+// scrittura applicata e la testa. La testa riallinea le repliche rimaste a una view precedente e propone una nuova
+// view quando una replica esclusa dalla catena torna a contattarla.
+func (kvs *KVSChain) heartbeatReceived(msg utils.ReplicationMessage, resp *utils.Response) {
+	kvs.viewMutex.Lock()
+	defer kvs.viewMutex.Unlock()
+	resp.View, resp.Stamp = kvs.view, utils.Stamp{Clock: kvs.applied, Origin: kvs.chain[0]}
+	resp.Succeeded = true
+	kvs.lastContact[msg.ServerIndex] = time.Now()
+	if kvs.chain[0] != kvs.index {
+		return
+	}
+
+	switch {
+	case !slices.Contains(kvs.chain, msg.ServerIndex) && kvs.promised == kvs.view:
+		fmt.Printf("\033[35mServer %s wants to rejoin the chain %v\033[0m\n", utils.Peers.ID(msg.ServerIndex), kvs.chain)
+		go kvs.reconfigure(kvs.view)
+	case msg.View < kvs.view:
+		go kvs.resync(msg.ServerIndex)
+	}
+}
+
+// startMonitor avvia il controllo periodico, se non è già attivo
+func (kvs *KVSChain) startMonitor() {
+	kvs.monitor.Do(func() { go kvs.checkPeriodically() })
+}
+
+// checkPeriodically esegue il controllo periodico: la testa controlla le altre repliche della catena, le altre
+// controllano la testa. Una replica che attende troppo a lungo la testa di una view promessa propone la successiva.
+func (kvs *KVSChain) checkPeriodically() {
+	kvs.viewMutex.Lock()
+	for i := 0; i < utils.NumberOfReplicas; i++ {
+		kvs.lastContact[i] = time.Now()
+	}
+	kvs.viewMutex.Unlock()
+	for {
+		time.Sleep(utils.Conf.Chain.Heartbeat)
+		if stalled, current := kvs.electionStalled(); stalled {
+			kvs.reconfigure(current)
+		} else if head, _ := kvs.ends(); head == kvs.index {
+			kvs.checkMembers()
+		} else {
+			kvs.checkHead()
+		}
+	}
+}
+
+// checkMembers invia il controllo periodico alle altre repliche della catena e, se qualcuna non risponde da
+// failure_timeout, propone una nuova view per escluderla
+func (kvs *KVSChain) checkMembers() {
+	kvs.viewMutex.Lock()
+	msg := utils.NewReplicationMessage(utils.Chain, utils.Args{}, kvs.index, utils.Heartbeat)
+	msg.View, msg.Sequence = kvs.view, kvs.applied
+	members := kvs.members()
+	kvs.viewMutex.Unlock()
+
+	//Una replica sospesa può non rispondere affatto: chi non risponde entro il prossimo controllo viene ignorato
+	got, _ := awaitReplies(utils.ReplicateTo(*msg, members, nil), len(members), len(members), time.Now().Add(utils.Conf.Chain.Heartbeat))
+	for _, reply := range got {
+		kvs.viewMutex.Lock()
+		kvs.lastContact[reply.Index] = time.Now()
+		kvs.viewMutex.Unlock()
+		if reply.Response.View > msg.View {
+			kvs.stepDown(reply.Response)
+		}
+	}
+
+	kvs.viewMutex.Lock()
+	if kvs.chain[0] != kvs.index || kvs.promised > kvs.view {
+		kvs.viewMutex.Unlock()
+		return //una view proposta viene riproposta solo dopo retryAt
+	}
+	failed := slices.DeleteFunc(kvs.members(), func(i int) bool {
+		return time.Since(kvs.lastContact[i]) < utils.Conf.Chain.FailureTimeout
+	})
+	view := kvs.view
+	kvs.viewMutex.Unlock()
+	if len(failed) > 0 {
+		fmt.Printf("\033[31mServers %v suspected failed\033[0m\n", failed)
+		kvs.reconfigure(view)
+	}
+}
+
+// checkHead invia il controllo periodico alla testa. Se non risponde per failure_timeout viene considerata guasta
+// e la replica corrente propone una nuova view.
+func (kvs *KVSChain) checkHead() {
+	kvs.viewMutex.Lock()
+	head := kvs.chain[0]
+	msg := utils.NewReplicationMessage(utils.Chain, utils.Args{}, kvs.index, utils.Heartbeat)
+	msg.View, msg.Sequence = kvs.view, kvs.applied
+	kvs.viewMutex.Unlock()
+
+	got, _ := awaitReplies(utils.ReplicateTo(*msg, []int{head}, nil), 1, 1, time.Now().Add(utils.Conf.Chain.Heartbeat))
+
+	kvs.viewMutex.Lock()
+	if kvs.chain[0] != head {
+		kvs.viewMutex.Unlock()
+		return //configurazione cambiata durante il controllo
+	}
+	if len(got) == 1 {
+		kvs.lastContact[head] = time.Now()
+		kvs.follow(got[0].Response) //la testa può essere stata sostituita: la nuova trasferirà la catena
+		kvs.viewMutex.Unlock()
+		return
+	}
+	failed := time.Since(kvs.lastContact[head]) >= utils.Conf.Chain.FailureTimeout && kvs.promised == kvs.view
+	view := kvs.view
+	kvs.viewMutex.Unlock()
+
+	if failed {
+		fmt.Printf("\033[31mHead %s suspected failed\033[0m\n", utils.Peers.ID(head))
+		kvs.reconfigure(view)
+	}
+}
+
+// follow fa contattare alla replica la testa riportata da una replica che ha una view successiva: la nuova testa
+// le trasferirà la catena. Va chiamata con viewMutex già acquisito.
+func (kvs *KVSChain) follow(newer *utils.Response) {
+	if newer.View <= kvs.view || newer.Stamp.Origin == kvs.chain[0] {
+		return
+	}
+	kvs.chain = []int{newer.Stamp.Origin}
+	kvs.lastContact[newer.Stamp.Origin] = time.Now()
+}
+
+// electionStalled indica se la replica ha promesso una view di cui non ha ricevuto lo stato in tempo, e ritorna la
+// view più alta promessa
+func (kvs *KVSChain) electionStalled() (bool, int) {
+	kvs.viewMutex.Lock()
+	defer kvs.viewMutex.Unlock()
+	return kvs.promised > kvs.view && time.Now().After(kvs.retryAt), kvs.promised
+}
+
+// promise registra la promessa della view e fissa il momento in cui riproporla se resta senza testa: dopo
+// failure_timeout più un ritardo casuale, così due repliche che propongono la stessa view non si ripetono
+// all'infinito. Va chiamata con viewMutex già acquisito.
+func (kvs *KVSChain) promise(view int) {
+	timeout := utils.Conf.Chain.FailureTimeout
+	kvs.promised, kvs.retryAt = view, time.Now().Add(timeout+rand.N(timeout))
+}
+
+// reconfigure propone a tutte le repliche la view successiva a current, se nel frattempo la replica non ne ha
+// adottata o promessa un'altra. Raccolte le promesse di una maggioranza, compresa quella della replica corrente, la
+// nuova catena è formata da chi ha promesso, con in testa la replica con l'ultima scrittura più recente. Se la
+// maggioranza non risponde, la view verrà riproposta più tardi.
+func (kvs *KVSChain) reconfigure(current int) {
+	kvs.viewMutex.Lock()
+	if max(kvs.view, kvs.promised) != current {
+		kvs.viewMutex.Unlock()
+		return
+	}
+	view := current + 1
+	kvs.promise(view)
+	msg := utils.NewReplicationMessage(utils.Chain, utils.Args{}, kvs.index, utils.Promise)
+	msg.View = view
+	best := utils.ReplicaReply{Index: kvs.index, Response: &utils.Response{View: kvs.view, Stamp: utils.Stamp{Clock: kvs.applied}}}
+	var peers []int
+	for i := 0; i < utils.NumberOfReplicas; i++ {
+		if i != kvs.index && !utils.HasPeerLeft(i) {
+			peers = append(peers, i) //anche le repliche escluse dalla catena, che se attive vi rientrano
+		}
+	}
+	kvs.viewMutex.Unlock()
+
+	fmt.Printf("\033[35mServer %s proposing view %d\033[0m\n", utils.Peers.ID(kvs.index), view)
+	//Chi promette entra nella catena: una replica attiva la cui risposta arriva in ritardo ne verrebbe esclusa
+	got, _ := awaitReplies(utils.ReplicateTo(*msg, peers, nil), len(peers), len(peers), time.Now().Add(utils.Conf.Chain.FailureTimeout))
+	chain := []int{kvs.index}
+	var newer *utils.Response
+	for _, reply := range got {
+		if !reply.Response.Succeeded {
+			if newer == nil || reply.Response.View > newer.View {
+				newer = reply.Response
+			}
+			continue
+		}
+		chain = append(chain, reply.Index)
+		if moreRecent(reply, best) {
+			best = reply
+		}
+	}
+	if majority := utils.NumberOfReplicas/2 + 1; len(chain) < majority {
+		fmt.Printf("\033[31mView %d promised by %d of %d replicas, a majority is %d\033[0m\n", view, len(chain), utils.NumberOfReplicas, majority)
+		if newer != nil {
+			kvs.viewMutex.Lock()
+			kvs.follow(newer)
+			kvs.viewMutex.Unlock()
+		}
+		return
+	}
+	slices.Sort(chain)
+	chain = append([]int{best.Index}, slices.DeleteFunc(chain, func(i int) bool { return i == best.Index })...)
+
+	if best.Index == kvs.index {
+		kvs.viewMutex.Lock()
+		defer kvs.viewMutex.Unlock()
+		if kvs.promised == view && kvs.view < view {
+			kvs.promote(view, chain)
+		}
+		return
+	}
+	msg = utils.NewReplicationMessage(utils.Chain, utils.Args{}, kvs.index, utils.StartView)
+	msg.View, msg.Chain = view, chain
+	fmt.Printf("\033[35mServer %s chosen as head of view %d: %v\033[0m\n", utils.Peers.ID(best.Index), view, chain)
+	utils.NetworkDelay()
+	if err := utils.Replicate(best.Index, *msg, utils.NewResponse()); err != nil {
+		fmt.Printf("\033[31mFailed to start view %d on server %s: %v\033[0m\n", view, utils.Peers.ID(best.Index), err)
+	}
+}
+
+// promiseReceived accetta la view proposta da un'altra replica se è successiva alla corrente e a quelle già
+// promesse: da quel momento la replica rifiuta scritture e trasferimenti delle view precedenti. La risposta
+// riporta la view, l'ultima scrittura applicata e la testa.
+func (kvs *KVSChain) promiseReceived(msg utils.ReplicationMessage, resp *utils.Response) {
+	kvs.viewMutex.Lock()
+	defer kvs.viewMutex.Unlock()
+	resp.View, resp.Stamp = kvs.view, utils.Stamp{Clock: kvs.applied, Origin: kvs.chain[0]}
+	if msg.View <= max(kvs.view, kvs.promised) {
+		fmt.Printf("\033[31mView %d proposed by server %s rejected, replica promised view %d\033[0m\n", msg.View, utils.Peers.ID(msg.ServerIndex), max(kvs.view, kvs.promised))
+		return
+	}
+	kvs.promise(msg.View)
+	resp.Succeeded = true
+	fmt.Printf("\033[35mView %d proposed by server %s promised (sequence %d)\033[0m\n", msg.View, utils.Peers.ID(msg.ServerIndex), kvs.applied)
+}
+
+// startViewReceived promuove la replica a testa della catena della view che ha promesso, scelta da chi ha raccolto
+// le promesse della maggioranza
+func (kvs *KVSChain) startViewReceived(msg utils.ReplicationMessage, resp *utils.Response) {
+	kvs.viewMutex.Lock()
+	defer kvs.viewMutex.Unlock()
+	resp.View, resp.Stamp = kvs.view, utils.Stamp{Clock: kvs.applied, Origin: kvs.chain[0]}
+	if msg.View != kvs.promised || msg.View <= kvs.view || len(msg.Chain) == 0 || msg.Chain[0] != kvs.index {
+		fmt.Printf("\033[31mView %d started by server %s rejected, replica promised view %d\033[0m\n", msg.View, utils.Peers.ID(msg.ServerIndex), kvs.promised)
+		return
+	}
+	kvs.promote(msg.View, msg.Chain)
+	resp.View, resp.Succeeded = kvs.view, true
+}
+
+// promote rende la replica corrente testa della catena chain nella view e trasferisce il proprio stato alle altre
+// repliche della catena. Va chiamata con viewMutex già acquisito.
+func (kvs *KVSChain) promote(view int, chain []int) {
+	kvs.view, kvs.chain = view, chain
+	for _, member := range kvs.members() {
+		kvs.lastContact[member] = time.Now() //finora controllavano la vecchia testa
+	}
+	fmt.Printf("\033[35mServer %s promoted to head of view %d: %v\033[0m\n", utils.Peers.ID(kvs.index), kvs.view, kvs.chain)
+	go func() { _ = kvs.resyncChain() }()
+}
+
+// stepDown fa uscire dalla catena una testa che ha scoperto una view successiva alla propria: tornerà a contattare
+// la nuova testa, che la farà rientrare nella catena con la view successiva
+func (kvs *KVSChain) stepDown(newer *utils.Response) {
+	kvs.viewMutex.Lock()
+	defer kvs.viewMutex.Unlock()
+	if kvs.chain[0] != kvs.index || newer.View <= kvs.view {
+		return
+	}
+	fmt.Printf("\033[31mServer %s replaced by head %s of view %d\033[0m\n", utils.Peers.ID(kvs.index), utils.Peers.ID(newer.Stamp.Origin), newer.View)
+	kvs.follow(newer)
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"SDCC/main/utils"
+	"testing"
+)
+
+// chainMessage ritorna il messaggio op della replica origin nella view indicata
+func chainMessage(op string, origin int, view int, sequence int) utils.ReplicationMessage {
+	msg := utils.NewReplicationMessage(utils.Chain, utils.Args{Key: "k", Value: []byte("v")}, origin, op)
+	msg.View, msg.Sequence = view, sequence
+	return *msg
+}
+
+func TestChainPromiseRejectsOlderView(t *testing.T) {
+	kvs := NewKVSChain(1)
+
+	//La replica promette la view 1 a un solo candidato
+	resp := utils.NewResponse()
+	kvs.promiseReceived(chainMessage(utils.Promise, 2, 1, 0), resp)
+	if !resp.Succeeded {
+		t.Fatal("view 1 not promised")
+	}
+	resp = utils.NewResponse()
+	kvs.promiseReceived(chainMessage(utils.Promise, 0, 1, 0), resp)
+	if resp.Succeeded {
+		t.Fatal("view 1 promised twice")
+	}
+
+	//La testa della view 0 non può più far percorrere la catena alle scritture né trasferire il proprio stato
+	resp = utils.NewResponse()
+	if err := kvs.deliver(chainMessage(utils.Put, 0, 0, 1), resp); err != nil || resp.Succeeded || kvs.applied != 0 {
+		t.Fatalf("write of view 0 applied after promising view 1 (applied %d, err %v)", kvs.applied, err)
+	}
+	state := chainMessage(utils.Sync, 0, 0, 1)
+	state.Chain = []int{0, 1, 2}
+	resp = utils.NewResponse()
+	kvs.syncReceived(state, resp)
+	if resp.Succeeded || kvs.view != 0 {
+		t.Fatal("state of view 0 accepted after promising view 1")
+	}
+
+	//Solo la replica in testa alla catena della view promessa diventa testa. La catena del test non ha altre
+	//repliche, così la promozione non tenta di trasferire loro lo stato.
+	start := chainMessage(utils.StartView, 2, 2, 0)
+	start.Chain = []int{1}
+	kvs.startViewReceived(start, utils.NewResponse())
+	if head, _ := kvs.ends(); head == kvs.index {
+		t.Fatal("promoted to a view that was not promised")
+	}
+	start.View, start.Chain = 1, []int{2, 1}
+	kvs.startViewReceived(start, utils.NewResponse())
+	if head, _ := kvs.ends(); head == kvs.index {
+		t.Fatal("promoted although another replica heads the chain")
+	}
+	start.Chain = []int{1}
+	kvs.startViewReceived(start, utils.NewResponse())
+	if head, _ := kvs.ends(); head != kvs.index || kvs.view != 1 {
+		t.Fatalf("head = %d, view = %d, want head 1 of view 1", head, kvs.view)
+	}
+}
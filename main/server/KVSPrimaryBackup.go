@@ -0,0 +1,582 @@
+package main
+
+import (
+	"SDCC/main/utils"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"sync"
+	"time"
+)
+
+/*
+ Primary-backup
+
+ I namespace con consistenza PrimaryBackup hanno un primario, la replica che ordina tutte le scritture: ogni
+ scrittura riceve un numero di sequenza e viene inoltrata ai backup, che la applicano nello stesso ordine. Il
+ primario la applica, rendendola visibile alle proprie letture, e la conferma al client solo dopo che la
+ maggioranza delle repliche, lui compreso, l'ha ricevuta nella view corrente; altrimenti lascia il posto a una
+ nuova view, che decide se la scrittura sopravvive. I backup che non rispondono smettono di riceverla finché non
+ tornano a controllare il primario, ma non contano per la maggioranza. Un backup che riceve una scrittura da un client la
+ inoltra al primario.
+
+ Le letture ricevute da un backup vengono servite secondo primary_backup.reads: con "primary" sono inoltrate al
+ primario, con "backup" il backup le serve se era aggiornato rispetto al primario al più max_staleness fa (il
+ controllo periodico del primario riporta la sua ultima scrittura), altrimenti le inoltra.
+
+ Ogni configurazione (view) ha un primario: all'inizio la replica di indice più basso. I backup controllano il
+ primario ogni primary_backup.heartbeat; se non risponde per failure_timeout il backup propone la view successiva a
+ tutte le repliche (Promise). Chi la accetta promette di non applicare più scritture e trasferimenti delle view
+ precedenti e riporta la propria view e l'ultima scrittura applicata. Raccolte le promesse di una maggioranza,
+ viene promossa (StartView) la replica con l'ultima scrittura più recente, a parità quella di indice più basso:
+ ogni scrittura confermata è stata applicata da una maggioranza, che ha almeno una replica in comune con quella
+ delle promesse, quindi un backup promosso a primario ha già tutte le scritture confermate. Il nuovo primario
+ trasferisce il proprio stato a tutte le altre (Sync), che da quel momento accettano solo le sue scritture. Lo
+ stesso trasferimento riallinea un backup che ha perso una scrittura o che era irraggiungibile durante il cambio
+ di view, e un primario che scopre di essere stato sostituito diventa un backup. Una replica che ha promesso una
+ view senza riceverne lo stato entro failure_timeout, più un ritardo casuale, propone a sua volta la successiva.
+
+ Il controllo del primario parte con il primo namespace primary-backup, quando tutte le repliche sono attive.
+*/
+
+// KVSPrimaryBackup ospita i namespace con consistenza PrimaryBackup
+type KVSPrimaryBackup struct {
+	index int //indice della replica corrente
+
+	sequencedKeyspaces //le chiavi di ogni namespace con consistenza primary-backup, con il mutex per accedervi
+
+	sequenceMutex sync.Mutex   //ordina le scritture una alla volta, da acquisire prima di viewMutex
+	viewMutex     sync.Mutex   //mutex per la configurazione, da acquisire prima di mapMutex
+	view          int          //configurazione corrente
+	promised      int          //view più alta promessa: i messaggi delle view precedenti vengono rifiutati
+	retryAt       time.Time    //istante dopo cui una view promessa e rimasta senza primario viene riproposta
+	primary       int          //primario della configurazione corrente
+	suspected     map[int]bool //repliche considerate guaste, escluse dalla scelta del primario e dai backup
+	applied       int          //numero di sequenza dell'ultima scrittura applicata
+	lastContact   time.Time    //ultima risposta del primario a un controllo
+	freshAt       time.Time    //ultimo istante in cui il backup aveva applicato tutte le scritture del primario
+	monitor       sync.Once    //avvio del controllo periodico
+
+	drainer //scritture e messaggi in corso, per lo spegnimento controllato
+}
+
+// NewKVSPrimaryBackup crea lo storage primary-backup della replica index. Il namespace "default" non è mai
+// primary-backup.
+func NewKVSPrimaryBackup(index int) *KVSPrimaryBackup {
+	return &KVSPrimaryBackup{
+		index:              index,
+		sequencedKeyspaces: newSequencedKeyspaces(),
+		suspected:          make(map[int]bool),
+	}
+}
+
+// multicast esegue l'operazione op di un client: le letture possono essere servite localmente, tutto il resto è
+// eseguito dal primario. Va chiamata solo dopo aver verificato l'ordine FIFO e i permessi.
+func (kvs *KVSPrimaryBackup) multicast(arg utils.Args, resp *utils.Response, op string) error {
+	msg := utils.NewReplicationMessage(utils.PrimaryBackup, arg, kvs.index, op)
+	if (op == utils.Get || op == utils.Scan) && kvs.readLocally() {
+		kvs.read(msg, resp)
+		return nil
+	}
+
+	var err error
+	if primary := kvs.currentPrimary(); primary == kvs.index {
+		err = kvs.serve(msg, resp)
+	} else {
+		utils.NetworkDelay()
+		if err = utils.Replicate(primary, *msg, resp); err != nil {
+			err = fmt.Errorf("%w: forwarding %s to %s: %v", utils.ErrNotPrimary, op, utils.Peers.ID(primary), err)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	if op == utils.Delete && !resp.Found {
+		return errors.New("delete operation failed. Key not found")
+	}
+	return nil
+}
+
+// readLocally indica se una lettura ricevuta dalla replica corrente può essere servita senza il primario
+func (kvs *KVSPrimaryBackup) readLocally() bool {
+	kvs.viewMutex.Lock()
+	defer kvs.viewMutex.Unlock()
+	conf := utils.Conf.PrimaryBackup
+	switch {
+	case kvs.primary == kvs.index:
+		return true
+	case conf.Reads == utils.ReadFromPrimary:
+		return false
+	case conf.MaxStaleness == 0:
+		return true
+	}
+	return time.Since(kvs.freshAt) <= conf.MaxStaleness
+}
+
+// currentPrimary ritorna il primario della configurazione corrente
+func (kvs *KVSPrimaryBackup) currentPrimary() int {
+	kvs.viewMutex.Lock()
+	defer kvs.viewMutex.Unlock()
+	return kvs.primary
+}
+
+// serve esegue sul primario una richiesta di un client, ricevuta direttamente o inoltrata da un backup. L'esito
+// di una Delete è riportato nella risposta (Found): gli errori indicano solo che la replica non è il primario.
+func (kvs *KVSPrimaryBackup) serve(msg *utils.ReplicationMessage, resp *utils.Response) error {
+	if msg.OpType == utils.Get || msg.OpType == utils.Scan {
+		if kvs.currentPrimary() != kvs.index {
+			return utils.ErrNotPrimary
+		}
+		kvs.read(msg, resp)
+		return nil
+	}
+	return kvs.sequence(msg, resp)
+}
+
+// sequence assegna alla scrittura il numero di sequenza successivo e la inoltra ai backup, attendendo le risposte di
+// tutti quelli raggiungibili. Il primario applica la scrittura, rendendola visibile alle sue letture, solo dopo che
+// la maggioranza delle repliche, lui compreso, l'ha ricevuta. Altrimenti il client riceve un errore e il primario
+// non può riusare il numero di sequenza, che alcuni backup hanno già applicato: lascia il posto a una nuova view,
+// che deciderà se la scrittura sopravvive. Le scritture vengono ordinate una alla volta.
+func (kvs *KVSPrimaryBackup) sequence(msg *utils.ReplicationMessage, resp *utils.Response) error {
+	kvs.sequenceMutex.Lock()
+	defer kvs.sequenceMutex.Unlock()
+
+	kvs.viewMutex.Lock()
+	if kvs.primary != kvs.index || kvs.promised > kvs.view {
+		kvs.viewMutex.Unlock()
+		return utils.ErrNotPrimary
+	}
+	if msg.OpType == utils.Delete && !kvs.exists(msg.Args) {
+		kvs.viewMutex.Unlock()
+		resp.Key, resp.Found = msg.Args.Key, false //una Delete che non ha effetto non va ordinata
+		return nil
+	}
+	msg.ServerIndex = kvs.index
+	msg.View = kvs.view
+	msg.Sequence = kvs.applied + 1
+	msg.Stamp = utils.Stamp{Clock: msg.Sequence, Origin: kvs.index}
+	view, backups := kvs.view, kvs.backups()
+	kvs.viewMutex.Unlock()
+
+	replies := utils.ReplicateTo(*msg, backups, nil)
+	replaced := false
+	acks := 1 //il primario
+	for range backups {
+		reply := <-replies
+		switch {
+		case reply.Err == nil && reply.Response.Succeeded:
+			acks++
+		case reply.Err == nil && reply.Response.View > view:
+			kvs.stepDown(reply.Response)
+			replaced = true
+		case reply.Err == nil:
+			go kvs.resync(reply.Index) //il backup ha perso scritture precedenti
+		default:
+			kvs.suspect(reply.Index) //sarà riallineato quando tornerà a controllare il primario
+		}
+	}
+	if replaced {
+		return fmt.Errorf("%w: a new primary was elected while applying the %s", utils.ErrNotPrimary, msg.OpType)
+	}
+
+	kvs.viewMutex.Lock()
+	defer kvs.viewMutex.Unlock()
+	majority := utils.NumberOfReplicas/2 + 1
+	if kvs.view != view || kvs.primary != kvs.index || kvs.applied != msg.Sequence-1 {
+		return fmt.Errorf("%w: the view changed while applying the %s", utils.ErrNotPrimary, msg.OpType)
+	}
+	if acks < majority {
+		if kvs.promised == view {
+			kvs.promise(view + 1)
+			kvs.retryAt = time.Now() //la nuova view va proposta al prossimo controllo
+		}
+		return fmt.Errorf("%w: %s of key %q received by %d of %d replicas, a majority is %d", utils.ErrNotPrimary, msg.OpType, msg.Args.Key, acks, utils.NumberOfReplicas, majority)
+	}
+	kvs.apply(msg, resp)
+	fmt.Printf("%s operation sequenced. Key: %s, Sequence: %d, View: %d, Backups: %d\n", msg.OpType, msg.Args.Key, msg.Sequence, view, len(backups))
+	return nil
+}
+
+// suspect esclude dai backup una replica che non ha risposto, finché non torna a controllare il primario
+func (kvs *KVSPrimaryBackup) suspect(backup int) {
+	kvs.viewMutex.Lock()
+	defer kvs.viewMutex.Unlock()
+	if !kvs.suspected[backup] {
+		kvs.suspected[backup] = true
+		fmt.Printf("\033[31mBackup %s suspected failed\033[0m\n", utils.Peers.ID(backup))
+	}
+}
+
+// apply applica una scrittura ordinata dal primario e ne avanza il numero di sequenza. Va chiamata con viewMutex
+// già acquisito.
+func (kvs *KVSPrimaryBackup) apply(msg *utils.ReplicationMessage, resp *utils.Response) {
+	kvs.applied = msg.Sequence
+	kvs.applySequenced(msg, resp)
+}
+
+// backups ritorna le repliche attive non considerate guaste, tranne la corrente. Va chiamata con viewMutex già
+// acquisito.
+func (kvs *KVSPrimaryBackup) backups() []int {
+	var backups []int
+	for i := 0; i < utils.NumberOfReplicas; i++ {
+		if i != kvs.index && !kvs.suspected[i] && !utils.HasPeerLeft(i) {
+			backups = append(backups, i)
+		}
+	}
+	return backups
+}
+
+// Replicate riceve i messaggi delle altre repliche: scritture ordinate dal primario, trasferimenti di stato,
+// controlli periodici, messaggi del cambio di view e richieste dei client inoltrate dai backup
+func (kvs *KVSPrimaryBackup) Replicate(msg utils.ReplicationMessage, resp *utils.Response) error {
+	kvs.beginUpdate()
+	defer kvs.end()
+	switch {
+	case msg.OpType == utils.Heartbeat:
+		kvs.heartbeatReceived(msg, resp)
+		return nil
+	case msg.OpType == utils.Sync:
+		kvs.syncReceived(msg, resp)
+		return nil
+	case msg.OpType == utils.Promise:
+		kvs.promiseReceived(msg, resp)
+		return nil
+	case msg.OpType == utils.StartView:
+		kvs.startViewReceived(msg, resp)
+		return nil
+	case msg.Sequence > 0:
+		kvs.deliver(msg, resp)
+		return nil
+	}
+	return kvs.serve(&msg, resp)
+}
+
+// deliver applica su un backup una scrittura ordinata dal primario, dopo tutte quelle che la precedono. Se le
+// precedenti non arrivano entro failure_timeout, o il messaggio appartiene a un'altra view o a una view precedente
+// a quella promessa, la scrittura non viene applicata e la risposta riporta la configurazione del backup: il primario lo riallineerà con un trasferimento
+// di stato, o scoprirà di essere stato sostituito.
+func (kvs *KVSPrimaryBackup) deliver(msg utils.ReplicationMessage, resp *utils.Response) {
+	deadline := time.Now().Add(utils.Conf.PrimaryBackup.FailureTimeout)
+	for {
+		kvs.viewMutex.Lock()
+		resp.View, resp.Stamp = kvs.view, utils.Stamp{Clock: kvs.applied, Origin: kvs.primary}
+		if msg.View == kvs.view && msg.ServerIndex == kvs.primary && kvs.promised <= kvs.view {
+			if msg.Sequence <= kvs.applied {
+				resp.Succeeded = true //già applicata, per esempio con un trasferimento di stato
+				kvs.viewMutex.Unlock()
+				return
+			}
+			if msg.Sequence == kvs.applied+1 {
+				kvs.apply(&msg, utils.NewResponse())
+				kvs.lastContact = time.Now()
+				resp.Succeeded = true
+				kvs.viewMutex.Unlock()
+				return
+			}
+		}
+		stale := msg.View < max(kvs.view, kvs.promised)
+		kvs.viewMutex.Unlock()
+
+		if stale || time.Now().After(deadline) {
+			fmt.Printf("\033[31mMSG %s (view %d, sequence %d) not applied, replica is at view %d\033[0m\n", msg.UUID, msg.View, msg.Sequence, resp.View)
+			return
+		}
+		time.Sleep(SLEEP_TIME)
+	}
+}
+
+// resync trasferisce lo stato del primario alla replica backup
+func (kvs *KVSPrimaryBackup) resync(backup int) {
+	kvs.beginUpdate()
+	defer kvs.end()
+	kvs.viewMutex.Lock()
+	if kvs.primary != kvs.index {
+		kvs.viewMutex.Unlock()
+		return
+	}
+	msg := kvs.syncMessage()
+	kvs.viewMutex.Unlock()
+
+	fmt.Printf("\033[35mSending state of view %d (sequence %d) to server %s\033[0m\n", msg.View, msg.Sequence, utils.Peers.ID(backup))
+	resp := utils.NewResponse()
+	if err := utils.Replicate(backup, *msg, resp); err == nil && resp.View > msg.View {
+		kvs.stepDown(resp)
+	}
+}
+
+// syncMessage crea il trasferimento dello stato corrente. Va chiamata con viewMutex già acquisito.
+func (kvs *KVSPrimaryBackup) syncMessage() *utils.ReplicationMessage {
+	msg := utils.NewReplicationMessage(utils.PrimaryBackup, utils.Args{}, kvs.index, utils.Sync)
+	msg.View, msg.Sequence = kvs.view, kvs.applied
+	msg.State = kvs.states()
+	return msg
+}
+
+// syncReceived sostituisce lo stato della replica con quello trasferito dal primario di una view uguale o
+// successiva alla corrente e a quella promessa. Le modifiche non vengono notificate ai Watch.
+func (kvs *KVSPrimaryBackup) syncReceived(msg utils.ReplicationMessage, resp *utils.Response) {
+	kvs.viewMutex.Lock()
+	defer kvs.viewMutex.Unlock()
+	resp.View, resp.Stamp = kvs.view, utils.Stamp{Clock: kvs.applied, Origin: kvs.primary}
+	if msg.View < max(kvs.view, kvs.promised) || (msg.View == kvs.view && msg.ServerIndex != kvs.primary) {
+		fmt.Printf("\033[31mState of view %d from server %s rejected, replica is at view %d\033[0m\n", msg.View, utils.Peers.ID(msg.ServerIndex), kvs.view)
+		return
+	}
+
+	if msg.View == kvs.view && msg.Sequence <= kvs.applied {
+		resp.Succeeded = true //le scritture trasferite sono già state applicate
+		return
+	}
+
+	kvs.view, kvs.primary, kvs.applied = msg.View, msg.ServerIndex, msg.Sequence
+	kvs.promised = max(kvs.promised, msg.View)
+	delete(kvs.suspected, msg.ServerIndex)
+	kvs.lastContact, kvs.freshAt = time.Now(), time.Now()
+
+	kvs.restore(msg.State, msg.ServerIndex)
+
+	if len(msg.State) > 0 {
+		kvs.startMonitor()
+	}
+	resp.View, resp.Succeeded = kvs.view, true
+	fmt.Printf("\033[35mState of view %d (sequence %d) received from primary %s\033[0m\n", msg.View, msg.Sequence, utils.Peers.ID(msg.ServerIndex))
+}
+
+// heartbeatReceived risponde al controllo periodico di un'altra replica con la propria configurazione e l'ultima
+// scrittura applicata. Il primario riallinea i backup rimasti a una view precedente o esclusi per un guasto.
+func (kvs *KVSPrimaryBackup) heartbeatReceived(msg utils.ReplicationMessage, resp *utils.Response) {
+	kvs.viewMutex.Lock()
+	resp.View, resp.Stamp = kvs.view, utils.Stamp{Clock: kvs.applied, Origin: kvs.primary}
+	resp.Succeeded = true
+	missed := kvs.suspected[msg.ServerIndex]
+	delete(kvs.suspected, msg.ServerIndex) //la replica è attiva
+	behind := kvs.primary == kvs.index && (msg.View < kvs.view || missed && msg.Sequence < kvs.applied)
+	kvs.viewMutex.Unlock()
+
+	if behind {
+		go kvs.resync(msg.ServerIndex)
+	}
+}
+
+// startMonitor avvia il controllo periodico, se non è già attivo
+func (kvs *KVSPrimaryBackup) startMonitor() {
+	kvs.monitor.Do(func() { go kvs.checkPeriodically() })
+}
+
+// checkPeriodically esegue il controllo periodico: il primario verifica di non essere stato sostituito, i backup
+// verificano che il primario sia attivo. Una replica che attende troppo a lungo il primario di una view
+// promessa propone la successiva.
+func (kvs *KVSPrimaryBackup) checkPeriodically() {
+	kvs.viewMutex.Lock()
+	kvs.lastContact = time.Now()
+	kvs.viewMutex.Unlock()
+	for {
+		time.Sleep(utils.Conf.PrimaryBackup.Heartbeat)
+		switch {
+		case kvs.electionStalled():
+			kvs.electPrimary()
+		case kvs.currentPrimary() == kvs.index:
+			kvs.checkBackups()
+		default:
+			kvs.checkPrimary()
+		}
+	}
+}
+
+// checkBackups invia il controllo periodico ai backup: se uno di essi ha una view successiva, il primario è stato
+// sostituito
+func (kvs *KVSPrimaryBackup) checkBackups() {
+	kvs.viewMutex.Lock()
+	msg := utils.NewReplicationMessage(utils.PrimaryBackup, utils.Args{}, kvs.index, utils.Heartbeat)
+	msg.View, msg.Sequence = kvs.view, kvs.applied
+	backups := kvs.backups()
+	kvs.viewMutex.Unlock()
+
+	//Un backup sospeso può non rispondere affatto: chi non risponde entro il prossimo controllo viene ignorato
+	got, _ := awaitReplies(utils.ReplicateTo(*msg, backups, nil), len(backups), len(backups), time.Now().Add(utils.Conf.PrimaryBackup.Heartbeat))
+	for _, reply := range got {
+		if reply.Response.View > msg.View {
+			kvs.stepDown(reply.Response)
+		}
+	}
+}
+
+// checkPrimary invia il controllo periodico al primario. Se non risponde per failure_timeout viene considerato
+// guasto e la replica corrente propone una nuova view.
+func (kvs *KVSPrimaryBackup) checkPrimary() {
+	kvs.viewMutex.Lock()
+	primary := kvs.primary
+	msg := utils.NewReplicationMessage(utils.PrimaryBackup, utils.Args{}, kvs.index, utils.Heartbeat)
+	msg.View, msg.Sequence = kvs.view, kvs.applied
+	kvs.viewMutex.Unlock()
+
+	sent := time.Now()
+	got, _ := awaitReplies(utils.ReplicateTo(*msg, []int{primary}, nil), 1, 1, sent.Add(utils.Conf.PrimaryBackup.Heartbeat))
+	answered := len(got) == 1
+	resp := utils.NewResponse()
+	if answered {
+		resp = got[0].Response
+	}
+
+	kvs.viewMutex.Lock()
+	if kvs.primary != primary {
+		kvs.viewMutex.Unlock()
+		return //configurazione cambiata durante il controllo
+	}
+	if answered && resp.Stamp.Origin == primary {
+		kvs.lastContact = sent
+		if resp.View == kvs.view && kvs.applied >= resp.Stamp.Clock {
+			kvs.freshAt = sent
+		}
+		kvs.viewMutex.Unlock()
+		return
+	}
+	if answered && resp.View > kvs.view {
+		kvs.primary = resp.Stamp.Origin //il primario è stato sostituito: il nuovo trasferirà il proprio stato
+		kvs.viewMutex.Unlock()
+		return
+	}
+	failed := time.Since(kvs.lastContact) >= utils.Conf.PrimaryBackup.FailureTimeout
+	if failed {
+		kvs.suspected[primary] = true
+		fmt.Printf("\033[31mPrimary %s suspected failed\033[0m\n", utils.Peers.ID(primary))
+	}
+	kvs.viewMutex.Unlock()
+
+	if failed {
+		kvs.electPrimary()
+	}
+}
+
+// electionStalled indica se la replica ha promesso una view di cui non ha ricevuto lo stato in tempo
+func (kvs *KVSPrimaryBackup) electionStalled() bool {
+	kvs.viewMutex.Lock()
+	defer kvs.viewMutex.Unlock()
+	return kvs.promised > kvs.view && time.Now().After(kvs.retryAt)
+}
+
+// promise registra la promessa della view e fissa il momento in cui riproporla se resta senza primario: dopo
+// failure_timeout più un ritardo casuale, così due repliche che propongono la stessa view non si ripetono
+// all'infinito. Va chiamata con viewMutex già acquisito.
+func (kvs *KVSPrimaryBackup) promise(view int) {
+	timeout := utils.Conf.PrimaryBackup.FailureTimeout
+	kvs.promised, kvs.retryAt = view, time.Now().Add(timeout+rand.N(timeout))
+}
+
+// electPrimary propone una nuova view a tutte le repliche. Raccolte le promesse di una maggioranza, compresa quella
+// della replica corrente, promuove la replica con l'ultima scrittura più recente. Se la maggioranza non risponde,
+// la view verrà riproposta più tardi.
+func (kvs *KVSPrimaryBackup) electPrimary() {
+	kvs.viewMutex.Lock()
+	view := max(kvs.view, kvs.promised) + 1
+	kvs.promise(view)
+	kvs.lastContact = time.Now()
+	msg := utils.NewReplicationMessage(utils.PrimaryBackup, utils.Args{}, kvs.index, utils.Promise)
+	msg.View = view
+	best := utils.ReplicaReply{Index: kvs.index, Response: &utils.Response{View: kvs.view, Stamp: utils.Stamp{Clock: kvs.applied}}}
+	var peers []int
+	for i := 0; i < utils.NumberOfReplicas; i++ {
+		if i != kvs.index && !utils.HasPeerLeft(i) {
+			peers = append(peers, i) //anche il primario sospettato, che se attivo smette di confermare scritture
+		}
+	}
+	kvs.viewMutex.Unlock()
+
+	fmt.Printf("\033[35mServer %s proposing view %d\033[0m\n", utils.Peers.ID(kvs.index), view)
+	got, _ := awaitReplies(utils.ReplicateTo(*msg, peers, nil), len(peers), len(peers), time.Now().Add(utils.Conf.PrimaryBackup.Heartbeat))
+	promises := 1
+	for _, reply := range got {
+		if !reply.Response.Succeeded {
+			continue
+		}
+		promises++
+		if moreRecent(reply, best) {
+			best = reply
+		}
+	}
+	if majority := utils.NumberOfReplicas/2 + 1; promises < majority {
+		fmt.Printf("\033[31mView %d promised by %d of %d replicas, a majority is %d\033[0m\n", view, promises, utils.NumberOfReplicas, majority)
+		return
+	}
+
+	if best.Index == kvs.index {
+		kvs.viewMutex.Lock()
+		defer kvs.viewMutex.Unlock()
+		if kvs.promised == view && kvs.view < view {
+			kvs.promote(view)
+		}
+		return
+	}
+	msg = utils.NewReplicationMessage(utils.PrimaryBackup, utils.Args{}, kvs.index, utils.StartView)
+	msg.View = view
+	fmt.Printf("\033[35mServer %s chosen as primary of view %d\033[0m\n", utils.Peers.ID(best.Index), view)
+	utils.NetworkDelay()
+	if err := utils.Replicate(best.Index, *msg, utils.NewResponse()); err != nil {
+		fmt.Printf("\033[31mFailed to start view %d on server %s: %v\033[0m\n", view, utils.Peers.ID(best.Index), err)
+	}
+}
+
+// moreRecent indica se la promessa reply riporta un'ultima scrittura più recente di quella di other: view più
+// alta, poi numero di sequenza più alto, poi indice più basso
+func moreRecent(reply utils.ReplicaReply, other utils.ReplicaReply) bool {
+	if reply.Response.View != other.Response.View {
+		return reply.Response.View > other.Response.View
+	}
+	if reply.Response.Stamp.Clock != other.Response.Stamp.Clock {
+		return reply.Response.Stamp.Clock > other.Response.Stamp.Clock
+	}
+	return reply.Index < other.Index
+}
+
+// promiseReceived accetta la view proposta da un'altra replica se è successiva alla corrente e a quelle già
+// promesse: da quel momento la replica rifiuta scritture e trasferimenti delle view precedenti. La risposta
+// riporta la view e l'ultima scrittura applicata.
+func (kvs *KVSPrimaryBackup) promiseReceived(msg utils.ReplicationMessage, resp *utils.Response) {
+	kvs.viewMutex.Lock()
+	defer kvs.viewMutex.Unlock()
+	resp.View, resp.Stamp = kvs.view, utils.Stamp{Clock: kvs.applied, Origin: kvs.primary}
+	if msg.View <= max(kvs.view, kvs.promised) {
+		fmt.Printf("\033[31mView %d proposed by server %s rejected, replica promised view %d\033[0m\n", msg.View, utils.Peers.ID(msg.ServerIndex), max(kvs.view, kvs.promised))
+		return
+	}
+	kvs.promise(msg.View)
+	resp.Succeeded = true
+	fmt.Printf("\033[35mView %d proposed by server %s promised (sequence %d)\033[0m\n", msg.View, utils.Peers.ID(msg.ServerIndex), kvs.applied)
+}
+
+// startViewReceived promuove la replica a primario della view che ha promesso, scelta da chi ha raccolto le
+// promesse della maggioranza
+func (kvs *KVSPrimaryBackup) startViewReceived(msg utils.ReplicationMessage, resp *utils.Response) {
+	kvs.viewMutex.Lock()
+	defer kvs.viewMutex.Unlock()
+	resp.View, resp.Stamp = kvs.view, utils.Stamp{Clock: kvs.applied, Origin: kvs.primary}
+	if msg.View != kvs.promised || msg.View <= kvs.view {
+		fmt.Printf("\033[31mView %d started by server %s rejected, replica promised view %d\033[0m\n", msg.View, utils.Peers.ID(msg.ServerIndex), kvs.promised)
+		return
+	}
+	kvs.promote(msg.View)
+	resp.View, resp.Succeeded = kvs.view, true
+}
+
+// promote rende la replica corrente primario della view e trasferisce il proprio stato a tutti i backup. Va
+// chiamata con viewMutex già acquisito.
+func (kvs *KVSPrimaryBackup) promote(view int) {
+	kvs.view, kvs.primary, kvs.lastContact = view, kvs.index, time.Now()
+	fmt.Printf("\033[35mServer %s promoted to primary of view %d (sequence %d)\033[0m\n", utils.Peers.ID(kvs.index), kvs.view, kvs.applied)
+	for _, backup := range kvs.backups() {
+		go kvs.resync(backup)
+	}
+}
+
+// stepDown fa diventare backup un primario che ha scoperto una view successiva alla propria. La view resta quella
+// vecchia finché il nuovo primario non trasferisce il proprio stato, che sostituisce le scritture non confermate.
+func (kvs *KVSPrimaryBackup) stepDown(newer *utils.Response) {
+	kvs.viewMutex.Lock()
+	defer kvs.viewMutex.Unlock()
+	if kvs.primary != kvs.index || newer.View <= kvs.view {
+		return
+	}
+	fmt.Printf("\033[31mServer %s replaced by primary %s of view %d\033[0m\n", utils.Peers.ID(kvs.index), utils.Peers.ID(newer.Stamp.Origin), newer.View)
+	kvs.primary, kvs.lastContact = newer.Stamp.Origin, time.Now()
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"SDCC/main/utils"
+	"testing"
+)
+
+// pbMessage ritorna il messaggio op della replica origin nella view indicata
+func pbMessage(op string, origin int, view int, sequence int) utils.ReplicationMessage {
+	msg := utils.NewReplicationMessage(utils.PrimaryBackup, utils.Args{Key: "k", Value: []byte("v")}, origin, op)
+	msg.View, msg.Sequence = view, sequence
+	return *msg
+}
+
+func TestPromiseRejectsOlderView(t *testing.T) {
+	kvs := NewKVSPrimaryBackup(1)
+
+	//La replica promette la view 1 a un solo candidato
+	resp := utils.NewResponse()
+	kvs.promiseReceived(pbMessage(utils.Promise, 2, 1, 0), resp)
+	if !resp.Succeeded {
+		t.Fatal("view 1 not promised")
+	}
+	resp = utils.NewResponse()
+	kvs.promiseReceived(pbMessage(utils.Promise, 0, 1, 0), resp)
+	if resp.Succeeded {
+		t.Fatal("view 1 promised twice")
+	}
+
+	//Il primario della view 0 non può più farsi confermare scritture né trasferire il proprio stato
+	resp = utils.NewResponse()
+	kvs.deliver(pbMessage(utils.Put, 0, 0, 1), resp)
+	if resp.Succeeded || kvs.applied != 0 {
+		t.Fatalf("write of view 0 applied after promising view 1 (applied %d)", kvs.applied)
+	}
+	resp = utils.NewResponse()
+	kvs.syncReceived(pbMessage(utils.Sync, 0, 0, 1), resp)
+	if resp.Succeeded || kvs.view != 0 {
+		t.Fatal("state of view 0 accepted after promising view 1")
+	}
+
+	//Solo la replica scelta per la view promessa diventa primario. Le altre repliche non sono raggiungibili nel
+	//test: sospettandole, la promozione non tenta di trasferire loro lo stato.
+	kvs.suspected[0], kvs.suspected[2] = true, true
+	kvs.startViewReceived(pbMessage(utils.StartView, 2, 2, 0), utils.NewResponse())
+	if kvs.primary == kvs.index {
+		t.Fatal("promoted to a view that was not promised")
+	}
+	kvs.startViewReceived(pbMessage(utils.StartView, 2, 1, 0), utils.NewResponse())
+	if kvs.primary != kvs.index || kvs.view != 1 {
+		t.Fatalf("primary = %d, view = %d, want primary 1 of view 1", kvs.primary, kvs.view)
+	}
+}
+
+func TestMoreRecentPrefersHighestApplied(t *testing.T) {
+	promise := func(index int, view int, applied int) utils.ReplicaReply {
+		return utils.ReplicaReply{Index: index, Response: &utils.Response{View: view, Stamp: utils.Stamp{Clock: applied}}}
+	}
+	cases := []struct {
+		a, b utils.ReplicaReply
+		want bool
+	}{
+		{promise(2, 0, 5), promise(0, 0, 4), true},  //più scritture applicate
+		{promise(2, 1, 1), promise(0, 0, 9), true},  //view più recente
+		{promise(2, 0, 5), promise(0, 0, 5), false}, //a parità vince l'indice più basso
+	}
+	for _, c := range cases {
+		if got := moreRecent(c.a, c.b); got != c.want {
+			t.Errorf("moreRecent(%d, %d) = %v, want %v", c.a.Index, c.b.Index, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"SDCC/main/utils"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+/*
+ Quorum
+
+ I namespace con consistenza Quorum sono replicati alla maniera di Dynamo, con quorum di lettura e scrittura
+ configurabili (quorum.n, quorum.r e quorum.w): ogni chiave è conservata dalle N repliche della sua preference
+ list, e la replica che riceve la richiesta fa da coordinatore verso di esse. Non serve che tutte le repliche
+ partecipino a ogni operazione, quindi il namespace resta disponibile finché per ogni chiave rispondono almeno R
+ (letture) o W (scritture) delle sue repliche.
+
+ Ogni valore ha una versione (Stamp: clock di Lamport e replica che ha coordinato la scrittura). Una scrittura
+ legge prima le versioni da R repliche e sceglie una versione successiva alla più recente, poi viene inviata a tutte
+ le N repliche e termina quando W l'hanno applicata; ogni replica tiene la versione più recente (last-writer-wins),
+ e una Delete lascia la propria versione come tombstone. Una lettura risponde con la versione più recente tra le
+ prime R risposte; le repliche che hanno risposto con una versione più vecchia, anche dopo la risposta al client,
+ vengono aggiornate in background (read repair).
+
+ Con R + W > N ogni lettura incontra almeno una replica che ha applicato l'ultima scrittura completata, quindi
+ legge il suo valore o uno più recente. Con quorum più piccoli le letture possono restituire valori vecchi, che il
+ read repair e le scritture successive fanno convergere.
+*/
+
+// KVSQuorum ospita i namespace con consistenza Quorum
+type KVSQuorum struct {
+	index     int                  //indice della replica corrente
+	keyspaces map[string]*keyspace //le chiavi di ogni namespace con consistenza Quorum
+	mapMutex  sync.Mutex           //mutex per accedere alla Map
+	clock     lamportClock         //clock della replica, da cui derivano le versioni delle scritture coordinate
+	drainer                        //messaggi in consegna e read repair in corso, per lo spegnimento controllato
+}
+
+// NewKVSQuorum crea lo storage a quorum della replica index. Il namespace "default" non usa mai i quorum.
+func NewKVSQuorum(index int) *KVSQuorum {
+	return &KVSQuorum{
+		index:     index,
+		keyspaces: make(map[string]*keyspace),
+	}
+}
+
+// multicast coordina l'operazione op di un client verso le repliche della chiave. Va chiamata solo dopo aver
+// verificato l'ordine FIFO e i permessi.
+func (kvs *KVSQuorum) multicast(arg utils.Args, resp *utils.Response, op string) error {
+	switch op {
+	case utils.Get:
+		return kvs.read(arg, resp)
+	case utils.Put, utils.Delete:
+		return kvs.write(arg, resp, op)
+	}
+	return fmt.Errorf("%w: %s on a %s namespace", utils.ErrNotSupported, op, utils.Quorum)
+}
+
+// read legge la chiave dalle sue repliche e risponde con la versione più recente tra le prime R risposte
+func (kvs *KVSQuorum) read(arg utils.Args, resp *utils.Response) error {
+	n, r, _ := utils.Conf.Quorum.Sizes()
+	targets := utils.PreferenceList(arg.Namespace, arg.Key, n)
+	deadline := time.Now().Add(utils.Conf.Quorum.Timeout)
+
+	msg := utils.NewReplicationMessage(utils.Quorum, arg, kvs.index, utils.Get)
+	replies := utils.ReplicateTo(*msg, targets, kvs.Replicate)
+	got, pending := awaitReplies(replies, len(targets), r, deadline)
+
+	kvs.beginUpdate()
+	go func() {
+		defer kvs.end()
+		late, _ := awaitReplies(replies, pending, pending, deadline)
+		kvs.repair(arg, append(got, late...))
+	}()
+
+	if len(got) < r {
+		return fmt.Errorf("%w: %d of %d replicas answered the read of key %q, r is %d", utils.ErrQuorumUnavailable, len(got), n, arg.Key, r)
+	}
+	latest := newestReply(got).Response
+	resp.Key, resp.Value, resp.Found, resp.Stamp, resp.IsPrintable = arg.Key, latest.Value, latest.Found, latest.Stamp, true
+	fmt.Printf("Get operation completed. Key: %s, Value: %s, Version: %s, Replies: %d/%d\n", arg.Key, latest.Value, latest.Stamp, len(got), n)
+	return nil
+}
+
+// repair invia la versione più recente della chiave arg.Key alle repliche che hanno risposto alla lettura con una
+// versione più vecchia
+func (kvs *KVSQuorum) repair(arg utils.Args, replies []utils.ReplicaReply) {
+	if len(replies) == 0 {
+		return
+	}
+	latest := newestReply(replies).Response
+	if latest.Stamp == (utils.Stamp{}) {
+		return //nessuna replica ha mai visto la chiave
+	}
+	var stale []int
+	for _, reply := range replies {
+		if reply.Response.Stamp.Less(latest.Stamp) {
+			stale = append(stale, reply.Index)
+		}
+	}
+	if len(stale) == 0 {
+		return
+	}
+
+	op := utils.Put
+	if !latest.Found {
+		op = utils.Delete
+	}
+	msg := utils.NewReplicationMessage(utils.Quorum, utils.Args{Namespace: arg.Namespace, NamespaceRevision: arg.NamespaceRevision, Key: arg.Key, Value: latest.Value}, kvs.index, op)
+	msg.Stamp = latest.Stamp
+	fmt.Printf("\033[35mRead repair of key %s: version %s sent to %d stale replicas\033[0m\n", arg.Key, latest.Stamp, len(stale))
+	repairs := utils.ReplicateTo(*msg, stale, kvs.Replicate)
+	for range stale {
+		<-repairs
+	}
+}
+
+// write legge la versione corrente della chiave da R repliche e invia la scrittura, con una versione successiva,
+// a tutte le repliche della chiave. Termina quando W l'hanno applicata.
+func (kvs *KVSQuorum) write(arg utils.Args, resp *utils.Response, op string) error {
+	n, r, w := utils.Conf.Quorum.Sizes()
+	targets := utils.PreferenceList(arg.Namespace, arg.Key, n)
+	deadline := time.Now().Add(utils.Conf.Quorum.Timeout)
+
+	read := utils.NewReplicationMessage(utils.Quorum, utils.Args{Namespace: arg.Namespace, NamespaceRevision: arg.NamespaceRevision, Key: arg.Key}, kvs.index, utils.Get)
+	got, _ := awaitReplies(utils.ReplicateTo(*read, targets, kvs.Replicate), len(targets), r, deadline)
+	if len(got) < r {
+		return fmt.Errorf("%w: %d of %d replicas answered the version read of key %q, r is %d", utils.ErrQuorumUnavailable, len(got), n, arg.Key, r)
+	}
+	latest := newestReply(got).Response
+	if op == utils.Delete && !latest.Found {
+		return errors.New("delete operation failed. Key not found")
+	}
+
+	msg := utils.NewReplicationMessage(utils.Quorum, arg, kvs.index, op)
+	msg.Stamp = kvs.clock.tick(kvs.index, latest.Stamp.Clock)
+	acks, _ := awaitReplies(utils.ReplicateTo(*msg, targets, kvs.Replicate), len(targets), w, deadline)
+	if len(acks) < w {
+		//Le repliche che hanno risposto hanno comunque applicato la scrittura: le letture successive possono vederla
+		return fmt.Errorf("%w: %d of %d replicas acknowledged the %s of key %q, w is %d", utils.ErrQuorumUnavailable, len(acks), n, op, arg.Key, w)
+	}
+	resp.Key, resp.Stamp = arg.Key, msg.Stamp
+	fmt.Printf("%s operation completed. Key: %s, Version: %s, Acks: %d/%d\n", op, arg.Key, msg.Stamp, len(acks), n)
+	return nil
+}
+
+// Replicate consegna un messaggio di un coordinatore: legge la versione corrente della chiave o applica una
+// scrittura se è più recente
+func (kvs *KVSQuorum) Replicate(msg utils.ReplicationMessage, resp *utils.Response) error {
+	kvs.beginUpdate()
+	defer kvs.end()
+	kvs.clock.observe(msg.Stamp)
+
+	kvs.mapMutex.Lock()
+	defer kvs.mapMutex.Unlock()
+	ks, ok := liveKeyspace(kvs.keyspaces, msg.Args)
+	if !ok {
+		return fmt.Errorf("%w: %q", utils.ErrNamespaceNotFound, msg.Args.Namespace)
+	}
+	resp.Key = msg.Args.Key
+	switch msg.OpType {
+	case utils.Get:
+		resp.Value, resp.Found = ks.store.Get(msg.Args.Key)
+	case utils.Put, utils.Delete:
+		resp.Succeeded = applyStamped(ks, &msg)
+		_, resp.Found = ks.store.Get(msg.Args.Key)
+	default:
+		return fmt.Errorf("unknown operation type: %s", msg.OpType)
+	}
+	resp.Stamp = ks.stamps[msg.Args.Key]
+	return nil
+}
+
+// awaitReplies riceve le risposte di total repliche finché needed non sono riuscite, non sono arrivate tutte o non
+// scade deadline. Ritorna le risposte riuscite e il numero di quelle ancora attese.
+func awaitReplies(replies <-chan utils.ReplicaReply, total int, needed int, deadline time.Time) ([]utils.ReplicaReply, int) {
+	var succeeded []utils.ReplicaReply
+	timer := time.NewTimer(time.Until(deadline))
+	defer timer.Stop()
+	for total > 0 && len(succeeded) < needed {
+		select {
+		case reply := <-replies:
+			total--
+			if reply.Err == nil {
+				succeeded = append(succeeded, reply)
+			}
+		case <-timer.C:
+			return succeeded, total
+		}
+	}
+	return succeeded, total
+}
+
+// newestReply ritorna la risposta con la versione più recente. replies non deve essere vuoto.
+func newestReply(replies []utils.ReplicaReply) utils.ReplicaReply {
+	newest := replies[0]
+	for _, reply := range replies[1:] {
+		if newest.Response.Stamp.Less(reply.Response.Stamp) {
+			newest = reply
+		}
+	}
+	return newest
+}
+
+// Snapshot ritorna una copia del contenuto dello storage, per namespace
+func (kvs *KVSQuorum) Snapshot() map[string]map[string][]byte {
+	kvs.mapMutex.Lock()
+	defer kvs.mapMutex.Unlock()
+	return snapshotKeyspaces(kvs.keyspaces)
+}
+
+// withKeyspaces esegue fn sui namespace dello storage con mapMutex acquisito
+func (kvs *KVSQuorum) withKeyspaces(fn func(keyspaces map[string]*keyspace)) {
+	kvs.mapMutex.Lock()
+	defer kvs.mapMutex.Unlock()
+	fn(kvs.keyspaces)
+}
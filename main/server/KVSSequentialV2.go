@@ -2,10 +2,8 @@ package main
 
 import (
 	"SDCC/main/utils"
+	"bytes"
 	"fmt"
-	"os"
-	"strconv"
-	"strings"
 	"sync"
 	"time"
 )
@@ -19,13 +17,15 @@ type LogicalClock struct {
 
 // KVSSequentialV2 is a concrete implementation of the KVS interface
 type KVSSequentialV2 struct {
-	index        int                 //indice della replica corrente
-	store        map[string]string   //KVS effettivo
-	mapMutex     sync.Mutex          //mutex per accedere alla Map
-	clientList   ClientList          //Lista dei client per il singolo server
-	logicalClock *LogicalClock       // clock logico del server
-	messageQueue *utils.MessageQueue //coda di messaggi del server
-	serverList   ServerList          //struct con contatori di ricezioni/invii per ogni server
+	index             int                  //indice della replica corrente
+	keyspaces         map[string]*keyspace //KVS effettivo: le chiavi di ogni namespace con consistenza sequenziale
+	mapMutex          sync.Mutex           //mutex per accedere alla Map
+	clientList        ClientList           //Lista dei client per il singolo server
+	drainer                                //richieste in corso, per lo spegnimento controllato
+	*namespaceCatalog                      //smistamento delle richieste tra i namespace e Watch
+	logicalClock      *LogicalClock        // clock logico del server
+	messageQueue      *utils.MessageQueue  //coda di messaggi del server
+	serverList        ServerList           //struct con contatori di ricezioni/invii per ogni server
 
 }
 
@@ -37,18 +37,19 @@ type ServerList struct {
 }
 
 type ClientList struct {
-	list            []int
+	list            map[int]int //ultimo numero di richiesta ricevuto da ogni client
 	clientListMutex sync.Mutex
 }
 
 // NewKVSSequentialV2 creates a new instance of KVSSequentialV2.go
-func NewKVSSequentialV2(index int) *KVSSequentialV2 {
-	numOfReplicas, _ := strconv.Atoi(os.Getenv("REPLICAS")) //numero di server = numero di client
+func NewKVSSequentialV2(index int, catalog *namespaceCatalog) *KVSSequentialV2 {
+	numOfReplicas := utils.NumberOfReplicas //numero di server = numero di client
 	kvs := &KVSSequentialV2{
-		index: index,
-		store: make(map[string]string),
+		index:            index,
+		keyspaces:        make(map[string]*keyspace),
+		namespaceCatalog: catalog,
 		clientList: ClientList{
-			list: make([]int, numOfReplicas),
+			list: make(map[int]int),
 		},
 		logicalClock: &LogicalClock{
 			clockValue: 0,
@@ -59,12 +60,16 @@ func NewKVSSequentialV2(index int) *KVSSequentialV2 {
 			ReceiveMsgCounter: make([]int, numOfReplicas),
 		},
 	}
-	go kvs.PeriodicCheckForEndKeys()
+	if utils.Conf.Consistency == utils.Sequential {
+		kvs.keyspaces[utils.DefaultNamespace] = newKeyspace(utils.Namespace{Name: utils.DefaultNamespace, Consistency: utils.Sequential})
+	}
 	return kvs
 }
 
 // Update è la funzione dedicata alla ricezione di messaggi che si scambiano i server
 func (kvs *KVSSequentialV2) Update(m utils.MessageNA, resp *utils.Response) error {
+	kvs.beginUpdate()
+	defer kvs.end()
 
 	msg := &utils.Message{
 		Args:             m.Args,
@@ -90,7 +95,7 @@ func (kvs *KVSSequentialV2) Update(m utils.MessageNA, resp *utils.Response) erro
 
 	<-cond0 //aspetto che cond0 sia verificata
 
-	if msg.OpType == utils.Get {
+	if isInternalEvent(msg.OpType) {
 
 		if msg.ServerIndex != kvs.index {
 			kvs.serverList.ReceiveMsgCounter[msg.ServerIndex] += 1 //conteggio il messaggio come ricevuto dal server
@@ -112,18 +117,18 @@ func (kvs *KVSSequentialV2) Update(m utils.MessageNA, resp *utils.Response) erro
 	kvs.UpdateLogicalClockAfterReception(msg)
 	kvs.logicalClock.clockMutex.Unlock()
 
-	if msg.Args.Key == utils.EndKey && msg.Args.Value == utils.EndValue {
+	if msg.OpType == utils.End {
 		//Se è un messaggio di End l'importante è che venga inserito in coda, poi non va
 		//realmente processato.
 		fmt.Println("\033[1;35mENDOPS FROM SERVER ", msg.ServerIndex, " CORRECTLY PROCESSED\033[0m")
 		return nil
 	}
 
-	if msg.OpType != utils.Get { //Per le GET (evento interno) non invio ack
+	if !isInternalEvent(msg.OpType) { //Per le GET e le Scan (eventi interni) non invio ack
 		utils.SendAllAcks(m) //Uso la versione del messaggio senza lock per inviare l'ack
 	}
 
-	fmt.Printf("MSG %s ready to wait for exec\n", msg.UUID)
+	fmt.Printf("MSG %s ready to wait for exec, clock = %s\n", msg.UUID, utils.FormatClock(msg.ClockValue))
 	kvs.WaitUntilExecutable(msg)
 
 	err := kvs.CallRealOperation(msg, resp)
@@ -235,7 +240,7 @@ func (kvs *KVSSequentialV2) checkForAllAcks(msg *utils.Message) bool {
 
 	currentAcks := msg.Acks.Load()
 
-	return int(currentAcks) == utils.NumberOfReplicas
+	return int(currentAcks) >= utils.ActiveReplicas() //le repliche uscite dal cluster non invieranno più ack
 }
 
 func (kvs *KVSSequentialV2) checkForHigherClocks(msg *utils.Message) bool {
@@ -252,6 +257,10 @@ func (kvs *KVSSequentialV2) checkForHigherClocks(msg *utils.Message) bool {
 	ok := 0
 
 	for serverIndex := 0; serverIndex < utils.NumberOfReplicas; serverIndex++ {
+		if utils.HasPeerLeft(serverIndex) {
+			ok++ //una replica uscita dal cluster non invierà più messaggi: non va attesa
+			continue
+		}
 		found := false
 		for i := 0; i < len(kvs.messageQueue.Queue); i++ {
 
@@ -313,39 +322,96 @@ func (kvs *KVSSequentialV2) CallRealOperation(msg *utils.Message, resp *utils.Re
 	kvs.mapMutex.Lock()
 	defer kvs.mapMutex.Unlock()
 
+	ks, ok := liveKeyspace(kvs.keyspaces, msg.Args)
+	if !ok {
+		//Il namespace è stato eliminato: su tutte le repliche l'operazione non ha effetto, anche se nel frattempo è
+		//stato ricreato con lo stesso nome
+		resp.Key = msg.Args.Key
+		fmt.Printf("%s operation skipped, namespace %s not found\n", msg.OpType, msg.Args.Namespace)
+		return nil
+	}
+
 	switch msg.OpType {
 	case utils.Get:
 		// Implementazione dell'operazione Get
-		value, ok := kvs.store[msg.Args.Key]
+		value, ok := ks.store.Get(msg.Args.Key)
 		if !ok {
 			resp.Key = msg.Args.Key
-			resp.Value = utils.KeyNotFound //Non è un vero e proprio errore, può succedere che un client richieda una risorsa che è stata eliminata da altri
+			resp.Found = false //Non è un vero e proprio errore, può succedere che un client richieda una risorsa che è stata eliminata da altri
 			resp.IsPrintable = true
-			fmt.Printf("Get operation completed. Key: %s, Value: %s\n", resp.Key, resp.Value)
+			fmt.Printf("Get operation completed. Key: %s not found\n", resp.Key)
 			break
 		}
 		resp.Key = msg.Args.Key
 		resp.Value = value
+		resp.Found = true
+		resp.Version = ks.versions[msg.Args.Key]
 		resp.IsPrintable = true
 		fmt.Printf("Get operation completed. Key: %s, Value: %s\n", msg.Args.Key, value)
 
 	case utils.Put:
 		// Implementazione dell'operazione Put
-		if msg.Args.Key == utils.EndKey && msg.Args.Value == utils.EndValue {
-			return nil //Se è il messaggio fittizio di End, non eseguire realmente la PUT
+		kvs.setKey(ks, msg, msg.Args.Key, msg.Args.Value)
+		resp.Version = ks.versions[msg.Args.Key]
+		if msg.Args.TTL > 0 {
+			kvs.scheduleExpiry(ks.info, msg.Args.Key, resp.Version, msg.Args.TTL, msg.ServerIndex)
 		}
-		kvs.store[msg.Args.Key] = msg.Args.Value
 		fmt.Printf("Put operation completed. Key: %s, Value: %s\n", msg.Args.Key, msg.Args.Value)
 
 	case utils.Delete:
 		// Implementazione dell'operazione Delete
-		/*_, ok := kvs.store[msg.Args.Key]
+		/*_, ok := ks.store.Get(msg.Args.Key)
 		if !ok {
 			return fmt.Errorf("error during Delete operation: key '%s' not found", msg.Args.Key)
 		}*/
-		delete(kvs.store, msg.Args.Key) //Se la chiave non c'è ho una no-op ed è il comportamento desiderato
+		kvs.deleteKey(ks, msg, msg.Args.Key) //Se la chiave non c'è ho una no-op ed è il comportamento desiderato
 		fmt.Printf("Delete operation completed. Key: %s\n", msg.Args.Key)
 
+	case utils.Increment, utils.Append:
+		//Il nuovo valore è calcolato alla consegna, nello stesso punto dell'ordine totale su ogni replica: due
+		//Increment concorrenti vengono applicate una dopo l'altra e nessuna delle due va persa
+		resp.Key = msg.Args.Key
+		if value, err := kvs.modifyKey(ks, msg, msg.OpType, msg.Args); err != nil {
+			resp.Value, resp.Found = ks.store.Get(msg.Args.Key)
+			fmt.Printf("%s operation skipped. Key: %s, Error: %v\n", msg.OpType, msg.Args.Key, err)
+		} else {
+			resp.Value, resp.Found, resp.Succeeded = value, true, true
+			resp.Version = ks.versions[msg.Args.Key]
+			fmt.Printf("%s operation completed. Key: %s, Value: %s\n", msg.OpType, msg.Args.Key, value)
+		}
+
+	case utils.CompareAndSwap:
+		//Il confronto avviene alla consegna, quindi nello stesso punto dell'ordine totale su ogni replica:
+		//tutte prendono la stessa decisione senza bisogno di coordinarsi ulteriormente
+		kvs.compareAndSwap(ks, msg, resp)
+		fmt.Printf("CompareAndSwap operation completed. Key: %s, Succeeded: %t, Version: %d\n",
+			msg.Args.Key, resp.Succeeded, resp.Version)
+
+	case utils.Batch:
+		kvs.applyBatch(ks, msg, resp)
+		fmt.Printf("Batch operation completed. Operations: %d\n", len(msg.Args.Batch))
+
+	case utils.Txn:
+		kvs.applyTxn(ks, msg, resp)
+		fmt.Printf("Txn operation completed. Operations: %d, Succeeded: %t\n", len(msg.Args.Txn), resp.Succeeded)
+
+	case utils.Scan:
+		resp.ScanResults, resp.Cursor = scanRange(ks.store, msg.Args)
+		for i := range resp.ScanResults {
+			resp.ScanResults[i].Version = ks.versions[resp.ScanResults[i].Key]
+		}
+		fmt.Printf("Scan operation completed. Range: [%s, %s), Keys: %d\n", msg.Args.Key, msg.Args.RangeEnd, len(resp.ScanResults))
+
+	case utils.Expire:
+		//La chiave viene eliminata solo se ha ancora la versione scritta dalla Put con TTL: se nel frattempo è stata
+		//sovrascritta o eliminata l'Expire non ha effetto. Tutte le repliche lo valutano nello stesso punto dell'ordine.
+		if matchesExpected(ks, msg.Args.Key, msg.Args.ExpectedVersion, nil, false) {
+			kvs.deleteKey(ks, msg, msg.Args.Key)
+			fmt.Printf("Expire operation completed. Key: %s\n", msg.Args.Key)
+		} else {
+			fmt.Printf("Expire operation skipped, key %s was overwritten\n", msg.Args.Key)
+		}
+
 	default:
 		return fmt.Errorf("unknown operation type: %s", msg.OpType)
 	}
@@ -353,6 +419,152 @@ func (kvs *KVSSequentialV2) CallRealOperation(msg *utils.Message, resp *utils.Re
 	return nil
 }
 
+// setKey scrive key nel namespace ks con la scrittura contenuta in msg, ne incrementa la versione e notifica i
+// Watch. Va chiamata con mapMutex già acquisito.
+func (kvs *KVSSequentialV2) setKey(ks *keyspace, msg *utils.Message, key string, value []byte) {
+	ks.set(key, value)
+	ks.versions[key]++
+	ks.publish(utils.WatchEvent{Namespace: ks.info.Name, OpType: utils.Put, Key: key, Value: value, Version: ks.versions[key], ClockValue: msg.ClockValue, Message: msg.UUID.String()})
+}
+
+// deleteKey elimina key dal namespace ks e, se la chiave esisteva, notifica i Watch. Va chiamata con mapMutex già
+// acquisito.
+func (kvs *KVSSequentialV2) deleteKey(ks *keyspace, msg *utils.Message, key string) {
+	if ks.remove(key) {
+		ks.publish(utils.WatchEvent{Namespace: ks.info.Name, OpType: utils.Delete, Key: key, Version: ks.versions[key], ClockValue: msg.ClockValue, Message: msg.UUID.String()})
+	}
+}
+
+// modifyKey applica una Increment o una Append alla chiave args.Key del namespace ks e ritorna il valore scritto.
+// Se il valore corrente non lo permette la chiave non viene modificata. Va chiamata con mapMutex già acquisito.
+func (kvs *KVSSequentialV2) modifyKey(ks *keyspace, msg *utils.Message, op string, args utils.Args) ([]byte, error) {
+	old, exists := ks.store.Get(args.Key)
+	value, err := utils.ModifiedValue(op, old, exists, args)
+	if err != nil {
+		return nil, err
+	}
+	kvs.setKey(ks, msg, args.Key, value)
+	return value, nil
+}
+
+// scheduleExpiry prepara l'invio dell'Expire per la versione version della chiave key del namespace namespace,
+// scritta con TTL ttl da una Put ricevuta dalla replica origin. Va chiamata con mapMutex già acquisito.
+func (kvs *KVSSequentialV2) scheduleExpiry(namespace utils.Namespace, key string, version int, ttl time.Duration, origin int) {
+	args := utils.Args{Namespace: namespace.Name, NamespaceRevision: namespace.Revision, Key: key, ExpectedVersion: version}
+	pending := func() bool {
+		kvs.mapMutex.Lock()
+		defer kvs.mapMutex.Unlock()
+		ks, ok := liveKeyspace(kvs.keyspaces, args)
+		return ok && matchesExpected(ks, key, version, nil, false)
+	}
+	send := func() error {
+		if err := kvs.beginRequest(); err != nil {
+			return err
+		}
+		defer kvs.end()
+		return kvs.multicast(args, utils.NewResponse(), utils.Expire)
+	}
+	scheduleExpiry(key, ttl, kvs.index, origin, pending, send)
+}
+
+// compareAndSwap scrive args.Value se la chiave ha la versione (o il valore) attesa. In entrambi i casi la
+// risposta riporta il valore e la versione correnti della chiave, per permettere al client di riprovare.
+// Va chiamata con mapMutex già acquisito.
+func (kvs *KVSSequentialV2) compareAndSwap(ks *keyspace, msg *utils.Message, resp *utils.Response) {
+	args := msg.Args
+	matches := matchesExpected(ks, args.Key, args.ExpectedVersion, args.ExpectedValue, args.CompareValue)
+	if matches {
+		kvs.setKey(ks, msg, args.Key, args.Value)
+	}
+
+	resp.Key = args.Key
+	resp.Succeeded = matches
+	resp.Value, resp.Found = ks.store.Get(args.Key)
+	if resp.Found {
+		resp.Version = ks.versions[args.Key]
+	}
+}
+
+// matchesExpected verifica la condizione di una CompareAndSwap o di una Guard nel namespace ks: la chiave deve
+// avere la versione attesa (0 = la chiave non deve esistere) oppure, con compareValue, il valore atteso.
+// Va chiamata con mapMutex già acquisito.
+func matchesExpected(ks *keyspace, key string, expectedVersion int, expectedValue []byte, compareValue bool) bool {
+	value, exists := ks.store.Get(key)
+	if compareValue {
+		return exists && bytes.Equal(value, expectedValue)
+	}
+	if expectedVersion == 0 {
+		return !exists
+	}
+	return exists && ks.versions[key] == expectedVersion
+}
+
+// applyTxn esegue una transazione alla consegna del messaggio. Le Guard vengono valutate tutte sullo stato
+// precedente alla transazione: se anche una sola non è soddisfatta nessuna scrittura viene applicata. Le altre
+// operazioni vengono eseguite nell'ordine indicato, quindi una Get vede le scritture precedenti della stessa
+// transazione. Poiché il messaggio è consegnato nello stesso punto dell'ordine totale su ogni replica, la
+// transazione è atomica senza bisogno di un protocollo di commit.
+// Va chiamata con mapMutex già acquisito.
+func (kvs *KVSSequentialV2) applyTxn(ks *keyspace, msg *utils.Message, resp *utils.Response) {
+	args := msg.Args
+	resp.Succeeded = true
+	for _, op := range args.Txn {
+		if op.OpType == utils.Guard && !matchesExpected(ks, op.Key, op.ExpectedVersion, op.ExpectedValue, op.CompareValue) {
+			resp.Succeeded = false
+		}
+	}
+
+	resp.TxnResults = make([]utils.TxnResult, 0)
+	for _, op := range args.Txn {
+		switch op.OpType {
+		case utils.Get:
+			result := utils.TxnResult{Key: op.Key}
+			if result.Value, result.Found = ks.store.Get(op.Key); result.Found {
+				result.Version = ks.versions[op.Key]
+			}
+			resp.TxnResults = append(resp.TxnResults, result)
+		case utils.Put:
+			if resp.Succeeded {
+				kvs.setKey(ks, msg, op.Key, op.Value)
+			}
+		case utils.Delete:
+			if resp.Succeeded {
+				kvs.deleteKey(ks, msg, op.Key)
+			}
+		}
+	}
+}
+
+// applyBatch esegue alla consegna gli elementi di un Batch, nell'ordine indicato. A differenza di una transazione
+// non ci sono condizioni: ogni elemento viene applicato e produce il proprio risultato.
+// Va chiamata con mapMutex già acquisito.
+func (kvs *KVSSequentialV2) applyBatch(ks *keyspace, msg *utils.Message, resp *utils.Response) {
+	args := msg.Args
+	resp.BatchResults = make([]utils.BatchResult, len(args.Batch))
+	for i, item := range args.Batch {
+		key := item.Args.Key
+		result := utils.BatchResult{Key: key}
+		switch item.OpType {
+		case utils.Get:
+			if result.Value, result.Found = ks.store.Get(key); result.Found {
+				result.Version = ks.versions[key]
+			}
+		case utils.Put:
+			kvs.setKey(ks, msg, key, item.Args.Value)
+			result.Version = ks.versions[key]
+		case utils.Delete:
+			kvs.deleteKey(ks, msg, key)
+		case utils.Increment, utils.Append:
+			if value, err := kvs.modifyKey(ks, msg, item.OpType, item.Args); err != nil {
+				result.Error = err.Error()
+			} else {
+				result.Value, result.Found, result.Version = value, true, ks.versions[key]
+			}
+		}
+		resp.BatchResults[i] = result
+	}
+}
+
 func (kvs *KVSSequentialV2) ExecuteClientRequest(arg utils.Args, resp *utils.Response, op string) error {
 
 	/*
@@ -378,6 +590,11 @@ func (kvs *KVSSequentialV2) ExecuteClientRequest(arg utils.Args, resp *utils.Res
 			dovrà fare uso dei relativi mutex delle strutture dati associate.
 	*/
 
+	if err := kvs.beginRequest(); err != nil {
+		return err //il server è in fase di spegnimento
+	}
+	defer kvs.end()
+
 	//Condizione 0: FIFO ordering per le richieste dai client
 
 	cond0 := make(chan bool)
@@ -395,12 +612,32 @@ func (kvs *KVSSequentialV2) ExecuteClientRequest(arg utils.Args, resp *utils.Res
 	<-cond0 //aspetto che cond0 sia verificata
 	//A questo punto sono sicuro di star processando la richiesta che mi aspettavo dal client.
 
+	if op == utils.End {
+		//L'End non riguarda un namespace: viene inviato dallo storage che riceve le richieste dei client
+		if err := utils.AuthorizeRequest(arg, op); err != nil {
+			return err
+		}
+		return kvs.multicast(arg, resp, op)
+	}
+	//Controllo della richiesta, dei permessi e delle quote e invio tramite lo storage che ospita il namespace
+	return kvs.dispatch(arg, resp, op)
+}
+
+// isInternalEvent indica le operazioni di sola lettura, che sono eventi interni della replica che le riceve: le
+// altre repliche le scartano e non ne inviano l'ack
+func isInternalEvent(op string) bool {
+	return op == utils.Get || op == utils.Scan
+}
+
+// multicast crea il messaggio dell'operazione op e lo invia a tutte le repliche tramite il multicast totalmente
+// ordinato. Per le richieste dei client va chiamata solo dopo aver verificato l'ordine FIFO e i permessi.
+func (kvs *KVSSequentialV2) multicast(arg utils.Args, resp *utils.Response, op string) error {
 	kvs.logicalClock.clockMutex.Lock()
 	kvs.serverList.sendMsgMutex.Lock()
 	kvs.serverList.SendMsgCounter += 1
 
 	sendCounter := kvs.serverList.SendMsgCounter
-	clockValue := kvs.logicalClock.clockValue
+	clockValue := utils.NextClock(kvs.logicalClock.clockValue, time.Now()) //con il clock ibrido segue il tempo fisico
 	msg := utils.NewMessageNA(arg, clockValue, kvs.index, sendCounter, op)
 
 	kvs.logicalClock.clockValue = clockValue + 1
@@ -408,13 +645,26 @@ func (kvs *KVSSequentialV2) ExecuteClientRequest(arg utils.Args, resp *utils.Res
 
 	kvs.serverList.sendMsgMutex.Unlock()
 
+	resp.ClockValue = msg.ClockValue
+
 	var err error
-	if msg.OpType == utils.Get {
-		respChannel := make(chan string, 1)
+	if msg.OpType == utils.Get || msg.OpType == utils.Scan || msg.OpType == utils.CompareAndSwap || msg.OpType == utils.Txn ||
+		msg.OpType == utils.Batch || msg.OpType == utils.Increment || msg.OpType == utils.Append {
+		//L'esito di Get, Scan, CompareAndSwap, Txn, Batch, Increment e Append è quello calcolato alla consegna
+		//dalla replica corrente
+		respChannel := make(chan *utils.Response, 1)
 		err = utils.SendGETToAllServer(*msg, respChannel, kvs.index)
-		resp.Value = <-respChannel
+		delivered := <-respChannel
 		resp.Key = msg.Args.Key
-		resp.IsPrintable = true
+		resp.Value = delivered.Value
+		resp.Found = delivered.Found
+		resp.Version = delivered.Version
+		resp.Succeeded = delivered.Succeeded
+		resp.TxnResults = delivered.TxnResults
+		resp.BatchResults = delivered.BatchResults
+		resp.ScanResults = delivered.ScanResults
+		resp.Cursor = delivered.Cursor
+		resp.IsPrintable = msg.OpType == utils.Get
 
 	} else {
 		err = utils.SendToAllServer(*msg)
@@ -431,9 +681,8 @@ func (kvs *KVSSequentialV2) ExecuteClientRequest(arg utils.Args, resp *utils.Res
 
 func (kvs *KVSSequentialV2) UpdateLogicalClockAfterReception(m *utils.Message) {
 
-	if kvs.logicalClock.clockValue < m.ClockValue { //Il messaggio ha un clock maggiore di quello corrente, allora aggiorno
-		kvs.logicalClock.clockValue = m.ClockValue
-	}
+	//Se il messaggio ha un clock maggiore di quello corrente aggiorno (con il clock ibrido, oltre il suo clock)
+	kvs.logicalClock.clockValue = utils.ObserveClock(kvs.logicalClock.clockValue, m.ClockValue)
 }
 
 func (kvs *KVSSequentialV2) Get(args utils.Args, reply *utils.Response) error {
@@ -454,6 +703,75 @@ func (kvs *KVSSequentialV2) Put(args utils.Args, reply *utils.Response) error {
 	return nil
 }
 
+// Increment somma args.Delta al contatore args.Key (0 se la chiave non esiste) e riporta in reply.Value il nuovo
+// valore. La somma avviene alla consegna del messaggio, quindi le Increment concorrenti non si sovrascrivono.
+func (kvs *KVSSequentialV2) Increment(args utils.Args, reply *utils.Response) error {
+	return kvs.ExecuteClientRequest(args, reply, utils.Increment)
+}
+
+// Append accoda args.Value al valore di args.Key e riporta in reply.Value il valore risultante
+func (kvs *KVSSequentialV2) Append(args utils.Args, reply *utils.Response) error {
+	return kvs.ExecuteClientRequest(args, reply, utils.Append)
+}
+
+// UpdateCRDT è supportata solo nei namespace con consistenza causale: nei namespace sequenziali la richiesta viene
+// rifiutata da dispatch
+func (kvs *KVSSequentialV2) UpdateCRDT(args utils.Args, reply *utils.Response) error {
+	return kvs.ExecuteClientRequest(args, reply, utils.UpdateCRDT)
+}
+
+// CompareAndSwap scrive args.Value solo se la versione corrente della chiave è args.ExpectedVersion (oppure, con
+// args.CompareValue, se il valore corrente è args.ExpectedValue). reply.Succeeded riporta l'esito.
+func (kvs *KVSSequentialV2) CompareAndSwap(args utils.Args, reply *utils.Response) error {
+	return kvs.ExecuteClientRequest(args, reply, utils.CompareAndSwap)
+}
+
+// Txn esegue atomicamente le operazioni di args.Txn come un unico messaggio del multicast totalmente ordinato.
+// reply.Succeeded è false se una Guard non è soddisfatta, reply.TxnResults contiene i risultati delle Get.
+func (kvs *KVSSequentialV2) Txn(args utils.Args, reply *utils.Response) error {
+	return kvs.ExecuteClientRequest(args, reply, utils.Txn)
+}
+
+// Batch esegue gli elementi di args.Batch come un unico messaggio del multicast totalmente ordinato: il Batch
+// occupa un solo turno FIFO del client e ogni replica ne applica gli elementi in sequenza, senza che altri
+// messaggi si interpongano. reply.BatchResults contiene il risultato di ogni elemento.
+func (kvs *KVSSequentialV2) Batch(args utils.Args, reply *utils.Response) error {
+	return kvs.ExecuteClientRequest(args, reply, utils.Batch)
+}
+
+// Scan legge in ordine le chiavi nell'intervallo [args.Key, args.RangeEnd), al più args.Limit per pagina. Come una
+// Get è un evento interno: viene servita nel punto dell'ordine totale in cui la replica consegna il messaggio.
+func (kvs *KVSSequentialV2) Scan(args utils.Args, reply *utils.Response) error {
+	return kvs.ExecuteClientRequest(args, reply, utils.Scan)
+}
+
+// ScanPrefix legge in ordine le chiavi che iniziano con args.Key, con le stesse regole di paginazione di Scan
+func (kvs *KVSSequentialV2) ScanPrefix(args utils.Args, reply *utils.Response) error {
+	return kvs.ExecuteClientRequest(prefixRange(args), reply, utils.Scan)
+}
+
+// End segnala che il client ha terminato le proprie operazioni: il messaggio di End viene inviato a tutte le
+// repliche ma non viene applicato, serve a far avanzare i clock e a svuotare le code al termine dei test
+func (kvs *KVSSequentialV2) End(args utils.Args, reply *utils.Response) error {
+	return kvs.ExecuteClientRequest(args, reply, utils.End)
+}
+
+// CreateNamespace crea il namespace args.NamespaceSpec. La creazione viene ordinata dal sequencer del catalogo, e
+// la risposta arriva solo quando tutte le repliche attive l'hanno applicata.
+func (kvs *KVSSequentialV2) CreateNamespace(args utils.Args, reply *utils.Response) error {
+	return kvs.ExecuteClientRequest(args, reply, utils.CreateNamespace)
+}
+
+// DropNamespace elimina il namespace args.NamespaceSpec.Name con tutte le sue chiavi
+func (kvs *KVSSequentialV2) DropNamespace(args utils.Args, reply *utils.Response) error {
+	return kvs.ExecuteClientRequest(args, reply, utils.DropNamespace)
+}
+
+// ListNamespaces riporta in reply.Namespaces i namespace esistenti sulla replica, con il loro utilizzo
+func (kvs *KVSSequentialV2) ListNamespaces(args utils.Args, reply *utils.Response) error {
+	return kvs.ExecuteClientRequest(args, reply, utils.ListNamespaces)
+}
+
 func (kvs *KVSSequentialV2) Delete(args utils.Args, reply *utils.Response) error {
 	// Chiamata al metodo ExecuteClientRequest con l'operazione "Delete"
 	err := kvs.ExecuteClientRequest(args, reply, utils.Delete)
@@ -463,10 +781,12 @@ func (kvs *KVSSequentialV2) Delete(args utils.Args, reply *utils.Response) error
 	return nil
 }
 
+// PeriodicCheckForEndKeys controlla periodicamente la coda dei messaggi. Viene avviato dal server dopo aver creato
+// il catalogo, così i test possono creare gli storage senza goroutine in background.
 func (kvs *KVSSequentialV2) PeriodicCheckForEndKeys() {
 	for {
 		kvs.checkForEndKeys()
-		time.Sleep(3 * time.Second)
+		time.Sleep(utils.Conf.Timeouts.EndCheckInterval)
 	}
 }
 
@@ -479,43 +799,45 @@ func (kvs *KVSSequentialV2) checkForEndKeys() {
 		return
 	}
 
-	// Controlla che tutti i messaggi nella coda abbiano Args.Key == utils.EndKey e Args.Value == utils.EndValue
+	// Controlla che tutti i messaggi nella coda siano messaggi di End
 	for _, msg := range kvs.messageQueue.Queue {
-		if msg.Args.Key != utils.EndKey || msg.Args.Value != utils.EndValue {
+		if msg.OpType != utils.End {
 			return
 		}
 	}
 
-	// Stampa in maniera formattata il contenuto della map
+	// Stampa in maniera formattata il contenuto di ogni namespace
 	kvs.mapMutex.Lock()
 	defer kvs.mapMutex.Unlock()
+	printKeyspaces(kvs.keyspaces)
 
-	// Trova la lunghezza massima delle chiavi e dei valori per la formattazione
-	maxKeyLen := 3 // La lunghezza minima per "Key"
-	maxValLen := 5 // La lunghezza minima per "Value"
-	for key, value := range kvs.store {
-		if len(key) > maxKeyLen {
-			maxKeyLen = len(key)
-		}
-		if len(value) > maxValLen {
-			maxValLen = len(value)
-		}
-	}
+	// Svuota la coda dagli END MESSAGE
+	kvs.messageQueue.Queue = kvs.messageQueue.Queue[:0]
 
-	// Stampa l'intestazione della tabella
-	fmt.Println(strings.Repeat("-", maxKeyLen+maxValLen+7))
-	fmt.Printf("| %-*s | %-*s |\n", maxKeyLen, "Key", maxValLen, "Value")
-	fmt.Println(strings.Repeat("-", maxKeyLen+maxValLen+7))
+}
 
-	// Stampa ogni riga della tabella con key e value
-	for key, value := range kvs.store {
-		fmt.Printf("| %-*s | %-*s |\n", maxKeyLen, key, maxValLen, value)
+// PeerLeaving riceve la notifica di uscita dal cluster di un'altra replica, che da questo momento non verrà
+// più considerata nell'invio dei messaggi e nelle condizioni di consegna
+func (kvs *KVSSequentialV2) PeerLeaving(args utils.LeaveArgs, resp *utils.Response) error {
+	index, ok := utils.Peers.IndexOf(args.ServerID)
+	if !ok {
+		return fmt.Errorf("unknown server id %q", args.ServerID)
 	}
+	utils.MarkPeerLeft(index)
+	fmt.Printf("\033[35mServer %s left the cluster\033[0m\n", args.ServerID)
+	return nil
+}
 
-	// Stampa la linea di chiusura della tabella
-	fmt.Println(strings.Repeat("-", maxKeyLen+maxValLen+7))
-
-	// Svuota la coda dagli END MESSAGE
-	kvs.messageQueue.Queue = kvs.messageQueue.Queue[:0]
+// Snapshot ritorna una copia del contenuto dello storage, per namespace
+func (kvs *KVSSequentialV2) Snapshot() map[string]map[string][]byte {
+	kvs.mapMutex.Lock()
+	defer kvs.mapMutex.Unlock()
+	return snapshotKeyspaces(kvs.keyspaces)
+}
 
+// withKeyspaces esegue fn sui namespace dello storage con mapMutex acquisito
+func (kvs *KVSSequentialV2) withKeyspaces(fn func(keyspaces map[string]*keyspace)) {
+	kvs.mapMutex.Lock()
+	defer kvs.mapMutex.Unlock()
+	fn(kvs.keyspaces)
 }
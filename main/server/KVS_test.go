@@ -0,0 +1,93 @@
+package main
+
+import (
+	"SDCC/main/utils"
+	"fmt"
+	"os"
+	"slices"
+	"testing"
+)
+
+// TestMain imposta una volta per tutti i test la tabella delle repliche: gli storage la leggono anche da goroutine
+// che possono sopravvivere al singolo test
+func TestMain(m *testing.M) {
+	peers := make([]utils.Peer, 3)
+	for i := range peers {
+		index := i
+		peers[i] = utils.Peer{ID: fmt.Sprintf("server%d", i), Index: &index}
+	}
+	utils.Peers = utils.NewPeerTable(peers)
+	utils.NumberOfReplicas = utils.Peers.Len()
+	os.Exit(m.Run())
+}
+
+// scanKeys ritorna le chiavi di una pagina di Scan
+func scanKeys(results []utils.ScanResult) []string {
+	keys := make([]string, len(results))
+	for i, result := range results {
+		keys[i] = result.Key
+	}
+	return keys
+}
+
+func TestScanRangePages(t *testing.T) {
+	store := newSkipList()
+	for _, key := range []string{"a", "b", "c", "d", "e"} {
+		store.Set(key, []byte(key))
+	}
+
+	results, cursor := scanRange(store, utils.Args{Key: "b", Limit: 2})
+	if got := scanKeys(results); !slices.Equal(got, []string{"b", "c"}) || cursor != "d" {
+		t.Fatalf("first page = %v, cursor %q, want [b c], cursor \"d\"", got, cursor)
+	}
+	results, cursor = scanRange(store, utils.Args{Key: "b", Limit: 2, Cursor: cursor})
+	if got := scanKeys(results); !slices.Equal(got, []string{"d", "e"}) || cursor != "" {
+		t.Fatalf("second page = %v, cursor %q, want [d e], no cursor", got, cursor)
+	}
+	results, cursor = scanRange(store, utils.Args{Key: "a", RangeEnd: "c"})
+	if got := scanKeys(results); !slices.Equal(got, []string{"a", "b"}) || cursor != "" {
+		t.Fatalf("bounded range = %v, cursor %q, want [a b], no cursor", got, cursor)
+	}
+}
+
+func TestScanRangeSkipsUnreadableKeys(t *testing.T) {
+	conf := utils.DefaultConfig()
+	conf.Auth = utils.AuthConfig{
+		Tokens: map[string]string{"token-alice": "alice"},
+		Rules: []utils.ACLRule{
+			{Identity: "alice", Prefix: "public/", Access: utils.ReadOnly},
+			{Identity: "alice", Prefix: "shared/", Access: utils.ReadWrite},
+		},
+	}
+	previous := utils.Conf
+	utils.Conf = conf
+	defer func() { utils.Conf = previous }()
+
+	store := newSkipList()
+	for _, key := range []string{"public/1", "public/2", "secret/1", "secret/2", "secret/3", "shared/1"} {
+		store.Set(key, []byte(key))
+	}
+
+	//Le chiavi non leggibili non contano nel limite e non diventano il cursore
+	args := utils.Args{Token: "token-alice", Limit: 2}
+	results, cursor := scanRange(store, args)
+	if got := scanKeys(results); !slices.Equal(got, []string{"public/1", "public/2"}) || cursor != "shared/1" {
+		t.Fatalf("first page = %v, cursor %q, want [public/1 public/2], cursor \"shared/1\"", got, cursor)
+	}
+	args.Cursor = cursor
+	results, cursor = scanRange(store, args)
+	if got := scanKeys(results); !slices.Equal(got, []string{"shared/1"}) || cursor != "" {
+		t.Fatalf("second page = %v, cursor %q, want [shared/1], no cursor", got, cursor)
+	}
+
+	//Una pagina piena di chiavi non leggibili non è vuota se più avanti ce ne sono di leggibili
+	results, _ = scanRange(store, utils.Args{Token: "token-alice", Key: "secret/", Limit: 1})
+	if got := scanKeys(results); !slices.Equal(got, []string{"shared/1"}) {
+		t.Fatalf("page after unreadable keys = %v, want [shared/1]", got)
+	}
+
+	results, cursor = scanRange(store, utils.Args{Token: "unknown", Limit: 2})
+	if len(results) != 0 || cursor != "" {
+		t.Fatalf("unauthenticated scan = %v, cursor %q, want no keys and no cursor", scanKeys(results), cursor)
+	}
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"SDCC/main/utils"
+	"bytes"
+	"encoding/json"
+	"slices"
+	"strconv"
+)
+
+/*
+ Valori CRDT
+
+ Nei namespace causali repliche diverse possono consegnare scritture concorrenti in ordini diversi: con le Put
+ l'ultima consegnata sovrascrive le altre, quindi le repliche possono restare con valori diversi. Gli aggiornamenti
+ CRDT sono invece operazioni che ogni replica applica al proprio stato, e che per costruzione portano allo stesso
+ stato qualunque sia l'ordine di consegna di quelle concorrenti. Il multicast causalmente ordinato garantisce che
+ un aggiornamento venga consegnato dopo tutti quelli da cui dipende; quelli concorrenti vengono riconosciuti
+ confrontando i clock vettoriali dei messaggi:
+ - GCounter e PNCounter tengono un contatore per ogni replica d'origine (due per il PNCounter, incrementi e
+   decrementi): la somma non dipende dall'ordine;
+ - ORSet conserva ogni aggiunta con il clock del proprio messaggio; una rimozione elimina le aggiunte dello stesso
+   elemento con clock minore o uguale al proprio, cioè quelle che la replica d'origine aveva già consegnato. Le
+   aggiunte concorrenti alla rimozione restano;
+ - LWWRegister conserva la scrittura maggiore secondo un ordine totale che estende la relazione di causalità:
+   somma delle componenti del clock e, a parità, indice della replica d'origine;
+ - MVRegister conserva tutte le scritture concorrenti: una scrittura elimina quelle con clock minore o uguale al
+   proprio.
+
+ Lo stato di una chiave CRDT non viene modificato alla consegna di ogni aggiornamento, ma ricalcolato dagli
+ aggiornamenti conservati tra i sibling della chiave (vedere siblings.go), nello stesso ordine su tutte le repliche:
+ anche il tipo del valore, e l'esito degli aggiornamenti di un tipo diverso, non dipendono dall'ordine di consegna.
+*/
+
+// crdtEntry è un elemento di un ORSet o una scrittura di un registro, con il clock del messaggio che l'ha prodotta
+type crdtEntry struct {
+	value  []byte
+	clock  []int
+	origin int
+}
+
+// before indica se e precede f nell'ordine totale usato dai registri, che estende la relazione di causalità
+func (e crdtEntry) before(f crdtEntry) bool {
+	if sumOf(e.clock) != sumOf(f.clock) {
+		return sumOf(e.clock) < sumOf(f.clock)
+	}
+	return e.origin < f.origin
+}
+
+// crdtState è lo stato di una chiave CRDT
+type crdtState struct {
+	kind     string
+	positive []int64     //incrementi di ogni replica d'origine (contatori)
+	negative []int64     //decrementi di ogni replica d'origine (PNCounter)
+	entries  []crdtEntry //elementi (ORSet) o scritture (registri), nell'ordine totale dei registri
+}
+
+func newCRDTState(kind string) *crdtState {
+	return &crdtState{
+		kind:     kind,
+		positive: make([]int64, utils.NumberOfReplicas),
+		negative: make([]int64, utils.NumberOfReplicas),
+	}
+}
+
+// clone ritorna una copia dello stato, che si può aggiornare senza modificare s. La copia di nil è nil.
+func (s *crdtState) clone() *crdtState {
+	if s == nil {
+		return nil
+	}
+	return &crdtState{kind: s.kind, positive: slices.Clone(s.positive), negative: slices.Clone(s.negative), entries: slices.Clone(s.entries)}
+}
+
+// apply applica l'aggiornamento u
+func (s *crdtState) apply(u update) {
+	args := u.args
+	entry := crdtEntry{value: args.Value, clock: u.clock, origin: u.origin}
+	switch s.kind {
+	case utils.GCounter, utils.PNCounter:
+		if args.Delta >= 0 {
+			s.positive[u.origin] += args.Delta
+		} else {
+			s.negative[u.origin] -= args.Delta
+		}
+	case utils.ORSet:
+		if args.CRDTRemove {
+			s.entries = slices.DeleteFunc(s.entries, func(e crdtEntry) bool {
+				return bytes.Equal(e.value, args.Value) && happenedBefore(e.clock, u.clock)
+			})
+		} else {
+			s.entries = append(s.entries, entry)
+		}
+	case utils.LWWRegister:
+		if len(s.entries) == 0 || s.entries[0].before(entry) {
+			s.entries = []crdtEntry{entry}
+		}
+	case utils.MVRegister:
+		s.entries = slices.DeleteFunc(s.entries, func(e crdtEntry) bool {
+			return happenedBefore(e.clock, u.clock)
+		})
+		s.entries = append(s.entries, entry)
+		slices.SortFunc(s.entries, func(a, b crdtEntry) int {
+			if a.before(b) {
+				return -1
+			}
+			return 1
+		})
+	}
+}
+
+// value ritorna il valore corrente, uguale su tutte le repliche che hanno consegnato gli stessi aggiornamenti
+func (s *crdtState) value() *utils.CRDTValue {
+	v := &utils.CRDTValue{Type: s.kind, Values: make([][]byte, 0)}
+	switch s.kind {
+	case utils.GCounter, utils.PNCounter:
+		for i := range s.positive {
+			v.Counter += s.positive[i] - s.negative[i]
+		}
+	case utils.ORSet:
+		for _, e := range s.entries {
+			if !slices.ContainsFunc(v.Values, func(value []byte) bool { return bytes.Equal(value, e.value) }) {
+				v.Values = append(v.Values, e.value)
+			}
+		}
+		slices.SortFunc(v.Values, bytes.Compare)
+	case utils.LWWRegister, utils.MVRegister:
+		for _, e := range s.entries {
+			v.Values = append(v.Values, e.value)
+		}
+	}
+	return v
+}
+
+// render ritorna la rappresentazione del valore scritta nello storage, letta da Scan, Watch e snapshot: i
+// contatori come intero decimale, il LWWRegister come il proprio valore, ORSet e MVRegister come array JSON
+// di stringhe
+func (s *crdtState) render() []byte {
+	v := s.value()
+	switch s.kind {
+	case utils.GCounter, utils.PNCounter:
+		return strconv.AppendInt(nil, v.Counter, 10)
+	case utils.LWWRegister:
+		if len(v.Values) == 0 {
+			return []byte{}
+		}
+		return v.Values[0]
+	}
+	values := make([]string, len(v.Values))
+	for i, value := range v.Values {
+		values[i] = string(value)
+	}
+	rendered, _ := json.Marshal(values)
+	return rendered
+}
+
+// happenedBefore indica se il clock vettoriale a è minore o uguale a b in ogni componente
+func happenedBefore(a []int, b []int) bool {
+	for i := range a {
+		if i >= len(b) || a[i] > b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func sumOf(clock []int) int {
+	sum := 0
+	for _, c := range clock {
+		sum += c
+	}
+	return sum
+}
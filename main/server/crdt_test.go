@@ -0,0 +1,50 @@
+package main
+
+import (
+	"SDCC/main/utils"
+	"testing"
+)
+
+func crdtMsg(origin int, clock []int, crdt string, delta int64, value string, remove bool) *utils.VMessageNA {
+	args := utils.Args{Namespace: "causal", NamespaceRevision: 1, Key: "k", CRDT: crdt, Delta: delta, CRDTRemove: remove}
+	if value != "" {
+		args.Value = []byte(value)
+	}
+	return utils.NewVMessageNA(args, clock, origin, utils.UpdateCRDT, 0)
+}
+
+func TestConcurrentCRDTTypesConverge(t *testing.T) {
+
+	//Aggiornamenti concorrenti di tipi diversi: il tipo della chiave non dipende dall'ordine di consegna, prevale il
+	//GCounter, primo nell'ordine totale, e l'aggiornamento successivo che l'ha visto si somma
+	msgs := []*utils.VMessageNA{
+		crdtMsg(0, []int{1, 0, 0}, utils.GCounter, 2, "", false),
+		crdtMsg(1, []int{0, 1, 0}, utils.ORSet, 0, "a", false),
+		crdtMsg(2, []int{0, 0, 1}, utils.PNCounter, -1, "", false),
+		crdtMsg(0, []int{2, 0, 0}, utils.GCounter, 3, "", false),
+		crdtMsg(1, []int{1, 2, 0}, utils.GCounter, 4, "", false),
+	}
+	want := `value="9" found=true crdt=&{Type:GCounter Counter:9 Values:[]} siblings=[{Value:[57] ClockVector:[2 2 0]}]`
+	if state := assertSameState(t, msgs, "k"); state != want {
+		t.Fatalf("state = %s, want %s", state, want)
+	}
+}
+
+func TestPutConcurrentWithCRDTConverges(t *testing.T) {
+
+	//Una Put concorrente con gli aggiornamenti ne resta un sibling su tutte le repliche, e gli aggiornamenti che
+	//l'hanno vista non hanno effetto
+	put := utils.NewVMessageNA(utils.Args{Namespace: "causal", NamespaceRevision: 1, Key: "k", Value: []byte("plain")}, []int{0, 0, 1}, 2, utils.Put, 0)
+	msgs := []*utils.VMessageNA{
+		crdtMsg(0, []int{1, 0, 0}, utils.ORSet, 0, "a", false),
+		crdtMsg(1, []int{1, 1, 0}, utils.ORSet, 0, "a", true),
+		crdtMsg(1, []int{1, 2, 0}, utils.ORSet, 0, "b", false),
+		put,
+		crdtMsg(2, []int{0, 0, 2}, utils.ORSet, 0, "c", false),
+	}
+	want := `value="[\"b\"]" found=true crdt=&{Type:ORSet Counter:0 Values:[[98]]} ` +
+		`siblings=[{Value:[112 108 97 105 110] ClockVector:[0 0 1]} {Value:[91 34 98 34 93] ClockVector:[1 2 0]}]`
+	if state := assertSameState(t, msgs, "k"); state != want {
+		t.Fatalf("state = %s, want %s", state, want)
+	}
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"SDCC/main/utils"
+	"errors"
+	"fmt"
+	"time"
+)
+
+/*
+ Scadenza delle chiavi (TTL)
+
+ Ogni replica, quando consegna una Put con TTL, avvia un timer locale. L'orologio della replica serve però solo a
+ decidere QUANDO proporre l'eliminazione, non a eseguirla: allo scadere del timer la replica responsabile invia
+ un'operazione Expire tramite il multicast, che viene consegnata come ogni altra scrittura nell'ordine garantito
+ dalla consistenza configurata. Con la consistenza sequenziale l'Expire elimina la chiave solo se nel frattempo non
+ è stata sovrascritta; con quella causale elimina solo le versioni viste dalla Put con TTL. In entrambi i casi
+ tutte le repliche prendono la stessa decisione, qualunque sia il punto in cui consegnano l'Expire.
+*/
+
+// expiryOwner indica se la replica index è responsabile dell'invio dell'Expire per una Put ricevuta dalla replica
+// origin: di norma è origin stessa, ma se ha lasciato il cluster subentra la prima replica ancora attiva
+func expiryOwner(index int, origin int) bool {
+	if !utils.HasPeerLeft(origin) {
+		return index == origin
+	}
+	for i := 0; i < utils.NumberOfReplicas; i++ {
+		if !utils.HasPeerLeft(i) {
+			return index == i
+		}
+	}
+	return false
+}
+
+// scheduleExpiry allo scadere di ttl invia l'Expire della chiave key tramite send, se la replica index ne è
+// responsabile e se pending indica che la scrittura con TTL è ancora quella corrente. Le repliche non responsabili
+// continuano a controllare periodicamente, per subentrare se origin lascia il cluster prima di averlo inviato.
+func scheduleExpiry(key string, ttl time.Duration, index int, origin int, pending func() bool, send func() error) {
+	time.AfterFunc(ttl, func() {
+		for pending() {
+			if expiryOwner(index, origin) {
+				err := send()
+				if err == nil {
+					fmt.Printf("\033[35mTTL of key %s expired, Expire sent\033[0m\n", key)
+					return
+				}
+				if errors.Is(err, utils.ErrShuttingDown) {
+					return //la replica sta lasciando il cluster: l'Expire verrà inviato da un'altra
+				}
+				fmt.Printf("\033[31mError sending Expire of key %s: %v\033[0m\n", key, err)
+			}
+			time.Sleep(utils.Conf.Timeouts.EndCheckInterval)
+		}
+	})
+}
@@ -0,0 +1,935 @@
+package main
+
+import (
+	"SDCC/main/utils"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// Gateway espone le operazioni dello storage tramite un'API REST con corpo JSON, per i client che non possono
+// usare net/rpc. Ogni richiesta HTTP viene tradotta in una chiamata all'interfaccia KVS dello storage locale.
+//
+// Per rispettare l'ordinamento FIFO delle richieste di un client, il chiamante può indicare la propria identità
+// con gli header X-Client-Id e X-Request-Number. In loro assenza il gateway agisce come un unico client, che
+// numera le richieste nell'ordine di arrivo. Il namespace delle richieste si indica con il parametro namespace
+// (in sua assenza "default").
+type Gateway struct {
+	kvs           KVS
+	index         int        //indice della replica che ospita il gateway
+	clientIndex   int        //identità con cui il gateway invia allo storage le richieste anonime
+	requestNumber int        //numero dell'ultima richiesta anonima inviata
+	requestMutex  sync.Mutex //mutex per la numerazione delle richieste anonime
+}
+
+// bodyValue è un valore nei corpi JSON: una stringa se il valore è testo UTF-8, altrimenti un oggetto
+// {"base64": "..."} con i byte codificati in base64. Nelle richieste sono accettate entrambe le forme.
+type bodyValue []byte
+
+func (v bodyValue) MarshalJSON() ([]byte, error) {
+	if utf8.Valid(v) {
+		return json.Marshal(string(v))
+	}
+	return json.Marshal(binaryValue{Base64: v})
+}
+
+func (v *bodyValue) UnmarshalJSON(data []byte) error {
+	var text string
+	if err := json.Unmarshal(data, &text); err == nil {
+		*v = bodyValue(text)
+		return nil
+	}
+	var binary struct {
+		Base64 *[]byte `json:"base64"`
+	}
+	if err := json.Unmarshal(data, &binary); err != nil || binary.Base64 == nil {
+		return errors.New(`a value must be a string or {"base64": "..."}`)
+	}
+	*v = *binary.Base64
+	return nil
+}
+
+type binaryValue struct {
+	Base64 []byte `json:"base64"`
+}
+
+type keyValueBody struct {
+	Key     string    `json:"key,omitempty"`
+	Value   bodyValue `json:"value"`
+	Version int       `json:"version,omitempty"`
+	CRDT    *crdtBody `json:"crdt,omitempty"` //solo per le chiavi con valore CRDT
+	//Solo nei namespace causali: le versioni concorrenti, se più di una, e il contesto da indicare nella PUT o
+	//nella DELETE che le risolve
+	Siblings []siblingBody `json:"siblings,omitempty"`
+	Context  []int         `json:"context,omitempty"`
+}
+
+// siblingBody è una delle versioni concorrenti di una chiave, con il clock vettoriale della scrittura
+type siblingBody struct {
+	Value bodyValue `json:"value"`
+	Clock []int     `json:"clock"`
+}
+
+func toSiblingBodies(siblings []utils.Sibling) []siblingBody {
+	if len(siblings) < 2 {
+		return nil
+	}
+	bodies := make([]siblingBody, len(siblings))
+	for i, sibling := range siblings {
+		bodies[i] = siblingBody{Value: sibling.Value, Clock: sibling.ClockVector}
+	}
+	return bodies
+}
+
+// crdtBody è il valore di una chiave CRDT: counter per i contatori, values per ORSet e registri
+type crdtBody struct {
+	Type    string      `json:"type"`
+	Counter *int64      `json:"counter,omitempty"`
+	Values  []bodyValue `json:"values,omitempty"`
+}
+
+func toCRDTBody(v *utils.CRDTValue) *crdtBody {
+	if v == nil {
+		return nil
+	}
+	body := &crdtBody{Type: v.Type}
+	if v.Type == utils.GCounter || v.Type == utils.PNCounter {
+		body.Counter = &v.Counter
+		return body
+	}
+	body.Values = make([]bodyValue, len(v.Values))
+	for i, value := range v.Values {
+		body.Values[i] = value
+	}
+	return body
+}
+
+// scanBody è il corpo della risposta di una Scan: cursor va passato alla richiesta della pagina successiva
+type scanBody struct {
+	Items  []keyValueBody `json:"items"`
+	Cursor string         `json:"cursor,omitempty"`
+}
+
+// watchEventBody è una riga dello stream di un Watch
+type watchEventBody struct {
+	Revision    int       `json:"revision"`
+	Namespace   string    `json:"namespace"`
+	Op          string    `json:"op"` //"put" o "delete"
+	Key         string    `json:"key"`
+	Value       bodyValue `json:"value,omitempty"`
+	Version     int       `json:"version,omitempty"`
+	Clock       *int      `json:"clock,omitempty"`      //clock scalare, solo con la consistenza sequenziale
+	ClockTime   string    `json:"clock_time,omitempty"` //clock scalare come tempo fisico e contatore logico, con il clock ibrido
+	ClockVector []int     `json:"clock_vector,omitempty"`
+	Message     string    `json:"message"`            //UUID del messaggio che ha applicato la modifica
+	Position    int       `json:"position,omitempty"` //posizione della modifica tra quelle del messaggio
+}
+
+// putBody è il corpo di una PUT: ttl è opzionale ed è una durata nel formato di Go (es. "30s", "5m"); context è il
+// contesto restituito dalla GET, per risolvere i sibling letti
+type putBody struct {
+	Value   bodyValue `json:"value"`
+	TTL     string    `json:"ttl,omitempty"`
+	Context []int     `json:"context,omitempty"`
+}
+
+// incrementBody è il corpo di una Increment: delta è opzionale (default 1) e può essere negativo
+type incrementBody struct {
+	Delta *int64 `json:"delta,omitempty"`
+}
+
+// appendBody è il corpo di una Append: value viene accodato al valore corrente
+type appendBody struct {
+	Value bodyValue `json:"value"`
+}
+
+// crdtUpdateBody è il corpo dell'aggiornamento di una chiave CRDT: delta per i contatori, value per i registri e
+// come elemento dell'ORSet, che con remove viene rimosso
+type crdtUpdateBody struct {
+	Type   string    `json:"type"`
+	Delta  int64     `json:"delta,omitempty"`
+	Value  bodyValue `json:"value,omitempty"`
+	Remove bool      `json:"remove,omitempty"`
+}
+
+// casBody è il corpo di una CompareAndSwap: va indicato expected_version oppure expected_value
+type casBody struct {
+	Value           bodyValue  `json:"value"`
+	ExpectedVersion *int       `json:"expected_version,omitempty"`
+	ExpectedValue   *bodyValue `json:"expected_value,omitempty"`
+}
+
+// txnBody è il corpo di una transazione: op è get, put, delete oppure guard
+type txnBody struct {
+	Ops []struct {
+		Op              string     `json:"op"`
+		Key             string     `json:"key"`
+		Value           bodyValue  `json:"value,omitempty"`
+		ExpectedVersion int        `json:"expected_version,omitempty"`
+		ExpectedValue   *bodyValue `json:"expected_value,omitempty"`
+	} `json:"ops"`
+}
+
+type txnResultBody struct {
+	Succeeded bool            `json:"succeeded"`
+	Results   []txnReadResult `json:"results"`
+}
+
+type txnReadResult struct {
+	Key     string    `json:"key"`
+	Value   bodyValue `json:"value,omitempty"`
+	Found   bool      `json:"found"`
+	Version int       `json:"version,omitempty"`
+}
+
+// batchBody è il corpo di un Batch: op è get, put, delete, increment oppure append
+type batchBody struct {
+	Ops []struct {
+		Op      string    `json:"op"`
+		Key     string    `json:"key"`
+		Value   bodyValue `json:"value,omitempty"`
+		Delta   *int64    `json:"delta,omitempty"`   //solo increment, default 1
+		Context []int     `json:"context,omitempty"` //solo put e delete
+	} `json:"ops"`
+}
+
+type batchResultBody struct {
+	Results []batchItemResult `json:"results"`
+}
+
+type batchItemResult struct {
+	Key     string    `json:"key"`
+	Value   bodyValue `json:"value,omitempty"`
+	Found   bool      `json:"found,omitempty"`
+	Version int       `json:"version,omitempty"`
+	Error   string    `json:"error,omitempty"`
+}
+
+var txnOpTypes = map[string]string{"get": utils.Get, "put": utils.Put, "delete": utils.Delete, "guard": utils.Guard}
+
+var batchOpTypes = map[string]string{"get": utils.Get, "put": utils.Put, "delete": utils.Delete,
+	"increment": utils.Increment, "append": utils.Append}
+
+type casResultBody struct {
+	Key       string    `json:"key"`
+	Value     bodyValue `json:"value,omitempty"`
+	Version   int       `json:"version"`
+	Succeeded bool      `json:"succeeded"`
+}
+
+// namespaceBody descrive un namespace: nel corpo di PUT /namespaces/{name} sono usati solo consistency
+// (opzionale, di default quella del cluster), max_keys e max_bytes (0 = nessun limite)
+type namespaceBody struct {
+	Name        string `json:"name,omitempty"`
+	Consistency string `json:"consistency,omitempty"`
+	MaxKeys     int    `json:"max_keys,omitempty"`
+	MaxBytes    int    `json:"max_bytes,omitempty"`
+	Keys        int    `json:"keys"`
+	Bytes       int    `json:"bytes"`
+	Revision    int    `json:"revision,omitempty"`
+}
+
+type namespaceListBody struct {
+	Namespaces []namespaceBody `json:"namespaces"`
+}
+
+func toNamespaceBody(n utils.Namespace) namespaceBody {
+	return namespaceBody{Name: n.Name, Consistency: n.Consistency, MaxKeys: n.MaxKeys, MaxBytes: n.MaxBytes, Keys: n.Keys, Bytes: n.Bytes,
+		Revision: n.Revision}
+}
+
+type errorBody struct {
+	Error string `json:"error"`
+}
+
+// NewGateway crea il gateway HTTP per lo storage della replica index
+func NewGateway(kvs KVS, index int) *Gateway {
+	return &Gateway{
+		kvs:   kvs,
+		index: index,
+		//I client "veri" hanno indici non negativi: un indice negativo distinto per replica non può collidere
+		clientIndex: -(index + 1),
+	}
+}
+
+// Handler ritorna l'handler HTTP con le rotte GET /keys, GET/PUT/DELETE /keys/{key}, POST /keys/{key}/increment,
+// POST /keys/{key}/append, POST /keys/{key}/crdt, POST /keys/{key}/cas, POST /txn, POST /batch, GET /watch, GET /namespaces e PUT/DELETE /namespaces/{name}
+func (g *Gateway) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /namespaces", g.handleListNamespaces)
+	mux.HandleFunc("PUT /namespaces/{name}", g.handleCreateNamespace)
+	mux.HandleFunc("DELETE /namespaces/{name}", g.handleDropNamespace)
+	mux.HandleFunc("GET /watch", g.handleWatch)
+	mux.HandleFunc("GET /keys", g.handleScan)
+	mux.HandleFunc("GET /keys/{key}", g.handleGet)
+	mux.HandleFunc("PUT /keys/{key}", g.handlePut)
+	mux.HandleFunc("DELETE /keys/{key}", g.handleDelete)
+	mux.HandleFunc("POST /keys/{key}/increment", g.handleIncrement)
+	mux.HandleFunc("POST /keys/{key}/append", g.handleAppend)
+	mux.HandleFunc("POST /keys/{key}/crdt", g.handleUpdateCRDT)
+	mux.HandleFunc("POST /keys/{key}/cas", g.handleCompareAndSwap)
+	mux.HandleFunc("POST /txn", g.handleTxn)
+	mux.HandleFunc("POST /batch", g.handleBatch)
+	return mux
+}
+
+func (g *Gateway) handleGet(w http.ResponseWriter, r *http.Request) {
+	args, ok := g.parseArgs(w, r)
+	if !ok {
+		return
+	}
+	resp, err := g.call(g.kvs.Get, args)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	g.writeClock(w, resp)
+	if !resp.Found {
+		writeJSON(w, http.StatusNotFound, errorBody{Error: fmt.Sprintf("key %q not found", args.Key)})
+		return
+	}
+	writeJSON(w, http.StatusOK, keyValueBody{Key: resp.Key, Value: resp.Value, Version: resp.Version, CRDT: toCRDTBody(resp.CRDT),
+		Siblings: toSiblingBodies(resp.Siblings), Context: resp.Context})
+}
+
+// handleScan esegue una Scan sull'intervallo [start, end) oppure, con il parametro prefix, una ScanPrefix.
+// limit e cursor sono opzionali.
+func (g *Gateway) handleScan(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	prefix, isPrefix := query["prefix"]
+	if isPrefix && (query.Has("start") || query.Has("end")) {
+		writeJSON(w, http.StatusBadRequest, errorBody{Error: "prefix cannot be combined with start or end"})
+		return
+	}
+	limit := 0
+	if value := query.Get("limit"); value != "" {
+		var err error
+		if limit, err = strconv.Atoi(value); err != nil {
+			writeJSON(w, http.StatusBadRequest, errorBody{Error: fmt.Sprintf("invalid limit %q", value)})
+			return
+		}
+	}
+
+	op := g.kvs.Scan
+	args, ok := g.parseClient(w, r, query.Get("start"))
+	if !ok {
+		return
+	}
+	if isPrefix {
+		op = g.kvs.ScanPrefix
+		args.Key = prefix[0]
+	}
+	args.RangeEnd = query.Get("end")
+	args.Limit = limit
+	args.Cursor = query.Get("cursor")
+
+	resp, err := g.call(op, args)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	g.writeClock(w, resp)
+	result := scanBody{Items: make([]keyValueBody, len(resp.ScanResults)), Cursor: resp.Cursor}
+	for i, item := range resp.ScanResults {
+		result.Items[i] = keyValueBody{Key: item.Key, Value: item.Value, Version: item.Version}
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+// handleWatch apre un Watch sulla chiave key o sul prefisso prefix e invia ogni modifica come una riga JSON
+// (application/x-ndjson) finché il client non chiude la connessione. Il punto di ripresa si indica con uno tra
+// after_revision, after_clock (con after_message e after_position) e after_vector (componenti separate da virgole).
+func (g *Gateway) handleWatch(w http.ResponseWriter, r *http.Request) {
+	watchable, ok := g.kvs.(Watchable)
+	flusher, canFlush := w.(http.Flusher)
+	if !ok || !canFlush {
+		writeError(w, fmt.Errorf("%w: watch is not available", utils.ErrNotSupported))
+		return
+	}
+	token, ok := authenticateHTTP(w, r)
+	if !ok {
+		return
+	}
+
+	query := r.URL.Query()
+	args := utils.WatchArgs{Namespace: query.Get("namespace"), Key: query.Get("key"), Token: token}
+	if query.Has("prefix") {
+		if query.Has("key") {
+			writeJSON(w, http.StatusBadRequest, errorBody{Error: "key and prefix cannot be combined"})
+			return
+		}
+		args.Key = query.Get("prefix")
+		args.Prefix = true
+	} else if args.Key == "" {
+		writeJSON(w, http.StatusBadRequest, errorBody{Error: "key or prefix is required"})
+		return
+	}
+	invalid := false
+	parseInt := func(value string) int {
+		n, err := strconv.Atoi(value)
+		invalid = invalid || err != nil
+		return n
+	}
+	if value := query.Get("after_revision"); value != "" {
+		args.AfterRevision = parseInt(value)
+	}
+	if value := query.Get("after_clock"); value != "" {
+		args.AfterClock = parseInt(value)
+	}
+	args.AfterMessage = query.Get("after_message")
+	if value := query.Get("after_position"); value != "" {
+		args.AfterPosition = parseInt(value)
+	}
+	if value := query.Get("after_vector"); value != "" {
+		for _, component := range strings.Split(value, ",") {
+			args.AfterVector = append(args.AfterVector, parseInt(component))
+		}
+	}
+	if invalid {
+		writeJSON(w, http.StatusBadRequest, errorBody{Error: "after_revision, after_clock, after_position and after_vector must be integers"})
+		return
+	}
+
+	watch, err := watchable.Subscribe(args)
+	if err != nil {
+		if errors.Is(err, utils.ErrCompacted) {
+			writeJSON(w, http.StatusGone, errorBody{Error: err.Error()})
+			return
+		}
+		writeError(w, err)
+		return
+	}
+	defer watchable.Unsubscribe(watch)
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.Header().Set("X-Server-Id", utils.Peers.ID(g.index))
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+	encoder := json.NewEncoder(w)
+	for {
+		select {
+		case event, open := <-watch.events:
+			if !open {
+				if watch.err != nil {
+					_ = encoder.Encode(errorBody{Error: watch.err.Error()}) //ultima riga dello stream
+				}
+				return
+			}
+			body := watchEventBody{Revision: event.Revision, Namespace: event.Namespace, Op: strings.ToLower(event.OpType),
+				Key: event.Key, Value: event.Value, Version: event.Version, ClockVector: event.ClockVector, Message: event.Message,
+				Position: event.Position}
+			if event.ClockVector == nil {
+				body.Clock = &event.ClockValue
+				if utils.Conf.Clock == utils.ClockHybrid && event.ClockValue != 0 {
+					body.ClockTime = utils.FormatClock(event.ClockValue)
+				}
+			}
+			if err := encoder.Encode(body); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+func (g *Gateway) handlePut(w http.ResponseWriter, r *http.Request) {
+	var body putBody
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeJSON(w, http.StatusBadRequest, errorBody{Error: "invalid JSON body: " + err.Error()})
+		return
+	}
+	var ttl time.Duration
+	if body.TTL != "" {
+		var err error
+		if ttl, err = time.ParseDuration(body.TTL); err != nil || ttl <= 0 {
+			writeJSON(w, http.StatusBadRequest, errorBody{Error: fmt.Sprintf("invalid ttl %q: must be a positive duration", body.TTL)})
+			return
+		}
+	}
+	args, ok := g.parseArgs(w, r)
+	if !ok {
+		return
+	}
+	args.Value = body.Value
+	args.TTL = ttl
+	args.Context = body.Context
+	resp, err := g.call(g.kvs.Put, args)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	g.writeClock(w, resp)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleDelete elimina la chiave. Il parametro context (componenti separate da virgole) è il contesto restituito
+// dalla GET, per risolvere i sibling letti.
+func (g *Gateway) handleDelete(w http.ResponseWriter, r *http.Request) {
+	args, ok := g.parseArgs(w, r)
+	if !ok {
+		return
+	}
+	if value := r.URL.Query().Get("context"); value != "" {
+		for _, component := range strings.Split(value, ",") {
+			c, err := strconv.Atoi(component)
+			if err != nil {
+				writeJSON(w, http.StatusBadRequest, errorBody{Error: "context components must be integers"})
+				return
+			}
+			args.Context = append(args.Context, c)
+		}
+	}
+	resp, err := g.call(g.kvs.Delete, args)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	g.writeClock(w, resp)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleIncrement somma delta al contatore della chiave e risponde 200 con il nuovo valore, oppure 409 se il valore
+// corrente non è un intero
+func (g *Gateway) handleIncrement(w http.ResponseWriter, r *http.Request) {
+	var body incrementBody
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil && !errors.Is(err, io.EOF) { //il corpo è opzionale
+		writeJSON(w, http.StatusBadRequest, errorBody{Error: "invalid JSON body: " + err.Error()})
+		return
+	}
+	args, ok := g.parseArgs(w, r)
+	if !ok {
+		return
+	}
+	args.Delta = 1
+	if body.Delta != nil {
+		args.Delta = *body.Delta
+	}
+	g.writeModified(w, g.kvs.Increment, args)
+}
+
+// handleAppend accoda value al valore della chiave e risponde 200 con il valore risultante
+func (g *Gateway) handleAppend(w http.ResponseWriter, r *http.Request) {
+	var body appendBody
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeJSON(w, http.StatusBadRequest, errorBody{Error: "invalid JSON body: " + err.Error()})
+		return
+	}
+	args, ok := g.parseArgs(w, r)
+	if !ok {
+		return
+	}
+	args.Value = body.Value
+	g.writeModified(w, g.kvs.Append, args)
+}
+
+// handleUpdateCRDT aggiorna la chiave CRDT e risponde 200 con il valore risultante, oppure 409 se la chiave
+// contiene un valore di un altro tipo
+func (g *Gateway) handleUpdateCRDT(w http.ResponseWriter, r *http.Request) {
+	var body crdtUpdateBody
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeJSON(w, http.StatusBadRequest, errorBody{Error: "invalid JSON body: " + err.Error()})
+		return
+	}
+	args, ok := g.parseArgs(w, r)
+	if !ok {
+		return
+	}
+	args.CRDT = body.Type
+	args.Delta = body.Delta
+	args.Value = body.Value
+	args.CRDTRemove = body.Remove
+	g.writeModified(w, g.kvs.UpdateCRDT, args)
+}
+
+// writeModified esegue una Increment, una Append o un aggiornamento CRDT e risponde con il valore scritto
+func (g *Gateway) writeModified(w http.ResponseWriter, op func(utils.Args, *utils.Response) error, args utils.Args) {
+	resp, err := g.call(op, args)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	g.writeClock(w, resp)
+	writeJSON(w, http.StatusOK, keyValueBody{Key: resp.Key, Value: resp.Value, Version: resp.Version, CRDT: toCRDTBody(resp.CRDT)})
+}
+
+// handleCompareAndSwap risponde 200 se la scrittura è avvenuta, 409 con il valore e la versione correnti altrimenti
+func (g *Gateway) handleCompareAndSwap(w http.ResponseWriter, r *http.Request) {
+	var body casBody
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeJSON(w, http.StatusBadRequest, errorBody{Error: "invalid JSON body: " + err.Error()})
+		return
+	}
+	if (body.ExpectedVersion == nil) == (body.ExpectedValue == nil) {
+		writeJSON(w, http.StatusBadRequest, errorBody{Error: "exactly one of expected_version and expected_value must be set"})
+		return
+	}
+	args, ok := g.parseArgs(w, r)
+	if !ok {
+		return
+	}
+	args.Value = body.Value
+	if body.ExpectedValue != nil {
+		args.CompareValue = true
+		args.ExpectedValue = *body.ExpectedValue
+	} else {
+		args.ExpectedVersion = *body.ExpectedVersion
+	}
+
+	resp, err := g.call(g.kvs.CompareAndSwap, args)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	g.writeClock(w, resp)
+	result := casResultBody{Key: resp.Key, Value: resp.Value, Version: resp.Version, Succeeded: resp.Succeeded}
+	status := http.StatusOK
+	if !resp.Succeeded {
+		status = http.StatusConflict
+	}
+	writeJSON(w, status, result)
+}
+
+// handleTxn risponde 200 se la transazione è stata applicata, 409 se una guard non è soddisfatta. In entrambi
+// i casi il corpo riporta i risultati delle get.
+func (g *Gateway) handleTxn(w http.ResponseWriter, r *http.Request) {
+	var body txnBody
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeJSON(w, http.StatusBadRequest, errorBody{Error: "invalid JSON body: " + err.Error()})
+		return
+	}
+	ops := make([]utils.TxnOp, len(body.Ops))
+	for i, op := range body.Ops {
+		opType, ok := txnOpTypes[strings.ToLower(op.Op)]
+		if !ok {
+			writeJSON(w, http.StatusBadRequest, errorBody{Error: fmt.Sprintf("ops[%d]: op must be get, put, delete or guard", i)})
+			return
+		}
+		ops[i] = utils.TxnOp{OpType: opType, Key: op.Key, Value: op.Value, ExpectedVersion: op.ExpectedVersion}
+		if op.ExpectedValue != nil {
+			ops[i].CompareValue = true
+			ops[i].ExpectedValue = *op.ExpectedValue
+		}
+	}
+	if err := utils.ValidateTxn(ops); err != nil {
+		writeJSON(w, http.StatusBadRequest, errorBody{Error: err.Error()})
+		return
+	}
+
+	args, ok := g.parseClient(w, r, "")
+	if !ok {
+		return
+	}
+	args.Txn = ops
+	resp, err := g.call(g.kvs.Txn, args)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	g.writeClock(w, resp)
+	result := txnResultBody{Succeeded: resp.Succeeded, Results: make([]txnReadResult, len(resp.TxnResults))}
+	for i, read := range resp.TxnResults {
+		result.Results[i] = txnReadResult{Key: read.Key, Value: read.Value, Found: read.Found, Version: read.Version}
+	}
+	status := http.StatusOK
+	if !resp.Succeeded {
+		status = http.StatusConflict
+	}
+	writeJSON(w, status, result)
+}
+
+// handleBatch esegue il Batch e risponde 200 con il risultato di ogni elemento
+func (g *Gateway) handleBatch(w http.ResponseWriter, r *http.Request) {
+	var body batchBody
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeJSON(w, http.StatusBadRequest, errorBody{Error: "invalid JSON body: " + err.Error()})
+		return
+	}
+	items := make([]utils.BatchItem, len(body.Ops))
+	for i, op := range body.Ops {
+		items[i] = utils.BatchItem{OpType: batchOpTypes[strings.ToLower(op.Op)], Args: utils.Args{Key: op.Key, Value: op.Value, Delta: 1, Context: op.Context}}
+		if op.Delta != nil {
+			items[i].Args.Delta = *op.Delta
+		}
+	}
+	if err := utils.ValidateBatch(items); err != nil {
+		writeJSON(w, http.StatusBadRequest, errorBody{Error: err.Error()})
+		return
+	}
+
+	args, ok := g.parseClient(w, r, "")
+	if !ok {
+		return
+	}
+	args.Batch = items
+	resp, err := g.call(g.kvs.Batch, args)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	g.writeClock(w, resp)
+	result := batchResultBody{Results: make([]batchItemResult, len(resp.BatchResults))}
+	for i, item := range resp.BatchResults {
+		result.Results[i] = batchItemResult{Key: item.Key, Value: item.Value, Found: item.Found, Version: item.Version, Error: item.Error}
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+// handleListNamespaces risponde con i namespace esistenti sulla replica e il loro utilizzo
+func (g *Gateway) handleListNamespaces(w http.ResponseWriter, r *http.Request) {
+	args, ok := g.parseClient(w, r, "")
+	if !ok {
+		return
+	}
+	resp, err := g.call(g.kvs.ListNamespaces, args)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	w.Header().Set("X-Server-Id", utils.Peers.ID(g.index))
+	result := namespaceListBody{Namespaces: make([]namespaceBody, len(resp.Namespaces))}
+	for i, n := range resp.Namespaces {
+		result.Namespaces[i] = toNamespaceBody(n)
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+// handleCreateNamespace crea il namespace name e risponde 201, oppure 409 se esiste già
+func (g *Gateway) handleCreateNamespace(w http.ResponseWriter, r *http.Request) {
+	var body namespaceBody
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil && !errors.Is(err, io.EOF) { //il corpo è opzionale
+		writeJSON(w, http.StatusBadRequest, errorBody{Error: "invalid JSON body: " + err.Error()})
+		return
+	}
+	args, ok := g.parseClient(w, r, "")
+	if !ok {
+		return
+	}
+	args.NamespaceSpec = utils.Namespace{Name: r.PathValue("name"), Consistency: body.Consistency,
+		MaxKeys: body.MaxKeys, MaxBytes: body.MaxBytes}
+	resp, err := g.call(g.kvs.CreateNamespace, args)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	g.writeClock(w, resp)
+	writeJSON(w, http.StatusCreated, toNamespaceBody(resp.Namespaces[0]))
+}
+
+// handleDropNamespace elimina il namespace name con tutte le sue chiavi e risponde 204
+func (g *Gateway) handleDropNamespace(w http.ResponseWriter, r *http.Request) {
+	args, ok := g.parseClient(w, r, "")
+	if !ok {
+		return
+	}
+	args.NamespaceSpec = utils.Namespace{Name: r.PathValue("name")}
+	resp, err := g.call(g.kvs.DropNamespace, args)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	g.writeClock(w, resp)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// parseArgs autentica la richiesta tramite l'header Authorization e ne costruisce gli Args
+func (g *Gateway) parseArgs(w http.ResponseWriter, r *http.Request) (utils.Args, bool) {
+	key := r.PathValue("key")
+	if key == "" {
+		writeJSON(w, http.StatusBadRequest, errorBody{Error: "key must not be empty"})
+		return utils.Args{}, false
+	}
+	return g.parseClient(w, r, key)
+}
+
+// parseClient autentica la richiesta e costruisce gli Args per la chiave indicata nel namespace del parametro
+// namespace
+func (g *Gateway) parseClient(w http.ResponseWriter, r *http.Request, key string) (utils.Args, bool) {
+	token, ok := authenticateHTTP(w, r)
+	if !ok {
+		return utils.Args{}, false
+	}
+	//Gli header di consistenza vanno letti prima dell'identità: una richiesta rifiutata dal gateway non deve
+	//consumare un numero di richiesta, altrimenti le successive resterebbero in attesa del turno FIFO mancante
+	var session []int
+	if value := r.Header.Get("X-Session-Clock"); value != "" {
+		for _, component := range strings.Split(value, ",") {
+			c, err := strconv.Atoi(component)
+			if err != nil {
+				writeJSON(w, http.StatusBadRequest, errorBody{Error: "X-Session-Clock components must be integers"})
+				return utils.Args{}, false
+			}
+			session = append(session, c)
+		}
+	}
+	var deps []utils.Dependency
+	if value := r.Header.Get("X-Dependencies"); value != "" {
+		var err error
+		if deps, err = parseDependencies(value); err != nil {
+			writeJSON(w, http.StatusBadRequest, errorBody{Error: err.Error()})
+			return utils.Args{}, false
+		}
+	}
+	args, ok := g.parseIdentity(w, r, key)
+	args.Token = token
+	args.Namespace = r.URL.Query().Get("namespace")
+	args.SessionClock = session
+	args.Dependencies = deps
+	return args, ok
+}
+
+// parseDependencies legge le dipendenze dell'header X-Dependencies, codificate come i parametri di una query:
+// namespace/chiave=clock.origine, separate da '&'
+func parseDependencies(value string) ([]utils.Dependency, error) {
+	query, err := url.ParseQuery(value)
+	if err != nil {
+		return nil, errors.New("X-Dependencies must be encoded as namespace/key=clock.origin pairs separated by '&'")
+	}
+	deps := make([]utils.Dependency, 0, len(query))
+	for name, stamps := range query {
+		namespace, key, found := strings.Cut(name, "/")
+		stamp := stamps[len(stamps)-1]
+		clock, origin, isStamp := strings.Cut(stamp, ".")
+		c, err1 := strconv.Atoi(clock)
+		o, err2 := strconv.Atoi(origin)
+		if !found || key == "" || !isStamp || err1 != nil || err2 != nil {
+			return nil, fmt.Errorf("X-Dependencies: invalid dependency %s=%s, expected namespace/key=clock.origin", name, stamp)
+		}
+		deps = append(deps, utils.Dependency{Namespace: namespace, Key: key, Stamp: utils.Stamp{Clock: c, Origin: o}})
+	}
+	return deps, nil
+}
+
+// formatDependencies codifica le dipendenze per l'header X-Dependencies
+func formatDependencies(deps []utils.Dependency) string {
+	query := make(url.Values, len(deps))
+	for _, d := range deps {
+		query.Set(d.Namespace+"/"+d.Key, d.Stamp.String())
+	}
+	return query.Encode()
+}
+
+// parseIdentity costruisce gli Args a partire dagli header X-Client-Id e X-Request-Number
+func (g *Gateway) parseIdentity(w http.ResponseWriter, r *http.Request, key string) (utils.Args, bool) {
+	clientID := r.Header.Get("X-Client-Id")
+	requestNumber := r.Header.Get("X-Request-Number")
+	if clientID == "" && requestNumber == "" {
+		return *utils.NewArg(key, nil, g.nextRequestNumber(), g.clientIndex), true
+	}
+
+	clientIndex, err1 := strconv.Atoi(clientID)
+	number, err2 := strconv.Atoi(requestNumber)
+	if err1 != nil || err2 != nil || clientIndex < 0 || number < 1 {
+		writeJSON(w, http.StatusBadRequest, errorBody{Error: "X-Client-Id and X-Request-Number must be set together " +
+			"to a non-negative client id and a positive request number"})
+		return utils.Args{}, false
+	}
+	return *utils.NewArg(key, nil, number, clientIndex), true
+}
+
+func (g *Gateway) nextRequestNumber() int {
+	g.requestMutex.Lock()
+	defer g.requestMutex.Unlock()
+	g.requestNumber++
+	return g.requestNumber
+}
+
+// call esegue l'operazione sullo storage, interrompendo l'attesa della risposta allo scadere del timeout
+// configurato (per esempio una Get causale su una chiave che non è mai stata scritta)
+func (g *Gateway) call(op func(utils.Args, *utils.Response) error, args utils.Args) (*utils.Response, error) {
+	type result struct {
+		resp *utils.Response
+		err  error
+	}
+	done := make(chan result, 1)
+	go func() {
+		resp := utils.NewResponse()
+		err := op(args, resp)
+		done <- result{resp: resp, err: err}
+	}()
+
+	select {
+	case res := <-done:
+		return res.resp, res.err
+	case <-time.After(utils.Conf.Timeouts.HTTPRequest):
+		return nil, errTimeout
+	}
+}
+
+var errTimeout = errors.New("timed out waiting for the store")
+
+// writeClock riporta negli header i metadati di consistenza del messaggio che ha servito la richiesta
+func (g *Gateway) writeClock(w http.ResponseWriter, resp *utils.Response) {
+	w.Header().Set("X-Server-Id", utils.Peers.ID(g.index))
+	if resp.ClockVector != nil {
+		w.Header().Set("X-Causal-Clock", joinClock(resp.ClockVector))
+	} else {
+		w.Header().Set("X-Logical-Clock", strconv.Itoa(resp.ClockValue))
+		if utils.Conf.Clock == utils.ClockHybrid && resp.ClockValue != 0 {
+			w.Header().Set("X-Hybrid-Clock", utils.FormatClock(resp.ClockValue))
+		}
+	}
+	if resp.SessionClock != nil {
+		w.Header().Set("X-Session-Clock", joinClock(resp.SessionClock))
+	}
+	if resp.Dependencies != nil {
+		w.Header().Set("X-Dependencies", formatDependencies(resp.Dependencies))
+	}
+	if resp.Stamp != (utils.Stamp{}) {
+		w.Header().Set("X-Version", resp.Stamp.String())
+	}
+}
+
+// joinClock ritorna le componenti di un clock vettoriale separate da virgole
+func joinClock(clock []int) string {
+	components := make([]string, len(clock))
+	for i, c := range clock {
+		components[i] = strconv.Itoa(c)
+	}
+	return strings.Join(components, ",")
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, utils.ErrShuttingDown), errors.Is(err, utils.ErrSessionBehind), errors.Is(err, utils.ErrQuorumUnavailable),
+		errors.Is(err, utils.ErrNotPrimary), errors.Is(err, utils.ErrChainUnavailable), errors.Is(err, utils.ErrCatalogUnavailable):
+		status = http.StatusServiceUnavailable
+	case errors.Is(err, errTimeout):
+		status = http.StatusGatewayTimeout
+	case errors.Is(err, utils.ErrUnauthenticated):
+		status = http.StatusUnauthorized
+	case errors.Is(err, utils.ErrAccessDenied):
+		status = http.StatusForbidden
+	case errors.Is(err, utils.ErrNotSupported):
+		status = http.StatusNotImplemented
+	case errors.Is(err, utils.ErrInvalidRequest):
+		status = http.StatusBadRequest
+	case errors.Is(err, utils.ErrTooLarge):
+		status = http.StatusRequestEntityTooLarge
+	case errors.Is(err, utils.ErrNamespaceNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, utils.ErrNamespaceExists), errors.Is(err, utils.ErrNotCounter), errors.Is(err, utils.ErrWrongType):
+		status = http.StatusConflict
+	case errors.Is(err, utils.ErrQuotaExceeded):
+		status = http.StatusInsufficientStorage
+	}
+	writeJSON(w, status, errorBody{Error: err.Error()})
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		fmt.Println("Error writing HTTP response:", err)
+	}
+}
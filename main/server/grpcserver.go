@@ -0,0 +1,265 @@
+package main
+
+import (
+	"SDCC/main/kvspb"
+	"SDCC/main/utils"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/status"
+)
+
+// keyValueService espone tramite gRPC l'API dei client, traducendo ogni chiamata nell'equivalente RPC dello storage
+type keyValueService struct {
+	kvspb.UnimplementedKeyValueServer
+	kvs KVS
+}
+
+func (s *keyValueService) Get(ctx context.Context, args *kvspb.Args) (*kvspb.Response, error) {
+	return callKVS(ctx, s.kvs.Get, args)
+}
+
+func (s *keyValueService) Put(ctx context.Context, args *kvspb.Args) (*kvspb.Response, error) {
+	return callKVS(ctx, s.kvs.Put, args)
+}
+
+func (s *keyValueService) Delete(ctx context.Context, args *kvspb.Args) (*kvspb.Response, error) {
+	return callKVS(ctx, s.kvs.Delete, args)
+}
+
+func (s *keyValueService) Increment(ctx context.Context, args *kvspb.Args) (*kvspb.Response, error) {
+	return callKVS(ctx, s.kvs.Increment, args)
+}
+
+func (s *keyValueService) Append(ctx context.Context, args *kvspb.Args) (*kvspb.Response, error) {
+	return callKVS(ctx, s.kvs.Append, args)
+}
+
+func (s *keyValueService) UpdateCRDT(ctx context.Context, args *kvspb.Args) (*kvspb.Response, error) {
+	return callKVS(ctx, s.kvs.UpdateCRDT, args)
+}
+
+func (s *keyValueService) CompareAndSwap(ctx context.Context, args *kvspb.Args) (*kvspb.Response, error) {
+	return callKVS(ctx, s.kvs.CompareAndSwap, args)
+}
+
+func (s *keyValueService) Txn(ctx context.Context, args *kvspb.Args) (*kvspb.Response, error) {
+	return callKVS(ctx, s.kvs.Txn, args)
+}
+
+func (s *keyValueService) Batch(ctx context.Context, args *kvspb.Args) (*kvspb.Response, error) {
+	return callKVS(ctx, s.kvs.Batch, args)
+}
+
+func (s *keyValueService) Scan(ctx context.Context, args *kvspb.Args) (*kvspb.Response, error) {
+	return callKVS(ctx, s.kvs.Scan, args)
+}
+
+func (s *keyValueService) ScanPrefix(ctx context.Context, args *kvspb.Args) (*kvspb.Response, error) {
+	return callKVS(ctx, s.kvs.ScanPrefix, args)
+}
+
+func (s *keyValueService) End(ctx context.Context, args *kvspb.Args) (*kvspb.Response, error) {
+	return callKVS(ctx, s.kvs.End, args)
+}
+
+func (s *keyValueService) CreateNamespace(ctx context.Context, args *kvspb.Args) (*kvspb.Response, error) {
+	return callKVS(ctx, s.kvs.CreateNamespace, args)
+}
+
+func (s *keyValueService) DropNamespace(ctx context.Context, args *kvspb.Args) (*kvspb.Response, error) {
+	return callKVS(ctx, s.kvs.DropNamespace, args)
+}
+
+func (s *keyValueService) ListNamespaces(ctx context.Context, args *kvspb.Args) (*kvspb.Response, error) {
+	return callKVS(ctx, s.kvs.ListNamespaces, args)
+}
+
+// Watch invia sullo stream le modifiche osservate finché il client non chiude la chiamata o il Watch non viene
+// chiuso dalla replica
+func (s *keyValueService) Watch(req *kvspb.WatchRequest, stream kvspb.KeyValue_WatchServer) error {
+	watchable, ok := s.kvs.(Watchable)
+	if !ok {
+		return grpcError(fmt.Errorf("%w: store %T does not support Watch", utils.ErrNotSupported, s.kvs))
+	}
+	args := utils.WatchArgsFromProto(req)
+	args.Token = grpcToken(stream.Context())
+	w, err := watchable.Subscribe(args)
+	if err != nil {
+		return grpcError(err)
+	}
+	defer watchable.Unsubscribe(w)
+
+	for {
+		select {
+		case event, open := <-w.events:
+			if !open {
+				return grpcError(w.err)
+			}
+			if err := stream.Send(utils.WatchEventToProto(event)); err != nil {
+				return err
+			}
+		case <-stream.Context().Done():
+			return nil
+		}
+	}
+}
+
+func callKVS(ctx context.Context, op func(utils.Args, *utils.Response) error, args *kvspb.Args) (*kvspb.Response, error) {
+	resp := utils.NewResponse()
+	a := utils.ArgsFromProto(args)
+	a.Token = grpcToken(ctx) //già verificato da unaryAuthInterceptor
+	if err := op(a, resp); err != nil {
+		return nil, grpcError(err)
+	}
+	return utils.ResponseToProto(resp), nil
+}
+
+// replicaService riceve tramite gRPC i messaggi scambiati tra le repliche e li consegna allo storage della
+// consistenza corrispondente
+type replicaService struct {
+	kvspb.UnimplementedReplicaServer
+	catalog *namespaceCatalog
+}
+
+// Multicast riceve i messaggi inviati da un'altra replica sullo stream. Ogni messaggio viene consegnato in una
+// goroutine dedicata, esattamente come avviene per le chiamate net/rpc, perché la consegna è bloccante e i
+// messaggi successivi possono essere proprio quelli che la sbloccano.
+func (s *replicaService) Multicast(stream kvspb.Replica_MulticastServer) error {
+	var sendMutex sync.Mutex
+	for {
+		msg, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		go func(msg *kvspb.ReplicaMessage) {
+			reply := &kvspb.UpdateReply{Uuid: msg.Uuid}
+			resp := utils.NewResponse()
+			if err := s.update(msg, resp); err != nil {
+				reply.Error = err.Error()
+			} else {
+				reply.Response = utils.ResponseToProto(resp)
+			}
+
+			sendMutex.Lock()
+			defer sendMutex.Unlock()
+			if err := stream.Send(reply); err != nil {
+				fmt.Println("Failed to send reply on multicast stream:", err)
+			}
+		}(msg)
+	}
+}
+
+// update consegna il messaggio allo storage del protocollo che lo ha prodotto: solo i messaggi causali hanno il
+// clock vettoriale
+func (s *replicaService) update(msg *kvspb.ReplicaMessage, resp *utils.Response) error {
+	if len(msg.GetClockVector()) > 0 {
+		m, err := utils.VMessageFromProto(msg)
+		if err != nil {
+			return err
+		}
+		return s.catalog.causal.Update(m, resp)
+	}
+	m, err := utils.MessageFromProto(msg)
+	if err != nil {
+		return err
+	}
+	return s.catalog.sequential.Update(m, resp)
+}
+
+func (s *replicaService) ReceiveAck(_ context.Context, msg *kvspb.ReplicaMessage) (*kvspb.Empty, error) {
+	m, err := utils.MessageFromProto(msg)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	return &kvspb.Empty{}, s.catalog.sequential.ReceiveAck(m, utils.NewResponse())
+}
+
+// Replicate consegna un messaggio dei protocolli di replicazione e ne ritorna l'esito. Come per Multicast, la
+// consegna può restare bloccata in attesa delle dipendenze: ogni chiamata gRPC ha già una goroutine dedicata.
+func (s *replicaService) Replicate(_ context.Context, msg *kvspb.ReplicationMessage) (*kvspb.Response, error) {
+	m, err := utils.ReplicationMessageFromProto(msg)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	resp := utils.NewResponse()
+	if err := s.catalog.replicate(m, resp); err != nil {
+		return nil, grpcError(err)
+	}
+	return utils.ResponseToProto(resp), nil
+}
+
+func (s *replicaService) PeerLeaving(_ context.Context, req *kvspb.LeaveRequest) (*kvspb.Empty, error) {
+	args := utils.LeaveArgs{ServerID: req.GetServerId()}
+	err := s.catalog.sequential.PeerLeaving(args, utils.NewResponse()) //l'uscita vale per entrambi gli storage
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	return &kvspb.Empty{}, nil
+}
+
+// grpcError converte gli errori dello storage nei corrispondenti codici di stato gRPC
+func grpcError(err error) error {
+	if err == nil {
+		return nil
+	}
+	if errors.Is(err, utils.ErrShuttingDown) || errors.Is(err, utils.ErrSessionBehind) || errors.Is(err, utils.ErrQuorumUnavailable) ||
+		errors.Is(err, utils.ErrNotPrimary) || errors.Is(err, utils.ErrChainUnavailable) || errors.Is(err, utils.ErrCatalogUnavailable) {
+		return status.Error(codes.Unavailable, err.Error())
+	}
+	if errors.Is(err, utils.ErrUnauthenticated) {
+		return status.Error(codes.Unauthenticated, err.Error())
+	}
+	if errors.Is(err, utils.ErrAccessDenied) {
+		return status.Error(codes.PermissionDenied, err.Error())
+	}
+	if errors.Is(err, utils.ErrNotSupported) {
+		return status.Error(codes.Unimplemented, err.Error())
+	}
+	if errors.Is(err, utils.ErrInvalidRequest) || errors.Is(err, utils.ErrTooLarge) {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	if errors.Is(err, utils.ErrCompacted) {
+		return status.Error(codes.OutOfRange, err.Error())
+	}
+	if errors.Is(err, utils.ErrWatchLagging) || errors.Is(err, utils.ErrQuotaExceeded) {
+		return status.Error(codes.ResourceExhausted, err.Error())
+	}
+	if errors.Is(err, utils.ErrNamespaceNotFound) {
+		return status.Error(codes.NotFound, err.Error())
+	}
+	if errors.Is(err, utils.ErrNamespaceExists) {
+		return status.Error(codes.AlreadyExists, err.Error())
+	}
+	if errors.Is(err, utils.ErrNotCounter) || errors.Is(err, utils.ErrWrongType) {
+		return status.Error(codes.FailedPrecondition, err.Error())
+	}
+	return status.Error(codes.Internal, err.Error())
+}
+
+// NewGRPCServer crea il server gRPC con il servizio KeyValue per lo storage della consistenza del cluster e il
+// servizio Replica per entrambi gli storage. Se TLS è abilitato le connessioni sono cifrate e ogni chiamata viene
+// autorizzata dagli interceptor.
+func NewGRPCServer(catalog *namespaceCatalog) *grpc.Server {
+	opts := []grpc.ServerOption{
+		grpc.UnaryInterceptor(unaryAuthInterceptor),
+		grpc.StreamInterceptor(streamAuthInterceptor),
+	}
+	if tlsConfig := utils.ServerTLS(); tlsConfig != nil {
+		opts = append(opts, grpc.Creds(credentials.NewTLS(tlsConfig)))
+	}
+	server := grpc.NewServer(opts...)
+	kvspb.RegisterKeyValueServer(server, &keyValueService{kvs: catalog.primary()})
+	kvspb.RegisterReplicaServer(server, &replicaService{catalog: catalog})
+	return server
+}
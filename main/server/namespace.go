@@ -0,0 +1,819 @@
+package main
+
+import (
+	"SDCC/main/utils"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+/*
+ Namespace
+
+ Ogni namespace ha un proprio keyspace: le chiavi, le versioni, l'utilizzo rispetto alle quote e i Watch aperti.
+ Ogni replica esegue tutti gli storage, quello sequenziale, quello causale, quello causale+, quello a quorum, quello
+ primary-backup e quello a catena: i keyspace appartengono allo storage della consistenza del namespace, e il
+ namespace "default" a quello della consistenza del cluster (sequenziale o causale). Le richieste dei client arrivano sempre allo storage
+ della consistenza del cluster, che dopo il controllo FIFO le inoltra al multicast dello storage che ospita il
+ namespace.
+
+ Creazione ed eliminazione dei namespace, di qualsiasi consistenza, sono ordinate da un'unica replica, il sequencer
+ del catalogo (la replica attiva di indice minore): le altre repliche gli inoltrano le richieste, e il sequencer
+ assegna a ognuna la revisione successiva del catalogo e la consegna a tutte le repliche attive, una alla volta e
+ ripetendo l'invio finché ognuna non l'ha applicata. Tutte le repliche applicano quindi le operazioni sui
+ namespace nello stesso ordine, e alla consegna una creazione viene rifiutata se uno qualsiasi degli storage ospita
+ già un namespace con lo stesso nome: due creazioni concorrenti dello stesso nome con consistenze diverse non
+ possono riuscire entrambe. La risposta arriva al client solo dopo che tutte le repliche attive hanno applicato
+ l'operazione.
+
+ Ogni namespace ricorda la revisione che lo ha creato, e ogni richiesta porta la revisione del namespace a cui è
+ stata inviata: alla consegna una richiesta destinata a un namespace eliminato e poi ricreato con lo stesso nome
+ viene scartata da tutte le repliche. Le quote vengono invece controllate dalla replica che riceve la richiesta,
+ prima dell'invio: scritture concorrenti ricevute da repliche diverse possono superarle di poco.
+*/
+
+// keyspace contiene le chiavi di un namespace. I campi vanno letti e modificati con il mapMutex dello storage che
+// ospita il namespace già acquisito.
+type keyspace struct {
+	info     utils.Namespace        //nome, consistenza e quote
+	store    *skipList              //chiavi del namespace, ordinate
+	versions map[string]int         //ultima versione di ogni chiave, conservata anche dopo la delete (sequenziale)
+	crdts    map[string]*crdtState  //stato delle chiavi CRDT, di cui store contiene la rappresentazione (causale)
+	siblings map[string]*siblingSet //versioni concorrenti di ogni chiave, di cui store contiene quella che prevale (causale)
+	stamps   map[string]utils.Stamp //versione dell'ultima scrittura visibile, conservata anche dopo la delete (causale+)
+	bytes    int                    //somma delle dimensioni di chiavi e valori presenti
+	watchHub                        //modifiche applicate al namespace, notificate ai Watch
+}
+
+func newKeyspace(info utils.Namespace) *keyspace {
+	info.Keys, info.Bytes = 0, 0
+	return &keyspace{
+		info:     info,
+		store:    newSkipList(),
+		versions: make(map[string]int),
+		crdts:    make(map[string]*crdtState),
+		siblings: make(map[string]*siblingSet),
+		stamps:   make(map[string]utils.Stamp),
+	}
+}
+
+// set scrive key aggiornando l'utilizzo del namespace
+func (ks *keyspace) set(key string, value []byte) {
+	if old, ok := ks.store.Get(key); ok {
+		ks.bytes -= len(key) + len(old)
+	}
+	ks.store.Set(key, value)
+	ks.bytes += len(key) + len(value)
+}
+
+// remove elimina key aggiornando l'utilizzo del namespace. Ritorna false se la chiave non esisteva.
+func (ks *keyspace) remove(key string) bool {
+	old, ok := ks.store.Get(key)
+	if !ok {
+		return false
+	}
+	ks.store.Delete(key)
+	ks.bytes -= len(key) + len(old)
+	return true
+}
+
+// usage ritorna la descrizione del namespace con l'utilizzo corrente
+func (ks *keyspace) usage() utils.Namespace {
+	info := ks.info
+	info.Keys = ks.store.Len()
+	info.Bytes = ks.bytes
+	return info
+}
+
+// checkQuota simula le scritture della richiesta e la rifiuta se porterebbe il namespace oltre una delle quote.
+// Una richiesta che non aumenta l'utilizzo è sempre accettata, anche se il namespace è già oltre la quota.
+func (ks *keyspace) checkQuota(args utils.Args, op string) error {
+	if ks.info.MaxKeys == 0 && ks.info.MaxBytes == 0 {
+		return nil
+	}
+
+	keys, size := ks.store.Len(), ks.bytes
+	written := make(map[string]*[]byte) //scritture già simulate (nil = chiave eliminata)
+	current := func(key string) ([]byte, bool) {
+		if pending, ok := written[key]; ok {
+			if pending == nil {
+				return nil, false
+			}
+			return *pending, true
+		}
+		return ks.store.Get(key)
+	}
+	apply := func(key string, value *[]byte) {
+		old, exists := current(key)
+		if exists {
+			keys--
+			size -= len(key) + len(old)
+		}
+		if value != nil {
+			keys++
+			size += len(key) + len(*value)
+		}
+		written[key] = value
+	}
+	modify := func(op string, args utils.Args) {
+		old, exists := current(args.Key)
+		if value, err := utils.ModifiedValue(op, old, exists, args); err == nil {
+			apply(args.Key, &value) //se fallisce, la scrittura non verrà applicata neanche alla consegna
+		}
+	}
+
+	switch op {
+	case utils.Put, utils.CompareAndSwap:
+		apply(args.Key, &args.Value)
+	case utils.Increment, utils.Append:
+		modify(op, args)
+	case utils.UpdateCRDT:
+		//Il valore risultante dipende anche dagli aggiornamenti concorrenti: si stima per eccesso accodando il
+		//nuovo elemento alla rappresentazione corrente
+		if !args.CRDTRemove {
+			old, _ := current(args.Key)
+			value := slices.Concat(old, args.Value)
+			apply(args.Key, &value)
+		}
+	case utils.Txn:
+		for i := range args.Txn {
+			switch args.Txn[i].OpType {
+			case utils.Put:
+				apply(args.Txn[i].Key, &args.Txn[i].Value)
+			case utils.Delete:
+				apply(args.Txn[i].Key, nil)
+			}
+		}
+	case utils.Batch:
+		for i := range args.Batch {
+			switch args.Batch[i].OpType {
+			case utils.Put:
+				apply(args.Batch[i].Args.Key, &args.Batch[i].Args.Value)
+			case utils.Delete:
+				apply(args.Batch[i].Args.Key, nil)
+			case utils.Increment, utils.Append:
+				modify(args.Batch[i].OpType, args.Batch[i].Args)
+			}
+		}
+	default:
+		return nil
+	}
+
+	if ks.info.MaxKeys > 0 && keys > ks.info.MaxKeys && keys > ks.store.Len() {
+		return fmt.Errorf("%w: namespace %q would have %d keys, max_keys is %d",
+			utils.ErrQuotaExceeded, ks.info.Name, keys, ks.info.MaxKeys)
+	}
+	if ks.info.MaxBytes > 0 && size > ks.info.MaxBytes && size > ks.bytes {
+		return fmt.Errorf("%w: namespace %q would use %d bytes, max_bytes is %d",
+			utils.ErrQuotaExceeded, ks.info.Name, size, ks.info.MaxBytes)
+	}
+	return nil
+}
+
+// namespaceHost è uno storage che ospita i keyspace dei namespace con la propria consistenza. Solo gli storage
+// della consistenza del cluster ricevono direttamente le richieste dei client (Stoppable).
+type namespaceHost interface {
+	StopAcceptingRequests()
+	Drain(timeout time.Duration) int
+	Snapshot() map[string]map[string][]byte
+	multicast(arg utils.Args, resp *utils.Response, op string) error
+	withKeyspaces(fn func(keyspaces map[string]*keyspace)) //esegue fn con il mapMutex dello storage acquisito
+}
+
+// monitoredHost è uno storage che, dalla creazione del suo primo namespace, controlla periodicamente le altre
+// repliche (primary-backup, catena)
+type monitoredHost interface {
+	startMonitor()
+}
+
+// namespaceCatalog contiene gli storage della replica e smista le richieste dei client verso quello che ospita
+// il namespace indicato. Ordina inoltre le operazioni sui namespace e implementa Watchable per tutti i namespace.
+type namespaceCatalog struct {
+	index int
+
+	sequential    *KVSSequentialV2
+	causal        *KVSCausal
+	causalPlus    *KVSCausalPlus
+	quorum        *KVSQuorum
+	primaryBackup *KVSPrimaryBackup
+	chain         *KVSChain
+
+	sequenceMutex  sync.Mutex               //acquisito dal sequencer per tutta la durata di un'operazione sui namespace
+	catalogMutex   sync.Mutex               //protegge revision e l'applicazione delle operazioni sui namespace
+	revision       int                      //revisione dell'ultima operazione sui namespace applicata
+	stoppedCatalog atomic.Bool              //true dopo stopAccepting: il sequencer non ordina più operazioni sui namespace
+	undelivered    utils.ReplicationMessage //ultima operazione ordinata dal sequencer
+	missing        []int                    //repliche attive che non hanno ancora applicato undelivered
+
+	watchMutex sync.Mutex
+	closed     bool //true dopo CloseWatchers: la replica si sta spegnendo
+}
+
+// newNamespaceCatalog crea gli storage della replica index
+func newNamespaceCatalog(index int) *namespaceCatalog {
+	c := &namespaceCatalog{index: index}
+	c.sequential = NewKVSSequentialV2(index, c)
+	c.causal = NewKVSCasual(index, c)
+	c.causalPlus = NewKVSCausalPlus(index)
+	c.quorum = NewKVSQuorum(index)
+	c.primaryBackup = NewKVSPrimaryBackup(index)
+	c.chain = NewKVSChain(index)
+	return c
+}
+
+// primary ritorna lo storage della consistenza del cluster, che riceve le richieste dei client
+func (c *namespaceCatalog) primary() Stoppable {
+	if utils.Conf.Consistency == utils.Causal {
+		return c.causal
+	}
+	return c.sequential
+}
+
+// hosts ritorna tutti gli storage, a partire da quello della consistenza del cluster
+func (c *namespaceCatalog) hosts() []namespaceHost {
+	if utils.Conf.Consistency == utils.Causal {
+		return []namespaceHost{c.causal, c.sequential, c.causalPlus, c.quorum, c.primaryBackup, c.chain}
+	}
+	return []namespaceHost{c.sequential, c.causal, c.causalPlus, c.quorum, c.primaryBackup, c.chain}
+}
+
+// host ritorna lo storage della consistenza indicata
+func (c *namespaceCatalog) host(consistency string) namespaceHost {
+	switch consistency {
+	case utils.Causal:
+		return c.causal
+	case utils.CausalPlus:
+		return c.causalPlus
+	case utils.Quorum:
+		return c.quorum
+	case utils.PrimaryBackup:
+		return c.primaryBackup
+	case utils.Chain:
+		return c.chain
+	}
+	return c.sequential
+}
+
+// replicate consegna un messaggio dei protocolli di replicazione allo storage che lo ha prodotto
+func (c *namespaceCatalog) replicate(msg utils.ReplicationMessage, resp *utils.Response) error {
+	switch msg.Protocol {
+	case utils.CausalPlus:
+		return c.causalPlus.Replicate(msg, resp)
+	case utils.Quorum:
+		return c.quorum.Replicate(msg, resp)
+	case utils.PrimaryBackup:
+		return c.primaryBackup.Replicate(msg, resp)
+	case utils.Chain:
+		return c.chain.Replicate(msg, resp)
+	case utils.Catalog:
+		if msg.Sequence == 0 {
+			return c.sequence(msg, resp) //richiesta inoltrata al sequencer
+		}
+		return c.applyNamespaceOp(msg, resp)
+	}
+	return fmt.Errorf("%w: replication protocol %q", utils.ErrNotSupported, msg.Protocol)
+}
+
+// lookup ritorna lo storage che ospita il namespace name e la sua descrizione
+func (c *namespaceCatalog) lookup(name string) (namespaceHost, utils.Namespace, bool) {
+	for _, host := range c.hosts() {
+		var info utils.Namespace
+		found := false
+		host.withKeyspaces(func(keyspaces map[string]*keyspace) {
+			if ks, ok := keyspaces[name]; ok {
+				info, found = ks.usage(), true
+			}
+		})
+		if found {
+			return host, info, true
+		}
+	}
+	return nil, utils.Namespace{}, false
+}
+
+// list ritorna i namespace di tutti gli storage, in ordine di nome
+func (c *namespaceCatalog) list() []utils.Namespace {
+	namespaces := make([]utils.Namespace, 0)
+	for _, host := range c.hosts() {
+		host.withKeyspaces(func(keyspaces map[string]*keyspace) {
+			for _, ks := range keyspaces {
+				namespaces = append(namespaces, ks.usage())
+			}
+		})
+	}
+	slices.SortFunc(namespaces, func(a, b utils.Namespace) int { return strings.Compare(a.Name, b.Name) })
+	return namespaces
+}
+
+// dispatch esegue la richiesta di un client dopo il controllo FIFO: gestisce le operazioni sui namespace,
+// controlla la richiesta, i permessi e le quote e la invia tramite il multicast dello storage che ospita il
+// namespace. Una richiesta rifiutata consuma comunque il proprio turno FIFO (le richieste successive del client
+// non restano bloccate), ma non viene inviata alle altre repliche.
+func (c *namespaceCatalog) dispatch(arg utils.Args, resp *utils.Response, op string) error {
+	switch op {
+	case utils.ListNamespaces:
+		if err := utils.AuthorizeRequest(arg, op); err != nil {
+			return err
+		}
+		resp.Namespaces = c.list()
+		return nil
+	case utils.CreateNamespace:
+		return c.createNamespace(arg, resp)
+	case utils.DropNamespace:
+		return c.dropNamespace(arg, resp)
+	}
+
+	arg.Namespace = utils.NamespaceName(arg.Namespace)
+	host, info, ok := c.lookup(arg.Namespace)
+	if !ok {
+		return fmt.Errorf("%w: %q", utils.ErrNamespaceNotFound, arg.Namespace)
+	}
+	arg.NamespaceRevision = info.Revision
+	if info.Consistency == utils.Causal && (op == utils.CompareAndSwap || op == utils.Txn) {
+		//Repliche diverse possono consegnare scritture concorrenti in ordini diversi: non esiste uno stato
+		//corrente su cui tutte concordino per valutare le condizioni
+		return fmt.Errorf("%w: %s requires Sequential consistency, namespace %q is Causal", utils.ErrNotSupported, op, arg.Namespace)
+	}
+	if info.Consistency == utils.Sequential && op == utils.UpdateCRDT {
+		//Con l'ordine totale non ci sono aggiornamenti concorrenti da riconciliare
+		return fmt.Errorf("%w: %s requires Causal consistency, namespace %q is Sequential", utils.ErrNotSupported, op, arg.Namespace)
+	}
+	if !supportsOperation(info.Consistency, arg, op) {
+		return fmt.Errorf("%w: %s is not supported by %s namespace %q", utils.ErrNotSupported, op, info.Consistency, arg.Namespace)
+	}
+	if err := validateRequest(arg, op); err != nil {
+		return err
+	}
+	if err := utils.AuthorizeRequest(arg, op); err != nil {
+		fmt.Printf("\033[31mRejected %s of key %s from client %d: %v\033[0m\n", op, arg.Key, arg.ClientIndex, err)
+		return err
+	}
+	var err error
+	host.withKeyspaces(func(keyspaces map[string]*keyspace) {
+		if ks, ok := liveKeyspace(keyspaces, arg); ok {
+			err = ks.checkQuota(arg, op)
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := host.multicast(arg, resp, op); err != nil {
+		return err
+	}
+	if (op == utils.Increment || op == utils.Append || op == utils.UpdateCRDT) && !resp.Succeeded {
+		return deliveryError(arg, resp, op)
+	}
+	return nil
+}
+
+// supportsOperation indica se l'operazione è supportata dai namespace della consistenza indicata. Quelli replicati
+// senza multicast supportano solo letture e scritture di singole chiavi (più la Scan nei causali+, nei
+// primary-backup e in quelli a catena); le Put con TTL non lo sono, perché la scadenza andrebbe ordinata rispetto alle scritture successive.
+func supportsOperation(consistency string, arg utils.Args, op string) bool {
+	switch consistency {
+	case utils.CausalPlus, utils.Quorum, utils.PrimaryBackup, utils.Chain:
+		switch op {
+		case utils.Get, utils.Delete:
+			return true
+		case utils.Put:
+			return arg.TTL == 0
+		case utils.Scan:
+			//Con N minore del numero di repliche nessuna replica conserva tutte le chiavi di un namespace a quorum
+			return consistency != utils.Quorum
+		}
+		return false
+	}
+	return true
+}
+
+// deliveryError ricostruisce l'errore di una Increment, di una Append o di un aggiornamento CRDT che alla consegna
+// non è stato applicato: la risposta riporta il valore corrente della chiave, e ripetendo il controllo su quel
+// valore si ottiene lo stesso errore
+func deliveryError(arg utils.Args, resp *utils.Response, op string) error {
+	if resp.CRDT != nil && (op != utils.UpdateCRDT || resp.CRDT.Type != arg.CRDT) {
+		return fmt.Errorf("%w: key %q holds a %s", utils.ErrWrongType, arg.Key, resp.CRDT.Type)
+	}
+	if op == utils.UpdateCRDT && resp.Found {
+		return fmt.Errorf("%w: key %q holds a plain value, not a %s", utils.ErrWrongType, arg.Key, arg.CRDT)
+	}
+	if op != utils.UpdateCRDT {
+		if _, err := utils.ModifiedValue(op, resp.Value, resp.Found, arg); err != nil {
+			return err
+		}
+	}
+	return fmt.Errorf("%w: %q", utils.ErrNamespaceNotFound, arg.Namespace) //eliminato prima della consegna
+}
+
+// createNamespace crea il namespace arg.NamespaceSpec tramite il sequencer del catalogo
+func (c *namespaceCatalog) createNamespace(arg utils.Args, resp *utils.Response) error {
+	spec := arg.NamespaceSpec
+	if spec.Consistency == "" {
+		spec.Consistency = utils.Conf.Consistency
+	}
+	spec.Revision = 0
+	if err := utils.ValidateNamespace(spec); err != nil {
+		return err
+	}
+	if err := utils.AuthorizeRequest(arg, utils.CreateNamespace); err != nil {
+		return err
+	}
+	if _, _, exists := c.lookup(spec.Name); exists {
+		return fmt.Errorf("%w: %q", utils.ErrNamespaceExists, spec.Name)
+	}
+
+	arg.Namespace = spec.Name
+	arg.NamespaceSpec = spec
+	if err := c.sendNamespaceOp(arg, resp, utils.CreateNamespace); err != nil {
+		return err
+	}
+	if !resp.Succeeded {
+		return fmt.Errorf("%w: %q", utils.ErrNamespaceExists, spec.Name) //creato da una richiesta concorrente
+	}
+	return nil
+}
+
+// dropNamespace elimina il namespace arg.NamespaceSpec.Name tramite il sequencer del catalogo
+func (c *namespaceCatalog) dropNamespace(arg utils.Args, resp *utils.Response) error {
+	name := arg.NamespaceSpec.Name
+	if err := utils.ValidateNamespaceName(name); err != nil {
+		return err
+	}
+	if name == utils.DefaultNamespace {
+		return fmt.Errorf("%w: the %q namespace cannot be dropped", utils.ErrInvalidRequest, utils.DefaultNamespace)
+	}
+	if err := utils.AuthorizeRequest(arg, utils.DropNamespace); err != nil {
+		return err
+	}
+	if _, _, ok := c.lookup(name); !ok {
+		return fmt.Errorf("%w: %q", utils.ErrNamespaceNotFound, name)
+	}
+
+	arg.Namespace = name
+	if err := c.sendNamespaceOp(arg, resp, utils.DropNamespace); err != nil {
+		return err
+	}
+	if !resp.Succeeded {
+		return fmt.Errorf("%w: %q", utils.ErrNamespaceNotFound, name) //eliminato da una richiesta concorrente
+	}
+	return nil
+}
+
+// catalogSequencer ritorna la replica che ordina le operazioni sui namespace: quella di indice minore tra quelle
+// che non hanno lasciato il cluster. Se il sequencer è guasto senza aver lasciato il cluster, le operazioni sui
+// namespace falliscono con ErrCatalogUnavailable finché non torna attivo.
+func catalogSequencer() int {
+	for i := 0; i < utils.NumberOfReplicas; i++ {
+		if !utils.HasPeerLeft(i) {
+			return i
+		}
+	}
+	return 0
+}
+
+// sendNamespaceOp fa ordinare l'operazione op sui namespace dal sequencer del catalogo, inoltrandogliela se non è
+// la replica corrente, e ne attende l'esito. Il sequencer risponde entro timeouts.catalog: se la risposta non
+// arriva entro il doppio, che copre il ritardo di rete, il sequencer è considerato irraggiungibile.
+func (c *namespaceCatalog) sendNamespaceOp(arg utils.Args, resp *utils.Response, op string) error {
+	msg := utils.NewReplicationMessage(utils.Catalog, arg, c.index, op)
+	sequencer := catalogSequencer()
+	if sequencer == c.index {
+		return c.sequence(*msg, resp)
+	}
+	fmt.Printf("Forwarding %s of namespace %s to catalog sequencer %s\n", op, arg.Namespace, utils.Peers.ID(sequencer))
+	timer := time.NewTimer(2 * utils.Conf.Timeouts.Catalog)
+	defer timer.Stop()
+	select {
+	case reply := <-utils.ReplicateTo(*msg, []int{sequencer}, nil):
+		*resp = *reply.Response
+		return reply.Err
+	case <-timer.C:
+		return fmt.Errorf("%w: catalog sequencer %s did not answer", utils.ErrCatalogUnavailable, utils.Peers.ID(sequencer))
+	}
+}
+
+// sequence assegna all'operazione sui namespace la revisione successiva del catalogo, la applica e la consegna a
+// tutte le altre repliche attive. Le operazioni vengono ordinate una alla volta: la successiva riceve una
+// revisione solo quando la precedente è stata applicata da tutte le repliche, che quindi le consegnano in ordine.
+// Un'operazione che verrebbe rifiutata alla consegna non riceve alcuna revisione. Le repliche che non applicano
+// l'operazione entro timeouts.catalog la ricevono di nuovo prima della successiva, che fino ad allora fallisce con
+// ErrCatalogUnavailable.
+func (c *namespaceCatalog) sequence(msg utils.ReplicationMessage, resp *utils.Response) error {
+	if sequencer := catalogSequencer(); sequencer != c.index {
+		return fmt.Errorf("%w: namespace operations are sequenced by %s", utils.ErrNotPrimary, utils.Peers.ID(sequencer))
+	}
+	c.sequenceMutex.Lock()
+	defer c.sequenceMutex.Unlock()
+	if c.stoppedCatalog.Load() {
+		return utils.ErrShuttingDown
+	}
+	deadline := time.Now().Add(utils.Conf.Timeouts.Catalog)
+	if c.missing = c.deliverNamespaceOp(c.undelivered, c.missing, deadline); len(c.missing) > 0 {
+		return fmt.Errorf("%w: revision %d not yet applied by %s", utils.ErrCatalogUnavailable, c.undelivered.Sequence, peerIDs(c.missing))
+	}
+
+	c.catalogMutex.Lock()
+	_, _, exists := c.lookup(msg.Args.Namespace)
+	msg.Sequence = c.revision + 1
+	c.catalogMutex.Unlock()
+	switch {
+	case msg.OpType == utils.CreateNamespace && exists:
+		fmt.Printf("CreateNamespace operation skipped, namespace %s already exists\n", msg.Args.Namespace)
+		return nil
+	case msg.OpType == utils.DropNamespace && !exists:
+		fmt.Printf("DropNamespace operation skipped, namespace %s not found\n", msg.Args.Namespace)
+		return nil
+	case msg.OpType == utils.CreateNamespace:
+		msg.Args.NamespaceSpec.Revision = msg.Sequence
+	}
+	msg.ServerIndex = c.index
+	if err := c.applyNamespaceOp(msg, resp); err != nil {
+		return err
+	}
+
+	var others []int
+	for i := 0; i < utils.NumberOfReplicas; i++ {
+		if i != c.index {
+			others = append(others, i)
+		}
+	}
+	c.undelivered, c.missing = msg, c.deliverNamespaceOp(msg, others, deadline)
+	if len(c.missing) > 0 {
+		return fmt.Errorf("%w: %s of namespace %s applied by the sequencer but not yet by %s", utils.ErrCatalogUnavailable, msg.OpType, msg.Args.Namespace, peerIDs(c.missing))
+	}
+	return nil
+}
+
+// deliverNamespaceOp consegna l'operazione sui namespace alle repliche pending che non hanno lasciato il cluster,
+// ripetendo l'invio finché non l'hanno applicata o non scade deadline. Ritorna le repliche che non l'hanno applicata.
+func (c *namespaceCatalog) deliverNamespaceOp(msg utils.ReplicationMessage, pending []int, deadline time.Time) []int {
+	for {
+		pending = slices.DeleteFunc(pending, utils.HasPeerLeft)
+		if len(pending) == 0 {
+			return nil
+		}
+		applied, _ := awaitReplies(utils.ReplicateTo(msg, pending, nil), len(pending), len(pending), deadline)
+		for _, reply := range applied {
+			pending = slices.DeleteFunc(pending, func(i int) bool { return i == reply.Index })
+		}
+		if len(pending) == 0 || time.Now().After(deadline) {
+			return pending
+		}
+		time.Sleep(SLEEP_TIME)
+	}
+}
+
+// peerIDs ritorna gli identificativi delle repliche indices, separati da virgole
+func peerIDs(indices []int) string {
+	ids := make([]string, len(indices))
+	for i, index := range indices {
+		ids[i] = utils.Peers.ID(index)
+	}
+	return strings.Join(ids, ", ")
+}
+
+// applyNamespaceOp applica un'operazione sui namespace ordinata dal sequencer. Una revisione già applicata viene
+// ignorata: il sequencer ripete l'invio finché non riceve la risposta.
+func (c *namespaceCatalog) applyNamespaceOp(msg utils.ReplicationMessage, resp *utils.Response) error {
+	c.catalogMutex.Lock()
+	defer c.catalogMutex.Unlock()
+	if msg.Sequence <= c.revision {
+		resp.Succeeded = true
+		return nil
+	}
+	if msg.Sequence != c.revision+1 {
+		return fmt.Errorf("catalog revision %d received before revision %d", msg.Sequence, c.revision+1)
+	}
+	c.revision = msg.Sequence
+
+	host, _, exists := c.lookup(msg.Args.Namespace)
+	switch msg.OpType {
+	case utils.CreateNamespace:
+		spec := msg.Args.NamespaceSpec
+		if exists {
+			//Ospitato da uno qualsiasi degli storage, anche di un'altra consistenza
+			resp.Succeeded = false
+			fmt.Printf("CreateNamespace operation skipped, namespace %s already exists\n", spec.Name)
+			return nil
+		}
+		host = c.host(spec.Consistency)
+		host.withKeyspaces(func(keyspaces map[string]*keyspace) {
+			createKeyspace(keyspaces, spec, resp)
+		})
+		if monitored, ok := host.(monitoredHost); ok {
+			monitored.startMonitor()
+		}
+		resp.Namespaces = []utils.Namespace{spec}
+	case utils.DropNamespace:
+		if !exists {
+			resp.Succeeded = false
+			fmt.Printf("DropNamespace operation skipped, namespace %s not found\n", msg.Args.Namespace)
+			return nil
+		}
+		host.withKeyspaces(func(keyspaces map[string]*keyspace) {
+			dropKeyspace(keyspaces, msg.Args.Namespace, resp)
+		})
+	}
+	return nil
+}
+
+// createKeyspace crea il keyspace di un namespace. Va chiamata con mapMutex già acquisito.
+func createKeyspace(keyspaces map[string]*keyspace, spec utils.Namespace, resp *utils.Response) {
+	keyspaces[spec.Name] = newKeyspace(spec)
+	resp.Succeeded = true
+	fmt.Printf("CreateNamespace operation completed. Namespace: %s, Consistency: %s, Revision: %d\n", spec.Name, spec.Consistency, spec.Revision)
+}
+
+// dropKeyspace elimina il keyspace di un namespace: le chiavi vengono scartate e i Watch aperti chiusi con
+// ErrNamespaceNotFound. Va chiamata con mapMutex già acquisito.
+func dropKeyspace(keyspaces map[string]*keyspace, name string, resp *utils.Response) {
+	ks := keyspaces[name]
+	delete(keyspaces, name)
+	ks.closeWatchers(fmt.Errorf("%w: %q was dropped", utils.ErrNamespaceNotFound, name))
+	resp.Succeeded = true
+	fmt.Printf("DropNamespace operation completed. Namespace: %s, Keys: %d\n", name, ks.store.Len())
+}
+
+// liveKeyspace ritorna il keyspace del namespace della richiesta, se è ancora quello a cui la richiesta è stata
+// inviata: un namespace eliminato e ricreato con lo stesso nome ha un'altra revisione. Va chiamata con mapMutex
+// già acquisito.
+func liveKeyspace(keyspaces map[string]*keyspace, args utils.Args) (*keyspace, bool) {
+	ks, ok := keyspaces[utils.NamespaceName(args.Namespace)]
+	if !ok || ks.info.Revision != args.NamespaceRevision {
+		return nil, false
+	}
+	return ks, true
+}
+
+// Subscribe apre un Watch sul namespace indicato in args
+func (c *namespaceCatalog) Subscribe(args utils.WatchArgs) (*watcher, error) {
+	c.watchMutex.Lock()
+	closed := c.closed
+	c.watchMutex.Unlock()
+	if closed {
+		return nil, utils.ErrShuttingDown
+	}
+
+	args.Namespace = utils.NamespaceName(args.Namespace)
+	for _, host := range c.hosts() {
+		var w *watcher
+		var err error
+		found := false
+		host.withKeyspaces(func(keyspaces map[string]*keyspace) {
+			if ks, ok := keyspaces[args.Namespace]; ok {
+				found = true
+				w, err = ks.Subscribe(args)
+			}
+		})
+		if found {
+			return w, err
+		}
+	}
+	return nil, fmt.Errorf("%w: %q", utils.ErrNamespaceNotFound, args.Namespace)
+}
+
+// Unsubscribe chiude il Watch, se non è già stato chiuso
+func (c *namespaceCatalog) Unsubscribe(w *watcher) {
+	w.hub.Unsubscribe(w)
+}
+
+// CloseWatchers chiude i Watch di tutti i namespace con ErrShuttingDown e rifiuta quelli nuovi
+func (c *namespaceCatalog) CloseWatchers() {
+	c.watchMutex.Lock()
+	c.closed = true
+	c.watchMutex.Unlock()
+	for _, host := range c.hosts() {
+		host.withKeyspaces(func(keyspaces map[string]*keyspace) {
+			for _, ks := range keyspaces {
+				ks.CloseWatchers()
+			}
+		})
+	}
+}
+
+// stopAccepting fa sì che tutti gli storage rifiutino le successive richieste dei client, e il sequencer quelle
+// sui namespace inoltrate dalle altre repliche
+func (c *namespaceCatalog) stopAccepting() {
+	c.stoppedCatalog.Store(true)
+	for _, host := range c.hosts() {
+		host.StopAcceptingRequests()
+	}
+}
+
+// drain attende che l'operazione sui namespace in corso sia stata consegnata a tutte le repliche e che tutti gli
+// storage abbiano consegnato i messaggi in corso, per al massimo timeout in totale
+func (c *namespaceCatalog) drain(timeout time.Duration) int {
+	deadline := time.Now().Add(timeout)
+	pending := 0
+	for !c.sequenceMutex.TryLock() {
+		if time.Now().After(deadline) {
+			pending++
+			break
+		}
+		time.Sleep(SLEEP_TIME)
+	}
+	if pending == 0 {
+		c.sequenceMutex.Unlock()
+	}
+	for _, host := range c.hosts() {
+		pending += host.Drain(time.Until(deadline))
+	}
+	return pending
+}
+
+// snapshot ritorna una copia del contenuto di tutti i namespace
+func (c *namespaceCatalog) snapshot() map[string]map[string][]byte {
+	snapshot := make(map[string]map[string][]byte)
+	for _, host := range c.hosts() {
+		for name, keys := range host.Snapshot() {
+			snapshot[name] = keys
+		}
+	}
+	return snapshot
+}
+
+// printKeyspaces stampa in maniera formattata il contenuto di ogni namespace, in ordine di nome.
+// Va chiamata con mapMutex già acquisito.
+func printKeyspaces(keyspaces map[string]*keyspace) {
+	names := make([]string, 0, len(keyspaces))
+	for name := range keyspaces {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	for _, name := range names {
+		store := keyspaces[name].store
+
+		// Trova la lunghezza massima delle chiavi e dei valori per la formattazione
+		maxKeyLen := 3 // La lunghezza minima per "Key"
+		maxValLen := 5 // La lunghezza minima per "Value"
+		store.Ascend("", "", func(key string, value []byte) bool {
+			if len(key) > maxKeyLen {
+				maxKeyLen = len(key)
+			}
+			if len(value) > maxValLen {
+				maxValLen = len(value)
+			}
+			return true
+		})
+
+		// Stampa l'intestazione della tabella
+		fmt.Printf("Namespace %s\n", name)
+		fmt.Println(strings.Repeat("-", maxKeyLen+maxValLen+7))
+		fmt.Printf("| %-*s | %-*s |\n", maxKeyLen, "Key", maxValLen, "Value")
+		fmt.Println(strings.Repeat("-", maxKeyLen+maxValLen+7))
+
+		// Stampa ogni riga della tabella con key e value, in ordine di chiave
+		store.Ascend("", "", func(key string, value []byte) bool {
+			fmt.Printf("| %-*s | %-*s |\n", maxKeyLen, key, maxValLen, value)
+			return true
+		})
+
+		// Stampa la linea di chiusura della tabella
+		fmt.Println(strings.Repeat("-", maxKeyLen+maxValLen+7))
+	}
+}
+
+// snapshotKeyspaces copia il contenuto dei namespace di uno storage. Va chiamata con mapMutex già acquisito.
+func snapshotKeyspaces(keyspaces map[string]*keyspace) map[string]map[string][]byte {
+	snapshot := make(map[string]map[string][]byte, len(keyspaces))
+	for name, ks := range keyspaces {
+		snapshot[name] = ks.store.Clone()
+	}
+	return snapshot
+}
+
+// keyspaceStates copia il contenuto dei namespace di uno storage per trasferirlo a un'altra replica, con il clock
+// della versione di ogni chiave. Va chiamata con mapMutex già acquisito.
+func keyspaceStates(keyspaces map[string]*keyspace) []utils.NamespaceState {
+	states := make([]utils.NamespaceState, 0, len(keyspaces))
+	for _, ks := range keyspaces {
+		state := utils.NamespaceState{Namespace: ks.info, Keys: make([]utils.ScanResult, 0, ks.store.Len())}
+		ks.store.Ascend("", "", func(key string, value []byte) bool {
+			state.Keys = append(state.Keys, utils.ScanResult{Key: key, Value: value, Version: ks.stamps[key].Clock})
+			return true
+		})
+		states = append(states, state)
+	}
+	return states
+}
+
+// restoreKeyspaces sostituisce il contenuto dei namespace di uno storage con quello trasferito dalla replica origin.
+// Creazioni ed eliminazioni dei namespace sono ordinate dal catalogo: vengono sostituiti solo i namespace presenti
+// anche sulla replica corrente con la stessa revisione. Va chiamata con mapMutex già acquisito.
+func restoreKeyspaces(keyspaces map[string]*keyspace, states []utils.NamespaceState, origin int) {
+	for _, state := range states {
+		ks, ok := keyspaces[state.Namespace.Name]
+		if !ok || ks.info.Revision != state.Namespace.Revision {
+			continue
+		}
+		ks.store, ks.bytes, ks.stamps = newSkipList(), 0, make(map[string]utils.Stamp)
+		for _, item := range state.Keys {
+			ks.set(item.Key, item.Value)
+			ks.stamps[item.Key] = utils.Stamp{Clock: item.Version, Origin: origin}
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"SDCC/main/utils"
+	"testing"
+)
+
+// catalogOp ritorna l'operazione sui namespace con revisione revision, come ordinata dal sequencer
+func catalogOp(revision int, op string, spec utils.Namespace) utils.ReplicationMessage {
+	spec.Revision = revision
+	if op == utils.DropNamespace {
+		spec.Revision = 0
+	}
+	msg := utils.NewReplicationMessage(utils.Catalog, utils.Args{Namespace: spec.Name, NamespaceSpec: spec}, 0, op)
+	msg.Sequence = revision
+	return *msg
+}
+
+func TestCatalogRejectsCreateInAnotherHost(t *testing.T) {
+	c := newNamespaceCatalog(0)
+	causal := utils.Namespace{Name: "orders", Consistency: utils.Causal}
+	quorum := utils.Namespace{Name: "orders", Consistency: utils.Quorum}
+
+	//Due creazioni concorrenti dello stesso nome con consistenze diverse: vince quella ordinata per prima
+	for _, msg := range []utils.ReplicationMessage{catalogOp(1, utils.CreateNamespace, causal), catalogOp(2, utils.CreateNamespace, quorum)} {
+		resp := utils.NewResponse()
+		if err := c.applyNamespaceOp(msg, resp); err != nil {
+			t.Fatalf("revision %d: %v", msg.Sequence, err)
+		}
+		if want := msg.Sequence == 1; resp.Succeeded != want {
+			t.Fatalf("revision %d succeeded = %v, want %v", msg.Sequence, resp.Succeeded, want)
+		}
+	}
+	host, info, ok := c.lookup("orders")
+	if !ok || host != namespaceHost(c.causal) || info.Revision != 1 {
+		t.Fatalf("lookup = %T, %+v, %v, want the causal namespace of revision 1", host, info, ok)
+	}
+
+	//Una revisione già applicata viene ignorata, una fuori ordine rifiutata
+	if err := c.applyNamespaceOp(catalogOp(2, utils.DropNamespace, causal), utils.NewResponse()); err != nil {
+		t.Fatalf("repeated revision: %v", err)
+	}
+	if _, _, ok := c.lookup("orders"); !ok {
+		t.Fatal("repeated revision dropped the namespace")
+	}
+	if err := c.applyNamespaceOp(catalogOp(4, utils.DropNamespace, causal), utils.NewResponse()); err == nil {
+		t.Fatal("revision 4 applied before revision 3")
+	}
+}
+
+func TestLiveKeyspaceRejectsRecreatedNamespace(t *testing.T) {
+	c := newNamespaceCatalog(0)
+	spec := utils.Namespace{Name: "orders", Consistency: utils.Causal}
+	for i, op := range []string{utils.CreateNamespace, utils.DropNamespace, utils.CreateNamespace} {
+		if err := c.applyNamespaceOp(catalogOp(i+1, op, spec), utils.NewResponse()); err != nil {
+			t.Fatalf("revision %d: %v", i+1, err)
+		}
+	}
+
+	c.causal.withKeyspaces(func(keyspaces map[string]*keyspace) {
+		//Una scrittura inviata al namespace eliminato non raggiunge quello ricreato
+		if _, ok := liveKeyspace(keyspaces, utils.Args{Namespace: "orders", NamespaceRevision: 1}); ok {
+			t.Error("request for revision 1 reached the namespace of revision 3")
+		}
+		if _, ok := liveKeyspace(keyspaces, utils.Args{Namespace: "orders", NamespaceRevision: 3}); !ok {
+			t.Error("request for revision 3 did not reach its namespace")
+		}
+	})
+}
@@ -0,0 +1,273 @@
+package main
+
+import (
+	"SDCC/main/utils"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"net/rpc"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// clientEndpoint è il servizio net/rpc esposto alle connessioni che non presentano il certificato di una
+// replica: registrato con lo stesso nome dello storage, offre solo le operazioni dei client e richiede un token valido.
+// In questo modo un client non può invocare Update, ReceiveAck o PeerLeaving.
+type clientEndpoint struct {
+	kvs KVS
+}
+
+func (e *clientEndpoint) Get(args utils.Args, reply *utils.Response) error {
+	if _, err := utils.Authenticate(args.Token); err != nil {
+		return err
+	}
+	return e.kvs.Get(args, reply)
+}
+
+func (e *clientEndpoint) Put(args utils.Args, reply *utils.Response) error {
+	if _, err := utils.Authenticate(args.Token); err != nil {
+		return err
+	}
+	return e.kvs.Put(args, reply)
+}
+
+func (e *clientEndpoint) Delete(args utils.Args, reply *utils.Response) error {
+	if _, err := utils.Authenticate(args.Token); err != nil {
+		return err
+	}
+	return e.kvs.Delete(args, reply)
+}
+
+func (e *clientEndpoint) Increment(args utils.Args, reply *utils.Response) error {
+	if _, err := utils.Authenticate(args.Token); err != nil {
+		return err
+	}
+	return e.kvs.Increment(args, reply)
+}
+
+func (e *clientEndpoint) Append(args utils.Args, reply *utils.Response) error {
+	if _, err := utils.Authenticate(args.Token); err != nil {
+		return err
+	}
+	return e.kvs.Append(args, reply)
+}
+
+func (e *clientEndpoint) UpdateCRDT(args utils.Args, reply *utils.Response) error {
+	if _, err := utils.Authenticate(args.Token); err != nil {
+		return err
+	}
+	return e.kvs.UpdateCRDT(args, reply)
+}
+
+func (e *clientEndpoint) CompareAndSwap(args utils.Args, reply *utils.Response) error {
+	if _, err := utils.Authenticate(args.Token); err != nil {
+		return err
+	}
+	return e.kvs.CompareAndSwap(args, reply)
+}
+
+func (e *clientEndpoint) Txn(args utils.Args, reply *utils.Response) error {
+	if _, err := utils.Authenticate(args.Token); err != nil {
+		return err
+	}
+	return e.kvs.Txn(args, reply)
+}
+
+func (e *clientEndpoint) Batch(args utils.Args, reply *utils.Response) error {
+	if _, err := utils.Authenticate(args.Token); err != nil {
+		return err
+	}
+	return e.kvs.Batch(args, reply)
+}
+
+func (e *clientEndpoint) Scan(args utils.Args, reply *utils.Response) error {
+	if _, err := utils.Authenticate(args.Token); err != nil {
+		return err
+	}
+	return e.kvs.Scan(args, reply)
+}
+
+func (e *clientEndpoint) ScanPrefix(args utils.Args, reply *utils.Response) error {
+	if _, err := utils.Authenticate(args.Token); err != nil {
+		return err
+	}
+	return e.kvs.ScanPrefix(args, reply)
+}
+
+func (e *clientEndpoint) End(args utils.Args, reply *utils.Response) error {
+	if _, err := utils.Authenticate(args.Token); err != nil {
+		return err
+	}
+	return e.kvs.End(args, reply)
+}
+
+func (e *clientEndpoint) CreateNamespace(args utils.Args, reply *utils.Response) error {
+	if _, err := utils.Authenticate(args.Token); err != nil {
+		return err
+	}
+	return e.kvs.CreateNamespace(args, reply)
+}
+
+func (e *clientEndpoint) DropNamespace(args utils.Args, reply *utils.Response) error {
+	if _, err := utils.Authenticate(args.Token); err != nil {
+		return err
+	}
+	return e.kvs.DropNamespace(args, reply)
+}
+
+func (e *clientEndpoint) ListNamespaces(args utils.Args, reply *utils.Response) error {
+	if _, err := utils.Authenticate(args.Token); err != nil {
+		return err
+	}
+	return e.kvs.ListNamespaces(args, reply)
+}
+
+// catalogEndpoint riceve le operazioni sui namespace inoltrate al sequencer del catalogo e quelle che ha ordinato
+type catalogEndpoint struct {
+	catalog *namespaceCatalog
+}
+
+func (e *catalogEndpoint) Replicate(msg utils.ReplicationMessage, reply *utils.Response) error {
+	return e.catalog.replicate(msg, reply)
+}
+
+// rpcServers contiene i due server net/rpc: quello completo per le repliche e quello ridotto per i client
+type rpcServers struct {
+	replicas *rpc.Server
+	clients  *rpc.Server
+}
+
+// newRPCServers registra per le repliche tutti gli storage ("sequential", "causal", "causalplus", "quorum",
+// "primarybackup" e "chain"), perché i namespace possono avere una consistenza diversa da quella del cluster, e il
+// sequencer del catalogo ("catalog"), e per i client solo lo storage del servizio service
+func newRPCServers(service string, catalog *namespaceCatalog) (*rpcServers, error) {
+	s := &rpcServers{replicas: rpc.NewServer(), clients: rpc.NewServer()}
+	if err := s.replicas.RegisterName("sequential", catalog.sequential); err != nil {
+		return nil, err
+	}
+	if err := s.replicas.RegisterName("causal", catalog.causal); err != nil {
+		return nil, err
+	}
+	if err := s.replicas.RegisterName(utils.ReplicationService(utils.CausalPlus), catalog.causalPlus); err != nil {
+		return nil, err
+	}
+	if err := s.replicas.RegisterName(utils.ReplicationService(utils.Quorum), catalog.quorum); err != nil {
+		return nil, err
+	}
+	if err := s.replicas.RegisterName(utils.ReplicationService(utils.PrimaryBackup), catalog.primaryBackup); err != nil {
+		return nil, err
+	}
+	if err := s.replicas.RegisterName(utils.ReplicationService(utils.Chain), catalog.chain); err != nil {
+		return nil, err
+	}
+	if err := s.replicas.RegisterName(utils.ReplicationService(utils.Catalog), &catalogEndpoint{catalog: catalog}); err != nil {
+		return nil, err
+	}
+	if err := s.clients.RegisterName(service, &clientEndpoint{kvs: catalog.primary()}); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// serve gestisce una connessione: senza TLS tutte le connessioni sono trattate come in passato, con TLS la
+// connessione raggiunge i metodi delle repliche solo se presenta un certificato di una replica della tabella dei peer
+func (s *rpcServers) serve(conn net.Conn) {
+	tlsConn, ok := conn.(*tls.Conn)
+	if !ok {
+		s.replicas.ServeConn(conn)
+		return
+	}
+
+	_ = tlsConn.SetDeadline(time.Now().Add(utils.Conf.Timeouts.Dial))
+	if err := tlsConn.Handshake(); err != nil {
+		fmt.Println("TLS handshake failed with", conn.RemoteAddr(), ":", err)
+		_ = conn.Close()
+		return
+	}
+	_ = tlsConn.SetDeadline(time.Time{})
+
+	if _, ok := utils.ReplicaIdentity(tlsConn.ConnectionState()); ok {
+		s.replicas.ServeConn(conn)
+	} else {
+		s.clients.ServeConn(conn)
+	}
+}
+
+// bearerToken estrae il token da un valore "Bearer <token>" dell'header Authorization
+func bearerToken(header string) string {
+	token, ok := strings.CutPrefix(header, "Bearer ")
+	if !ok {
+		return ""
+	}
+	return strings.TrimSpace(token)
+}
+
+// authenticateHTTP verifica il token della richiesta HTTP, rispondendo 401 se non è valido
+func authenticateHTTP(w http.ResponseWriter, r *http.Request) (string, bool) {
+	token := bearerToken(r.Header.Get("Authorization"))
+	if _, err := utils.Authenticate(token); err != nil {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		writeJSON(w, http.StatusUnauthorized, errorBody{Error: err.Error()})
+		return "", false
+	}
+	return token, true
+}
+
+// grpcToken estrae il token del client dai metadata della chiamata
+func grpcToken(ctx context.Context) string {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ""
+	}
+	values := md.Get("authorization")
+	if len(values) == 0 {
+		return ""
+	}
+	return bearerToken(values[0])
+}
+
+// authorizeGRPC verifica le credenziali di una chiamata gRPC: i metodi del servizio Replica richiedono il
+// certificato di una replica, quelli del servizio KeyValue un token valido
+func authorizeGRPC(ctx context.Context, fullMethod string) error {
+	if utils.ServerTLS() == nil {
+		return nil
+	}
+	if strings.HasPrefix(fullMethod, "/kvs.v1.Replica/") {
+		p, ok := peer.FromContext(ctx)
+		if ok {
+			if info, ok := p.AuthInfo.(credentials.TLSInfo); ok {
+				if _, ok := utils.ReplicaIdentity(info.State); ok {
+					return nil
+				}
+			}
+		}
+		return status.Error(codes.PermissionDenied, "replica methods require a replica certificate")
+	}
+	if _, err := utils.Authenticate(grpcToken(ctx)); err != nil {
+		return status.Error(codes.Unauthenticated, err.Error())
+	}
+	return nil
+}
+
+func unaryAuthInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	if err := authorizeGRPC(ctx, info.FullMethod); err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+func streamAuthInterceptor(srv any, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if err := authorizeGRPC(stream.Context(), info.FullMethod); err != nil {
+		return err
+	}
+	return handler(srv, stream)
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"SDCC/main/utils"
+	"fmt"
+	"sync"
+)
+
+// sequencedKeyspaces sono i namespace degli storage in cui una sola replica ordina le scritture con un numero di
+// sequenza (primary-backup, catena): le altre le applicano nello stesso ordine, o ricevono l'intero stato con un
+// trasferimento. Va incluso nello storage, che ne eredita i namespace e il mutex.
+type sequencedKeyspaces struct {
+	keyspaces map[string]*keyspace //le chiavi di ogni namespace dello storage
+	mapMutex  sync.Mutex           //mutex per accedere alla Map
+}
+
+func newSequencedKeyspaces() sequencedKeyspaces {
+	return sequencedKeyspaces{keyspaces: make(map[string]*keyspace)}
+}
+
+// read legge la chiave o l'intervallo della richiesta dallo stato della replica corrente
+func (s *sequencedKeyspaces) read(msg *utils.ReplicationMessage, resp *utils.Response) {
+	s.mapMutex.Lock()
+	defer s.mapMutex.Unlock()
+	ks, ok := liveKeyspace(s.keyspaces, msg.Args)
+	if !ok {
+		resp.Key = msg.Args.Key
+		return //eliminato dopo il controllo
+	}
+	if msg.OpType == utils.Scan {
+		resp.ScanResults, resp.Cursor = scanRange(ks.store, msg.Args)
+		fmt.Printf("Scan operation completed. Range: [%s, %s), Keys: %d\n", msg.Args.Key, msg.Args.RangeEnd, len(resp.ScanResults))
+		return
+	}
+	value, found := ks.store.Get(msg.Args.Key)
+	resp.Key, resp.Value, resp.Found, resp.Stamp, resp.IsPrintable = msg.Args.Key, value, found, ks.stamps[msg.Args.Key], true
+	fmt.Printf("Get operation completed. Key: %s, Value: %s, Version: %s\n", msg.Args.Key, value, resp.Stamp)
+}
+
+// exists indica se la chiave della richiesta esiste sulla replica corrente
+func (s *sequencedKeyspaces) exists(args utils.Args) bool {
+	s.mapMutex.Lock()
+	defer s.mapMutex.Unlock()
+	ks, ok := liveKeyspace(s.keyspaces, args)
+	if !ok {
+		return false
+	}
+	_, found := ks.store.Get(args.Key)
+	return found
+}
+
+// applySequenced applica una scrittura già ordinata, riportando nella risposta se la chiave esisteva. Il numero di
+// sequenza applicato va avanzato dal chiamante.
+func (s *sequencedKeyspaces) applySequenced(msg *utils.ReplicationMessage, resp *utils.Response) {
+	s.mapMutex.Lock()
+	defer s.mapMutex.Unlock()
+	resp.Key, resp.Stamp = msg.Args.Key, msg.Stamp
+
+	ks, ok := liveKeyspace(s.keyspaces, msg.Args)
+	if !ok {
+		fmt.Printf("%s operation skipped, namespace %s not found\n", msg.OpType, msg.Args.Namespace)
+		return
+	}
+	_, resp.Found = ks.store.Get(msg.Args.Key)
+	applyStamped(ks, msg)
+}
+
+// states copia il contenuto dei namespace per trasferirlo a un'altra replica
+func (s *sequencedKeyspaces) states() []utils.NamespaceState {
+	s.mapMutex.Lock()
+	defer s.mapMutex.Unlock()
+	return keyspaceStates(s.keyspaces)
+}
+
+// restore sostituisce il contenuto dei namespace con quello trasferito dalla replica origin
+func (s *sequencedKeyspaces) restore(states []utils.NamespaceState, origin int) {
+	s.mapMutex.Lock()
+	defer s.mapMutex.Unlock()
+	restoreKeyspaces(s.keyspaces, states, origin)
+}
+
+// Snapshot ritorna una copia del contenuto dello storage, per namespace
+func (s *sequencedKeyspaces) Snapshot() map[string]map[string][]byte {
+	s.mapMutex.Lock()
+	defer s.mapMutex.Unlock()
+	return snapshotKeyspaces(s.keyspaces)
+}
+
+// withKeyspaces esegue fn sui namespace dello storage con mapMutex acquisito
+func (s *sequencedKeyspaces) withKeyspaces(fn func(keyspaces map[string]*keyspace)) {
+	s.mapMutex.Lock()
+	defer s.mapMutex.Unlock()
+	fn(s.keyspaces)
+}
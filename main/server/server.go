@@ -2,65 +2,145 @@ package main
 
 import (
 	"SDCC/main/utils"
+	"context"
+	"crypto/tls"
+	"errors"
+	"flag"
 	"fmt"
 	"net"
-	"net/rpc"
+	"net/http"
 	"os"
-	"strconv"
+	"os/signal"
+	"syscall"
+
+	"google.golang.org/grpc"
 )
 
 func main() {
+	// Lettura della configurazione: file YAML + flag da riga di comando
+	err := utils.ParseConfig(flag.CommandLine, os.Args[1:])
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
 	// Check command line arguments
-	if len(os.Args) < 2 {
-		fmt.Println("Usage: go run server.go <server_index>")
+	if flag.NArg() < 1 {
+		fmt.Println("Usage: server [-config <file>] [flags] <server_id>")
 		os.Exit(1)
 	}
-	index, err := strconv.Atoi(os.Args[1])
+	index, err := utils.ParseServerID(flag.Arg(0))
 	if err != nil {
-		fmt.Println("Invalid index")
+		fmt.Println(err)
 		os.Exit(1)
 	}
 
-	// Check environment variable
-	consistType := os.Getenv("CONSIST_TYPE")
-	if consistType == "" {
-		fmt.Println("Environment variable CONSIST_TYPE is not set")
+	if err := utils.LoadTLS(utils.Peers.ID(index)); err != nil {
+		fmt.Println(err)
 		os.Exit(1)
 	}
+
+	SLEEP_TIME = utils.Conf.Timeouts.PollInterval
+	consistType := utils.Conf.Consistency
 	fmt.Printf("CONSIST_TYPE: %s\n", consistType)
 
-	if consistType == "Sequential" { // Set up RPC server
-		sequential := NewKVSSequentialV2(index)
-		err = rpc.RegisterName("sequential", sequential)
-		if err != nil {
-			fmt.Println("Error registering RPC:", err)
-			return
-		}
-	} else if consistType == "Causal" {
-		causal := NewKVSCasual(index)
-		err = rpc.RegisterName("causal", causal)
-		if err != nil {
-			fmt.Println("Error registering RPC:", err)
-			return
-		}
+	//Ogni replica esegue entrambi gli storage: quello della consistenza del cluster riceve le richieste dei client
+	//e ospita il namespace "default", l'altro i namespace creati con la consistenza diversa
+	var service string
+	if consistType == utils.Sequential { // Set up RPC server
+		service = "sequential"
+	} else if consistType == utils.Causal {
+		service = "causal"
 	} else {
 		fmt.Println("Unknown consist type:", consistType)
 		os.Exit(1)
 	}
+	catalog := newNamespaceCatalog(index)
+	go catalog.sequential.PeriodicCheckForEndKeys()
+	kvs := catalog.primary()
+	servers, err := newRPCServers(service, catalog)
+	if err != nil {
+		fmt.Println("Error registering RPC:", err)
+		return
+	}
 
-	port := utils.GetServerPort(index)
-	addr := "localhost:" + port
-	fmt.Println("Registering server ", index, " at address: ", addr)
-	listener, err := net.Listen("tcp", ":"+port)
+	addr := utils.Conf.ListenAddress(index)
+	fmt.Println("Registering server ", utils.Peers.ID(index), " at address: ", utils.Peers.Address(index))
+	listener, err := net.Listen("tcp", addr)
 	if err != nil {
 		fmt.Println("Error listening:", err)
 		return
 	}
+	if tlsConfig := utils.ServerTLS(); tlsConfig != nil {
+		listener = tls.NewListener(listener, tlsConfig)
+	}
+
+	var gateway *http.Server
+	if httpAddr := utils.Conf.HTTPListenAddress(index); httpAddr != "" {
+		gateway = &http.Server{Addr: httpAddr, Handler: NewGateway(kvs, index).Handler(), TLSConfig: utils.ServerTLS()}
+		go func() {
+			fmt.Printf("Server %s: HTTP gateway listening on %s\n", utils.Peers.ID(index), httpAddr)
+			var err error
+			if gateway.TLSConfig != nil {
+				err = gateway.ListenAndServeTLS("", "") //certificato già presente in TLSConfig
+			} else {
+				err = gateway.ListenAndServe()
+			}
+			if err != nil && !errors.Is(err, http.ErrServerClosed) {
+				fmt.Println("Error in HTTP gateway:", err)
+			}
+		}()
+	}
+
+	var grpcServer *grpc.Server
+	if grpcAddr := utils.Conf.GRPCListenAddress(index); grpcAddr != "" {
+		grpcListener, err := net.Listen("tcp", grpcAddr)
+		if err != nil {
+			fmt.Println("Error listening for gRPC:", err)
+			return
+		}
+		grpcServer = NewGRPCServer(catalog)
+		go func() {
+			fmt.Printf("Server %s: gRPC server listening on %s\n", utils.Peers.ID(index), grpcAddr)
+			if err := grpcServer.Serve(grpcListener); err != nil {
+				fmt.Println("Error in gRPC server:", err)
+			}
+		}()
+	}
+
+	// Alla ricezione di SIGTERM (o SIGINT) il server si spegne in modo controllato. Durante lo svuotamento delle
+	// code il listener resta aperto, perché le altre repliche devono poter continuare a inviare messaggi e ack.
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, syscall.SIGTERM, syscall.SIGINT)
+	stopped := make(chan struct{})
+	go func() {
+		sig := <-stop
+		fmt.Printf("\033[35mReceived %s\033[0m\n", sig)
+		if gateway != nil {
+			//Il gateway smette di accettare connessioni e attende le richieste in corso per al massimo il tempo di drain
+			ctx, cancel := context.WithTimeout(context.Background(), utils.Conf.Timeouts.Drain)
+			catalog.stopAccepting()
+			catalog.CloseWatchers() //gli stream dei Watch non terminano da soli
+			_ = gateway.Shutdown(ctx)
+			cancel()
+		}
+		gracefulShutdown(catalog, service, index)
+		if grpcServer != nil {
+			grpcServer.Stop() //gli stream Multicast delle altre repliche non terminano da soli
+		}
+		close(stopped)
+		_ = listener.Close()
+	}()
 
-	fmt.Printf("Server %d: ready to listen on port %s\n", index, port)
+	fmt.Printf("Server %s: ready to listen on %s\n", utils.Peers.ID(index), addr)
 	for {
 		conn, err := listener.Accept()
 		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				<-stopped
+				fmt.Printf("Server %s: stopped\n", utils.Peers.ID(index))
+				return
+			}
 			fmt.Println("SERVER: Errore nell'accettare la connessione dal client:", err)
 			continue
 		}
@@ -68,7 +148,7 @@ func main() {
 		// Avvia la gestione della connessione in un goroutine
 		go func(conn net.Conn) {
 			// Servi la connessione RPC
-			rpc.ServeConn(conn)
+			servers.serve(conn)
 
 			defer func() {
 				err := conn.Close()
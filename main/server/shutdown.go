@@ -0,0 +1,107 @@
+package main
+
+import (
+	"SDCC/main/utils"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// drainer tiene traccia delle richieste in corso su uno storage, in modo da poterlo spegnere senza perdere
+// i messaggi già ricevuti. Va incluso negli storage che implementano Stoppable.
+type drainer struct {
+	mutex    sync.Mutex
+	draining bool //true quando il server non accetta più richieste dai client
+	inFlight int  //richieste dei client e messaggi delle altre repliche ancora in elaborazione
+}
+
+// beginRequest registra una nuova richiesta di un client, rifiutandola se il server è in spegnimento
+func (d *drainer) beginRequest() error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	if d.draining {
+		return utils.ErrShuttingDown
+	}
+	d.inFlight++
+	return nil
+}
+
+// beginUpdate registra un messaggio ricevuto da un'altra replica. I messaggi delle altre repliche vanno
+// accettati anche durante lo spegnimento, altrimenti le repliche resterebbero bloccate in attesa di consegna.
+func (d *drainer) beginUpdate() {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.inFlight++
+}
+
+// end segnala il termine di una richiesta o di un messaggio registrato in precedenza
+func (d *drainer) end() {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.inFlight--
+}
+
+// StopAcceptingRequests fa sì che tutte le successive richieste dei client vengano rifiutate
+func (d *drainer) StopAcceptingRequests() {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.draining = true
+}
+
+// Drain attende che tutte le richieste e i messaggi in corso siano stati consegnati, per al massimo timeout.
+// Ritorna il numero di elementi ancora in corso allo scadere del timeout (0 se lo storage è stato svuotato).
+func (d *drainer) Drain(timeout time.Duration) int {
+	deadline := time.Now().Add(timeout)
+	for {
+		d.mutex.Lock()
+		pending := d.inFlight
+		d.mutex.Unlock()
+
+		if pending == 0 || time.Now().After(deadline) {
+			return pending
+		}
+		time.Sleep(SLEEP_TIME)
+	}
+}
+
+// gracefulShutdown esegue lo spegnimento controllato della replica index: smette di accettare richieste dai
+// client, consegna i messaggi già ricevuti da entrambi gli storage, salva i namespace su disco e comunica alle
+// altre repliche l'uscita.
+func gracefulShutdown(catalog *namespaceCatalog, service string, index int) {
+	fmt.Printf("\033[35mServer %s: stopped accepting client requests, draining...\033[0m\n", utils.Peers.ID(index))
+	catalog.stopAccepting()
+
+	pending := catalog.drain(utils.Conf.Timeouts.Drain)
+	if pending > 0 {
+		fmt.Printf("\033[31mServer %s: drain timeout expired with %d messages still pending\033[0m\n", utils.Peers.ID(index), pending)
+	} else {
+		fmt.Printf("\033[35mServer %s: all messages delivered\033[0m\n", utils.Peers.ID(index))
+	}
+
+	if utils.Conf.SnapshotFile != "" {
+		err := writeSnapshot(utils.Conf.SnapshotFile, catalog.snapshot())
+		if err != nil {
+			fmt.Println("Error writing snapshot:", err)
+		} else {
+			fmt.Println("Store saved to", utils.Conf.SnapshotFile)
+		}
+	}
+
+	utils.NotifyLeaving(service, index)
+}
+
+// writeSnapshot salva il contenuto dei namespace in formato JSON (namespace -> chiave -> valore), con i valori codificati in base64.
+// Il file viene prima scritto in una copia temporanea e poi rinominato, in modo da non lasciare mai su disco uno snapshot incompleto.
+func writeSnapshot(path string, store map[string]map[string][]byte) error {
+	data, err := json.MarshalIndent(store, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err = os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,398 @@
+package main
+
+import (
+	"SDCC/main/utils"
+	"bytes"
+	"fmt"
+	"slices"
+)
+
+/*
+ Sibling
+
+ Nei namespace causali due Put concorrenti sulla stessa chiave possono essere consegnate in ordini diversi dalle
+ varie repliche: invece di tenere solo l'ultima consegnata, ogni replica conserva tutte le versioni concorrenti
+ (sibling), ognuna con il clock vettoriale del messaggio che l'ha scritta.
+
+ Con il multicast causalmente ordinato un clock "ha visto" la scrittura w se è maggiore o uguale al clock di w
+ in ogni componente: la replica che l'ha prodotto aveva già consegnato w. Una scrittura rende obsolete le versioni
+ viste dal proprio clock e dal contesto indicato dal client (Args.Context, il contesto restituito dalla Get con cui
+ ha letto i sibling): così una Put che riporta il contesto letto li risolve anche se la replica che la riceve non
+ li ha ancora consegnati. Per ogni chiave si conserva l'unione dei clock e dei contesti delle scritture applicate (seen): una
+ versione che arriva dopo una scrittura che l'ha già vista viene scartata. Le Delete sono scritture di una versione
+ cancellata (tombstone), che risolvono i sibling come una Put.
+
+ Increment, Append e gli aggiornamenti CRDT leggono il valore corrente: non sono versioni ma operazioni (update),
+ conservate finché una scrittura non le vede. Le versioni che prevalgono vengono ricalcolate riapplicando gli
+ aggiornamenti in un ordine totale che estende la relazione di causalità: ognuno si applica alla versione più
+ recente tra quelle viste dal proprio clock (a una chiave che non esiste se non ne ha vista nessuna) e rende
+ obsolete solo le altre versioni che ha visto, come una scrittura. Gli aggiornamenti concorrenti che si applicano
+ alla stessa versione si sommano nello stesso ordine su tutte le repliche, mentre una Put concorrente resta un
+ sibling. Il tipo di un CRDT su cui arrivano aggiornamenti concorrenti di tipi diversi è quello del primo
+ nell'ordine totale, e gli altri non hanno effetto su nessuna replica, come un aggiornamento che ha visto un valore
+ ordinario.
+
+ Il risultato è quindi una funzione dell'insieme delle scritture e degli aggiornamenti consegnati, e non dipende
+ dall'ordine di consegna. Quando sono causalmente stabili, cioè ogni messaggio che le repliche invieranno li avrà
+ visti, gli aggiornamenti vengono incorporati nelle versioni a cui si applicano: nessun messaggio successivo può
+ essere ordinato prima di loro, quindi il risultato non cambia.
+*/
+
+// version è una versione di una chiave di un namespace causale
+type version struct {
+	value   []byte
+	deleted bool       //versione scritta da una Delete
+	clock   []int      //clock del messaggio che l'ha scritta, unito a quelli degli aggiornamenti che le si sono applicati
+	base    []int      //clock della versione prima degli aggiornamenti: un aggiornamento le si applica se l'ha visto
+	origin  int        //replica d'origine del messaggio con clock base
+	crdt    *crdtState //stato del CRDT creato dagli aggiornamenti, nil per un valore ordinario
+}
+
+// compareVersions ordina le versioni per decidere quale prevale come valore della chiave: per somma delle
+// componenti del clock, che estende la relazione di causalità, e a parità per valore e per clock
+func compareVersions(v version, w version) int {
+	if sumOf(v.clock) != sumOf(w.clock) {
+		return sumOf(v.clock) - sumOf(w.clock)
+	}
+	if c := bytes.Compare(v.value, w.value); c != 0 {
+		return c
+	}
+	return slices.Compare(v.clock, w.clock)
+}
+
+// apply applica l'aggiornamento u alla versione. Ritorna un errore, senza modificarla, se il valore non lo permette.
+func (v *version) apply(u update) error {
+	switch u.op {
+	case utils.UpdateCRDT:
+		if v.crdt == nil && !v.deleted {
+			return fmt.Errorf("%w: key %q holds a plain value", utils.ErrWrongType, u.args.Key)
+		}
+		if v.crdt != nil && v.crdt.kind != u.args.CRDT {
+			return fmt.Errorf("%w: key %q holds a %s", utils.ErrWrongType, u.args.Key, v.crdt.kind)
+		}
+		if v.crdt == nil {
+			v.crdt = newCRDTState(u.args.CRDT)
+		}
+		v.crdt.apply(u)
+		v.value = v.crdt.render()
+	case utils.Increment, utils.Append:
+		if v.crdt != nil {
+			return fmt.Errorf("%w: key %q holds a %s", utils.ErrWrongType, u.args.Key, v.crdt.kind)
+		}
+		value, err := utils.ModifiedValue(u.op, v.value, !v.deleted, u.args)
+		if err != nil {
+			return err
+		}
+		v.value = value
+	}
+	v.deleted = false
+	v.clock = mergeClocks(v.clock, u.clock)
+	return nil
+}
+
+// update è un aggiornamento di una chiave di un namespace causale, con il clock e la replica d'origine del messaggio
+// che l'ha prodotto
+type update struct {
+	op     string
+	args   utils.Args
+	clock  []int
+	origin int
+	seq    int //posizione nel Batch: gli elementi dello stesso messaggio hanno lo stesso clock
+}
+
+// compareUpdates ordina gli aggiornamenti in un ordine totale che estende la relazione di causalità: per somma delle
+// componenti del clock, a parità per replica d'origine e, nello stesso messaggio, per posizione nel Batch
+func compareUpdates(u update, w update) int {
+	if sumOf(u.clock) != sumOf(w.clock) {
+		return sumOf(u.clock) - sumOf(w.clock)
+	}
+	if u.origin != w.origin {
+		return u.origin - w.origin
+	}
+	return u.seq - w.seq
+}
+
+// siblingSet contiene le versioni concorrenti di una chiave e gli aggiornamenti non ancora visti da una scrittura
+type siblingSet struct {
+	versions []version
+	updates  []update
+	seen     []int //unione dei clock e dei contesti delle scritture e dei clock degli aggiornamenti applicati alla chiave
+	gone     []int //per ogni replica d'origine, la componente minima del clock delle versioni eliminate (0 se nessuna)
+}
+
+func newSiblingSet() *siblingSet {
+	return &siblingSet{seen: make([]int, utils.NumberOfReplicas), gone: make([]int, utils.NumberOfReplicas)}
+}
+
+// remove elimina le versioni che soddisfano del, ricordando per ogni replica d'origine quali sono state eliminate
+func (s *siblingSet) remove(del func(version) bool) bool {
+	before := len(s.versions)
+	s.versions = slices.DeleteFunc(s.versions, func(v version) bool {
+		if del(v) {
+			s.forget(v)
+			return true
+		}
+		return false
+	})
+	return len(s.versions) != before
+}
+
+// forget ricorda che la versione v è stata eliminata
+func (s *siblingSet) forget(v version) {
+	if c := v.base[v.origin]; s.gone[v.origin] == 0 || c < s.gone[v.origin] {
+		s.gone[v.origin] = c
+	}
+}
+
+// sawGone indica se il clock clock ha visto una versione eliminata: per i clock dei messaggi w ha visto v se e solo
+// se la componente della replica d'origine di v non è minore di quella di v
+func (s *siblingSet) sawGone(clock []int) bool {
+	for i, c := range s.gone {
+		if c > 0 && clock[i] >= c {
+			return true
+		}
+	}
+	return false
+}
+
+// obsolete indica se il messaggio con clock clock è già stato visto da una scrittura. Gli elementi di un Batch hanno
+// lo stesso clock: non sono obsoleti finché la chiave contiene ciò che ha scritto un elemento precedente.
+func (s *siblingSet) obsolete(clock []int) bool {
+	if !happenedBefore(clock, s.seen) {
+		return false
+	}
+	same := func(c []int) bool { return slices.Equal(c, clock) }
+	return !slices.ContainsFunc(s.versions, func(v version) bool { return same(v.base) }) &&
+		!slices.ContainsFunc(s.updates, func(u update) bool { return same(u.clock) })
+}
+
+// write applica la scrittura v con il contesto context (nil se il client non l'ha indicato). Ritorna false se la
+// versione è già stata vista da un'altra scrittura ed è quindi obsoleta.
+func (s *siblingSet) write(v version, context []int) bool {
+	obsolete := s.obsolete(v.clock)
+
+	covers := mergeClocks(v.clock, context)
+	s.remove(func(w version) bool { return happenedBefore(w.clock, covers) })
+	s.updates = slices.DeleteFunc(s.updates, func(u update) bool { return happenedBefore(u.clock, covers) })
+	s.seen = mergeClocks(s.seen, covers)
+	if !obsolete {
+		v.base = v.clock
+		s.versions = append(s.versions, v)
+	}
+	return !obsolete
+}
+
+// update aggiunge l'aggiornamento u e ritorna la versione a cui si è applicato, o l'errore per cui non ha avuto
+// effetto tra gli aggiornamenti consegnati finora. Un aggiornamento già visto da una scrittura viene scartato.
+func (s *siblingSet) update(u update) (version, error) {
+	if s.obsolete(u.clock) {
+		return version{}, fmt.Errorf("%s of key %q is obsolete, a concurrent write already saw it", u.op, u.args.Key)
+	}
+	s.updates = append(s.updates, u)
+	s.seen = mergeClocks(s.seen, u.clock)
+	results, applied, errs := s.resolve()
+	if errs[len(errs)-1] != nil {
+		return version{}, errs[len(errs)-1]
+	}
+	return results[applied[len(applied)-1]], nil
+}
+
+// expire elimina le versioni viste da clock, il clock di una Put con TTL, come l'Expire e, che ne scrive una
+// cancellata: le versioni concorrenti o successive alla Put restano. Gli aggiornamenti che non hanno visto l'Expire
+// non si applicano più alle versioni eliminate. Ritorna false se non ha eliminato nulla.
+func (s *siblingSet) expire(clock []int, e *utils.VMessageNA) bool {
+	if !s.remove(func(v version) bool { return happenedBefore(v.base, clock) }) {
+		return false
+	}
+	s.versions = append(s.versions, version{deleted: true, clock: e.ClockVector, base: e.ClockVector, origin: e.ServerIndex})
+	s.seen = mergeClocks(s.seen, e.ClockVector)
+	return true
+}
+
+// has indica se tra le versioni c'è ancora quella scritta dal messaggio con clock clock
+func (s *siblingSet) has(clock []int) bool {
+	return slices.ContainsFunc(s.versions, func(v version) bool { return slices.Equal(v.base, clock) })
+}
+
+// siblingsOf ritorna le versioni di key, creandone l'insieme se la chiave non è mai stata scritta. Va chiamata con
+// il mapMutex dello storage già acquisito.
+func (ks *keyspace) siblingsOf(key string) *siblingSet {
+	set, ok := ks.siblings[key]
+	if !ok {
+		set = newSiblingSet()
+		ks.siblings[key] = set
+	}
+	return set
+}
+
+// resolve applica gli aggiornamenti alle versioni nell'ordine di compareUpdates. Ritorna le versioni risultanti,
+// dalla più vecchia a quella che prevale, e per ogni aggiornamento la versione a cui si è applicato o l'errore per
+// cui non ha avuto effetto. Ogni aggiornamento si applica alla versione più recente tra quelle viste dal proprio
+// clock, preferendo quelle non cancellate, e rende obsolete le altre versioni che ha visto. Se non ne ha vista
+// nessuna si applica a una chiave che non esiste, a meno che quelle che ha visto siano state eliminate da scritture
+// concorrenti: in quel caso non ha effetto.
+func (s *siblingSet) resolve() ([]version, []int, []error) {
+	results, removed, applied, errs := s.replay(s.updates)
+	kept := make([]int, 0, len(results))
+	for j := range results {
+		if !removed[j] {
+			kept = append(kept, j)
+		}
+	}
+	slices.SortFunc(kept, func(a, b int) int { return compareVersions(results[a], results[b]) })
+	versions := make([]version, len(kept))
+	position := make([]int, len(results))
+	for j := range position {
+		position[j] = -1
+	}
+	for k, j := range kept {
+		versions[k], position[j] = results[j], k
+	}
+	for i, u := range s.updates {
+		if applied[i] < 0 {
+			continue
+		}
+		if applied[i] = position[applied[i]]; applied[i] < 0 && errs[i] == nil {
+			errs[i] = fmt.Errorf("%s of key %q has no effect, the version it applies to was overwritten", u.op, u.args.Key)
+		}
+	}
+	return versions, applied, errs
+}
+
+// replay applica gli aggiornamenti updates alle versioni nell'ordine di compareUpdates, come descritto in resolve.
+// Ritorna le versioni risultanti, quali sono state rese obsolete, e per ogni aggiornamento l'indice della versione a
+// cui si è applicato (-1 se nessuna) o l'errore per cui non ha avuto effetto.
+func (s *siblingSet) replay(updates []update) ([]version, []bool, []int, []error) {
+	results := make([]version, len(s.versions))
+	for i, v := range s.versions {
+		v.crdt = v.crdt.clone()
+		results[i] = v
+	}
+	removed := make([]bool, len(results))
+	absent := -1 //versione creata dagli aggiornamenti che non hanno visto nessuna versione
+
+	order := make([]int, len(updates))
+	for i := range order {
+		order[i] = i
+	}
+	slices.SortFunc(order, func(a, b int) int { return compareUpdates(updates[a], updates[b]) })
+
+	applied := make([]int, len(updates))
+	errs := make([]error, len(updates))
+	for _, i := range order {
+		u := updates[i]
+		target := -1
+		for j, r := range results {
+			if removed[j] || !happenedBefore(r.base, u.clock) {
+				continue
+			}
+			if target < 0 || (results[target].deleted && !r.deleted) ||
+				(results[target].deleted == r.deleted && compareVersions(results[target], r) < 0) {
+				target = j
+			}
+		}
+		overwritten := s.sawGone(u.clock)
+		for j, r := range results {
+			overwritten = overwritten || (removed[j] && happenedBefore(r.base, u.clock))
+		}
+		if target < 0 && overwritten {
+			applied[i], errs[i] = -1, fmt.Errorf("%s of key %q has no effect, the version it applies to was overwritten", u.op, u.args.Key)
+			continue
+		}
+		if target < 0 && (absent < 0 || removed[absent]) {
+			results = append(results, version{deleted: true, clock: make([]int, len(s.seen)), base: u.clock, origin: u.origin})
+			removed = append(removed, false)
+			absent = len(results) - 1
+		}
+		if target < 0 {
+			target = absent
+		}
+
+		applied[i] = target
+		if errs[i] = results[target].apply(u); errs[i] != nil {
+			continue
+		}
+		for j, r := range results {
+			if j != target && happenedBefore(r.clock, u.clock) {
+				removed[j] = true
+			}
+		}
+	}
+
+	return results, removed, applied, errs
+}
+
+// compact incorpora nelle versioni gli aggiornamenti visti da stable, il clock dei messaggi causalmente stabili, se
+// tutti gli altri aggiornamenti li hanno visti: ogni messaggio consegnato in seguito li ha visti, quindi viene
+// ordinato dopo di loro e trova le stesse versioni, e il risultato non cambia. Le versioni che hanno reso obsolete
+// vengono ricordate come eliminate.
+func (s *siblingSet) compact(stable []int) {
+	var folded, rest []update
+	for _, u := range s.updates {
+		if happenedBefore(u.clock, stable) {
+			folded = append(folded, u)
+		} else {
+			rest = append(rest, u)
+		}
+	}
+	if len(folded) == 0 {
+		return
+	}
+	for _, u := range rest {
+		for _, f := range folded {
+			if !happenedBefore(f.clock, u.clock) {
+				return
+			}
+		}
+	}
+
+	results, removed, _, _ := s.replay(folded)
+	s.versions = make([]version, 0, len(results))
+	for j, r := range results {
+		if removed[j] {
+			s.forget(r)
+		} else {
+			s.versions = append(s.versions, r)
+		}
+	}
+	s.updates = rest
+}
+
+// current ritorna la versione che prevale tra quelle non cancellate, false se la chiave non esiste
+func (s *siblingSet) current() (version, bool) {
+	results, _, _ := s.resolve()
+	for i := len(results) - 1; i >= 0; i-- {
+		if !results[i].deleted {
+			return results[i], true
+		}
+	}
+	return version{}, false
+}
+
+// siblings ritorna le versioni non cancellate, dalla più vecchia a quella che prevale
+func (s *siblingSet) siblings() []utils.Sibling {
+	results, _, _ := s.resolve()
+	siblings := make([]utils.Sibling, 0, len(results))
+	for _, r := range results {
+		if !r.deleted {
+			siblings = append(siblings, utils.Sibling{Value: r.value, ClockVector: r.clock})
+		}
+	}
+	return siblings
+}
+
+// context ritorna il contesto da indicare nella Put che risolve i sibling letti
+func (s *siblingSet) context() []int {
+	return slices.Clone(s.seen)
+}
+
+// mergeClocks ritorna il massimo componente per componente di a e b
+func mergeClocks(a []int, b []int) []int {
+	merged := slices.Clone(a)
+	for i := range b {
+		if i < len(merged) && b[i] > merged[i] {
+			merged[i] = b[i]
+		}
+	}
+	return merged
+}
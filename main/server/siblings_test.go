@@ -0,0 +1,210 @@
+package main
+
+import (
+	"SDCC/main/utils"
+	"fmt"
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+)
+
+// permutations ritorna tutte le permutazioni degli indici da 0 a n-1
+func permutations(n int) [][]int {
+	if n == 0 {
+		return [][]int{{}}
+	}
+	var result [][]int
+	for _, p := range permutations(n - 1) {
+		for i := 0; i <= len(p); i++ {
+			result = append(result, slices.Insert(slices.Clone(p), i, n-1))
+		}
+	}
+	return result
+}
+
+// causalOrder indica se l'ordine di consegna order rispetta la causalità tra i messaggi
+func causalOrder(msgs []*utils.VMessageNA, order []int) bool {
+	for i := range order {
+		for _, later := range order[i+1:] {
+			if happenedBefore(msgs[later].ClockVector, msgs[order[i]].ClockVector) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// deliverAll consegna i messaggi a una nuova replica nell'ordine order e ne ritorna lo stato della chiave key
+func deliverAll(t *testing.T, msgs []*utils.VMessageNA, order []int, key string) string {
+	c := newNamespaceCatalog(0)
+	spec := utils.Namespace{Name: "causal", Consistency: utils.Causal}
+	if err := c.applyNamespaceOp(catalogOp(1, utils.CreateNamespace, spec), utils.NewResponse()); err != nil {
+		t.Fatal(err)
+	}
+	for _, i := range order {
+		if err := c.causal.CallRealOperation(msgs[i], utils.NewResponse()); err != nil {
+			t.Fatalf("message %d: %v", i, err)
+		}
+	}
+
+	var state string
+	c.causal.withKeyspaces(func(keyspaces map[string]*keyspace) {
+		ks := keyspaces["causal"]
+		value, found := ks.store.Get(key)
+		var crdt *utils.CRDTValue
+		if s, ok := ks.crdts[key]; ok {
+			crdt = s.value()
+		}
+		var siblings []utils.Sibling
+		if set, ok := ks.siblings[key]; ok {
+			siblings = set.siblings()
+		}
+		state = fmt.Sprintf("value=%q found=%v crdt=%+v siblings=%+v", value, found, crdt, siblings)
+	})
+	return state
+}
+
+// assertSameState consegna i messaggi in tutti gli ordini causali e verifica che ogni replica arrivi allo stesso
+// stato della chiave key
+func assertSameState(t *testing.T, msgs []*utils.VMessageNA, key string) string {
+	t.Helper()
+	var want string
+	var first []int
+	for _, order := range permutations(len(msgs)) {
+		if !causalOrder(msgs, order) {
+			continue
+		}
+		got := deliverAll(t, msgs, order, key)
+		if first == nil {
+			want, first = got, order
+		} else if !reflect.DeepEqual(got, want) {
+			t.Fatalf("order %v: %s\norder %v: %s", order, got, first, want)
+		}
+	}
+	return want
+}
+
+// causalMsg ritorna il messaggio dell'operazione op sulla chiave k inviato dalla replica origin con clock clock
+func causalMsg(origin int, clock []int, op string, args utils.Args) *utils.VMessageNA {
+	args.Namespace, args.NamespaceRevision, args.Key = "causal", 1, "k"
+	return utils.NewVMessageNA(args, clock, origin, op, 0)
+}
+
+func TestIncrementResolvesOnlySeenSiblings(t *testing.T) {
+
+	//Due Put concorrenti restano sibling; le Increment che hanno visto solo la prima si sommano su quella, in
+	//qualunque ordine vengano consegnate, e la seconda resta
+	msgs := []*utils.VMessageNA{
+		causalMsg(0, []int{1, 0, 0}, utils.Put, utils.Args{Value: []byte("10")}),
+		causalMsg(1, []int{0, 1, 0}, utils.Put, utils.Args{Value: []byte("b")}),
+		causalMsg(2, []int{1, 0, 1}, utils.Increment, utils.Args{Delta: 5}),
+		causalMsg(0, []int{2, 0, 0}, utils.Increment, utils.Args{Delta: 1}),
+	}
+	want := `value="16" found=true crdt=<nil> siblings=[{Value:[98] ClockVector:[0 1 0]} {Value:[49 54] ClockVector:[2 0 1]}]`
+	if state := assertSameState(t, msgs, "k"); state != want {
+		t.Fatalf("state = %s, want %s", state, want)
+	}
+}
+
+func TestAppendConcurrentWithPutConverges(t *testing.T) {
+
+	//Le Append concorrenti vengono accodate nello stesso ordine su tutte le repliche. La Put che ha visto solo la
+	//prima Append la sostituisce, e la seconda, che ha visto la versione sostituita ma non la Put, non ha effetto.
+	msgs := []*utils.VMessageNA{
+		causalMsg(0, []int{1, 0, 0}, utils.Put, utils.Args{Value: []byte("a")}),
+		causalMsg(1, []int{1, 1, 0}, utils.Append, utils.Args{Value: []byte("b")}),
+		causalMsg(2, []int{1, 0, 1}, utils.Append, utils.Args{Value: []byte("c")}),
+		causalMsg(0, []int{2, 1, 0}, utils.Put, utils.Args{Value: []byte("x")}),
+	}
+	want := `value="x" found=true crdt=<nil> siblings=[{Value:[120] ClockVector:[2 1 0]}]`
+	if state := assertSameState(t, msgs, "k"); state != want {
+		t.Fatalf("state = %s, want %s", state, want)
+	}
+
+	//Senza la Put restano le due Append, nell'ordine totale
+	want = `value="abc" found=true crdt=<nil> siblings=[{Value:[97 98 99] ClockVector:[1 1 1]}]`
+	if state := assertSameState(t, msgs[:3], "k"); state != want {
+		t.Fatalf("state = %s, want %s", state, want)
+	}
+}
+
+func TestExpireRemovesIncrementsOfExpiredVersion(t *testing.T) {
+
+	//L'Increment applicata alla versione scritta dalla Put con TTL scade con lei, quella che ha visto l'Expire
+	//ricrea la chiave
+	msgs := []*utils.VMessageNA{
+		causalMsg(0, []int{1, 0, 0}, utils.Put, utils.Args{Value: []byte("10"), TTL: time.Hour}),
+		causalMsg(1, []int{1, 1, 0}, utils.Increment, utils.Args{Delta: 1}),
+		causalMsg(0, []int{2, 0, 0}, utils.Expire, utils.Args{Context: []int{1, 0, 0}}),
+		causalMsg(2, []int{2, 0, 1}, utils.Increment, utils.Args{Delta: 3}),
+	}
+	want := `value="3" found=true crdt=<nil> siblings=[{Value:[51] ClockVector:[2 0 1]}]`
+	if state := assertSameState(t, msgs, "k"); state != want {
+		t.Fatalf("state = %s, want %s", state, want)
+	}
+}
+
+func TestStableUpdatesAreCompacted(t *testing.T) {
+
+	c := newNamespaceCatalog(0)
+	spec := utils.Namespace{Name: "causal", Consistency: utils.Causal}
+	if err := c.applyNamespaceOp(catalogOp(1, utils.CreateNamespace, spec), utils.NewResponse()); err != nil {
+		t.Fatal(err)
+	}
+
+	//Le repliche incrementano la chiave a turno, ognuna dopo aver consegnato le Increment delle altre: quelle viste
+	//da tutte vengono incorporate nella versione, quindi gli aggiornamenti conservati restano pochi
+	clock := make([]int, 3)
+	for i := 0; i < 30; i++ {
+		clock[i%3]++
+		msg := causalMsg(i%3, slices.Clone(clock), utils.Increment, utils.Args{Delta: 1})
+		if err := c.causal.CallRealOperation(msg, utils.NewResponse()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	c.causal.withKeyspaces(func(keyspaces map[string]*keyspace) {
+		ks := keyspaces["causal"]
+		if value, _ := ks.store.Get("k"); string(value) != "30" {
+			t.Errorf("value = %q, want 30", value)
+		}
+		if set := ks.siblings["k"]; len(set.updates) > 3 || len(set.versions) != 1 {
+			t.Errorf("%d updates and %d versions kept, want at most 3 and 1", len(set.updates), len(set.versions))
+		}
+	})
+}
+
+func TestCompactionKeepsAppendOrder(t *testing.T) {
+
+	//Due turni di Append concorrenti: il primo diventa stabile quando sono consegnate tutte quelle del secondo e
+	//viene incorporato nella versione, senza cambiare l'ordine in cui sono accodate
+	var msgs []*utils.VMessageNA
+	for round := 0; round < 2; round++ {
+		for origin := 0; origin < 3; origin++ {
+			clock := []int{round, round, round}
+			clock[origin]++
+			value := string(rune('a' + 3*round + origin))
+			msgs = append(msgs, causalMsg(origin, clock, utils.Append, utils.Args{Value: []byte(value)}))
+		}
+	}
+	want := `value="abcdef" found=true crdt=<nil> siblings=[{Value:[97 98 99 100 101 102] ClockVector:[2 2 2]}]`
+	if state := assertSameState(t, msgs, "k"); state != want {
+		t.Fatalf("state = %s, want %s", state, want)
+	}
+
+	c := newNamespaceCatalog(0)
+	spec := utils.Namespace{Name: "causal", Consistency: utils.Causal}
+	if err := c.applyNamespaceOp(catalogOp(1, utils.CreateNamespace, spec), utils.NewResponse()); err != nil {
+		t.Fatal(err)
+	}
+	for _, msg := range msgs {
+		if err := c.causal.CallRealOperation(msg, utils.NewResponse()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	c.causal.withKeyspaces(func(keyspaces map[string]*keyspace) {
+		if set := keyspaces["causal"].siblings["k"]; len(set.updates) != 3 {
+			t.Errorf("%d updates kept, want the 3 of the second round", len(set.updates))
+		}
+	})
+}
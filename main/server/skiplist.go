@@ -0,0 +1,129 @@
+package main
+
+import "math/rand/v2"
+
+const (
+	maxSkipLevel = 32 //livelli massimi della skiplist, sufficienti per ben oltre 2^32 chiavi
+	skipFraction = 4  //in media un nodo su skipFraction sale al livello successivo
+)
+
+// skipList è la struttura ordinata su cui si basa lo storage: le chiavi sono mantenute in ordine lessicografico,
+// in modo che le Scan possano visitare un intervallo senza scorrere tutte le chiavi. Non è thread safe: va
+// protetta dallo stesso mutex dello storage.
+type skipList struct {
+	head   *skipNode //nodo sentinella, senza chiave
+	level  int       //numero di livelli attualmente in uso
+	length int       //numero di chiavi
+}
+
+type skipNode struct {
+	key   string
+	value []byte
+	next  []*skipNode //successore a ogni livello
+}
+
+func newSkipList() *skipList {
+	return &skipList{head: &skipNode{next: make([]*skipNode, maxSkipLevel)}, level: 1}
+}
+
+// randomLevel estrae il numero di livelli di un nuovo nodo
+func randomLevel() int {
+	level := 1
+	for level < maxSkipLevel && rand.IntN(skipFraction) == 0 {
+		level++
+	}
+	return level
+}
+
+// seek ritorna il primo nodo con chiave >= key. Se update non è nil, vi salva per ogni livello l'ultimo nodo con
+// chiave < key, cioè quello da aggiornare per inserire o eliminare key.
+func (l *skipList) seek(key string, update []*skipNode) *skipNode {
+	node := l.head
+	for i := l.level - 1; i >= 0; i-- {
+		for node.next[i] != nil && node.next[i].key < key {
+			node = node.next[i]
+		}
+		if update != nil {
+			update[i] = node
+		}
+	}
+	return node.next[0]
+}
+
+// Get ritorna il valore di key e se la chiave è presente
+func (l *skipList) Get(key string) ([]byte, bool) {
+	node := l.seek(key, nil)
+	if node == nil || node.key != key {
+		return nil, false
+	}
+	return node.value, true
+}
+
+// Set inserisce key o ne sostituisce il valore
+func (l *skipList) Set(key string, value []byte) {
+	update := make([]*skipNode, maxSkipLevel)
+	node := l.seek(key, update)
+	if node != nil && node.key == key {
+		node.value = value
+		return
+	}
+
+	level := randomLevel()
+	for i := l.level; i < level; i++ {
+		update[i] = l.head
+	}
+	if level > l.level {
+		l.level = level
+	}
+	node = &skipNode{key: key, value: value, next: make([]*skipNode, level)}
+	for i := 0; i < level; i++ {
+		node.next[i] = update[i].next[i]
+		update[i].next[i] = node
+	}
+	l.length++
+}
+
+// Delete elimina key, ritornando false se la chiave non era presente
+func (l *skipList) Delete(key string) bool {
+	update := make([]*skipNode, maxSkipLevel)
+	node := l.seek(key, update)
+	if node == nil || node.key != key {
+		return false
+	}
+	for i := 0; i < len(node.next); i++ {
+		update[i].next[i] = node.next[i]
+	}
+	for l.level > 1 && l.head.next[l.level-1] == nil {
+		l.level--
+	}
+	l.length--
+	return true
+}
+
+// Len ritorna il numero di chiavi
+func (l *skipList) Len() int {
+	return l.length
+}
+
+// Ascend chiama fn in ordine per ogni chiave in [start, end), fermandosi quando fn ritorna false.
+// end vuoto indica un intervallo senza limite superiore.
+func (l *skipList) Ascend(start string, end string, fn func(key string, value []byte) bool) {
+	for node := l.seek(start, nil); node != nil; node = node.next[0] {
+		if end != "" && node.key >= end {
+			return
+		}
+		if !fn(node.key, node.value) {
+			return
+		}
+	}
+}
+
+// Clone ritorna una copia del contenuto come map
+func (l *skipList) Clone() map[string][]byte {
+	clone := make(map[string][]byte, l.length)
+	l.Ascend("", "", func(key string, value []byte) bool {
+		clone[key] = value
+		return true
+	})
+	return clone
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"testing"
+)
+
+// ascendKeys ritorna le chiavi visitate da Ascend in [start, end)
+func ascendKeys(l *skipList, start string, end string) []string {
+	var keys []string
+	l.Ascend(start, end, func(key string, value []byte) bool {
+		keys = append(keys, key)
+		return true
+	})
+	return keys
+}
+
+func TestSkipListSetGet(t *testing.T) {
+	l := newSkipList()
+	if _, ok := l.Get("a"); ok {
+		t.Fatal("Get on an empty list found a key")
+	}
+
+	l.Set("b", []byte("1"))
+	l.Set("a", []byte("2"))
+	l.Set("b", []byte("3")) //sostituisce il valore senza aggiungere una chiave
+	if l.Len() != 2 {
+		t.Fatalf("Len = %d, want 2", l.Len())
+	}
+	if value, ok := l.Get("b"); !ok || string(value) != "3" {
+		t.Fatalf("Get(b) = %q, %v, want \"3\", true", value, ok)
+	}
+	if value, ok := l.Get("a"); !ok || string(value) != "2" {
+		t.Fatalf("Get(a) = %q, %v, want \"2\", true", value, ok)
+	}
+	if _, ok := l.Get("c"); ok {
+		t.Fatal("Get(c) found a key that was never set")
+	}
+
+	//Una chiave vuota con valore vuoto è una chiave come le altre
+	l.Set("", []byte{})
+	if value, ok := l.Get(""); !ok || len(value) != 0 {
+		t.Fatalf("Get(\"\") = %q, %v, want empty value, true", value, ok)
+	}
+}
+
+func TestSkipListDelete(t *testing.T) {
+	l := newSkipList()
+	for _, key := range []string{"a", "b", "c"} {
+		l.Set(key, []byte(key))
+	}
+
+	if !l.Delete("b") {
+		t.Fatal("Delete(b) = false, want true")
+	}
+	if l.Delete("b") {
+		t.Fatal("second Delete(b) = true, want false")
+	}
+	if l.Delete("z") {
+		t.Fatal("Delete of a missing key = true, want false")
+	}
+	if l.Len() != 2 {
+		t.Fatalf("Len = %d, want 2", l.Len())
+	}
+	if got := ascendKeys(l, "", ""); !slices.Equal(got, []string{"a", "c"}) {
+		t.Fatalf("keys after Delete = %v, want [a c]", got)
+	}
+
+	l.Delete("a")
+	l.Delete("c")
+	if l.Len() != 0 || l.level != 1 || len(ascendKeys(l, "", "")) != 0 {
+		t.Fatalf("list not empty after deleting every key: Len = %d, level = %d", l.Len(), l.level)
+	}
+}
+
+func TestSkipListAscendBounds(t *testing.T) {
+	l := newSkipList()
+	for _, key := range []string{"d", "a", "c", "e", "b"} {
+		l.Set(key, []byte(key))
+	}
+
+	tests := []struct {
+		start, end string
+		want       []string
+	}{
+		{"", "", []string{"a", "b", "c", "d", "e"}},
+		{"b", "d", []string{"b", "c"}}, //start incluso, end escluso
+		{"bb", "", []string{"c", "d", "e"}},
+		{"", "c", []string{"a", "b"}},
+		{"c", "c", nil},
+		{"f", "", nil},
+		{"0", "a", nil},
+	}
+	for _, test := range tests {
+		if got := ascendKeys(l, test.start, test.end); !slices.Equal(got, test.want) {
+			t.Errorf("Ascend(%q, %q) = %v, want %v", test.start, test.end, got, test.want)
+		}
+	}
+
+	var visited []string
+	l.Ascend("", "", func(key string, value []byte) bool {
+		visited = append(visited, key)
+		return key != "b"
+	})
+	if !slices.Equal(visited, []string{"a", "b"}) {
+		t.Fatalf("Ascend did not stop when fn returned false: visited %v", visited)
+	}
+}
+
+func TestSkipListOrderWithManyKeys(t *testing.T) {
+	l := newSkipList()
+	want := make(map[string][]byte)
+	for i := 999; i >= 0; i-- {
+		key := fmt.Sprintf("key%03d", i)
+		l.Set(key, []byte(key))
+		want[key] = []byte(key)
+	}
+	for i := 0; i < 1000; i += 3 {
+		key := fmt.Sprintf("key%03d", i)
+		l.Delete(key)
+		delete(want, key)
+	}
+
+	keys := ascendKeys(l, "", "")
+	if !slices.IsSorted(keys) {
+		t.Fatal("Ascend did not visit the keys in order")
+	}
+	if len(keys) != len(want) || l.Len() != len(want) {
+		t.Fatalf("visited %d keys, Len = %d, want %d", len(keys), l.Len(), len(want))
+	}
+	if clone := l.Clone(); !maps.EqualFunc(clone, want, slices.Equal) {
+		t.Fatal("Clone does not match the keys that were set")
+	}
+}
+
+func TestSkipListClone(t *testing.T) {
+	l := newSkipList()
+	if clone := l.Clone(); clone == nil || len(clone) != 0 {
+		t.Fatalf("Clone of an empty list = %v, want an empty map", clone)
+	}
+
+	l.Set("a", []byte("1"))
+	l.Set("b", []byte("2"))
+	clone := l.Clone()
+	l.Set("c", []byte("3"))
+	l.Delete("a")
+
+	want := map[string][]byte{"a": []byte("1"), "b": []byte("2")}
+	if !maps.EqualFunc(clone, want, slices.Equal) {
+		t.Fatalf("Clone = %v, want %v: later changes to the list must not affect it", clone, want)
+	}
+}
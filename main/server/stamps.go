@@ -0,0 +1,52 @@
+package main
+
+import (
+	"SDCC/main/utils"
+	"fmt"
+	"sync"
+)
+
+// lamportClock è il clock di Lamport da cui gli storage replicati senza multicast (causale+, quorum) derivano le
+// versioni delle scritture
+type lamportClock struct {
+	mutex sync.Mutex
+	value int
+}
+
+// tick porta il clock oltre floor, lo incrementa e ritorna la versione di una nuova scrittura della replica index
+func (c *lamportClock) tick(index int, floor int) utils.Stamp {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.value = max(c.value, floor) + 1
+	return utils.Stamp{Clock: c.value, Origin: index}
+}
+
+// observe porta il clock almeno alla versione di una scrittura ricevuta
+func (c *lamportClock) observe(stamp utils.Stamp) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.value = max(c.value, stamp.Clock)
+}
+
+// applyStamped rende visibile la Put o la Delete del messaggio se la sua versione è più recente di quella corrente
+// della chiave (last-writer-wins), e notifica i Watch. Una Delete lascia la versione come tombstone. Ritorna false
+// se la scrittura è obsoleta. Va chiamata con il mapMutex dello storage già acquisito.
+func applyStamped(ks *keyspace, msg *utils.ReplicationMessage) bool {
+	key := msg.Args.Key
+	if current := ks.stamps[key]; !current.Less(msg.Stamp) {
+		fmt.Printf("%s of key %s is obsolete, version %s already applied\n", msg.OpType, key, current)
+		return false
+	}
+	ks.stamps[key] = msg.Stamp
+	if msg.OpType == utils.Delete {
+		if ks.remove(key) {
+			ks.publish(utils.WatchEvent{Namespace: ks.info.Name, OpType: utils.Delete, Key: key, ClockValue: msg.Stamp.Clock, Message: msg.UUID.String()})
+		}
+		fmt.Printf("Delete operation completed. Key: %s, Version: %s\n", key, msg.Stamp)
+		return true
+	}
+	ks.set(key, msg.Args.Value)
+	ks.publish(utils.WatchEvent{Namespace: ks.info.Name, OpType: utils.Put, Key: key, Value: msg.Args.Value, ClockValue: msg.Stamp.Clock, Message: msg.UUID.String()})
+	fmt.Printf("Put operation completed. Key: %s, Value: %s, Version: %s\n", key, msg.Args.Value, msg.Stamp)
+	return true
+}
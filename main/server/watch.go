@@ -0,0 +1,182 @@
+package main
+
+import (
+	"SDCC/main/utils"
+	"slices"
+	"sync"
+)
+
+// watchBuffer è il numero di eventi che un Watch può avere in attesa di invio prima di essere chiuso
+const watchBuffer = 256
+
+// Watchable è implementato dagli storage che notificano ai client le modifiche applicate alle chiavi. Gli storage
+// lo implementano tramite namespaceCatalog, che inoltra ogni Watch al watchHub del namespace osservato.
+type Watchable interface {
+	Subscribe(args utils.WatchArgs) (*watcher, error)
+	Unsubscribe(w *watcher)
+	CloseWatchers()
+}
+
+// watcher è un Watch aperto: riceve gli eventi su events, che viene chiuso quando il Watch termina
+type watcher struct {
+	args   utils.WatchArgs
+	hub    *watchHub //watchHub del namespace osservato
+	events chan utils.WatchEvent
+	err    error //motivo della chiusura di events (nil se chiuso con Unsubscribe)
+}
+
+// wants indica se il Watch osserva la chiave e se il client ha il permesso di leggerla
+func (w *watcher) wants(key string) bool {
+	return w.args.Matches(key) && utils.Authorize(w.args.Token, utils.Get, w.args.Namespace, key) == nil
+}
+
+// watchHub numera le modifiche applicate a un namespace, ne conserva le ultime Conf.WatchHistory e le inoltra ai
+// Watch aperti. Va incluso nei keyspace e alimentato con publish dalla CallRealOperation, che lo chiama
+// nell'ordine di consegna dei messaggi.
+type watchHub struct {
+	watchMutex sync.Mutex
+	revision   int                   //Revision dell'ultima modifica applicata
+	history    []utils.WatchEvent    //ultime modifiche, in ordine di Revision
+	compacted  utils.WatchEvent      //limite superiore delle modifiche scartate dallo storico (Revision, clock e punto di ripresa)
+	watchers   map[*watcher]struct{} //Watch aperti
+	closed     bool                  //true dopo CloseWatchers: la replica si sta spegnendo
+}
+
+// publish numera la modifica e la inoltra ai Watch interessati. Le modifiche di uno stesso messaggio vengono
+// pubblicate una dopo l'altra, quindi la posizione di ognuna segue quella della modifica precedente con lo stesso
+// Message. Un Watch che ha già watchBuffer eventi in attesa viene chiuso con ErrWatchLagging, per non bloccare la
+// consegna dei messaggi.
+func (h *watchHub) publish(event utils.WatchEvent) {
+	h.watchMutex.Lock()
+	defer h.watchMutex.Unlock()
+
+	h.revision++
+	event.Revision = h.revision
+	if n := len(h.history); n > 0 && event.Message != "" && h.history[n-1].Message == event.Message {
+		event.Position = h.history[n-1].Position + 1
+	}
+	h.history = append(h.history, event)
+	if len(h.history) > utils.Conf.WatchHistory {
+		h.compact(h.history[0])
+		h.history = slices.Delete(h.history, 0, 1)
+	}
+
+	for w := range h.watchers {
+		if !w.wants(event.Key) {
+			continue
+		}
+		select {
+		case w.events <- event:
+		default:
+			h.close(w, utils.ErrWatchLagging)
+		}
+	}
+}
+
+// compact registra che l'evento è stato scartato dallo storico
+func (h *watchHub) compact(event utils.WatchEvent) {
+	h.compacted.Revision = event.Revision
+	if event.After(h.compacted.ClockValue, h.compacted.Message, h.compacted.Position) {
+		h.compacted.ClockValue, h.compacted.Message, h.compacted.Position = event.ClockValue, event.Message, event.Position
+	}
+	if event.ClockVector != nil {
+		if h.compacted.ClockVector == nil {
+			h.compacted.ClockVector = make([]int, len(event.ClockVector))
+		}
+		for i, c := range event.ClockVector {
+			h.compacted.ClockVector[i] = max(h.compacted.ClockVector[i], c)
+		}
+	}
+}
+
+// Subscribe apre un Watch. Se args indica un punto di ripresa, le modifiche dello storico successive a quel punto
+// vengono inviate prima di quelle nuove; se alcune sono già state scartate dallo storico ritorna ErrCompacted.
+func (h *watchHub) Subscribe(args utils.WatchArgs) (*watcher, error) {
+	if err := utils.ValidateWatch(args); err != nil {
+		return nil, err
+	}
+	if _, err := utils.Authenticate(args.Token); err != nil {
+		return nil, err
+	}
+
+	h.watchMutex.Lock()
+	defer h.watchMutex.Unlock()
+	if h.closed {
+		return nil, utils.ErrShuttingDown
+	}
+
+	var replay []utils.WatchEvent
+	switch {
+	case args.AfterRevision > 0:
+		if args.AfterRevision < h.compacted.Revision {
+			return nil, utils.ErrCompacted
+		}
+		for _, event := range h.history {
+			if event.Revision > args.AfterRevision {
+				replay = append(replay, event)
+			}
+		}
+	case args.AfterClock > 0 || args.AfterMessage != "":
+		if h.compacted.After(args.AfterClock, args.AfterMessage, args.AfterPosition) {
+			return nil, utils.ErrCompacted
+		}
+		for _, event := range h.history {
+			if event.After(args.AfterClock, args.AfterMessage, args.AfterPosition) {
+				replay = append(replay, event)
+			}
+		}
+	case args.AfterVector != nil:
+		if !utils.VectorLessEqual(h.compacted.ClockVector, args.AfterVector) {
+			return nil, utils.ErrCompacted
+		}
+		for _, event := range h.history {
+			if !utils.VectorLessEqual(event.ClockVector, args.AfterVector) {
+				replay = append(replay, event)
+			}
+		}
+	}
+
+	w := &watcher{args: args, hub: h, events: make(chan utils.WatchEvent, len(replay)+watchBuffer)}
+	for _, event := range replay {
+		if w.wants(event.Key) {
+			w.events <- event
+		}
+	}
+	if h.watchers == nil {
+		h.watchers = make(map[*watcher]struct{})
+	}
+	h.watchers[w] = struct{}{}
+	return w, nil
+}
+
+// Unsubscribe chiude il Watch, se non è già stato chiuso
+func (h *watchHub) Unsubscribe(w *watcher) {
+	h.watchMutex.Lock()
+	defer h.watchMutex.Unlock()
+	h.close(w, nil)
+}
+
+// CloseWatchers chiude tutti i Watch con ErrShuttingDown e rifiuta quelli nuovi
+func (h *watchHub) CloseWatchers() {
+	h.closeWatchers(utils.ErrShuttingDown)
+}
+
+// closeWatchers chiude tutti i Watch con l'errore indicato e rifiuta quelli nuovi
+func (h *watchHub) closeWatchers(err error) {
+	h.watchMutex.Lock()
+	defer h.watchMutex.Unlock()
+	h.closed = true
+	for w := range h.watchers {
+		h.close(w, err)
+	}
+}
+
+// close va chiamata con watchMutex già acquisito
+func (h *watchHub) close(w *watcher, err error) {
+	if _, open := h.watchers[w]; !open {
+		return
+	}
+	delete(h.watchers, w)
+	w.err = err
+	close(w.events)
+}
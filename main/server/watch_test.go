@@ -0,0 +1,94 @@
+package main
+
+import (
+	"SDCC/main/utils"
+	"errors"
+	"slices"
+	"testing"
+)
+
+// replayedKeys apre un Watch sul prefisso vuoto con il punto di ripresa indicato e ritorna le chiavi degli eventi
+// dello storico che riceve
+func replayedKeys(t *testing.T, h *watchHub, args utils.WatchArgs) []string {
+	t.Helper()
+	args.Prefix = true
+	w, err := h.Subscribe(args)
+	if err != nil {
+		t.Fatalf("Subscribe(%+v) failed: %v", args, err)
+	}
+	defer h.Unsubscribe(w)
+	var keys []string
+	for len(w.events) > 0 {
+		keys = append(keys, (<-w.events).Key)
+	}
+	return keys
+}
+
+// publishSequence pubblica le modifiche di tre messaggi nell'ordine totale: un Batch di due chiavi e una Put con lo
+// stesso clock (con UUID maggiore), poi una Put con clock maggiore
+func publishSequence(h *watchHub) {
+	h.publish(utils.WatchEvent{Key: "a", ClockValue: 5, Message: "m1"})
+	h.publish(utils.WatchEvent{Key: "b", ClockValue: 5, Message: "m1"})
+	h.publish(utils.WatchEvent{Key: "c", ClockValue: 5, Message: "m2"})
+	h.publish(utils.WatchEvent{Key: "d", ClockValue: 6, Message: "m3"})
+}
+
+func TestWatchPositions(t *testing.T) {
+	h := &watchHub{}
+	publishSequence(h)
+	var positions []int
+	for _, event := range h.history {
+		positions = append(positions, event.Position)
+	}
+	if !slices.Equal(positions, []int{0, 1, 0, 0}) {
+		t.Fatalf("positions = %v, want [0 1 0 0]", positions)
+	}
+}
+
+func TestWatchResumeAfterClock(t *testing.T) {
+	h := &watchHub{}
+	publishSequence(h)
+
+	tests := []struct {
+		args utils.WatchArgs
+		want []string
+	}{
+		//Ripresa a metà del Batch: la seconda modifica dello stesso messaggio non va persa
+		{utils.WatchArgs{AfterClock: 5, AfterMessage: "m1", AfterPosition: 0}, []string{"b", "c", "d"}},
+		//Ripresa dopo il Batch: il messaggio con lo stesso clock e UUID maggiore non va perso
+		{utils.WatchArgs{AfterClock: 5, AfterMessage: "m1", AfterPosition: 1}, []string{"c", "d"}},
+		{utils.WatchArgs{AfterClock: 5, AfterMessage: "m2"}, []string{"d"}},
+		//Con il solo clock si ricevono anche le modifiche con quel clock
+		{utils.WatchArgs{AfterClock: 5}, []string{"a", "b", "c", "d"}},
+		{utils.WatchArgs{AfterClock: 6, AfterMessage: "m3"}, nil},
+		{utils.WatchArgs{AfterRevision: 2}, []string{"c", "d"}},
+		//Il clock 0 è un clock valido: il primo messaggio del multicast ha clock 0
+		{utils.WatchArgs{AfterClock: 0, AfterMessage: "m0"}, []string{"a", "b", "c", "d"}},
+	}
+	for _, test := range tests {
+		if got := replayedKeys(t, h, test.args); !slices.Equal(got, test.want) {
+			t.Errorf("resume %+v replayed %v, want %v", test.args, got, test.want)
+		}
+	}
+}
+
+func TestWatchResumeCompacted(t *testing.T) {
+	previous := utils.Conf
+	conf := *previous
+	conf.WatchHistory = 2
+	utils.Conf = &conf
+	defer func() { utils.Conf = previous }()
+
+	h := &watchHub{}
+	publishSequence(h) //scarta le due modifiche del Batch
+
+	if _, err := h.Subscribe(utils.WatchArgs{Prefix: true, AfterClock: 5, AfterMessage: "m1", AfterPosition: 0}); !errors.Is(err, utils.ErrCompacted) {
+		t.Fatalf("resume inside the compacted Batch: err = %v, want ErrCompacted", err)
+	}
+	if _, err := h.Subscribe(utils.WatchArgs{Prefix: true, AfterClock: 5}); !errors.Is(err, utils.ErrCompacted) {
+		t.Fatalf("resume before the compacted clock: err = %v, want ErrCompacted", err)
+	}
+	if got := replayedKeys(t, h, utils.WatchArgs{AfterClock: 5, AfterMessage: "m1", AfterPosition: 1}); !slices.Equal(got, []string{"c", "d"}) {
+		t.Fatalf("resume after the compacted Batch replayed %v, want [c d]", got)
+	}
+}
@@ -0,0 +1,165 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrAccessDenied viene restituito ai client autenticati che non hanno i permessi per l'operazione richiesta
+var ErrAccessDenied = errors.New("access denied")
+
+// Livelli di accesso di una regola, in ordine crescente di permessi
+const (
+	ReadOnly  = "read-only"  //solo Get
+	ReadWrite = "read-write" //Get, Put, Delete, Increment e Append
+	Admin     = "admin"      //tutte le operazioni, comprese quelle amministrative
+)
+
+var accessLevels = map[string]int{ReadOnly: 1, ReadWrite: 2, Admin: 3}
+
+// ACLRule concede all'identità indicata il livello di accesso Access sulle chiavi che iniziano con Prefix nel
+// namespace Namespace. L'identità "*" vale per tutti i client autenticati, il prefisso vuoto per tutte le chiavi e
+// il namespace vuoto per tutti i namespace.
+type ACLRule struct {
+	Identity  string `yaml:"identity"`
+	Namespace string `yaml:"namespace"`
+	Prefix    string `yaml:"prefix"`
+	Access    string `yaml:"access"`
+}
+
+// validateRules controlla le regole di accesso della configurazione
+func validateRules(rules []ACLRule) []error {
+	var errs []error
+	for i, rule := range rules {
+		if rule.Identity == "" {
+			errs = append(errs, fmt.Errorf("auth.rules[%d]: identity is required", i))
+		}
+		if rule.Namespace != "" {
+			if err := ValidateNamespaceName(rule.Namespace); err != nil {
+				errs = append(errs, fmt.Errorf("auth.rules[%d]: %w", i, err))
+			}
+		}
+		if _, ok := accessLevels[rule.Access]; !ok {
+			errs = append(errs, fmt.Errorf("auth.rules[%d]: access %q must be %s, %s or %s",
+				i, rule.Access, ReadOnly, ReadWrite, Admin))
+		}
+	}
+	return errs
+}
+
+// requiredAccess ritorna il livello di accesso necessario per eseguire op
+func requiredAccess(op string) string {
+	if op == Get || op == Guard || op == Scan {
+		return ReadOnly
+	}
+	if op == CreateNamespace || op == DropNamespace {
+		return Admin
+	}
+	return ReadWrite
+}
+
+// Authorize verifica che il client con il token indicato possa eseguire op sulla chiave key del namespace
+// indicato. Tra le regole che si applicano al client prevale quella con il prefisso più lungo; se nessuna si
+// applica l'accesso è negato. Senza regole configurate il controllo degli accessi è disabilitato.
+func Authorize(token string, op string, namespace string, key string) error {
+	identity, err := Authenticate(token)
+	if err != nil {
+		return err
+	}
+	return authorizeIdentity(identity, requiredAccess(op), op, NamespaceName(namespace), key)
+}
+
+// AuthorizeRequest verifica i permessi per tutte le chiavi toccate da una richiesta: per transazioni e Batch ogni
+// operazione viene controllata singolarmente
+func AuthorizeRequest(args Args, op string) error {
+	if op == Scan || op == End || op == ListNamespaces {
+		//L'intervallo di una Scan può attraversare regole diverse: qui basta che il client sia autenticato, le
+		//chiavi che non può leggere vengono escluse dalla replica che la esegue con ReadableKeys. L'End e l'elenco dei
+		//namespace non toccano chiavi.
+		_, err := Authenticate(args.Token)
+		return err
+	}
+	if op == CreateNamespace || op == DropNamespace {
+		//Le operazioni sui namespace richiedono il livello Admin sull'intero namespace (prefisso vuoto)
+		return Authorize(args.Token, op, args.NamespaceSpec.Name, "")
+	}
+	if op != Txn && op != Batch {
+		return Authorize(args.Token, op, args.Namespace, args.Key)
+	}
+	identity, err := Authenticate(args.Token)
+	if err != nil {
+		return err
+	}
+	namespace := NamespaceName(args.Namespace)
+	for _, txnOp := range args.Txn {
+		if err := authorizeIdentity(identity, requiredAccess(txnOp.OpType), txnOp.OpType, namespace, txnOp.Key); err != nil {
+			return err
+		}
+	}
+	for _, item := range args.Batch {
+		if err := authorizeIdentity(identity, requiredAccess(item.OpType), item.OpType, namespace, item.Args.Key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReadableKeys ritorna la funzione che indica se il client con il token indicato ha il permesso di leggere una
+// chiave del namespace indicato, usata dalle Scan per escludere le chiavi non leggibili
+func ReadableKeys(token string, namespace string) func(key string) bool {
+	identity, err := Authenticate(token)
+	if err != nil {
+		return func(string) bool { return false }
+	}
+	return func(key string) bool {
+		return authorizeIdentity(identity, ReadOnly, Scan, NamespaceName(namespace), key) == nil
+	}
+}
+
+func authorizeIdentity(identity string, required string, op string, namespace string, key string) error {
+	if len(Conf.Auth.Rules) == 0 {
+		return nil
+	}
+
+	var best *ACLRule
+	for i, rule := range Conf.Auth.Rules {
+		if rule.Identity != identity && rule.Identity != "*" {
+			continue
+		}
+		if rule.Namespace != "" && rule.Namespace != namespace {
+			continue
+		}
+		if !strings.HasPrefix(key, rule.Prefix) {
+			continue
+		}
+		if best == nil || moreSpecific(rule, *best) {
+			best = &Conf.Auth.Rules[i]
+		}
+	}
+
+	if best == nil || accessLevels[best.Access] < accessLevels[required] {
+		if key == "" {
+			return fmt.Errorf("%w: client %q cannot %s namespace %q", ErrAccessDenied, identity, op, namespace)
+		}
+		return fmt.Errorf("%w: client %q cannot %s key %q in namespace %q", ErrAccessDenied, identity, op, key, namespace)
+	}
+	return nil
+}
+
+// moreSpecific indica se la regola a prevale su b: conta prima il prefisso più lungo, poi la regola del singolo
+// namespace su quella valida per tutti, infine la regola specifica per l'identità su quella con "*"
+func moreSpecific(a ACLRule, b ACLRule) bool {
+	if len(a.Prefix) != len(b.Prefix) {
+		return len(a.Prefix) > len(b.Prefix)
+	}
+	if (a.Namespace != "") != (b.Namespace != "") {
+		return a.Namespace != ""
+	}
+	return a.Identity != "*" && b.Identity == "*"
+}
+
+// IsAccessDenied indica se err è un ErrAccessDenied, anche quando è stato ricevuto come stringa tramite net/rpc
+func IsAccessDenied(err error) bool {
+	return err != nil && (errors.Is(err, ErrAccessDenied) || strings.HasPrefix(err.Error(), ErrAccessDenied.Error()))
+}
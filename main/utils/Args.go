@@ -1,13 +1,64 @@
 package utils
 
+import "time"
+
 type Args struct {
+	Namespace     string //namespace della richiesta ("" = DefaultNamespace)
 	Key           string
-	Value         string
+	Value         []byte //valore binario qualsiasi, al più Conf.Limits.MaxValueSize byte
 	RequestNumber int
 	ClientIndex   int
+	Token         string //token di autenticazione del client (vuoto se l'autenticazione è disabilitata)
+
+	//Quantità sommata al contatore da una Increment (anche negativa). Una Append usa invece Value come suffisso.
+	Delta int64
+
+	//Aggiornamento di una chiave con valore CRDT (UpdateCRDT): il tipo del valore, Delta per i contatori, Value per
+	//i registri e come elemento dell'ORSet, che con CRDTRemove viene rimosso invece che aggiunto
+	CRDT       string
+	CRDTRemove bool
+
+	//Contesto di una Put o di una Delete in un namespace causale: il Context della Response della Get con cui il
+	//client ha letto i sibling della chiave, che la scrittura risolve (nil = solo le versioni già consegnate alla
+	//replica)
+	Context []int
+
+	//Clock di sessione del client: il SessionClock dell'ultima Response ricevuta, anche da un'altra replica. Nei
+	//namespace causali la replica serve la richiesta solo quando il proprio clock vettoriale lo raggiunge (nil =
+	//nessun vincolo)
+	SessionClock []int
+
+	//Dipendenze causali del client nei namespace causali+: le Dependencies dell'ultima Response ricevuta. La
+	//replica serve la richiesta solo quando sono tutte visibili, e una scrittura le porta con sé alle altre repliche
+	Dependencies []Dependency
+
+	//Condizione di una CompareAndSwap: la versione attesa (0 = la chiave non deve esistere) oppure, se
+	//CompareValue è true, il valore atteso
+	ExpectedVersion int
+	ExpectedValue   []byte
+	CompareValue    bool
+
+	//Durata di una Put dopo la quale la chiave viene eliminata (0 = nessuna scadenza). La scadenza non viene
+	//decisa dall'orologio di ogni replica ma da un'operazione Expire inviata tramite il multicast
+	TTL time.Duration
+
+	//Intervallo di una Scan: da Key (inclusa) a RangeEnd (esclusa, "" = fino all'ultima chiave), al più Limit chiavi
+	//(0 = DefaultScanLimit). Cursor, se presente, è il Cursor della Response della pagina precedente.
+	RangeEnd string
+	Limit    int
+	Cursor   string
+
+	Txn   []TxnOp     //operazioni di una transazione
+	Batch []BatchItem //operazioni di un Batch
+
+	NamespaceSpec Namespace //namespace da creare (CreateNamespace) o eliminare (DropNamespace, solo Name)
+
+	//Revisione del catalogo che ha creato il namespace della richiesta, indicata dalla replica che la riceve dal
+	//client: alla consegna la richiesta viene scartata se nel frattempo il namespace è stato eliminato e ricreato
+	NamespaceRevision int
 }
 
-func NewArg(key string, value string, requestNumber int, clientIndex int) *Args {
+func NewArg(key string, value []byte, requestNumber int, clientIndex int) *Args {
 	args := &Args{Key: key, Value: value, RequestNumber: requestNumber, ClientIndex: clientIndex}
 	return args
 }
@@ -0,0 +1,39 @@
+package utils
+
+import "fmt"
+
+// BatchItem è un'operazione di un Batch: OpType è Get, Put, Delete, Increment o Append e Args ne contiene chiave,
+// valore e incremento.
+// RequestNumber e ClientIndex degli Args degli elementi non vengono usati: il Batch occupa un solo turno FIFO,
+// quello della richiesta che lo contiene.
+type BatchItem struct {
+	OpType string
+	Args   Args
+}
+
+// BatchResult è il risultato di un elemento di un Batch
+type BatchResult struct {
+	Key     string
+	Value   []byte //valore letto dalle Get o scritto dalle Increment e dalle Append
+	Found   bool   //false se la chiave letta da una Get non esiste
+	Version int
+	Error   string //errore dell'operazione, vuoto se è andata a buon fine
+}
+
+// ValidateBatch controlla che il Batch sia ben formato prima di inviarlo alle altre repliche
+func ValidateBatch(items []BatchItem) error {
+	if len(items) == 0 {
+		return fmt.Errorf("%w: a batch needs at least one operation", ErrInvalidRequest)
+	}
+	for i, item := range items {
+		switch item.OpType {
+		case Get, Put, Delete, Increment, Append:
+		default:
+			return fmt.Errorf("%w: batch[%d]: unknown operation type %q", ErrInvalidRequest, i, item.OpType)
+		}
+		if item.Args.Key == "" {
+			return fmt.Errorf("%w: batch[%d]: key must not be empty", ErrInvalidRequest, i)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,44 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Tipi dei valori CRDT, utilizzabili nei namespace con consistenza causale
+const (
+	GCounter    = "GCounter"    //contatore che può solo crescere
+	PNCounter   = "PNCounter"   //contatore che può crescere e diminuire
+	ORSet       = "ORSet"       //insieme observed-remove: un'aggiunta concorrente a una rimozione prevale
+	LWWRegister = "LWWRegister" //registro last-writer-wins: tra scritture concorrenti ne prevale una sola
+	MVRegister  = "MVRegister"  //registro multi-valore: conserva tutte le scritture concorrenti
+)
+
+// ErrWrongType viene restituito per le operazioni su una chiave che contiene un valore di un altro tipo (per
+// esempio l'aggiornamento di un GCounter su una chiave che contiene un ORSet)
+var ErrWrongType = errors.New("key holds a value of another type")
+
+// CRDTValue è il valore di una chiave CRDT riportato dalla Get e da UpdateCRDT
+type CRDTValue struct {
+	Type    string
+	Counter int64    //valore dei contatori
+	Values  [][]byte //elementi di un ORSet (in ordine), valore di un LWWRegister o valori concorrenti di un MVRegister
+}
+
+// ValidateCRDT controlla un aggiornamento CRDT prima che venga inviato alle altre repliche
+func ValidateCRDT(args Args) error {
+	switch args.CRDT {
+	case GCounter:
+		if args.Delta < 0 {
+			return fmt.Errorf("%w: a %s can only be incremented, got delta %d", ErrInvalidRequest, GCounter, args.Delta)
+		}
+	case PNCounter, ORSet, LWWRegister, MVRegister:
+	default:
+		return fmt.Errorf("%w: crdt type must be %s, %s, %s, %s or %s, got %q",
+			ErrInvalidRequest, GCounter, PNCounter, ORSet, LWWRegister, MVRegister, args.CRDT)
+	}
+	if args.CRDTRemove && args.CRDT != ORSet {
+		return fmt.Errorf("%w: only an %s supports remove", ErrInvalidRequest, ORSet)
+	}
+	return nil
+}
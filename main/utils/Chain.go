@@ -0,0 +1,32 @@
+package utils
+
+import (
+	"errors"
+	"time"
+)
+
+// Chain è la consistenza dei namespace replicati a catena: le scritture entrano dalla testa e la percorrono fino
+// alla coda, che serve le letture
+const Chain = "Chain"
+
+// ErrChainUnavailable viene restituito quando una richiesta non riesce a percorrere la catena, per esempio durante
+// la sua riconfigurazione dopo un guasto: il client può ripetere la richiesta più tardi
+var ErrChainUnavailable = errors.New("chain is being reconfigured")
+
+// ChainConfig configura i namespace con consistenza Chain
+type ChainConfig struct {
+	Heartbeat      time.Duration `yaml:"heartbeat"`       //intervallo dei controlli tra la testa e le altre repliche
+	FailureTimeout time.Duration `yaml:"failure_timeout"` //tempo senza risposte dopo cui una replica è considerata guasta
+}
+
+// validate controlla la configurazione della catena
+func (c ChainConfig) validate() []error {
+	var errs []error
+	if c.Heartbeat <= 0 {
+		errs = append(errs, errors.New("chain.heartbeat: must be positive"))
+	}
+	if c.FailureTimeout < c.Heartbeat {
+		errs = append(errs, errors.New("chain.failure_timeout: must be at least chain.heartbeat"))
+	}
+	return errs
+}
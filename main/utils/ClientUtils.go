@@ -6,12 +6,16 @@ import (
 )
 
 var randomReplicaMutex = sync.Mutex{}
-var assignedServer = make([]bool, NumberOfReplicas)
+var assignedServer []bool
 
 func GetRandomReplica() int {
 	randomReplicaMutex.Lock() //thread safe
 	defer randomReplicaMutex.Unlock()
 
+	if assignedServer == nil { //il numero di repliche è noto solo dopo aver letto la configurazione
+		assignedServer = make([]bool, NumberOfReplicas)
+	}
+
 	// Lista dei possibili numeri di server non ancora assegnati
 	var availableReplicas []int
 	for i := 0; i < NumberOfReplicas; i++ {
@@ -0,0 +1,52 @@
+package utils
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+const (
+	ClockLamport = "lamport" //clock di Lamport scalare
+	ClockHybrid  = "hybrid"  //hybrid logical clock: tempo fisico in millisecondi e contatore logico
+)
+
+// hybridLogicalBits sono i bit meno significativi di un valore di clock ibrido, riservati al contatore logico: i
+// restanti contengono i millisecondi del tempo fisico, quindi l'ordine dei valori interi è quello dei clock
+const hybridLogicalBits = 16
+
+// NextClock ritorna il valore di clock di un nuovo messaggio inviato da una replica il cui clock vale current. Con
+// il clock di Lamport è current stesso; con il clock ibrido è il tempo fisico now, se è più avanti del clock,
+// altrimenti il clock con il contatore logico incrementato. In entrambi i casi il clock della replica diventa il
+// valore ritornato più uno.
+func NextClock(current int, now time.Time) int {
+	if Conf.Clock != ClockHybrid {
+		return current
+	}
+	return max(current, int(now.UnixMilli())<<hybridLogicalBits)
+}
+
+// ObserveClock ritorna il clock di una replica il cui clock vale current dopo la ricezione di un messaggio con
+// clock received. Con il clock ibrido il clock supera sempre quello del messaggio, come nella regola di ricezione
+// degli hybrid logical clock, quindi i messaggi inviati dopo la ricezione hanno un clock maggiore.
+func ObserveClock(current int, received int) int {
+	if Conf.Clock != ClockHybrid {
+		return max(current, received)
+	}
+	return max(current, received+1)
+}
+
+// HybridClock scompone un valore di clock ibrido nel tempo fisico e nel contatore logico
+func HybridClock(value int) (time.Time, int) {
+	return time.UnixMilli(int64(value >> hybridLogicalBits)).UTC(), value & (1<<hybridLogicalBits - 1)
+}
+
+// FormatClock ritorna il valore di clock di un messaggio nel formato dei log e degli header: il valore stesso con
+// il clock di Lamport, il tempo fisico e il contatore logico con il clock ibrido
+func FormatClock(value int) string {
+	if Conf.Clock != ClockHybrid {
+		return strconv.Itoa(value)
+	}
+	physical, logical := HybridClock(value)
+	return fmt.Sprintf("%s+%d", physical.Format("2006-01-02T15:04:05.000Z07:00"), logical)
+}
@@ -0,0 +1,462 @@
+package utils
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	Sequential = "Sequential"
+	Causal     = "Causal"
+
+	TransportRPC  = "rpc"  //net/rpc con codifica gob
+	TransportGRPC = "grpc" //gRPC con lo schema protobuf di kvspb
+)
+
+// Config raccoglie tutta la configurazione di server e client. Viene letta da un file YAML e può essere
+// sovrascritta dai flag da riga di comando.
+type Config struct {
+	Consistency   string              `yaml:"consistency"`   //"Sequential" o "Causal"
+	Peers         []Peer              `yaml:"peers"`         //tabella delle repliche (ID, indirizzo host:port)
+	Listen        string              `yaml:"listen"`        //indirizzo di ascolto del server (default: porta del proprio peer)
+	HTTPListen    string              `yaml:"http_listen"`   //indirizzo di ascolto del gateway HTTP (default: porta http del proprio peer)
+	GRPCListen    string              `yaml:"grpc_listen"`   //indirizzo di ascolto del server gRPC (default: porta grpc del proprio peer)
+	Transport     string              `yaml:"transport"`     //trasporto dei messaggi tra repliche e client: "rpc" o "grpc"
+	Clock         string              `yaml:"clock"`         //clock dei messaggi sequenziali: "lamport" o "hybrid"
+	Seed          int64               `yaml:"seed"`          //seed per la simulazione del ritardo di rete
+	SnapshotFile  string              `yaml:"snapshot_file"` //file in cui salvare lo storage allo spegnimento (vuoto = nessuno)
+	WatchHistory  int                 `yaml:"watch_history"` //modifiche conservate da ogni replica per riprendere i Watch
+	Timeouts      Timeouts            `yaml:"timeouts"`
+	Limits        Limits              `yaml:"limits"`
+	Quorum        QuorumConfig        `yaml:"quorum"`
+	PrimaryBackup PrimaryBackupConfig `yaml:"primary_backup"`
+	Chain         ChainConfig         `yaml:"chain"`
+	TLS           TLSConfig           `yaml:"tls"`
+	Auth          AuthConfig          `yaml:"auth"`
+	Client        ClientConfig        `yaml:"client"`
+}
+
+type Timeouts struct {
+	PollInterval     time.Duration `yaml:"poll_interval"`      //intervallo di polling delle condizioni di consegna
+	MinNetworkDelay  time.Duration `yaml:"min_network_delay"`  //ritardo di rete simulato minimo
+	MaxNetworkDelay  time.Duration `yaml:"max_network_delay"`  //ritardo di rete simulato massimo
+	Dial             time.Duration `yaml:"dial"`               //timeout di connessione verso le altre repliche
+	EndCheckInterval time.Duration `yaml:"end_check_interval"` //intervallo di controllo dei messaggi di End
+	Drain            time.Duration `yaml:"drain"`              //tempo massimo di attesa dei messaggi in coda allo spegnimento
+	HTTPRequest      time.Duration `yaml:"http_request"`       //tempo massimo di attesa di una richiesta al gateway HTTP
+	Session          time.Duration `yaml:"session"`            //attesa massima di una replica indietro rispetto alla sessione del client
+	Catalog          time.Duration `yaml:"catalog"`            //attesa massima della consegna di un'operazione sui namespace
+}
+
+type ClientConfig struct {
+	RandomReplica        bool          `yaml:"random_replica"`        //ogni client sceglie casualmente il server
+	Operation            int           `yaml:"operation"`             //test da eseguire senza prompt interattivo (0 = prompt)
+	KeepAlive            time.Duration `yaml:"keep_alive"`            //tempo per cui il client resta attivo dopo i test
+	Token                string        `yaml:"token"`                 //token con cui il client si autentica presso i server
+	Namespace            string        `yaml:"namespace"`             //namespace delle operazioni dei test ("" = DefaultNamespace)
+	NamespaceConsistency string        `yaml:"namespace_consistency"` //consistenza con cui il client crea client.namespace prima dei test ("" = nessuna creazione)
+}
+
+// Conf è la configurazione attiva del processo, impostata da SetConfig
+var Conf = DefaultConfig()
+
+// DefaultConfig ritorna la configurazione con i valori di default, equivalenti ai vecchi valori hard-coded
+func DefaultConfig() *Config {
+	return &Config{
+		Consistency:  Sequential,
+		Transport:    TransportRPC,
+		Clock:        ClockLamport,
+		Seed:         123456,
+		WatchHistory: 1000,
+		Limits: Limits{
+			MaxKeySize:   1024,
+			MaxValueSize: 1 << 20,
+		},
+		Quorum: QuorumConfig{
+			Timeout: 2 * time.Second,
+		},
+		PrimaryBackup: PrimaryBackupConfig{
+			Heartbeat:      500 * time.Millisecond,
+			FailureTimeout: 2 * time.Second,
+			Reads:          ReadFromBackup,
+			MaxStaleness:   time.Second,
+		},
+		Chain: ChainConfig{
+			Heartbeat:      500 * time.Millisecond,
+			FailureTimeout: 2 * time.Second,
+		},
+		Timeouts: Timeouts{
+			PollInterval:     250 * time.Millisecond,
+			MinNetworkDelay:  10 * time.Millisecond,
+			MaxNetworkDelay:  1000 * time.Millisecond,
+			Dial:             5 * time.Second,
+			EndCheckInterval: 3 * time.Second,
+			Drain:            10 * time.Second,
+			HTTPRequest:      30 * time.Second,
+			Session:          5 * time.Second,
+			Catalog:          10 * time.Second,
+		},
+	}
+}
+
+// LoadConfig legge il file YAML indicato partendo dalla configurazione di default
+func LoadConfig(path string) (*Config, error) {
+	conf := DefaultConfig()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read config file: %w", err)
+	}
+	if err = yaml.Unmarshal(data, conf); err != nil {
+		return nil, fmt.Errorf("cannot parse config file %s: %w", path, err)
+	}
+	return conf, nil
+}
+
+// Validate controlla la coerenza della configurazione, riportando tutti gli errori trovati
+func (c *Config) Validate() error {
+	var errs []error
+
+	if c.Consistency != Sequential && c.Consistency != Causal {
+		errs = append(errs, fmt.Errorf("consistency: must be %q or %q, got %q", Sequential, Causal, c.Consistency))
+	}
+	if len(c.Peers) == 0 {
+		errs = append(errs, errors.New("peers: at least one peer is required"))
+	}
+	seenIDs := make(map[string]bool)
+	seenIndexes := make(map[int]bool)
+	seenAddrs := make(map[string]bool)
+	for i, p := range c.Peers {
+		if p.ID == "" {
+			errs = append(errs, fmt.Errorf("peers[%d]: id is required", i))
+		} else if seenIDs[p.ID] {
+			errs = append(errs, fmt.Errorf("peers[%d]: duplicate id %q", i, p.ID))
+		}
+		seenIDs[p.ID] = true
+
+		switch {
+		case p.Index == nil:
+			errs = append(errs, fmt.Errorf("peers[%d]: index is required", i))
+		case *p.Index < 0 || *p.Index >= len(c.Peers):
+			errs = append(errs, fmt.Errorf("peers[%d]: index must be between 0 and %d, got %d", i, len(c.Peers)-1, *p.Index))
+		case seenIndexes[*p.Index]:
+			errs = append(errs, fmt.Errorf("peers[%d]: duplicate index %d", i, *p.Index))
+		default:
+			seenIndexes[*p.Index] = true
+		}
+
+		if _, _, err := net.SplitHostPort(p.Address); err != nil {
+			errs = append(errs, fmt.Errorf("peers[%d]: %q is not a valid host:port address", i, p.Address))
+		} else if seenAddrs[p.Address] {
+			errs = append(errs, fmt.Errorf("peers[%d]: duplicate address %q", i, p.Address))
+		}
+		seenAddrs[p.Address] = true
+
+		if p.HTTPAddress != "" {
+			if _, _, err := net.SplitHostPort(p.HTTPAddress); err != nil {
+				errs = append(errs, fmt.Errorf("peers[%d]: http_address %q is not a valid host:port address", i, p.HTTPAddress))
+			}
+		}
+		if p.GRPCAddress != "" {
+			if _, _, err := net.SplitHostPort(p.GRPCAddress); err != nil {
+				errs = append(errs, fmt.Errorf("peers[%d]: grpc_address %q is not a valid host:port address", i, p.GRPCAddress))
+			}
+		} else if c.Transport == TransportGRPC {
+			errs = append(errs, fmt.Errorf("peers[%d]: grpc_address is required when transport is %q", i, TransportGRPC))
+		}
+	}
+	if c.WatchHistory <= 0 {
+		errs = append(errs, errors.New("watch_history: must be positive"))
+	}
+	if c.Transport != TransportRPC && c.Transport != TransportGRPC {
+		errs = append(errs, fmt.Errorf("transport: must be %q or %q, got %q", TransportRPC, TransportGRPC, c.Transport))
+	}
+	if c.Clock != ClockLamport && c.Clock != ClockHybrid {
+		errs = append(errs, fmt.Errorf("clock: must be %q or %q, got %q", ClockLamport, ClockHybrid, c.Clock))
+	}
+	if c.Listen != "" {
+		if _, _, err := net.SplitHostPort(c.Listen); err != nil {
+			errs = append(errs, fmt.Errorf("listen: %q is not a valid host:port address", c.Listen))
+		}
+	}
+	if c.HTTPListen != "" {
+		if _, _, err := net.SplitHostPort(c.HTTPListen); err != nil {
+			errs = append(errs, fmt.Errorf("http_listen: %q is not a valid host:port address", c.HTTPListen))
+		}
+	}
+	if c.GRPCListen != "" {
+		if _, _, err := net.SplitHostPort(c.GRPCListen); err != nil {
+			errs = append(errs, fmt.Errorf("grpc_listen: %q is not a valid host:port address", c.GRPCListen))
+		}
+	}
+
+	t := c.Timeouts
+	if t.PollInterval <= 0 {
+		errs = append(errs, errors.New("timeouts.poll_interval: must be positive"))
+	}
+	if t.MinNetworkDelay < 0 || t.MaxNetworkDelay < t.MinNetworkDelay {
+		errs = append(errs, errors.New("timeouts: require 0 <= min_network_delay <= max_network_delay"))
+	}
+	if t.Dial <= 0 {
+		errs = append(errs, errors.New("timeouts.dial: must be positive"))
+	}
+	if t.EndCheckInterval <= 0 {
+		errs = append(errs, errors.New("timeouts.end_check_interval: must be positive"))
+	}
+	if t.Drain < 0 {
+		errs = append(errs, errors.New("timeouts.drain: must not be negative"))
+	}
+	if t.HTTPRequest <= 0 {
+		errs = append(errs, errors.New("timeouts.http_request: must be positive"))
+	}
+	if t.Session < 0 {
+		errs = append(errs, errors.New("timeouts.session: must not be negative"))
+	}
+	if t.Catalog <= 0 {
+		errs = append(errs, errors.New("timeouts.catalog: must be positive"))
+	}
+
+	if c.Limits.MaxKeySize <= 0 {
+		errs = append(errs, errors.New("limits.max_key_size: must be positive"))
+	}
+	if c.Limits.MaxValueSize <= 0 {
+		errs = append(errs, errors.New("limits.max_value_size: must be positive"))
+	}
+	errs = append(errs, c.Quorum.validate(len(c.Peers))...)
+	errs = append(errs, c.PrimaryBackup.validate()...)
+	errs = append(errs, c.Chain.validate()...)
+
+	if c.TLS.Enabled {
+		if c.TLS.CAFile == "" {
+			errs = append(errs, errors.New("tls.ca_file: required when tls is enabled"))
+		}
+		if c.TLS.CertFile == "" || c.TLS.KeyFile == "" {
+			errs = append(errs, errors.New("tls.cert_file, tls.key_file: required when tls is enabled"))
+		}
+	}
+	if len(c.Auth.Tokens) > 0 && !c.TLS.Enabled {
+		errs = append(errs, errors.New("auth.tokens: client tokens require tls to be enabled"))
+	}
+	if len(c.Auth.Rules) > 0 && len(c.Auth.Tokens) == 0 {
+		errs = append(errs, errors.New("auth.rules: access rules require client tokens"))
+	}
+	errs = append(errs, validateRules(c.Auth.Rules)...)
+	for token, identity := range c.Auth.Tokens {
+		if token == "" || identity == "" {
+			errs = append(errs, errors.New("auth.tokens: tokens and identities must not be empty"))
+			break
+		}
+	}
+
+	if c.Client.Operation < 0 || c.Client.Operation > 5 {
+		errs = append(errs, fmt.Errorf("client.operation: must be between 0 and 5, got %d", c.Client.Operation))
+	}
+	if c.Client.KeepAlive < 0 {
+		errs = append(errs, errors.New("client.keep_alive: must not be negative"))
+	}
+	if c.Client.Namespace != "" {
+		if err := ValidateNamespaceName(c.Client.Namespace); err != nil {
+			errs = append(errs, fmt.Errorf("client.namespace: %w", err))
+		}
+	}
+	if c.Client.NamespaceConsistency != "" {
+		err := ValidateNamespace(Namespace{Name: c.Client.Namespace, Consistency: c.Client.NamespaceConsistency})
+		if err != nil {
+			errs = append(errs, fmt.Errorf("client.namespace_consistency: %w", err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// ListenAddress ritorna l'indirizzo su cui il server di indice index deve mettersi in ascolto
+func (c *Config) ListenAddress(index int) string {
+	if c.Listen != "" {
+		return c.Listen
+	}
+	_, port, _ := net.SplitHostPort(Peers.Address(index))
+	return ":" + port
+}
+
+// HTTPListenAddress ritorna l'indirizzo su cui il gateway HTTP del server di indice index deve mettersi in
+// ascolto, oppure una stringa vuota se per questo server il gateway è disabilitato
+func (c *Config) HTTPListenAddress(index int) string {
+	if c.HTTPListen != "" {
+		return c.HTTPListen
+	}
+	httpAddress := Peers.Get(index).HTTPAddress
+	if httpAddress == "" {
+		return ""
+	}
+	_, port, _ := net.SplitHostPort(httpAddress)
+	return ":" + port
+}
+
+// GRPCListenAddress ritorna l'indirizzo su cui il server gRPC del server di indice index deve mettersi in
+// ascolto, oppure una stringa vuota se per questo server gRPC è disabilitato
+func (c *Config) GRPCListenAddress(index int) string {
+	if c.GRPCListen != "" {
+		return c.GRPCListen
+	}
+	grpcAddress := Peers.Get(index).GRPCAddress
+	if grpcAddress == "" {
+		return ""
+	}
+	_, port, _ := net.SplitHostPort(grpcAddress)
+	return ":" + port
+}
+
+// SetConfig rende attiva la configurazione per tutto il processo
+func SetConfig(c *Config) {
+	Conf = c
+	Peers = NewPeerTable(c.Peers)
+	NumberOfReplicas = Peers.Len()
+	initNetworkDelay(c.Seed)
+}
+
+// ParseConfig definisce i flag comuni a server e client, legge il file di configurazione e applica sopra di esso
+// i flag impostati esplicitamente. La configurazione risultante viene validata e resa attiva.
+func ParseConfig(fs *flag.FlagSet, args []string) error {
+	defaults := DefaultConfig()
+	configPath := fs.String("config", "config.yaml", "path of the YAML configuration file")
+	consistency := fs.String("consistency", "", "consistency model: Sequential or Causal")
+	peers := fs.String("peers", "", "comma separated list of replicas in the form id=host:port")
+	listen := fs.String("listen", "", "address the server listens on (default: port of its own peer entry)")
+	httpListen := fs.String("http-listen", "", "address of the HTTP gateway (default: http port of its own peer entry)")
+	grpcListen := fs.String("grpc-listen", "", "address of the gRPC server (default: grpc port of its own peer entry)")
+	transport := fs.String("transport", "", "transport between replicas and clients: rpc or grpc")
+	clock := fs.String("clock", "", "timestamp source of sequential messages: lamport or hybrid")
+	seed := fs.Int64("seed", defaults.Seed, "seed of the simulated network delay")
+	pollInterval := fs.Duration("poll-interval", defaults.Timeouts.PollInterval, "polling interval of the delivery conditions")
+	minDelay := fs.Duration("min-network-delay", defaults.Timeouts.MinNetworkDelay, "minimum simulated network delay")
+	maxDelay := fs.Duration("max-network-delay", defaults.Timeouts.MaxNetworkDelay, "maximum simulated network delay")
+	dialTimeout := fs.Duration("dial-timeout", defaults.Timeouts.Dial, "timeout for connections between replicas")
+	drainTimeout := fs.Duration("drain-timeout", defaults.Timeouts.Drain, "how long a stopping server waits for queued messages")
+	snapshotFile := fs.String("snapshot-file", "", "file where the server saves its store when it stops")
+	tlsEnabled := fs.Bool("tls", false, "enable TLS between replicas and clients")
+	token := fs.String("token", "", "token the client uses to authenticate")
+	randomReplica := fs.Bool("random-replica", false, "every client picks a random replica")
+	operation := fs.Int("op", 0, "test to run without the interactive prompt (0 = prompt)")
+	keepAlive := fs.Duration("keep-alive", 0, "how long the client stays up after the tests")
+	namespace := fs.String("namespace", "", "namespace of the client operations (default: the default namespace)")
+	namespaceConsistency := fs.String("namespace-consistency", "", "consistency of the namespace the client creates before the tests (default: no creation)")
+	quorumN := fs.Int("quorum-n", 0, "replicas holding each key of Quorum namespaces (0 = all)")
+	quorumR := fs.Int("quorum-r", 0, "replies needed by a read in Quorum namespaces (0 = majority)")
+	quorumW := fs.Int("quorum-w", 0, "acks needed by a write in Quorum namespaces (0 = majority)")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	set := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+
+	conf, err := LoadConfig(*configPath)
+	if err != nil {
+		//Il file è opzionale solo se non è stato richiesto esplicitamente
+		if set["config"] || !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		conf = DefaultConfig()
+	}
+
+	if set["consistency"] {
+		conf.Consistency = *consistency
+	}
+	if set["peers"] {
+		if conf.Peers, err = ParsePeers(*peers); err != nil {
+			return err
+		}
+	}
+	if set["listen"] {
+		conf.Listen = *listen
+	}
+	if set["http-listen"] {
+		conf.HTTPListen = *httpListen
+	}
+	if set["grpc-listen"] {
+		conf.GRPCListen = *grpcListen
+	}
+	if set["transport"] {
+		conf.Transport = *transport
+	}
+	if set["clock"] {
+		conf.Clock = *clock
+	}
+	if set["seed"] {
+		conf.Seed = *seed
+	}
+	if set["poll-interval"] {
+		conf.Timeouts.PollInterval = *pollInterval
+	}
+	if set["min-network-delay"] {
+		conf.Timeouts.MinNetworkDelay = *minDelay
+	}
+	if set["max-network-delay"] {
+		conf.Timeouts.MaxNetworkDelay = *maxDelay
+	}
+	if set["dial-timeout"] {
+		conf.Timeouts.Dial = *dialTimeout
+	}
+	if set["drain-timeout"] {
+		conf.Timeouts.Drain = *drainTimeout
+	}
+	if set["snapshot-file"] {
+		conf.SnapshotFile = *snapshotFile
+	}
+	if set["tls"] {
+		conf.TLS.Enabled = *tlsEnabled
+	}
+	if set["token"] {
+		conf.Client.Token = *token
+	}
+	if set["random-replica"] {
+		conf.Client.RandomReplica = *randomReplica
+	}
+	if set["op"] {
+		conf.Client.Operation = *operation
+	}
+	if set["keep-alive"] {
+		conf.Client.KeepAlive = *keepAlive
+	}
+	if set["namespace"] {
+		conf.Client.Namespace = *namespace
+	}
+	if set["namespace-consistency"] {
+		conf.Client.NamespaceConsistency = *namespaceConsistency
+	}
+	if set["quorum-n"] {
+		conf.Quorum.N = *quorumN
+	}
+	if set["quorum-r"] {
+		conf.Quorum.R = *quorumR
+	}
+	if set["quorum-w"] {
+		conf.Quorum.W = *quorumW
+	}
+
+	if err = conf.Validate(); err != nil {
+		return fmt.Errorf("invalid configuration:\n%w", err)
+	}
+	SetConfig(conf)
+	return nil
+}
+
+// ParseServerID risolve l'ID del server passato come argomento posizionale nella sua posizione nella tabella dei peer
+func ParseServerID(id string) (int, error) {
+	index, ok := Peers.IndexOf(id)
+	if !ok {
+		ids := make([]string, Peers.Len())
+		for i := range ids {
+			ids[i] = Peers.ID(i)
+		}
+		return 0, fmt.Errorf("unknown server id %q: configured peers are %s", id, strings.Join(ids, ", "))
+	}
+	return index, nil
+}
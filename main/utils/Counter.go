@@ -0,0 +1,42 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// ErrNotCounter viene restituito per le Increment su una chiave il cui valore non è un intero a 64 bit, o che
+// porterebbero il contatore oltre i limiti di un intero a 64 bit
+var ErrNotCounter = errors.New("value is not a 64-bit integer")
+
+// ModifiedValue calcola il valore scritto da una Increment o da una Append a partire dal valore corrente old della
+// chiave (exists è false se la chiave non esiste: vale 0 per una Increment e il valore vuoto per una Append).
+// Un contatore è memorizzato come intero decimale, quindi una Get ne legge il valore come testo.
+// Il calcolo dipende solo dal valore corrente, quindi tutte le repliche che lo eseguono sullo stesso valore
+// ottengono lo stesso risultato, oppure lo stesso errore.
+func ModifiedValue(op string, old []byte, exists bool, args Args) ([]byte, error) {
+	switch op {
+	case Increment:
+		var counter int64
+		if exists {
+			var err error
+			if counter, err = strconv.ParseInt(string(old), 10, 64); err != nil {
+				return nil, fmt.Errorf("%w: key %q has value %q", ErrNotCounter, args.Key, old)
+			}
+		}
+		if (args.Delta > 0 && counter > math.MaxInt64-args.Delta) || (args.Delta < 0 && counter < math.MinInt64-args.Delta) {
+			return nil, fmt.Errorf("%w: %d + %d overflows key %q", ErrNotCounter, counter, args.Delta, args.Key)
+		}
+		return strconv.AppendInt(nil, counter+args.Delta, 10), nil
+	case Append:
+		if len(old)+len(args.Value) > Conf.Limits.MaxValueSize {
+			return nil, fmt.Errorf("%w: appending %d bytes to key %q would exceed limits.max_value_size of %d bytes",
+				ErrTooLarge, len(args.Value), args.Key, Conf.Limits.MaxValueSize)
+		}
+		value := make([]byte, 0, len(old)+len(args.Value))
+		return append(append(value, old...), args.Value...), nil
+	}
+	return nil, fmt.Errorf("%w: %s does not modify a value", ErrInvalidRequest, op)
+}
@@ -0,0 +1,69 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrTooLarge viene restituito per le richieste con una chiave o un valore più grandi dei limiti configurati
+var ErrTooLarge = errors.New("request too large")
+
+// Limits sono le dimensioni massime, in byte, di chiavi e valori accettati dai server
+type Limits struct {
+	MaxKeySize   int `yaml:"max_key_size"`
+	MaxValueSize int `yaml:"max_value_size"`
+}
+
+// CheckLimits controlla le dimensioni di tutte le chiavi e di tutti i valori della richiesta, compresi quelli
+// delle transazioni, dei Batch e gli estremi di una Scan, prima che venga inviata alle altre repliche
+func CheckLimits(args Args) error {
+	if err := checkKey("key", args.Key); err != nil {
+		return err
+	}
+	if err := checkKey("range_end", args.RangeEnd); err != nil {
+		return err
+	}
+	if err := checkKey("cursor", args.Cursor); err != nil {
+		return err
+	}
+	if err := checkValue("value", args.Value); err != nil {
+		return err
+	}
+	if err := checkValue("expected_value", args.ExpectedValue); err != nil {
+		return err
+	}
+	for i, op := range args.Txn {
+		if err := checkKey(fmt.Sprintf("txn[%d].key", i), op.Key); err != nil {
+			return err
+		}
+		if err := checkValue(fmt.Sprintf("txn[%d].value", i), op.Value); err != nil {
+			return err
+		}
+		if err := checkValue(fmt.Sprintf("txn[%d].expected_value", i), op.ExpectedValue); err != nil {
+			return err
+		}
+	}
+	for i, item := range args.Batch {
+		if err := checkKey(fmt.Sprintf("batch[%d].key", i), item.Args.Key); err != nil {
+			return err
+		}
+		if err := checkValue(fmt.Sprintf("batch[%d].value", i), item.Args.Value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func checkKey(field string, key string) error {
+	if len(key) > Conf.Limits.MaxKeySize {
+		return fmt.Errorf("%w: %s of %d bytes exceeds limits.max_key_size of %d bytes", ErrTooLarge, field, len(key), Conf.Limits.MaxKeySize)
+	}
+	return nil
+}
+
+func checkValue(field string, value []byte) error {
+	if len(value) > Conf.Limits.MaxValueSize {
+		return fmt.Errorf("%w: %s of %d bytes exceeds limits.max_value_size of %d bytes", ErrTooLarge, field, len(value), Conf.Limits.MaxValueSize)
+	}
+	return nil
+}
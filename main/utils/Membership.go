@@ -0,0 +1,81 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// ErrShuttingDown viene restituito ai client che inviano richieste a un server in fase di spegnimento
+var ErrShuttingDown = errors.New("server is shutting down")
+
+// LeaveArgs è il messaggio con cui una replica comunica alle altre che sta lasciando il cluster
+type LeaveArgs struct {
+	ServerID string
+}
+
+var leftPeersMutex sync.Mutex
+var leftPeers = make(map[int]bool)
+
+// MarkPeerLeft registra che la replica in posizione index ha lasciato il cluster: da questo momento non le
+// verranno più inviati messaggi e non si attenderanno più suoi ack o suoi messaggi
+func MarkPeerLeft(index int) {
+	leftPeersMutex.Lock()
+	defer leftPeersMutex.Unlock()
+	leftPeers[index] = true
+}
+
+// HasPeerLeft indica se la replica in posizione index ha lasciato il cluster
+func HasPeerLeft(index int) bool {
+	leftPeersMutex.Lock()
+	defer leftPeersMutex.Unlock()
+	return leftPeers[index]
+}
+
+// ActiveReplicas ritorna il numero di repliche che non hanno lasciato il cluster
+func ActiveReplicas() int {
+	leftPeersMutex.Lock()
+	defer leftPeersMutex.Unlock()
+	return NumberOfReplicas - len(leftPeers)
+}
+
+// activePeers ritorna le posizioni delle repliche a cui vanno ancora inviati i messaggi
+func activePeers() []int {
+	leftPeersMutex.Lock()
+	defer leftPeersMutex.Unlock()
+	var peers []int
+	for i := 0; i < NumberOfReplicas; i++ {
+		if !leftPeers[i] {
+			peers = append(peers, i)
+		}
+	}
+	return peers
+}
+
+// NotifyLeaving invia a tutte le altre repliche attive la notifica di uscita dal cluster della replica index.
+// service è il nome con cui è registrato lo storage ("sequential" o "causal").
+func NotifyLeaving(service string, index int) {
+	var wg sync.WaitGroup
+	for _, i := range activePeers() {
+		if i == index {
+			continue
+		}
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			conn, err := DialReplica(i)
+			if err != nil {
+				fmt.Println("Failed to connect to server", Peers.ID(i), "to notify leave:", err)
+				return
+			}
+			defer conn.Close()
+			err = conn.Call(service+".PeerLeaving", LeaveArgs{ServerID: Peers.ID(index)}, NewResponse())
+			if err != nil {
+				fmt.Println("Failed to notify leave to server", Peers.ID(i), "with error: ", err)
+				return
+			}
+			fmt.Printf("\033[35mNotified server %s that %s is leaving\033[0m\n", Peers.ID(i), Peers.ID(index))
+		}(i)
+	}
+	wg.Wait()
+}
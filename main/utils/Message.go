@@ -1,6 +1,7 @@
 package utils
 
 import (
+	"errors"
 	"fmt"
 	"github.com/google/uuid"
 	"slices"
@@ -9,14 +10,26 @@ import (
 )
 
 const (
-	Get         = "Get"
-	Put         = "Put"
-	Delete      = "Delete"
-	EndKey      = "EndKey"
-	EndValue    = "EndValue"
-	KeyNotFound = "KeyNotFound"
+	Get            = "Get"
+	Put            = "Put"
+	Delete         = "Delete"
+	Increment      = "Increment"      //somma Args.Delta al contatore della chiave, applicata alla consegna
+	Append         = "Append"         //accoda Args.Value al valore della chiave, applicata alla consegna
+	UpdateCRDT     = "UpdateCRDT"     //aggiornamento di una chiave con valore CRDT (consistenza causale)
+	CompareAndSwap = "CompareAndSwap" //scrive il valore solo se la versione (o il valore) della chiave è quella attesa
+	Txn            = "Txn"            //transazione con più operazioni, applicata atomicamente alla consegna
+	Batch          = "Batch"          //più operazioni indipendenti inviate come un unico messaggio
+	Expire         = "Expire"         //eliminazione di una chiave allo scadere del TTL, inviata da una replica
+	Scan           = "Scan"           //lettura ordinata di un intervallo di chiavi (anche per le ScanPrefix)
+	End            = "End"            //fine delle operazioni di un client, usata dai test per svuotare le code
 )
 
+// ErrNotSupported viene restituito per le operazioni che la consistenza configurata non può garantire
+var ErrNotSupported = errors.New("operation not supported")
+
+// ErrInvalidRequest viene restituito per le richieste malformate, prima che vengano inviate alle altre repliche
+var ErrInvalidRequest = errors.New("invalid request")
+
 type Message struct {
 	Args             Args         //Args della richiesta
 	ClockValue       int          //clock logico scalare
@@ -29,7 +42,7 @@ type Message struct {
 
 type MessageNA struct {
 	Args             Args      //Args della richiesta
-	ClockValue       int       //clock logico scalare
+	ClockValue       int       //clock logico scalare: di Lamport o ibrido, secondo Conf.Clock
 	UUID             uuid.UUID //unique identifier del messaggio
 	ServerIndex      int
 	ServerMsgCounter int
@@ -96,7 +109,7 @@ func (mq *MessageQueue) InsertAndSort(message *Message, alreadyLocked ...bool) *
 	fmt.Print("\033[33mCurrent message queue composition:\033[0m\n") // Giallo scuro per intestazione
 
 	for _, m := range mq.Queue {
-		fmt.Printf("UUID: %s, OpType: %s, Acks: %d, originatingServer: %d, CLOCK: %d\n", m.UUID, m.OpType, m.Acks.Load(), m.ServerIndex, m.ClockValue)
+		fmt.Printf("UUID: %s, OpType: %s, Acks: %d, originatingServer: %d, CLOCK: %s\n", m.UUID, m.OpType, m.Acks.Load(), m.ServerIndex, FormatClock(m.ClockValue))
 	}
 
 	// Stampa l'UUID del messaggio che stiamo controllando
@@ -130,7 +143,7 @@ func (mq *MessageQueue) Pop(uuid uuid.UUID) error {
 	fmt.Print("\033[33mCurrent message queue composition after POP:\033[0m\n") // Giallo scuro per intestazione
 
 	for _, m := range mq.Queue {
-		fmt.Printf("UUID: %s, OpType: %s, Acks: %d, originatingServer: %d, CLOCK: %d\n", m.UUID, m.OpType, m.Acks.Load(), m.ServerIndex, m.ClockValue)
+		fmt.Printf("UUID: %s, OpType: %s, Acks: %d, originatingServer: %d, CLOCK: %s\n", m.UUID, m.OpType, m.Acks.Load(), m.ServerIndex, FormatClock(m.ClockValue))
 	}
 
 	// Stampa l'UUID del messaggio che stiamo controllando
@@ -0,0 +1,94 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// DefaultNamespace è il namespace delle richieste che non ne indicano uno: esiste sempre, ha la consistenza del
+// cluster e non può essere eliminato
+const DefaultNamespace = "default"
+
+const (
+	CreateNamespace = "CreateNamespace" //creazione di un namespace, ordinata dal sequencer del catalogo
+	DropNamespace   = "DropNamespace"   //eliminazione di un namespace e di tutte le sue chiavi
+	ListNamespaces  = "ListNamespaces"  //elenco dei namespace, letto dalla replica che riceve la richiesta
+)
+
+// Catalog è il protocollo dei ReplicationMessage con cui il sequencer del catalogo ordina creazioni ed
+// eliminazioni dei namespace di tutte le consistenze
+const Catalog = "Catalog"
+
+// NamespaceConsistencies sono le consistenze che si possono indicare alla creazione di un namespace
+var NamespaceConsistencies = []string{Sequential, Causal, CausalPlus, Quorum, PrimaryBackup, Chain}
+
+// MaxNamespaceName è la lunghezza massima del nome di un namespace
+const MaxNamespaceName = 64
+
+// ErrNamespaceNotFound viene restituito per le richieste su un namespace che non esiste
+var ErrNamespaceNotFound = errors.New("namespace not found")
+
+// ErrNamespaceExists viene restituito alla creazione di un namespace già esistente
+var ErrNamespaceExists = errors.New("namespace already exists")
+
+// ErrCatalogUnavailable viene restituito quando un'operazione sui namespace non raggiunge il sequencer del catalogo
+// o tutte le repliche entro timeouts.catalog: il client può ripetere la richiesta più tardi
+var ErrCatalogUnavailable = errors.New("namespace catalog is unavailable")
+
+// ErrQuotaExceeded viene restituito per le scritture che porterebbero un namespace oltre la propria quota
+var ErrQuotaExceeded = errors.New("namespace quota exceeded")
+
+// Namespace descrive un namespace: ognuno ha le proprie chiavi, le proprie quote e la propria consistenza
+type Namespace struct {
+	Name        string
+	Consistency string //una delle NamespaceConsistencies ("" alla creazione = la consistenza del cluster)
+	MaxKeys     int    //numero massimo di chiavi (0 = nessun limite)
+	MaxBytes    int    //dimensione massima della somma di chiavi e valori, in byte (0 = nessun limite)
+	Revision    int    //revisione del catalogo che ha creato il namespace (0 = "default")
+
+	//Utilizzo corrente, riportato da ListNamespaces
+	Keys  int
+	Bytes int
+}
+
+// NamespaceName ritorna il nome del namespace di una richiesta, DefaultNamespace se non è indicato
+func NamespaceName(name string) string {
+	if name == "" {
+		return DefaultNamespace
+	}
+	return name
+}
+
+// ValidateNamespaceName controlla il nome di un namespace: da 1 a MaxNamespaceName caratteri tra lettere
+// minuscole, cifre, '-', '_' e '.'
+func ValidateNamespaceName(name string) error {
+	if name == "" || len(name) > MaxNamespaceName {
+		return fmt.Errorf("%w: namespace name must be between 1 and %d characters", ErrInvalidRequest, MaxNamespaceName)
+	}
+	for _, c := range name {
+		if (c < 'a' || c > 'z') && (c < '0' || c > '9') && c != '-' && c != '_' && c != '.' {
+			return fmt.Errorf("%w: namespace name %q may only contain lowercase letters, digits, '-', '_' and '.'",
+				ErrInvalidRequest, name)
+		}
+	}
+	return nil
+}
+
+// ValidateNamespace controlla la richiesta di creazione di un namespace
+func ValidateNamespace(spec Namespace) error {
+	if err := ValidateNamespaceName(spec.Name); err != nil {
+		return err
+	}
+	if spec.Name == DefaultNamespace {
+		return fmt.Errorf("%w: %q", ErrNamespaceExists, DefaultNamespace)
+	}
+	if spec.Consistency != "" && !slices.Contains(NamespaceConsistencies, spec.Consistency) {
+		return fmt.Errorf("%w: consistency must be one of %s, got %q", ErrInvalidRequest, strings.Join(NamespaceConsistencies, ", "), spec.Consistency)
+	}
+	if spec.MaxKeys < 0 || spec.MaxBytes < 0 {
+		return fmt.Errorf("%w: namespace quotas must not be negative", ErrInvalidRequest)
+	}
+	return nil
+}
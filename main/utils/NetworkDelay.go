@@ -2,20 +2,30 @@ package utils
 
 import (
 	"math/rand"
+	"sync"
 	"time"
 )
 
 var r *rand.Rand
-var SEED = int64(123456)
+var rMutex sync.Mutex //rand.Rand non è thread safe
 
 func init() {
-	r = rand.New(rand.NewSource(SEED))
+	initNetworkDelay(Conf.Seed)
+}
+
+func initNetworkDelay(seed int64) {
+	rMutex.Lock()
+	defer rMutex.Unlock()
+	r = rand.New(rand.NewSource(seed))
 }
 
 func NetworkDelay() {
 
-	// Genera un tempo randomico tra 10 e 1000 millisecondi
-	sleepTime := time.Duration(r.Intn(991)+10) * time.Millisecond
+	// Genera un tempo randomico tra il ritardo minimo e massimo configurati
+	minDelay, maxDelay := Conf.Timeouts.MinNetworkDelay, Conf.Timeouts.MaxNetworkDelay
+	rMutex.Lock()
+	sleepTime := minDelay + time.Duration(r.Int63n(int64(maxDelay-minDelay)+1))
+	rMutex.Unlock()
 
 	// Effettua la sleep
 	time.Sleep(sleepTime)
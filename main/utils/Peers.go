@@ -0,0 +1,84 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Peer identifica una replica: l'ID è stabile e scelto in configurazione, l'indirizzo è host:port. L'indice è la
+// posizione della replica nella tabella, scritto esplicitamente in configurazione per ogni ID.
+type Peer struct {
+	ID          string `yaml:"id"`
+	Index       *int   `yaml:"index"`
+	Address     string `yaml:"address"`
+	HTTPAddress string `yaml:"http_address"` //indirizzo del gateway HTTP (vuoto = gateway disabilitato)
+	GRPCAddress string `yaml:"grpc_address"` //indirizzo del server gRPC (vuoto = gRPC disabilitato)
+}
+
+// PeerTable è la tabella delle repliche del cluster. Ogni replica occupa la posizione indicata dal proprio indice
+// (usata per esempio come componente del clock vettoriale), quindi la posizione è la stessa su tutti i processi e
+// non cambia aggiungendo repliche, qualunque sia il loro ID o l'ordine in cui sono scritte nel file di
+// configurazione.
+type PeerTable struct {
+	peers []Peer
+	byID  map[string]int
+}
+
+// Peers è la tabella delle repliche attiva, impostata da SetConfig
+var Peers = NewPeerTable(nil)
+
+// NewPeerTable crea la tabella a partire dalla lista di peer della configurazione, già validata: gli indici sono
+// distinti e vanno da 0 al numero di repliche meno uno
+func NewPeerTable(peers []Peer) *PeerTable {
+	table := &PeerTable{peers: make([]Peer, len(peers)), byID: make(map[string]int)}
+	for _, p := range peers {
+		table.peers[*p.Index] = p
+		table.byID[p.ID] = *p.Index
+	}
+	return table
+}
+
+// Len ritorna il numero di repliche
+func (t *PeerTable) Len() int {
+	return len(t.peers)
+}
+
+// Get ritorna la replica in posizione index
+func (t *PeerTable) Get(index int) Peer {
+	return t.peers[index]
+}
+
+// Address ritorna l'indirizzo della replica in posizione index
+func (t *PeerTable) Address(index int) string {
+	if index < 0 || index >= len(t.peers) {
+		fmt.Printf("[ERROR] Requested a server outside the peer table: requested index %d but Replicas = %d\n", index, len(t.peers))
+		return ""
+	}
+	return t.peers[index].Address
+}
+
+// ID ritorna l'ID della replica in posizione index
+func (t *PeerTable) ID(index int) string {
+	return t.peers[index].ID
+}
+
+// IndexOf ritorna la posizione della replica con l'ID indicato
+func (t *PeerTable) IndexOf(id string) (int, bool) {
+	index, ok := t.byID[id]
+	return index, ok
+}
+
+// ParsePeers interpreta una lista di peer nel formato "id=host:port,id=host:port". L'indice di ogni replica è la
+// sua posizione nella lista.
+func ParsePeers(s string) ([]Peer, error) {
+	var peers []Peer
+	for i, entry := range strings.Split(s, ",") {
+		id, addr, ok := strings.Cut(entry, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid peer %q: expected id=host:port", entry)
+		}
+		index := i
+		peers = append(peers, Peer{ID: strings.TrimSpace(id), Index: &index, Address: strings.TrimSpace(addr)})
+	}
+	return peers, nil
+}
@@ -0,0 +1,53 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// PrimaryBackup è la consistenza dei namespace replicati con un primario, che ordina tutte le scritture e le
+// inoltra in ordine alle repliche di backup
+const PrimaryBackup = "PrimaryBackup"
+
+const (
+	Heartbeat = "Heartbeat" //controllo periodico del primario da parte dei backup (primary-backup, catena)
+	Sync      = "Sync"      //trasferimento dello stato del primario a un backup (primary-backup, catena)
+	Promise   = "Promise"   //richiesta di non accettare più messaggi di view precedenti a quella proposta
+	StartView = "StartView" //promozione a primario, o a testa della catena, della replica scelta da chi ha raccolto le promesse
+)
+
+const (
+	ReadFromPrimary = "primary" //i backup inoltrano tutte le letture al primario
+	ReadFromBackup  = "backup"  //i backup servono le letture entro primary_backup.max_staleness
+)
+
+// ErrNotPrimary viene restituito quando la replica a cui è stata inoltrata una richiesta non è il primario, per
+// esempio durante la promozione di un backup: il client può ripetere la richiesta più tardi
+var ErrNotPrimary = errors.New("replica is not the primary")
+
+// PrimaryBackupConfig configura i namespace con consistenza PrimaryBackup
+type PrimaryBackupConfig struct {
+	Heartbeat      time.Duration `yaml:"heartbeat"`       //intervallo dei controlli dei backup verso il primario
+	FailureTimeout time.Duration `yaml:"failure_timeout"` //tempo senza risposte dopo cui il primario è considerato guasto
+	Reads          string        `yaml:"reads"`           //"primary" o "backup": dove vengono servite le letture ricevute dai backup
+	MaxStaleness   time.Duration `yaml:"max_staleness"`   //ritardo massimo di un backup che serve una lettura (0 = nessun limite)
+}
+
+// validate controlla la configurazione del primary-backup
+func (p PrimaryBackupConfig) validate() []error {
+	var errs []error
+	if p.Heartbeat <= 0 {
+		errs = append(errs, errors.New("primary_backup.heartbeat: must be positive"))
+	}
+	if p.FailureTimeout < p.Heartbeat {
+		errs = append(errs, errors.New("primary_backup.failure_timeout: must be at least primary_backup.heartbeat"))
+	}
+	if p.Reads != ReadFromPrimary && p.Reads != ReadFromBackup {
+		errs = append(errs, fmt.Errorf("primary_backup.reads: must be %q or %q, got %q", ReadFromPrimary, ReadFromBackup, p.Reads))
+	}
+	if p.MaxStaleness < 0 {
+		errs = append(errs, errors.New("primary_backup.max_staleness: must not be negative"))
+	}
+	return errs
+}
@@ -0,0 +1,90 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"time"
+)
+
+// Quorum è la consistenza dei namespace replicati con quorum di lettura e scrittura: ogni chiave è conservata da N
+// repliche, una scrittura termina quando W di esse l'hanno applicata e una lettura quando R hanno risposto
+const Quorum = "Quorum"
+
+// ErrQuorumUnavailable viene restituito quando troppe repliche della chiave non rispondono per formare il quorum:
+// il client può ripetere la richiesta più tardi
+var ErrQuorumUnavailable = errors.New("not enough replicas available for the quorum")
+
+// QuorumConfig configura i namespace con consistenza Quorum. I valori 0 indicano i default: N pari al numero di
+// repliche, R e W pari alla maggioranza di N. Solo con R+W>N ogni lettura vede l'ultima scrittura confermata.
+type QuorumConfig struct {
+	N       int           `yaml:"n"`       //repliche che conservano ogni chiave
+	R       int           `yaml:"r"`       //risposte necessarie per una lettura
+	W       int           `yaml:"w"`       //conferme necessarie per una scrittura
+	Timeout time.Duration `yaml:"timeout"` //attesa massima delle risposte necessarie al quorum
+}
+
+// Sizes ritorna N, R e W effettivi, applicando i default
+func (q QuorumConfig) Sizes() (n, r, w int) {
+	n, r, w = q.N, q.R, q.W
+	if n == 0 {
+		n = NumberOfReplicas
+	}
+	if r == 0 {
+		r = n/2 + 1
+	}
+	if w == 0 {
+		w = n/2 + 1
+	}
+	return n, r, w
+}
+
+// validate controlla la configurazione rispetto al numero di repliche della tabella dei peer. Quorum che non si
+// intersecano (R+W<=N) sono ammessi, ma una lettura potrebbe non vedere l'ultima scrittura confermata: viene
+// stampato un avviso.
+func (q QuorumConfig) validate(replicas int) []error {
+	var errs []error
+	n := q.N
+	if n == 0 {
+		n = replicas
+	}
+	if q.N < 0 || n > replicas {
+		errs = append(errs, fmt.Errorf("quorum.n: must be between 1 and the number of peers (%d), or 0 for all of them, got %d", replicas, q.N))
+	}
+	if q.R < 0 || q.R > n {
+		errs = append(errs, fmt.Errorf("quorum.r: must be between 1 and quorum.n (%d), or 0 for a majority, got %d", n, q.R))
+	}
+	if q.W < 0 || q.W > n {
+		errs = append(errs, fmt.Errorf("quorum.w: must be between 1 and quorum.n (%d), or 0 for a majority, got %d", n, q.W))
+	}
+	r, w := q.R, q.W
+	if r == 0 {
+		r = n/2 + 1
+	}
+	if w == 0 {
+		w = n/2 + 1
+	}
+	if r+w <= n {
+		fmt.Printf("\033[33mWARNING: quorum.r + quorum.w (%d + %d) is not greater than quorum.n (%d): reads may miss the latest write\033[0m\n", r, w, n)
+	}
+	if q.Timeout <= 0 {
+		errs = append(errs, errors.New("quorum.timeout: must be positive"))
+	}
+	return errs
+}
+
+// PreferenceList ritorna gli indici delle N repliche che conservano la chiave key del namespace namespace: quella
+// scelta dall'hash della chiave e le successive nell'ordine della tabella dei peer
+func PreferenceList(namespace string, key string, n int) []int {
+	h := fnv.New32a()
+	h.Write([]byte(namespace))
+	h.Write([]byte{0})
+	h.Write([]byte(key))
+	first := int(h.Sum32() % uint32(NumberOfReplicas))
+
+	replicas := make([]int, n)
+	for i := range replicas {
+		replicas[i] = (first + i) % NumberOfReplicas
+	}
+	return replicas
+}
@@ -0,0 +1,153 @@
+package utils
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// CausalPlus è la consistenza causale+ dei namespace replicati alla maniera di COPS: ogni scrittura porta con sé
+// solo le proprie dipendenze più vicine, invece di un clock vettoriale su tutte le repliche
+const CausalPlus = "CausalPlus"
+
+// Stamp è la versione di una scrittura: il clock di Lamport della replica d'origine al momento della scrittura e
+// l'indice della replica stessa, che rende l'ordine totale. Lo Stamp zero indica che non c'è alcuna scrittura.
+type Stamp struct {
+	Clock  int
+	Origin int
+}
+
+// Less indica se la scrittura s precede t nell'ordine delle versioni, che estende la relazione di causalità
+func (s Stamp) Less(t Stamp) bool {
+	if s.Clock != t.Clock {
+		return s.Clock < t.Clock
+	}
+	return s.Origin < t.Origin
+}
+
+func (s Stamp) String() string {
+	return fmt.Sprintf("%d.%d", s.Clock, s.Origin)
+}
+
+// Dependency è una dipendenza causale: la scrittura con versione Stamp (o una successiva) della chiave Key del
+// namespace Namespace deve essere visibile prima dell'operazione che la indica
+type Dependency struct {
+	Namespace string
+	Key       string
+	Stamp     Stamp
+}
+
+// MergeDependencies aggiunge dep alle dipendenze deps, sostituendo quella sulla stessa chiave se è più vecchia
+func MergeDependencies(deps []Dependency, dep Dependency) []Dependency {
+	for i, d := range deps {
+		if d.Namespace == dep.Namespace && d.Key == dep.Key {
+			if d.Stamp.Less(dep.Stamp) {
+				deps[i] = dep
+			}
+			return deps
+		}
+	}
+	return append(deps, dep)
+}
+
+// ValidateDependencies controlla le dipendenze indicate dal client (Args.Dependencies)
+func ValidateDependencies(deps []Dependency) error {
+	for _, d := range deps {
+		if err := ValidateNamespaceName(NamespaceName(d.Namespace)); err != nil {
+			return err
+		}
+		if d.Stamp.Clock < 1 || d.Stamp.Origin < 0 || d.Stamp.Origin >= NumberOfReplicas {
+			return fmt.Errorf("%w: invalid dependency %s on key %q", ErrInvalidRequest, d.Stamp, d.Key)
+		}
+	}
+	return nil
+}
+
+// ReplicationMessage è il messaggio scambiato tra le repliche dai protocolli di replicazione dei namespace che non
+// usano il multicast (Protocol è la consistenza del namespace). Viene consegnato con la RPC Replicate dello
+// storage del protocollo.
+type ReplicationMessage struct {
+	Protocol     string
+	Args         Args
+	UUID         uuid.UUID
+	ServerIndex  int              //replica d'origine
+	OpType       string           //nome operazione
+	Stamp        Stamp            //versione della scrittura
+	Dependencies []Dependency     //dipendenze più vicine della scrittura (causale+)
+	View         int              //configurazione delle repliche in cui è stato inviato il messaggio (primary-backup, catena)
+	Sequence     int              //posizione della scrittura nell'ordine del primario o della testa della catena
+	State        []NamespaceState //contenuto dei namespace, trasferito dopo una riconfigurazione (primary-backup, catena)
+	Chain        []int            //repliche della catena nella configurazione View, dalla testa alla coda (catena)
+}
+
+// NamespaceState è il contenuto di un namespace trasferito a un'altra replica
+type NamespaceState struct {
+	Namespace Namespace
+	Keys      []ScanResult
+}
+
+func NewReplicationMessage(protocol string, args Args, serverIndex int, opType string) *ReplicationMessage {
+	return &ReplicationMessage{Protocol: protocol, Args: args, UUID: uuid.New(), ServerIndex: serverIndex, OpType: opType}
+}
+
+// ReplicationService ritorna il nome con cui è registrato lo storage del protocollo ("causalplus", ...)
+func ReplicationService(protocol string) string {
+	return strings.ToLower(protocol)
+}
+
+// Replicate consegna il messaggio alla replica index con il trasporto configurato e ne attende la risposta
+func Replicate(index int, msg ReplicationMessage, resp *Response) error {
+	conn, err := DialReplica(index)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	return conn.Call(ReplicationService(msg.Protocol)+".Replicate", msg, resp)
+}
+
+// ReplicaReply è la risposta di una replica a un ReplicationMessage
+type ReplicaReply struct {
+	Index    int
+	Response *Response
+	Err      error
+}
+
+// ReplicateTo consegna il messaggio alle repliche targets in parallelo, con il ritardo di rete simulato, e ritorna
+// il canale su cui arriva la risposta di ognuna. La replica d'origine, se compare tra le destinazioni, consegna il
+// messaggio con local senza passare dalla rete.
+func ReplicateTo(msg ReplicationMessage, targets []int, local func(ReplicationMessage, *Response) error) <-chan ReplicaReply {
+	replies := make(chan ReplicaReply, len(targets))
+	for _, i := range targets {
+		go func(i int) {
+			resp := NewResponse()
+			var err error
+			if i == msg.ServerIndex {
+				err = local(msg, resp)
+			} else {
+				NetworkDelay()
+				err = Replicate(i, msg, resp)
+			}
+			if err != nil {
+				fmt.Printf("\033[31mFailed to replicate msg %s to server %s: %v\033[0m\n", msg.UUID, Peers.ID(i), err)
+			}
+			replies <- ReplicaReply{Index: i, Response: resp, Err: err}
+		}(i)
+	}
+	return replies
+}
+
+// ReplicateToPeers consegna il messaggio a tutte le repliche attive tranne quella d'origine e attende tutte le
+// risposte. Ritorna il primo errore ricevuto.
+func ReplicateToPeers(msg ReplicationMessage) error {
+	targets := slices.DeleteFunc(activePeers(), func(i int) bool { return i == msg.ServerIndex })
+	replies := ReplicateTo(msg, targets, nil)
+	var firstErr error
+	for range targets {
+		if reply := <-replies; reply.Err != nil && firstErr == nil {
+			firstErr = reply.Err
+		}
+	}
+	return firstErr
+}
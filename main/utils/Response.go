@@ -1,11 +1,28 @@
 package utils
 
 type Response struct {
-	Key         string
-	Value       string
-	IsPrintable bool
+	Key          string
+	Value        []byte
+	Found        bool //true se la chiave letta esiste (Value vuoto e Found true indicano un valore vuoto)
+	IsPrintable  bool
+	ClockValue   int           //clock logico scalare del messaggio che ha servito la richiesta (consistenza sequenziale)
+	ClockVector  []int         //clock vettoriale del messaggio che ha servito la richiesta (consistenza causale)
+	Version      int           //versione della chiave dopo l'operazione (0 se la chiave non esiste)
+	Succeeded    bool          //esito di una CompareAndSwap o di una transazione
+	TxnResults   []TxnResult   //risultati delle Get di una transazione, nell'ordine delle operazioni
+	BatchResults []BatchResult //risultati degli elementi di un Batch, nello stesso ordine
+	ScanResults  []ScanResult  //chiavi restituite da una Scan, in ordine
+	Cursor       string        //chiave da cui riprendere la Scan con la pagina successiva ("" se terminata)
+	Namespaces   []Namespace   //namespace esistenti, in ordine di nome (ListNamespaces)
+	CRDT         *CRDTValue    //valore della chiave, se è un CRDT (Get e UpdateCRDT, consistenza causale)
+	Siblings     []Sibling     //versioni concorrenti della chiave letta, l'ultima è quella in Value (consistenza causale)
+	Context      []int         //contesto da indicare in Args.Context per risolvere i Siblings (consistenza causale)
+	SessionClock []int         //clock di sessione da indicare nella richiesta successiva (consistenza causale)
+	Stamp        Stamp         //versione della chiave letta o scritta (consistenza causale+)
+	Dependencies []Dependency  //dipendenze da indicare nella richiesta successiva (consistenza causale+)
+	View         int           //configurazione della replica che ha risposto (primary-backup, catena)
 }
 
 func NewResponse() *Response {
-	return &Response{Value: "", IsPrintable: false, Key: ""} //inizializzazione
+	return &Response{Value: nil, IsPrintable: false, Key: ""} //inizializzazione
 }
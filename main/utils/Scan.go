@@ -0,0 +1,43 @@
+package utils
+
+import "fmt"
+
+// Numero di chiavi restituite da una pagina di Scan quando Args.Limit non è indicato, e numero massimo ammesso
+const (
+	DefaultScanLimit = 100
+	MaxScanLimit     = 1000
+)
+
+// ScanResult è una chiave restituita da una Scan, con il proprio valore
+type ScanResult struct {
+	Key     string
+	Value   []byte
+	Version int //versione della chiave (solo con la consistenza sequenziale)
+}
+
+// ValidateScan controlla l'intervallo e il limite di una Scan prima che venga inviata alle altre repliche
+func ValidateScan(args Args) error {
+	if args.Limit < 0 || args.Limit > MaxScanLimit {
+		return fmt.Errorf("%w: limit must be between 0 and %d", ErrInvalidRequest, MaxScanLimit)
+	}
+	if args.RangeEnd != "" && args.Key > args.RangeEnd {
+		return fmt.Errorf("%w: start key %q is after end key %q", ErrInvalidRequest, args.Key, args.RangeEnd)
+	}
+	if args.Cursor != "" && (args.Cursor < args.Key || (args.RangeEnd != "" && args.Cursor >= args.RangeEnd)) {
+		return fmt.Errorf("%w: cursor %q is outside the scanned range", ErrInvalidRequest, args.Cursor)
+	}
+	return nil
+}
+
+// PrefixEnd ritorna la più piccola chiave maggiore di tutte quelle che iniziano con prefix, da usare come fine
+// (esclusa) dell'intervallo di una ScanPrefix. Ritorna "" (nessun limite) se prefix è vuoto o composto solo da 0xff.
+func PrefixEnd(prefix string) string {
+	end := []byte(prefix)
+	for i := len(end) - 1; i >= 0; i-- {
+		if end[i] < 0xff {
+			end[i]++
+			return string(end[:i+1])
+		}
+	}
+	return ""
+}
@@ -0,0 +1,130 @@
+package utils
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+)
+
+// ErrUnauthenticated viene restituito ai client che non presentano un token valido
+var ErrUnauthenticated = errors.New("unauthenticated: missing or invalid client token")
+
+// TLSConfig contiene i file per TLS. L'identità di una replica è il CommonName del suo certificato, che deve
+// coincidere con il suo ID nella tabella dei peer. cert_file e key_file possono contenere il segnaposto {id},
+// sostituito con l'ID del server che li carica.
+type TLSConfig struct {
+	Enabled  bool   `yaml:"enabled"`
+	CAFile   string `yaml:"ca_file"`   //CA che firma i certificati di tutte le repliche
+	CertFile string `yaml:"cert_file"` //certificato della replica
+	KeyFile  string `yaml:"key_file"`  //chiave privata della replica
+}
+
+// AuthConfig contiene le credenziali dei client e le regole di accesso alle chiavi
+type AuthConfig struct {
+	Tokens map[string]string `yaml:"tokens"` //token -> identità del client
+	Rules  []ACLRule         `yaml:"rules"`
+}
+
+var serverTLS *tls.Config //configurazione per accettare connessioni (solo sui server)
+var clientTLS *tls.Config //configurazione base per aprire connessioni verso le repliche
+
+// LoadTLS carica certificati e CA se TLS è abilitato. selfID è l'ID della replica che esegue il processo,
+// oppure una stringa vuota per i client, che non presentano un certificato.
+func LoadTLS(selfID string) error {
+	if !Conf.TLS.Enabled {
+		return nil
+	}
+
+	caPEM, err := os.ReadFile(Conf.TLS.CAFile)
+	if err != nil {
+		return fmt.Errorf("tls: cannot read ca_file: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(caPEM) {
+		return fmt.Errorf("tls: no valid certificate in %s", Conf.TLS.CAFile)
+	}
+
+	clientTLS = &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}
+	if selfID == "" {
+		return nil
+	}
+
+	certFile := strings.ReplaceAll(Conf.TLS.CertFile, "{id}", selfID)
+	keyFile := strings.ReplaceAll(Conf.TLS.KeyFile, "{id}", selfID)
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return fmt.Errorf("tls: cannot load certificate of %s: %w", selfID, err)
+	}
+	if cert.Leaf == nil {
+		cert.Leaf, _ = x509.ParseCertificate(cert.Certificate[0])
+	}
+	if cert.Leaf.Subject.CommonName != selfID {
+		return fmt.Errorf("tls: certificate %s has CommonName %q, expected the server id %q",
+			certFile, cert.Leaf.Subject.CommonName, selfID)
+	}
+
+	//Le repliche presentano il proprio certificato anche quando si connettono alle altre (mTLS)
+	clientTLS.Certificates = []tls.Certificate{cert}
+	serverTLS = &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		ClientCAs:    pool,
+		//Il certificato è obbligatorio solo per le repliche: i client si autenticano con un token
+		ClientAuth: tls.VerifyClientCertIfGiven,
+		MinVersion: tls.VersionTLS12,
+	}
+	return nil
+}
+
+// ServerTLS ritorna la configurazione TLS per accettare connessioni, oppure nil se TLS è disabilitato
+func ServerTLS() *tls.Config {
+	return serverTLS
+}
+
+// PeerTLS ritorna la configurazione TLS per connettersi alla replica index, che verifica che il certificato
+// presentato appartenga proprio a quella replica. Ritorna nil se TLS è disabilitato.
+func PeerTLS(index int, addr string) *tls.Config {
+	if clientTLS == nil {
+		return nil
+	}
+	config := clientTLS.Clone()
+	config.ServerName, _, _ = net.SplitHostPort(addr)
+	expectedID := Peers.ID(index)
+	config.VerifyConnection = func(cs tls.ConnectionState) error {
+		if cs.PeerCertificates[0].Subject.CommonName != expectedID {
+			return fmt.Errorf("tls: server presented the identity %q instead of %q",
+				cs.PeerCertificates[0].Subject.CommonName, expectedID)
+		}
+		return nil
+	}
+	return config
+}
+
+// ReplicaIdentity ritorna l'ID della replica che ha aperto la connessione, se questa ha presentato un
+// certificato valido appartenente a una replica della tabella dei peer
+func ReplicaIdentity(state tls.ConnectionState) (string, bool) {
+	if len(state.VerifiedChains) == 0 {
+		return "", false
+	}
+	id := state.PeerCertificates[0].Subject.CommonName
+	if _, ok := Peers.IndexOf(id); !ok {
+		return "", false
+	}
+	return id, true
+}
+
+// Authenticate verifica il token di un client e ne ritorna l'identità. Se non sono configurati token
+// l'autenticazione dei client è disabilitata.
+func Authenticate(token string) (string, error) {
+	if len(Conf.Auth.Tokens) == 0 {
+		return "", nil
+	}
+	identity, ok := Conf.Auth.Tokens[token]
+	if !ok || token == "" {
+		return "", ErrUnauthenticated
+	}
+	return identity, nil
+}
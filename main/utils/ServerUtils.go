@@ -1,43 +1,33 @@
 package utils
 
 import (
+	"crypto/tls"
 	"fmt"
+	"net"
 	"net/rpc"
 	"os"
-	"strconv"
 	"sync"
 )
 
-var replicas = os.Getenv("REPLICAS") //Assunzione: per tre repliche, questo valore sarà "3"
-var basePort = 8080                  //Porta base: 8080. Le repliche avranno porte successive a questa
-var NumberOfReplicas, _ = strconv.Atoi(replicas)
+var NumberOfReplicas int //impostato da SetConfig in base al numero di peer configurati
 
-func GetServerPort(index int) string { //Assunzione: gli indici del server partono da 0
-
-	if index > (NumberOfReplicas - 1) { //Se ho tre repliche e sto richiedendo una porta con indice > 2 non è corretto
-		fmt.Printf("[ERROR] Requested a port higher than replicas: requested index %d but Replicas = %d\n"+
-			"[REPLICAS] = %s", index, NumberOfReplicas, replicas)
-		return ""
-	}
-	return strconv.Itoa(basePort + index) //return come string
-
-}
-
-func GetServerName(index int) string {
-	if index > (NumberOfReplicas - 1) { //Se ho tre repliche e sto richiedendo una porta con indice > 2 non è corretto
-		fmt.Println("[ERROR] Requested a server higher than replicas")
-		return ""
+// DialServer apre una connessione RPC verso la replica index rispettando il timeout di connessione configurato.
+// Se TLS è abilitato la connessione è cifrata e l'identità della replica viene verificata tramite il certificato.
+func DialServer(index int) (*rpc.Client, error) {
+	addr := Peers.Address(index)
+	dialer := &net.Dialer{Timeout: Conf.Timeouts.Dial}
+	if tlsConfig := PeerTLS(index, addr); tlsConfig != nil {
+		conn, err := tls.DialWithDialer(dialer, "tcp", addr, tlsConfig)
+		if err != nil {
+			return nil, err
+		}
+		return rpc.NewClient(conn), nil
 	}
-
-	if os.Getenv("LOCAL") == "1" {
-		return "localhost:"
-	} else if os.Getenv("DOCKER") == "1" {
-		return "server" + strconv.Itoa(index) + ":"
+	conn, err := dialer.Dial("tcp", addr)
+	if err != nil {
+		return nil, err
 	}
-
-	//Qui poi ci va anche il caso per Docker
-	return ""
-
+	return rpc.NewClient(conn), nil
 }
 
 func SendAllAcks(msg MessageNA) {
@@ -45,28 +35,27 @@ func SendAllAcks(msg MessageNA) {
 
 	var wg sync.WaitGroup
 	sent := 0
-	wg.Add(NumberOfReplicas)
+	peers := activePeers() //le repliche che hanno lasciato il cluster non vanno contattate
+	wg.Add(len(peers))
 
-	for i := 0; i < NumberOfReplicas; i++ {
+	for _, i := range peers {
 
-		port := GetServerPort(i)
 		NetworkDelay()
-		serverName := GetServerName(i)
-		addr := serverName + port
+		addr := Peers.Address(i)
 
-		conn, err := rpc.Dial("tcp", addr)
+		conn, err := DialReplica(i)
 		if err != nil {
-			fmt.Println("Failed to connect to server", i)
+			fmt.Println("Failed to connect to server", Peers.ID(i))
 			os.Exit(1)
 		}
 
 		go func() {
 			err = conn.Call("sequential.ReceiveAck", msg, NewResponse())
 			if err != nil {
-				fmt.Println("Failed to send ack to server", i, "with error: ", err)
+				fmt.Println("Failed to send ack to server", Peers.ID(i), "with error: ", err)
 				return
 			}
-			fmt.Printf("\033[32;1mSent ACK to server %d at address %s [UUID %s]\033[0m\n", i, addr, msg.UUID)
+			fmt.Printf("\033[32;1mSent ACK to server %s at address %s [UUID %s]\033[0m\n", Peers.ID(i), addr, msg.UUID)
 			sent += 1
 			err := conn.Close()
 			if err != nil {
@@ -78,8 +67,8 @@ func SendAllAcks(msg MessageNA) {
 
 	}
 	wg.Wait()
-	if sent != NumberOfReplicas {
-		fmt.Printf("[ERROR] Sent %d acks instead of %d", sent, NumberOfReplicas)
+	if sent != len(peers) {
+		fmt.Printf("[ERROR] Sent %d acks instead of %d", sent, len(peers))
 		os.Exit(1)
 	}
 }
@@ -88,25 +77,24 @@ func SendToAllServer(msg MessageNA) error {
 	fmt.Println("Sending to all server")
 
 	var wg sync.WaitGroup
-	wg.Add(NumberOfReplicas)
+	peers := activePeers() //le repliche che hanno lasciato il cluster non vanno contattate
+	wg.Add(len(peers))
 
-	for i := 0; i < NumberOfReplicas; i++ {
+	for _, i := range peers {
 
-		port := GetServerPort(i)
 		NetworkDelay()
-		serverName := GetServerName(i)
-		addr := serverName + port
+		addr := Peers.Address(i)
 
-		conn, err := rpc.Dial("tcp", addr)
+		conn, err := DialReplica(i)
 		if err != nil {
-			fmt.Println("Failed to connect to server", i)
+			fmt.Println("Failed to connect to server", Peers.ID(i))
 			return err
 		}
 
 		go func() {
 			err := conn.Call("sequential.Update", msg, NewResponse())
 			if err != nil {
-				fmt.Printf("\033[31mFailed to send msg to server %d with error: %s\033[0m\n", i, err)
+				fmt.Printf("\033[31mFailed to send msg to server %s with error: %s\033[0m\n", Peers.ID(i), err)
 			}
 			err2 := conn.Close()
 			if err2 != nil {
@@ -122,24 +110,25 @@ func SendToAllServer(msg MessageNA) error {
 	return nil
 }
 
-func SendGETToAllServer(msg MessageNA, respChannel chan string, answeringServer int) error {
+// SendGETToAllServer invia il messaggio a tutte le repliche e inoltra su respChannel la risposta calcolata alla
+// consegna da answeringServer (usata per le operazioni il cui esito va restituito al client)
+func SendGETToAllServer(msg MessageNA, respChannel chan *Response, answeringServer int) error {
 	fmt.Println("Sending to all server")
 
 	var wg sync.WaitGroup
-	wg.Add(NumberOfReplicas)
+	peers := activePeers() //le repliche che hanno lasciato il cluster non vanno contattate
+	wg.Add(len(peers))
 
-	for i := 0; i < NumberOfReplicas; i++ {
+	for _, i := range peers {
 
-		port := GetServerPort(i)
 		if i != answeringServer {
 			NetworkDelay() //Per un messaggio a me stesso non sperimento ritardo di rete
 		}
-		serverName := GetServerName(i)
-		addr := serverName + port
+		addr := Peers.Address(i)
 
-		conn, err := rpc.Dial("tcp", addr)
+		conn, err := DialReplica(i)
 		if err != nil {
-			fmt.Println("Failed to connect to server", i)
+			fmt.Println("Failed to connect to server", Peers.ID(i))
 			return err
 		}
 
@@ -147,14 +136,14 @@ func SendGETToAllServer(msg MessageNA, respChannel chan string, answeringServer
 			resp := NewResponse()
 			err := conn.Call("sequential.Update", msg, resp)
 			if err != nil {
-				fmt.Printf("\033[31mFailed to send msg to server %d with error: %s\033[0m\n", i, err)
+				fmt.Printf("\033[31mFailed to send msg to server %s with error: %s\033[0m\n", Peers.ID(i), err)
 			}
 			err2 := conn.Close()
 			if err2 != nil {
 				fmt.Println("WARNING:::: Error closing send server v2 connection: ", err2)
 			}
 			if i == answeringServer {
-				respChannel <- resp.Value
+				respChannel <- resp
 			}
 			wg.Done()
 		}()
@@ -170,25 +159,24 @@ func SendToAllServerCausal(msg VMessageNA) error {
 	fmt.Println("Sending to all server")
 
 	var wg sync.WaitGroup
-	wg.Add(NumberOfReplicas)
+	peers := activePeers() //le repliche che hanno lasciato il cluster non vanno contattate
+	wg.Add(len(peers))
 
-	for i := 0; i < NumberOfReplicas; i++ {
+	for _, i := range peers {
 
-		port := GetServerPort(i)
 		NetworkDelay()
-		serverName := GetServerName(i)
-		addr := serverName + port
+		addr := Peers.Address(i)
 
-		conn, err := rpc.Dial("tcp", addr)
+		conn, err := DialReplica(i)
 		if err != nil {
-			fmt.Println("Failed to connect to server", i)
+			fmt.Println("Failed to connect to server", Peers.ID(i))
 			return err
 		}
 
 		go func() {
 			err := conn.Call("causal.Update", msg, NewResponse())
 			if err != nil {
-				fmt.Printf("\033[31mFailed to send msg to server %d with error: %s\033[0m\n", i, err)
+				fmt.Printf("\033[31mFailed to send msg to server %s with error: %s\033[0m\n", Peers.ID(i), err)
 			}
 			err2 := conn.Close()
 			if err2 != nil {
@@ -204,24 +192,23 @@ func SendToAllServerCausal(msg VMessageNA) error {
 	return nil
 }
 
-func SendGETToAllServerCausal(msg VMessageNA, respChannel chan string, answeringServer int) error {
+func SendGETToAllServerCausal(msg VMessageNA, respChannel chan *Response, answeringServer int) error {
 	fmt.Println("Sending to all server")
 
 	var wg sync.WaitGroup
-	wg.Add(NumberOfReplicas)
+	peers := activePeers() //le repliche che hanno lasciato il cluster non vanno contattate
+	wg.Add(len(peers))
 
-	for i := 0; i < NumberOfReplicas; i++ {
+	for _, i := range peers {
 
-		port := GetServerPort(i)
 		if i != answeringServer {
 			NetworkDelay() //Per un messaggio a me stesso non sperimento ritardo di rete
 		}
-		serverName := GetServerName(i)
-		addr := serverName + port
+		addr := Peers.Address(i)
 
-		conn, err := rpc.Dial("tcp", addr)
+		conn, err := DialReplica(i)
 		if err != nil {
-			fmt.Println("Failed to connect to server", i)
+			fmt.Println("Failed to connect to server", Peers.ID(i))
 			return err
 		}
 
@@ -229,14 +216,14 @@ func SendGETToAllServerCausal(msg VMessageNA, respChannel chan string, answering
 			resp := NewResponse()
 			err := conn.Call("causal.Update", msg, resp)
 			if err != nil {
-				fmt.Printf("\033[31mFailed to send msg to server %d with error: %s\033[0m\n", i, err)
+				fmt.Printf("\033[31mFailed to send msg to server %s with error: %s\033[0m\n", Peers.ID(i), err)
 			}
 			err2 := conn.Close()
 			if err2 != nil {
 				fmt.Println("WARNING:::: Error closing send server v2 connection: ", err2)
 			}
 			if i == answeringServer {
-				respChannel <- resp.Value
+				respChannel <- resp
 			}
 			wg.Done()
 		}()
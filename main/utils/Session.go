@@ -0,0 +1,79 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// ErrSessionBehind viene restituito quando la replica non raggiunge entro timeouts.session il clock di sessione del
+// client: il client può ripetere la richiesta più tardi o verso un'altra replica
+var ErrSessionBehind = errors.New("replica has not yet seen the client session")
+
+// IsSessionBehind indica se err è un ErrSessionBehind, anche quando è stato ricevuto come stringa tramite net/rpc o
+// come stato gRPC
+func IsSessionBehind(err error) bool {
+	return err != nil && (errors.Is(err, ErrSessionBehind) || strings.Contains(err.Error(), ErrSessionBehind.Error()))
+}
+
+// Session è il clock di sessione di un client nei namespace causali: l'unione dei clock vettoriali delle risposte
+// ricevute, anche da repliche diverse. Inviato con ogni richiesta, fa attendere alla replica di aver consegnato
+// tutte le scritture che il client ha già eseguito o letto (read-your-writes e monotonic reads). Una Session può
+// essere usata da più goroutine. Nei namespace causali+ la sessione conserva invece le dipendenze più vicine del
+// client, riportate da ogni risposta.
+type Session struct {
+	mutex        sync.Mutex
+	clock        []int
+	dependencies []Dependency
+}
+
+// Apply indica negli args il clock di sessione corrente
+func (s *Session) Apply(args *Args) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	args.SessionClock = slices.Clone(s.clock)
+	args.Dependencies = slices.Clone(s.dependencies)
+}
+
+// Update aggiunge al clock di sessione quello riportato dalla risposta (le risposte dei namespace sequenziali non
+// ne hanno uno e lo lasciano invariato) e sostituisce le dipendenze con quelle della risposta, se presenti
+func (s *Session) Update(resp *Response) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.clock == nil && resp.SessionClock != nil {
+		s.clock = make([]int, len(resp.SessionClock))
+	}
+	for i := range resp.SessionClock {
+		if i < len(s.clock) && resp.SessionClock[i] > s.clock[i] {
+			s.clock[i] = resp.SessionClock[i]
+		}
+	}
+	if resp.Dependencies != nil {
+		//Dopo una scrittura la risposta riporta solo la scrittura stessa, che dipende già da tutte le precedenti
+		s.dependencies = slices.Clone(resp.Dependencies)
+	}
+}
+
+// ValidateSessionClock controlla il clock di sessione di una richiesta (Args.SessionClock)
+func ValidateSessionClock(clock []int) error {
+	return validateClock("session clock", clock)
+}
+
+// validateClock controlla un clock vettoriale indicato dal client: se presente deve avere una componente non
+// negativa per ogni replica
+func validateClock(name string, clock []int) error {
+	if clock == nil {
+		return nil
+	}
+	if len(clock) != NumberOfReplicas {
+		return fmt.Errorf("%w: %s must have %d components, got %d", ErrInvalidRequest, name, NumberOfReplicas, len(clock))
+	}
+	for _, c := range clock {
+		if c < 0 {
+			return fmt.Errorf("%w: %s components must not be negative", ErrInvalidRequest, name)
+		}
+	}
+	return nil
+}
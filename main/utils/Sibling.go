@@ -0,0 +1,14 @@
+package utils
+
+// Sibling è una delle versioni concorrenti di una chiave di un namespace causale, con il clock vettoriale del
+// messaggio che l'ha scritta
+type Sibling struct {
+	Value       []byte
+	ClockVector []int
+}
+
+// ValidateContext controlla il contesto di una scrittura (Args.Context): se indicato deve avere una componente per
+// ogni replica
+func ValidateContext(context []int) error {
+	return validateClock("context", context)
+}
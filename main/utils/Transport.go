@@ -0,0 +1,786 @@
+package utils
+
+import (
+	"SDCC/main/kvspb"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+)
+
+// Conn è una connessione verso una replica, indipendente dal trasporto configurato. I metodi si invocano con
+// lo stesso nome usato da net/rpc ("sequential.Update", "causal.Get", ...), e *rpc.Client la implementa già.
+type Conn interface {
+	Call(serviceMethod string, args any, reply any) error
+	Close() error
+}
+
+// DialReplica apre una connessione verso la replica in posizione index con il trasporto configurato
+func DialReplica(index int) (Conn, error) {
+	if Conf.Transport == TransportGRPC {
+		return dialGRPC(index)
+	}
+	return DialServer(index)
+}
+
+// DialAddress ritorna l'indirizzo a cui ci si connette per raggiungere la replica index con il trasporto configurato
+func DialAddress(index int) string {
+	if Conf.Transport == TransportGRPC {
+		return Peers.Get(index).GRPCAddress
+	}
+	return Peers.Address(index)
+}
+
+// grpcReplica è la connessione gRPC condivisa verso una replica. Sopra di essa viene mantenuto un unico stream
+// Multicast, su cui vengono inviati tutti i messaggi destinati alla replica; le risposte vengono smistate ai
+// chiamanti in base allo uuid del messaggio.
+type grpcReplica struct {
+	conn      *grpc.ClientConn
+	replica   kvspb.ReplicaClient
+	keyValue  kvspb.KeyValueClient
+	mutex     sync.Mutex //protegge stream e pending
+	stream    kvspb.Replica_MulticastClient
+	pending   map[string]chan *kvspb.UpdateReply
+	sendMutex sync.Mutex //Send non può essere chiamata in concorrenza sullo stesso stream
+}
+
+var grpcReplicasMutex sync.Mutex
+var grpcReplicas = make(map[int]*grpcReplica)
+
+// grpcConn implementa Conn sopra la connessione condivisa: Close non chiude la connessione, che resta
+// disponibile per i messaggi successivi
+type grpcConn struct {
+	replica *grpcReplica
+}
+
+func dialGRPC(index int) (*grpcConn, error) {
+	grpcReplicasMutex.Lock()
+	defer grpcReplicasMutex.Unlock()
+
+	if r, ok := grpcReplicas[index]; ok {
+		return &grpcConn{replica: r}, nil
+	}
+	addr := Peers.Get(index).GRPCAddress
+	creds := insecure.NewCredentials()
+	if tlsConfig := PeerTLS(index, addr); tlsConfig != nil {
+		creds = credentials.NewTLS(tlsConfig)
+	}
+	conn, err := grpc.NewClient(addr, grpc.WithTransportCredentials(creds))
+	if err != nil {
+		return nil, err
+	}
+	r := &grpcReplica{
+		conn:     conn,
+		replica:  kvspb.NewReplicaClient(conn),
+		keyValue: kvspb.NewKeyValueClient(conn),
+		pending:  make(map[string]chan *kvspb.UpdateReply),
+	}
+	grpcReplicas[index] = r
+	return &grpcConn{replica: r}, nil
+}
+
+func (c *grpcConn) Close() error {
+	return nil
+}
+
+func (c *grpcConn) Call(serviceMethod string, args any, reply any) error {
+	resp, ok := reply.(*Response)
+	if !ok {
+		return fmt.Errorf("unsupported reply type %T", reply)
+	}
+	_, method, _ := strings.Cut(serviceMethod, ".")
+	ctx, cancel := context.WithTimeout(context.Background(), Conf.Timeouts.Dial)
+	defer cancel()
+
+	var err error
+	var protoResp *kvspb.Response
+	switch a := args.(type) {
+	case MessageNA:
+		if method == "ReceiveAck" {
+			_, err = c.replica.replica.ReceiveAck(ctx, MessageToProto(a))
+			return err
+		}
+		return c.replica.update(MessageToProto(a), resp)
+	case VMessageNA:
+		return c.replica.update(VMessageToProto(a), resp)
+	case ReplicationMessage:
+		//La consegna può attendere le dipendenze della scrittura: niente timeout, come per i messaggi del multicast
+		protoResp, err = c.replica.replica.Replicate(context.Background(), ReplicationMessageToProto(a))
+		if err != nil {
+			return err
+		}
+		ResponseFromProto(protoResp, resp)
+		return nil
+	case LeaveArgs:
+		_, err = c.replica.replica.PeerLeaving(ctx, &kvspb.LeaveRequest{ServerId: a.ServerID})
+		return err
+	case *Args:
+		//Le richieste dei client possono restare in attesa a lungo (ordinamento FIFO e multicast): niente timeout.
+		//Il token viaggia nei metadata della chiamata, come da convenzione gRPC.
+		clientCtx := context.Background()
+		if a.Token != "" {
+			clientCtx = metadata.AppendToOutgoingContext(clientCtx, "authorization", "Bearer "+a.Token)
+		}
+		protoArgs := ArgsToProto(*a)
+		switch method {
+		case "Get":
+			protoResp, err = c.replica.keyValue.Get(clientCtx, protoArgs)
+		case "Put":
+			protoResp, err = c.replica.keyValue.Put(clientCtx, protoArgs)
+		case "Delete":
+			protoResp, err = c.replica.keyValue.Delete(clientCtx, protoArgs)
+		case "Increment":
+			protoResp, err = c.replica.keyValue.Increment(clientCtx, protoArgs)
+		case "Append":
+			protoResp, err = c.replica.keyValue.Append(clientCtx, protoArgs)
+		case "UpdateCRDT":
+			protoResp, err = c.replica.keyValue.UpdateCRDT(clientCtx, protoArgs)
+		case "CompareAndSwap":
+			protoResp, err = c.replica.keyValue.CompareAndSwap(clientCtx, protoArgs)
+		case "Txn":
+			protoResp, err = c.replica.keyValue.Txn(clientCtx, protoArgs)
+		case "Batch":
+			protoResp, err = c.replica.keyValue.Batch(clientCtx, protoArgs)
+		case "Scan":
+			protoResp, err = c.replica.keyValue.Scan(clientCtx, protoArgs)
+		case "ScanPrefix":
+			protoResp, err = c.replica.keyValue.ScanPrefix(clientCtx, protoArgs)
+		case "End":
+			protoResp, err = c.replica.keyValue.End(clientCtx, protoArgs)
+		case "CreateNamespace":
+			protoResp, err = c.replica.keyValue.CreateNamespace(clientCtx, protoArgs)
+		case "DropNamespace":
+			protoResp, err = c.replica.keyValue.DropNamespace(clientCtx, protoArgs)
+		case "ListNamespaces":
+			protoResp, err = c.replica.keyValue.ListNamespaces(clientCtx, protoArgs)
+		default:
+			return fmt.Errorf("unsupported method %s", serviceMethod)
+		}
+		if err != nil {
+			return err
+		}
+		ResponseFromProto(protoResp, resp)
+		return nil
+	}
+	return fmt.Errorf("unsupported arguments %T for %s", args, serviceMethod)
+}
+
+// update invia il messaggio sullo stream Multicast e attende la risposta della replica
+func (r *grpcReplica) update(msg *kvspb.ReplicaMessage, resp *Response) error {
+	stream, replyChannel, err := r.register(msg.Uuid)
+	if err != nil {
+		return err
+	}
+
+	r.sendMutex.Lock()
+	err = stream.Send(msg)
+	r.sendMutex.Unlock()
+	if err != nil {
+		r.reset(stream)
+		return err
+	}
+
+	reply, ok := <-replyChannel
+	if !ok {
+		return errors.New("multicast stream closed before the reply")
+	}
+	if reply.Error != "" {
+		return errors.New(reply.Error)
+	}
+	ResponseFromProto(reply.Response, resp)
+	return nil
+}
+
+// register apre lo stream se necessario e registra l'attesa della risposta al messaggio id
+func (r *grpcReplica) register(id string) (kvspb.Replica_MulticastClient, chan *kvspb.UpdateReply, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.stream == nil {
+		stream, err := r.replica.Multicast(context.Background())
+		if err != nil {
+			return nil, nil, err
+		}
+		r.stream = stream
+		go r.receive(stream)
+	}
+	replyChannel := make(chan *kvspb.UpdateReply, 1)
+	r.pending[id] = replyChannel
+	return r.stream, replyChannel, nil
+}
+
+// receive smista le risposte ricevute sullo stream ai rispettivi chiamanti
+func (r *grpcReplica) receive(stream kvspb.Replica_MulticastClient) {
+	for {
+		reply, err := stream.Recv()
+		if err != nil {
+			fmt.Println("Multicast stream closed:", err)
+			r.reset(stream)
+			return
+		}
+		r.mutex.Lock()
+		replyChannel, ok := r.pending[reply.Uuid]
+		delete(r.pending, reply.Uuid)
+		r.mutex.Unlock()
+		if ok {
+			replyChannel <- reply
+		}
+	}
+}
+
+// reset scarta lo stream non più utilizzabile: i chiamanti in attesa ricevono un errore e il prossimo messaggio
+// aprirà un nuovo stream
+func (r *grpcReplica) reset(stream kvspb.Replica_MulticastClient) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.stream != stream {
+		return
+	}
+	r.stream = nil
+	for id, replyChannel := range r.pending {
+		close(replyChannel)
+		delete(r.pending, id)
+	}
+}
+
+func ArgsToProto(a Args) *kvspb.Args {
+	return &kvspb.Args{
+		Namespace:       a.Namespace,
+		NamespaceSpec:   namespaceToProto(a.NamespaceSpec),
+		Key:             a.Key,
+		Value:           a.Value,
+		RequestNumber:   int64(a.RequestNumber),
+		ClientIndex:     int64(a.ClientIndex),
+		Delta:           a.Delta,
+		Crdt:            a.CRDT,
+		CrdtRemove:      a.CRDTRemove,
+		Context:         intsToProto(a.Context),
+		SessionClock:    intsToProto(a.SessionClock),
+		Dependencies:    dependenciesToProto(a.Dependencies),
+		ExpectedVersion: int64(a.ExpectedVersion),
+		ExpectedValue:   a.ExpectedValue,
+		CompareValue:    a.CompareValue,
+		Txn:             txnToProto(a.Txn),
+		Batch:           batchToProto(a.Batch),
+		TtlMs:           a.TTL.Milliseconds(),
+		RangeEnd:        a.RangeEnd,
+		Limit:           int64(a.Limit),
+		Cursor:          a.Cursor,
+		Token:           a.Token,
+
+		NamespaceRevision: int64(a.NamespaceRevision),
+	}
+}
+
+func ArgsFromProto(a *kvspb.Args) Args {
+	return Args{
+		Namespace:       a.GetNamespace(),
+		NamespaceSpec:   namespaceFromProto(a.GetNamespaceSpec()),
+		Key:             a.GetKey(),
+		Value:           a.GetValue(),
+		RequestNumber:   int(a.GetRequestNumber()),
+		ClientIndex:     int(a.GetClientIndex()),
+		Delta:           a.GetDelta(),
+		CRDT:            a.GetCrdt(),
+		CRDTRemove:      a.GetCrdtRemove(),
+		Context:         intsFromProto(a.GetContext()),
+		SessionClock:    intsFromProto(a.GetSessionClock()),
+		Dependencies:    dependenciesFromProto(a.GetDependencies()),
+		ExpectedVersion: int(a.GetExpectedVersion()),
+		ExpectedValue:   a.GetExpectedValue(),
+		CompareValue:    a.GetCompareValue(),
+		Txn:             txnFromProto(a.GetTxn()),
+		Batch:           batchFromProto(a.GetBatch()),
+		TTL:             time.Duration(a.GetTtlMs()) * time.Millisecond,
+		RangeEnd:        a.GetRangeEnd(),
+		Limit:           int(a.GetLimit()),
+		Cursor:          a.GetCursor(),
+		Token:           a.GetToken(),
+
+		NamespaceRevision: int(a.GetNamespaceRevision()),
+	}
+}
+
+func ResponseToProto(r *Response) *kvspb.Response {
+	return &kvspb.Response{
+		Key:          r.Key,
+		Value:        r.Value,
+		Found:        r.Found,
+		IsPrintable:  r.IsPrintable,
+		ClockValue:   int64(r.ClockValue),
+		ClockVector:  intsToProto(r.ClockVector),
+		Version:      int64(r.Version),
+		Succeeded:    r.Succeeded,
+		TxnResults:   txnResultsToProto(r.TxnResults),
+		BatchResults: batchResultsToProto(r.BatchResults),
+		ScanResults:  scanResultsToProto(r.ScanResults),
+		Cursor:       r.Cursor,
+		Namespaces:   namespacesToProto(r.Namespaces),
+		Crdt:         crdtToProto(r.CRDT),
+		Siblings:     siblingsToProto(r.Siblings),
+		Context:      intsToProto(r.Context),
+		SessionClock: intsToProto(r.SessionClock),
+		Stamp:        stampToProto(r.Stamp),
+		Dependencies: dependenciesToProto(r.Dependencies),
+		View:         int64(r.View),
+	}
+}
+
+func ResponseFromProto(p *kvspb.Response, r *Response) {
+	r.Key = p.GetKey()
+	r.Value = p.GetValue()
+	r.Found = p.GetFound()
+	r.IsPrintable = p.GetIsPrintable()
+	r.ClockValue = int(p.GetClockValue())
+	r.ClockVector = intsFromProto(p.GetClockVector())
+	r.Version = int(p.GetVersion())
+	r.Succeeded = p.GetSucceeded()
+	r.TxnResults = txnResultsFromProto(p.GetTxnResults())
+	r.BatchResults = batchResultsFromProto(p.GetBatchResults())
+	r.ScanResults = scanResultsFromProto(p.GetScanResults())
+	r.Cursor = p.GetCursor()
+	r.Namespaces = namespacesFromProto(p.GetNamespaces())
+	r.CRDT = crdtFromProto(p.GetCrdt())
+	r.Siblings = siblingsFromProto(p.GetSiblings())
+	r.Context = intsFromProto(p.GetContext())
+	r.SessionClock = intsFromProto(p.GetSessionClock())
+	r.Stamp = stampFromProto(p.GetStamp())
+	r.Dependencies = dependenciesFromProto(p.GetDependencies())
+	r.View = int(p.GetView())
+}
+
+func namespaceToProto(n Namespace) *kvspb.Namespace {
+	if n == (Namespace{}) {
+		return nil
+	}
+	return &kvspb.Namespace{
+		Name:        n.Name,
+		Consistency: n.Consistency,
+		MaxKeys:     int64(n.MaxKeys),
+		MaxBytes:    int64(n.MaxBytes),
+		Keys:        int64(n.Keys),
+		Bytes:       int64(n.Bytes),
+		Revision:    int64(n.Revision),
+	}
+}
+
+func namespaceFromProto(p *kvspb.Namespace) Namespace {
+	return Namespace{
+		Name:        p.GetName(),
+		Consistency: p.GetConsistency(),
+		MaxKeys:     int(p.GetMaxKeys()),
+		MaxBytes:    int(p.GetMaxBytes()),
+		Keys:        int(p.GetKeys()),
+		Bytes:       int(p.GetBytes()),
+		Revision:    int(p.GetRevision()),
+	}
+}
+
+func namespacesToProto(namespaces []Namespace) []*kvspb.Namespace {
+	if namespaces == nil {
+		return nil
+	}
+	out := make([]*kvspb.Namespace, len(namespaces))
+	for i, n := range namespaces {
+		out[i] = namespaceToProto(n)
+	}
+	return out
+}
+
+func namespacesFromProto(namespaces []*kvspb.Namespace) []Namespace {
+	if namespaces == nil {
+		return nil
+	}
+	out := make([]Namespace, len(namespaces))
+	for i, n := range namespaces {
+		out[i] = namespaceFromProto(n)
+	}
+	return out
+}
+
+func txnToProto(ops []TxnOp) []*kvspb.TxnOp {
+	if ops == nil {
+		return nil
+	}
+	out := make([]*kvspb.TxnOp, len(ops))
+	for i, op := range ops {
+		out[i] = &kvspb.TxnOp{
+			OpType:          op.OpType,
+			Key:             op.Key,
+			Value:           op.Value,
+			ExpectedVersion: int64(op.ExpectedVersion),
+			ExpectedValue:   op.ExpectedValue,
+			CompareValue:    op.CompareValue,
+		}
+	}
+	return out
+}
+
+func txnFromProto(ops []*kvspb.TxnOp) []TxnOp {
+	if ops == nil {
+		return nil
+	}
+	out := make([]TxnOp, len(ops))
+	for i, op := range ops {
+		out[i] = TxnOp{
+			OpType:          op.GetOpType(),
+			Key:             op.GetKey(),
+			Value:           op.GetValue(),
+			ExpectedVersion: int(op.GetExpectedVersion()),
+			ExpectedValue:   op.GetExpectedValue(),
+			CompareValue:    op.GetCompareValue(),
+		}
+	}
+	return out
+}
+
+func txnResultsToProto(results []TxnResult) []*kvspb.TxnResult {
+	if results == nil {
+		return nil
+	}
+	out := make([]*kvspb.TxnResult, len(results))
+	for i, r := range results {
+		out[i] = &kvspb.TxnResult{Key: r.Key, Value: r.Value, Found: r.Found, Version: int64(r.Version)}
+	}
+	return out
+}
+
+func txnResultsFromProto(results []*kvspb.TxnResult) []TxnResult {
+	if results == nil {
+		return nil
+	}
+	out := make([]TxnResult, len(results))
+	for i, r := range results {
+		out[i] = TxnResult{Key: r.GetKey(), Value: r.GetValue(), Found: r.GetFound(), Version: int(r.GetVersion())}
+	}
+	return out
+}
+
+func MessageToProto(m MessageNA) *kvspb.ReplicaMessage {
+	return &kvspb.ReplicaMessage{
+		Args:             ArgsToProto(m.Args),
+		Uuid:             m.UUID.String(),
+		ServerIndex:      int64(m.ServerIndex),
+		OpType:           m.OpType,
+		ClockValue:       int64(m.ClockValue),
+		ServerMsgCounter: int64(m.ServerMsgCounter),
+	}
+}
+
+func MessageFromProto(p *kvspb.ReplicaMessage) (MessageNA, error) {
+	id, err := uuid.Parse(p.GetUuid())
+	if err != nil {
+		return MessageNA{}, fmt.Errorf("invalid message uuid: %w", err)
+	}
+	return MessageNA{
+		Args:             ArgsFromProto(p.GetArgs()),
+		ClockValue:       int(p.GetClockValue()),
+		UUID:             id,
+		ServerIndex:      int(p.GetServerIndex()),
+		ServerMsgCounter: int(p.GetServerMsgCounter()),
+		OpType:           p.GetOpType(),
+	}, nil
+}
+
+func VMessageToProto(m VMessageNA) *kvspb.ReplicaMessage {
+	return &kvspb.ReplicaMessage{
+		Args:        ArgsToProto(m.Args),
+		Uuid:        m.UUID.String(),
+		ServerIndex: int64(m.ServerIndex),
+		OpType:      m.OpType,
+		ClockVector: intsToProto(m.ClockVector),
+		FifoIndex:   int64(m.FifoIndex),
+	}
+}
+
+func VMessageFromProto(p *kvspb.ReplicaMessage) (VMessageNA, error) {
+	id, err := uuid.Parse(p.GetUuid())
+	if err != nil {
+		return VMessageNA{}, fmt.Errorf("invalid message uuid: %w", err)
+	}
+	return VMessageNA{
+		Args:        ArgsFromProto(p.GetArgs()),
+		ClockVector: intsFromProto(p.GetClockVector()),
+		UUID:        id,
+		ServerIndex: int(p.GetServerIndex()),
+		OpType:      p.GetOpType(),
+		FifoIndex:   int(p.GetFifoIndex()),
+	}, nil
+}
+
+func ReplicationMessageToProto(m ReplicationMessage) *kvspb.ReplicationMessage {
+	return &kvspb.ReplicationMessage{
+		Protocol:     m.Protocol,
+		Args:         ArgsToProto(m.Args),
+		Uuid:         m.UUID.String(),
+		ServerIndex:  int64(m.ServerIndex),
+		OpType:       m.OpType,
+		Stamp:        stampToProto(m.Stamp),
+		Dependencies: dependenciesToProto(m.Dependencies),
+		View:         int64(m.View),
+		Sequence:     int64(m.Sequence),
+		State:        namespaceStatesToProto(m.State),
+		Chain:        intsToProto(m.Chain),
+	}
+}
+
+func ReplicationMessageFromProto(p *kvspb.ReplicationMessage) (ReplicationMessage, error) {
+	id, err := uuid.Parse(p.GetUuid())
+	if err != nil {
+		return ReplicationMessage{}, fmt.Errorf("invalid message uuid: %w", err)
+	}
+	return ReplicationMessage{
+		Protocol:     p.GetProtocol(),
+		Args:         ArgsFromProto(p.GetArgs()),
+		UUID:         id,
+		ServerIndex:  int(p.GetServerIndex()),
+		OpType:       p.GetOpType(),
+		Stamp:        stampFromProto(p.GetStamp()),
+		Dependencies: dependenciesFromProto(p.GetDependencies()),
+		View:         int(p.GetView()),
+		Sequence:     int(p.GetSequence()),
+		State:        namespaceStatesFromProto(p.GetState()),
+		Chain:        intsFromProto(p.GetChain()),
+	}, nil
+}
+
+func namespaceStatesToProto(states []NamespaceState) []*kvspb.NamespaceState {
+	if states == nil {
+		return nil
+	}
+	out := make([]*kvspb.NamespaceState, len(states))
+	for i, s := range states {
+		out[i] = &kvspb.NamespaceState{Namespace: namespaceToProto(s.Namespace), Keys: scanResultsToProto(s.Keys)}
+	}
+	return out
+}
+
+func namespaceStatesFromProto(states []*kvspb.NamespaceState) []NamespaceState {
+	if states == nil {
+		return nil
+	}
+	out := make([]NamespaceState, len(states))
+	for i, s := range states {
+		out[i] = NamespaceState{Namespace: namespaceFromProto(s.GetNamespace()), Keys: scanResultsFromProto(s.GetKeys())}
+	}
+	return out
+}
+
+func stampToProto(s Stamp) *kvspb.Stamp {
+	if s == (Stamp{}) {
+		return nil
+	}
+	return &kvspb.Stamp{Clock: int64(s.Clock), Origin: int64(s.Origin)}
+}
+
+func stampFromProto(p *kvspb.Stamp) Stamp {
+	return Stamp{Clock: int(p.GetClock()), Origin: int(p.GetOrigin())}
+}
+
+func dependenciesToProto(deps []Dependency) []*kvspb.Dependency {
+	if deps == nil {
+		return nil
+	}
+	out := make([]*kvspb.Dependency, len(deps))
+	for i, d := range deps {
+		out[i] = &kvspb.Dependency{Namespace: d.Namespace, Key: d.Key, Stamp: stampToProto(d.Stamp)}
+	}
+	return out
+}
+
+func dependenciesFromProto(deps []*kvspb.Dependency) []Dependency {
+	if deps == nil {
+		return nil
+	}
+	out := make([]Dependency, len(deps))
+	for i, d := range deps {
+		out[i] = Dependency{Namespace: d.GetNamespace(), Key: d.GetKey(), Stamp: stampFromProto(d.GetStamp())}
+	}
+	return out
+}
+
+func batchToProto(items []BatchItem) []*kvspb.BatchItem {
+	if items == nil {
+		return nil
+	}
+	out := make([]*kvspb.BatchItem, len(items))
+	for i, item := range items {
+		out[i] = &kvspb.BatchItem{OpType: item.OpType, Args: ArgsToProto(item.Args)}
+	}
+	return out
+}
+
+func batchFromProto(items []*kvspb.BatchItem) []BatchItem {
+	if items == nil {
+		return nil
+	}
+	out := make([]BatchItem, len(items))
+	for i, item := range items {
+		out[i] = BatchItem{OpType: item.GetOpType(), Args: ArgsFromProto(item.GetArgs())}
+	}
+	return out
+}
+
+func batchResultsToProto(results []BatchResult) []*kvspb.BatchResult {
+	if results == nil {
+		return nil
+	}
+	out := make([]*kvspb.BatchResult, len(results))
+	for i, r := range results {
+		out[i] = &kvspb.BatchResult{Key: r.Key, Value: r.Value, Found: r.Found, Version: int64(r.Version), Error: r.Error}
+	}
+	return out
+}
+
+func batchResultsFromProto(results []*kvspb.BatchResult) []BatchResult {
+	if results == nil {
+		return nil
+	}
+	out := make([]BatchResult, len(results))
+	for i, r := range results {
+		out[i] = BatchResult{Key: r.GetKey(), Value: r.GetValue(), Found: r.GetFound(), Version: int(r.GetVersion()), Error: r.GetError()}
+	}
+	return out
+}
+
+func WatchArgsToProto(a WatchArgs) *kvspb.WatchRequest {
+	return &kvspb.WatchRequest{
+		Namespace:     a.Namespace,
+		Key:           a.Key,
+		Prefix:        a.Prefix,
+		AfterRevision: int64(a.AfterRevision),
+		AfterClock:    int64(a.AfterClock),
+		AfterMessage:  a.AfterMessage,
+		AfterPosition: int64(a.AfterPosition),
+		AfterVector:   intsToProto(a.AfterVector),
+	}
+}
+
+func WatchArgsFromProto(p *kvspb.WatchRequest) WatchArgs {
+	return WatchArgs{
+		Namespace:     p.GetNamespace(),
+		Key:           p.GetKey(),
+		Prefix:        p.GetPrefix(),
+		AfterRevision: int(p.GetAfterRevision()),
+		AfterClock:    int(p.GetAfterClock()),
+		AfterMessage:  p.GetAfterMessage(),
+		AfterPosition: int(p.GetAfterPosition()),
+		AfterVector:   intsFromProto(p.GetAfterVector()),
+	}
+}
+
+func WatchEventToProto(e WatchEvent) *kvspb.WatchEvent {
+	return &kvspb.WatchEvent{
+		Revision:    int64(e.Revision),
+		Namespace:   e.Namespace,
+		OpType:      e.OpType,
+		Key:         e.Key,
+		Value:       e.Value,
+		Version:     int64(e.Version),
+		ClockValue:  int64(e.ClockValue),
+		ClockVector: intsToProto(e.ClockVector),
+		Message:     e.Message,
+		Position:    int64(e.Position),
+	}
+}
+
+func WatchEventFromProto(p *kvspb.WatchEvent) WatchEvent {
+	return WatchEvent{
+		Revision:    int(p.GetRevision()),
+		Namespace:   p.GetNamespace(),
+		OpType:      p.GetOpType(),
+		Key:         p.GetKey(),
+		Value:       p.GetValue(),
+		Version:     int(p.GetVersion()),
+		ClockValue:  int(p.GetClockValue()),
+		ClockVector: intsFromProto(p.GetClockVector()),
+	}
+}
+
+func crdtToProto(v *CRDTValue) *kvspb.CRDTValue {
+	if v == nil {
+		return nil
+	}
+	return &kvspb.CRDTValue{Type: v.Type, Counter: v.Counter, Values: v.Values}
+}
+
+func crdtFromProto(p *kvspb.CRDTValue) *CRDTValue {
+	if p == nil {
+		return nil
+	}
+	return &CRDTValue{Type: p.GetType(), Counter: p.GetCounter(), Values: p.GetValues()}
+}
+
+func siblingsToProto(siblings []Sibling) []*kvspb.Sibling {
+	if siblings == nil {
+		return nil
+	}
+	out := make([]*kvspb.Sibling, len(siblings))
+	for i, s := range siblings {
+		out[i] = &kvspb.Sibling{Value: s.Value, ClockVector: intsToProto(s.ClockVector)}
+	}
+	return out
+}
+
+func siblingsFromProto(siblings []*kvspb.Sibling) []Sibling {
+	if siblings == nil {
+		return nil
+	}
+	out := make([]Sibling, len(siblings))
+	for i, s := range siblings {
+		out[i] = Sibling{Value: s.GetValue(), ClockVector: intsFromProto(s.GetClockVector())}
+	}
+	return out
+}
+
+func scanResultsToProto(results []ScanResult) []*kvspb.ScanResult {
+	if results == nil {
+		return nil
+	}
+	out := make([]*kvspb.ScanResult, len(results))
+	for i, r := range results {
+		out[i] = &kvspb.ScanResult{Key: r.Key, Value: r.Value, Version: int64(r.Version)}
+	}
+	return out
+}
+
+func scanResultsFromProto(results []*kvspb.ScanResult) []ScanResult {
+	if results == nil {
+		return nil
+	}
+	out := make([]ScanResult, len(results))
+	for i, r := range results {
+		out[i] = ScanResult{Key: r.GetKey(), Value: r.GetValue(), Version: int(r.GetVersion())}
+	}
+	return out
+}
+
+func intsToProto(values []int) []int64 {
+	if values == nil {
+		return nil
+	}
+	out := make([]int64, len(values))
+	for i, v := range values {
+		out[i] = int64(v)
+	}
+	return out
+}
+
+func intsFromProto(values []int64) []int {
+	if values == nil {
+		return nil
+	}
+	out := make([]int, len(values))
+	for i, v := range values {
+		out[i] = int(v)
+	}
+	return out
+}
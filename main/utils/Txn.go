@@ -0,0 +1,44 @@
+package utils
+
+import "fmt"
+
+// Guard è il tipo delle operazioni di una transazione che non leggono né scrivono, ma ne condizionano l'esito
+const Guard = "Guard"
+
+// TxnOp è una singola operazione di una transazione. OpType è Get, Put, Delete oppure Guard: una Guard è
+// soddisfatta se la chiave ha la versione ExpectedVersion (0 = la chiave non deve esistere) oppure, con
+// CompareValue, il valore ExpectedValue.
+type TxnOp struct {
+	OpType          string
+	Key             string
+	Value           []byte
+	ExpectedVersion int
+	ExpectedValue   []byte
+	CompareValue    bool
+}
+
+// TxnResult è il risultato di una Get di una transazione
+type TxnResult struct {
+	Key     string
+	Value   []byte
+	Found   bool //false se la chiave non esiste
+	Version int
+}
+
+// ValidateTxn controlla che la transazione sia ben formata prima di inviarla alle altre repliche
+func ValidateTxn(ops []TxnOp) error {
+	if len(ops) == 0 {
+		return fmt.Errorf("%w: a transaction needs at least one operation", ErrInvalidRequest)
+	}
+	for i, op := range ops {
+		switch op.OpType {
+		case Get, Put, Delete, Guard:
+		default:
+			return fmt.Errorf("%w: txn[%d]: unknown operation type %q", ErrInvalidRequest, i, op.OpType)
+		}
+		if op.Key == "" {
+			return fmt.Errorf("%w: txn[%d]: key must not be empty", ErrInvalidRequest, i)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,113 @@
+package utils
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrCompacted viene restituito quando il punto da cui riprendere un Watch non è più nello storico della replica:
+// il client deve rileggere lo stato corrente (per esempio con una Scan) e aprire un nuovo Watch
+var ErrCompacted = errors.New("watch history compacted")
+
+// ErrWatchLagging chiude un Watch il cui client non riceve gli eventi abbastanza velocemente: il client può
+// riprendere dall'ultima Revision ricevuta
+var ErrWatchLagging = errors.New("watcher fell behind")
+
+// WatchArgs descrive un Watch sulla chiave Key o, con Prefix, sulle chiavi che iniziano con Key.
+// Senza punto di ripresa vengono notificate solo le modifiche successive; altrimenti va indicato uno solo tra
+// AfterRevision (eventi con Revision maggiore), AfterClock (consistenza sequenziale: eventi successivi all'evento
+// AfterPosition del messaggio AfterMessage con clock AfterClock, vedere WatchEvent.After) e AfterVector
+// (consistenza causale: eventi non ancora inclusi nel clock vettoriale indicato).
+type WatchArgs struct {
+	Namespace string //namespace osservato ("" = DefaultNamespace)
+	Key       string
+	Prefix    bool
+	Token     string //token di autenticazione del client
+
+	AfterRevision int
+	AfterClock    int
+	AfterMessage  string
+	AfterPosition int
+	AfterVector   []int
+}
+
+// WatchEvent è una Put o una Delete applicata da una replica. Revision numera gli eventi di ogni namespace
+// nell'ordine in cui la replica li ha applicati: con la consistenza sequenziale l'ordine, e quindi la
+// numerazione, è lo stesso su tutte le repliche.
+type WatchEvent struct {
+	Revision    int
+	Namespace   string //namespace della chiave modificata
+	OpType      string //Put o Delete
+	Key         string
+	Value       []byte
+	Version     int    //versione della chiave dopo la modifica (solo con la consistenza sequenziale)
+	ClockValue  int    //clock scalare del messaggio che ha applicato la modifica (consistenza sequenziale)
+	ClockVector []int  //clock vettoriale del messaggio che ha applicato la modifica (consistenza causale)
+	Message     string //UUID del messaggio che ha applicato la modifica
+	Position    int    //posizione della modifica tra quelle applicate dallo stesso messaggio (Batch e Txn)
+}
+
+// After indica se l'evento segue il punto di ripresa (clock, message, position) nell'ordine dei messaggi del
+// multicast totalmente ordinato: per clock, a parità di clock per UUID del messaggio, e tra le modifiche dello
+// stesso messaggio per posizione. Più messaggi possono avere lo stesso clock e un messaggio può applicare più
+// modifiche, quindi il solo clock non basta a indicare da dove riprendere: con message vuoto il punto di ripresa
+// precede tutte le modifiche con clock uguale a clock.
+func (e WatchEvent) After(clock int, message string, position int) bool {
+	if c := cmp.Compare(e.ClockValue, clock); c != 0 {
+		return c > 0
+	}
+	if message == "" {
+		return true
+	}
+	if c := cmp.Compare(e.Message, message); c != 0 {
+		return c > 0
+	}
+	return e.Position > position
+}
+
+// ValidateWatch controlla che sia indicato al più un punto di ripresa
+func ValidateWatch(args WatchArgs) error {
+	resumes := 0
+	if args.AfterRevision != 0 {
+		resumes++
+	}
+	if args.AfterClock != 0 || args.AfterMessage != "" {
+		resumes++
+	}
+	if args.AfterVector != nil {
+		resumes++
+	}
+	if resumes > 1 {
+		return fmt.Errorf("%w: at most one of after_revision, after_clock and after_vector can be set", ErrInvalidRequest)
+	}
+	if args.AfterRevision < 0 || args.AfterClock < 0 || args.AfterPosition < 0 {
+		return fmt.Errorf("%w: resume points must not be negative", ErrInvalidRequest)
+	}
+	if args.AfterPosition != 0 && args.AfterMessage == "" {
+		return fmt.Errorf("%w: after_position requires after_message", ErrInvalidRequest)
+	}
+	if args.AfterVector != nil && len(args.AfterVector) != NumberOfReplicas {
+		return fmt.Errorf("%w: after_vector must have %d components", ErrInvalidRequest, NumberOfReplicas)
+	}
+	return nil
+}
+
+// Matches indica se l'evento riguarda le chiavi osservate dal Watch
+func (args WatchArgs) Matches(key string) bool {
+	if args.Prefix {
+		return strings.HasPrefix(key, args.Key)
+	}
+	return key == args.Key
+}
+
+// VectorLessEqual indica se ogni componente di a è minore o uguale alla corrispondente di b
+func VectorLessEqual(a []int, b []int) bool {
+	for i := range a {
+		if i >= len(b) || a[i] > b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -1,13 +1,10 @@
 #!/bin/bash
 
 
-export REPLICAS=3
-export LOCAL=1
-export DOCKER=0
-#export CONSIST_TYPE=Sequential
-export CONSIST_TYPE=Causal
-export RANDOM_REPLICA=0
-export DELETE_CAUSAL=1
+# La configurazione di server e client si trova in config.yaml.
+# SERVER_IDS deve corrispondere agli ID dei peer elencati nel file.
+CONFIG=config.yaml
+SERVER_IDS=(server0 server1 server2)
 
 if [ -d "bin" ]; then
     cd bin || exit
@@ -37,10 +34,10 @@ open_terminal() {
 }
 
 # Lanciare le istanze del server
-for ((i=0; i<REPLICAS; i++)); do
-    open_terminal "./bin/server $i"
-    echo "Server $i started"
+for id in "${SERVER_IDS[@]}"; do
+    open_terminal "./bin/server -config $CONFIG $id"
+    echo "Server $id started"
 done
 
-    open_terminal "./bin/client"
+    open_terminal "./bin/client -config $CONFIG"
     echo "Client started"